@@ -0,0 +1,115 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/sha256"
+	"fmt"
+	"regexp"
+
+	"github.com/bnb-chain/tss-lib/tss"
+	"github.com/btcsuite/btcutil/base58"
+	"github.com/btcsuite/btcutil/bech32"
+	ethcommon "github.com/ethereum/go-ethereum/common"
+	"golang.org/x/crypto/ripemd160"
+)
+
+// Address types a wallet can be created with, ethereum is the default. Every
+// wallet has an Ethereum address, which identifies it in the API.
+const (
+	addressEthereum  = "ethereum"
+	addressBTCP2WPKH = "btc-p2wpkh"
+	addressBTCP2PKH  = "btc-p2pkh"
+	addressSolana    = "solana"
+)
+
+// walletAddressPattern matches the Ethereum address identifying a wallet
+var walletAddressPattern = regexp.MustCompile(`^0x[0-9a-fA-F]{40}$`)
+
+// validateWalletAddress checks that address is 0x followed by 40 hex digits
+func validateWalletAddress(address string) error {
+	if !walletAddressPattern.MatchString(address) {
+		return fmt.Errorf("invalid wallet address %q, expected 0x followed by 40 hex digits", address)
+	}
+	return nil
+}
+
+// checksumAddress returns the EIP-55 checksummed form of a well formed wallet
+// address, the form wallets are registered under. Hex digits are case
+// insensitive, so clients may send addresses in any case.
+func checksumAddress(address string) string {
+	return ethcommon.HexToAddress(address).Hex()
+}
+
+// Bitcoin mainnet address parameters
+const (
+	btcBech32HRP     = "bc"
+	btcP2PKHVersion  = 0x00
+	btcWitnessV0Byte = 0x00
+)
+
+// validateAddressType checks that the address type is supported on the curve,
+// Bitcoin addresses require secp256k1 and Solana ones ed25519
+func validateAddressType(addressType string, curveName tss.CurveName) error {
+	switch addressType {
+	case "", addressEthereum:
+		return nil
+	case addressBTCP2WPKH, addressBTCP2PKH:
+		if curveName != curveSecp256k1 {
+			return fmt.Errorf("%s addresses require a %s wallet", addressType, curveSecp256k1)
+		}
+		return nil
+	case addressSolana:
+		if curveName != curveEd25519 {
+			return fmt.Errorf("%s addresses require a %s wallet", addressType, curveEd25519)
+		}
+		return nil
+	default:
+		return fmt.Errorf("unsupported address type %q", addressType)
+	}
+}
+
+// deriveAddresses returns the addresses of a wallet by type, the Ethereum
+// address along with the one of the requested type
+func deriveAddresses(pubKey *ecdsa.PublicKey, addressType string) (map[string]string, error) {
+	addresses := map[string]string{addressEthereum: deriveAddress(pubKey)}
+	switch addressType {
+	case "", addressEthereum:
+	case addressBTCP2WPKH:
+		address, err := btcP2WPKHAddress(pubKey)
+		if err != nil {
+			return nil, err
+		}
+		addresses[addressBTCP2WPKH] = address
+	case addressBTCP2PKH:
+		addresses[addressBTCP2PKH] = btcP2PKHAddress(pubKey)
+	case addressSolana:
+		addresses[addressSolana] = base58.Encode(ed25519PubKeyBytes(pubKey))
+	default:
+		return nil, fmt.Errorf("unsupported address type %q", addressType)
+	}
+	return addresses, nil
+}
+
+// hash160 is RIPEMD-160(SHA-256(compressed public key)), the hash Bitcoin
+// addresses commit to
+func hash160(pubKey *ecdsa.PublicKey) []byte {
+	sha := sha256.Sum256(compressedPubKeyBytes(pubKey))
+	hasher := ripemd160.New()
+	hasher.Write(sha[:])
+	return hasher.Sum(nil)
+}
+
+// btcP2PKHAddress returns the legacy base58check address, starting with 1
+func btcP2PKHAddress(pubKey *ecdsa.PublicKey) string {
+	return base58.CheckEncode(hash160(pubKey), btcP2PKHVersion)
+}
+
+// btcP2WPKHAddress returns the native segwit v0 bech32 address, starting with
+// bc1q (BIP 173)
+func btcP2WPKHAddress(pubKey *ecdsa.PublicKey) (string, error) {
+	program, err := bech32.ConvertBits(hash160(pubKey), 8, 5, true)
+	if err != nil {
+		return "", err
+	}
+	return bech32.Encode(btcBech32HRP, append([]byte{btcWitnessV0Byte}, program...))
+}
@@ -0,0 +1,194 @@
+package main
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/bnb-chain/tss-lib/tss"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBitcoinAddresses(t *testing.T) {
+	// Public key of the private key 1, the generator point. Addresses from
+	// BIP 173 and the well known P2PKH address of that key.
+	curve := tss.S256()
+	generator := &ecdsa.PublicKey{Curve: curve, X: curve.Params().Gx, Y: curve.Params().Gy}
+
+	p2wpkh, err := btcP2WPKHAddress(generator)
+	assert.NoError(t, err)
+	assert.Equal(t, "bc1qw508d6qejxtdg4y5r3zarvary0c5xw7kv8f3t4", p2wpkh)
+	assert.Equal(t, "1BgGZ9tcN4rm9KBzDn7KprQz87SZ26SAMH", btcP2PKHAddress(generator))
+
+	// Private key 2, whose public key has an odd Y
+	key, err := crypto.ToECDSA(privateKeyBytes(2))
+	assert.NoError(t, err)
+	assert.Equal(t, "1cMh228HTCiwS8ZsaakH8A8wze1JR5ZsP", btcP2PKHAddress(&key.PublicKey))
+}
+
+func TestDeriveAddresses(t *testing.T) {
+	key, err := crypto.GenerateKey()
+	assert.NoError(t, err)
+
+	addresses, err := deriveAddresses(&key.PublicKey, addressEthereum)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{addressEthereum: crypto.PubkeyToAddress(key.PublicKey).Hex()}, addresses)
+
+	addresses, err = deriveAddresses(&key.PublicKey, addressBTCP2WPKH)
+	assert.NoError(t, err)
+	assert.Len(t, addresses, 2, "The Ethereum address should always be derived")
+	assert.Regexp(t, "^bc1q", addresses[addressBTCP2WPKH])
+
+	_, err = deriveAddresses(&key.PublicKey, "btc-p2tr")
+	assert.Error(t, err)
+}
+
+func TestCreateWalletInvalidAddressType(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	router := gin.Default()
+	router.POST("/wallet", createWallet)
+
+	for _, requestBody := range []createWalletRequest{
+		{Parties: 2, Threshold: 1, AddressType: "btc-p2tr"},
+		{Parties: 2, Threshold: 1, Curve: string(curveP256), AddressType: addressBTCP2WPKH},
+	} {
+		jsonBody, _ := json.Marshal(requestBody)
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/wallet", bytes.NewBuffer(jsonBody))
+		req.Header.Set("Content-Type", "application/json")
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	}
+}
+
+func TestCreateWalletBitcoinAddress(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	useDeterministicKeygen(t, "bitcoin address")
+
+	router := gin.Default()
+	router.POST("/wallet", createWallet)
+	router.GET("/wallet/:address", getWallet)
+
+	jsonBody, _ := json.Marshal(createWalletRequest{Parties: 2, Threshold: 1, AddressType: addressBTCP2WPKH})
+	w1 := httptest.NewRecorder()
+	req1, _ := http.NewRequest("POST", "/wallet", bytes.NewBuffer(jsonBody))
+	req1.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w1, req1)
+	assert.Equal(t, http.StatusOK, w1.Code)
+
+	var createResponse stringFields
+	err := decodeData(w1.Body.Bytes(), &createResponse)
+	if err != nil {
+		t.Fatalf("Failed to parse create wallet response: %v", err)
+	}
+	walletAddress := createResponse["address"]
+
+	w2 := httptest.NewRecorder()
+	req2, _ := http.NewRequest("GET", "/wallet/"+walletAddress, nil)
+	router.ServeHTTP(w2, req2)
+	var getResponse walletResponse
+	err = decodeData(w2.Body.Bytes(), &getResponse)
+	if err != nil {
+		t.Fatalf("Failed to parse get wallet response: %v", err)
+	}
+
+	walletsMutex.Lock()
+	wallet := wallets[walletAddress]
+	walletsMutex.Unlock()
+	if !assert.NotNil(t, wallet) {
+		return
+	}
+	p2wpkh, err := btcP2WPKHAddress(wallet.PubKey)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{
+		addressEthereum:  walletAddress,
+		addressBTCP2WPKH: p2wpkh,
+	}, getResponse.Addresses)
+}
+
+// privateKeyBytes returns n as a 32 byte big endian private key
+func privateKeyBytes(n int64) []byte {
+	return big.NewInt(n).FillBytes(make([]byte, 32))
+}
+
+func TestMalformedWalletAddress(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	previousLimit := keygenRateLimit
+	keygenRateLimit = 0
+	t.Cleanup(func() { keygenRateLimit = previousLimit })
+	router := newRouter(nil)
+
+	malformed := "0xNotAnAddress"
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		body   string
+	}{
+		{"get", "GET", "/wallet/" + malformed, ""},
+		{"delete", "DELETE", "/wallet/" + malformed, ""},
+		{"export", "GET", "/wallet/" + malformed + "/export?confirm=true", ""},
+		{"reshare", "POST", "/wallet/" + malformed + "/reshare", `{"parties": 3, "threshold": 1}`},
+		{"refresh", "POST", "/wallet/" + malformed + "/refresh", ""},
+		{"sign", "POST", "/sign", `{"wallet": "` + malformed + `", "data": "0x01"}`},
+		{"sign batch", "POST", "/sign/batch", `{"wallet": "` + malformed + `", "items": [{"data": "0x01"}]}`},
+		{"verify", "POST", "/verify", `{"wallet": "` + malformed + `", "data": "0x01", "signature": "0x01"}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set(exportPassphraseHeader, "correct horse battery staple")
+			router.ServeHTTP(w, req)
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+		})
+	}
+}
+
+func TestWalletAddressCase(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	key, _ := crypto.GenerateKey()
+	useInstantSigning(t, key)
+	address := deriveAddress(&key.PublicKey)
+	addFakeWallet(address).PubKey = &key.PublicKey
+	t.Cleanup(func() {
+		walletsMutex.Lock()
+		delete(wallets, address)
+		walletsMutex.Unlock()
+	})
+	lower := strings.ToLower(address)
+	upper := "0x" + strings.ToUpper(address[2:])
+	assert.NotEqual(t, lower, address, "Address should be checksummed")
+
+	router := gin.Default()
+	router.POST("/sign", signData)
+	router.GET("/wallet/:address", getWallet)
+	for _, variant := range []string{address, lower, upper} {
+		jsonBody, _ := json.Marshal(signDataRequest{Data: "0x74657374", Wallet: variant})
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/sign", bytes.NewBuffer(jsonBody))
+		req.Header.Set("Content-Type", "application/json")
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code, "Signing with %s should succeed", variant)
+
+		w = httptest.NewRecorder()
+		req, _ = http.NewRequest("GET", "/wallet/"+variant, nil)
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code, "Getting %s should succeed", variant)
+		var response walletResponse
+		if assert.NoError(t, decodeData(w.Body.Bytes(), &response)) {
+			assert.Equal(t, address, response.Address, "The checksummed address should be returned")
+		}
+	}
+}
@@ -0,0 +1,226 @@
+package main
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+// apiKeysEnv is the environment variable holding a comma separated list of
+// API keys allowed to call the service
+const apiKeysEnv = "API_KEYS"
+
+// minAPIKeyLength is the length required of the API keys added at runtime
+const minAPIKeyLength = 16
+
+// apiKeySet holds the SHA-256 of every accepted API key. Keys are compared by
+// hash so the comparison does not leak their length. Keys can be added and
+// revoked while requests are authenticated.
+type apiKeySet struct {
+	mu     sync.RWMutex
+	hashes [][sha256.Size]byte
+}
+
+// newAPIKeySet creates a key set, empty keys are ignored
+func newAPIKeySet(keys []string) *apiKeySet {
+	set := &apiKeySet{}
+	for _, key := range keys {
+		key = strings.TrimSpace(key)
+		if key == "" {
+			continue
+		}
+		set.hashes = append(set.hashes, sha256.Sum256([]byte(key)))
+	}
+	return set
+}
+
+// contains reports whether key is in the set in constant time, every key is
+// compared even once a match has been found
+func (s *apiKeySet) contains(key string) bool {
+	hash := sha256.Sum256([]byte(key))
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	found := 0
+	for _, candidate := range s.hashes {
+		found |= subtle.ConstantTimeCompare(hash[:], candidate[:])
+	}
+	return found == 1
+}
+
+// len returns the number of keys in the set
+func (s *apiKeySet) len() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.hashes)
+}
+
+// add adds a key to the set, it reports false when the key was already there
+func (s *apiKeySet) add(key string) bool {
+	hash := sha256.Sum256([]byte(key))
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, candidate := range s.hashes {
+		if candidate == hash {
+			return false
+		}
+	}
+	s.hashes = append(s.hashes, hash)
+	return true
+}
+
+// revoke removes the keys with the given ID from the set, it reports false
+// when there was none. The next request made with a revoked key is rejected.
+func (s *apiKeySet) revoke(id string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	kept := s.hashes[:0]
+	for _, hash := range s.hashes {
+		if hashKeyID(hash) != id {
+			kept = append(kept, hash)
+		}
+	}
+	revoked := len(kept) < len(s.hashes)
+	clear(s.hashes[len(kept):])
+	s.hashes = kept
+	return revoked
+}
+
+// ids returns the IDs of the keys in the set
+func (s *apiKeySet) ids() []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	ids := make([]string, len(s.hashes))
+	for i, hash := range s.hashes {
+		ids[i] = hashKeyID(hash)
+	}
+	return ids
+}
+
+// loadAPIKeys returns the API keys set in the API_KEYS environment variable
+// and, when path is not empty, the ones listed in that file, one per line.
+// Empty lines and lines starting with # are skipped.
+func loadAPIKeys(path string) ([]string, error) {
+	var keys []string
+	if env := os.Getenv(apiKeysEnv); env != "" {
+		keys = append(keys, strings.Split(env, ",")...)
+	}
+	if path == "" {
+		return keys, nil
+	}
+	fileKeys, err := readKeysFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return append(keys, fileKeys...), nil
+}
+
+// readKeysFile returns the keys listed in a file, one per line. Empty lines
+// and lines starting with # are skipped.
+func readKeysFile(path string) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open keys file: %w", err)
+	}
+	defer file.Close()
+
+	var keys []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		keys = append(keys, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read keys file: %w", err)
+	}
+	return keys, nil
+}
+
+// apiKeyAuth rejects requests without an "Authorization: Bearer <key>" header
+// holding one of the accepted keys
+func apiKeyAuth(keys *apiKeySet) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key, found := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+		if !found || !keys.contains(key) {
+			c.Header("WWW-Authenticate", "Bearer")
+			abortWithError(c, http.StatusUnauthorized, "invalid or missing API key")
+			return
+		}
+		c.Set(apiKeyIDContextKey, apiKeyID(key))
+		c.Next()
+	}
+}
+
+// apiKeyID identifies an API key by a prefix of its hash so the key itself is
+// never kept around
+func apiKeyID(key string) string {
+	return hashKeyID(sha256.Sum256([]byte(key)))
+}
+
+// hashKeyID returns the ID of the API key with the given hash
+func hashKeyID(hash [sha256.Size]byte) string {
+	return "key:" + hex.EncodeToString(hash[:8])
+}
+
+// addAPIKeyRequest represents the request body of addAPIKey
+type addAPIKeyRequest struct {
+	Key string `json:"key" binding:"required"`
+}
+
+// listAPIKeys returns the IDs of the accepted API keys
+func listAPIKeys(keys *apiKeySet) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		respond(c, http.StatusOK, gin.H{"keys": keys.ids()})
+	}
+}
+
+// addAPIKey accepts a new API key from the next request on. Keys added this
+// way are not written to the keys file and are forgotten on restart.
+func addAPIKey(keys *apiKeySet) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var requestBody addAPIKeyRequest
+		if err := c.ShouldBindJSON(&requestBody); err != nil {
+			respondError(c, http.StatusBadRequest, "invalid request body")
+			return
+		}
+		key := strings.TrimSpace(requestBody.Key)
+		if len(key) < minAPIKeyLength {
+			respondError(c, http.StatusBadRequest, fmt.Sprintf("API keys must be at least %d characters long", minAPIKeyLength))
+			return
+		}
+
+		id := apiKeyID(key)
+		status := http.StatusOK
+		if keys.add(key) {
+			status = http.StatusCreated
+			log.Printf("admin %s added API key %s", c.GetString(apiKeyIDContextKey), id)
+		}
+		respond(c, status, gin.H{"id": id})
+	}
+}
+
+// revokeAPIKey rejects an API key, identified by its ID, from the next request
+// on. A key listed in the keys file is accepted again after a restart unless it
+// is removed from the file as well.
+func revokeAPIKey(keys *apiKeySet) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.Param("id")
+		if !keys.revoke(id) {
+			respondError(c, http.StatusNotFound, "API key not found")
+			return
+		}
+		log.Printf("admin %s revoked API key %s", c.GetString(apiKeyIDContextKey), id)
+		respond(c, http.StatusOK, gin.H{"id": id, "revoked": true})
+	}
+}
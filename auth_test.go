@@ -0,0 +1,175 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAPIKeyAuth(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	router := newRouter(newAPIKeySet([]string{"first-key", "second-key"}))
+
+	// Requests that fail validation once authenticated, so no keygen or
+	// signing ceremony is needed to tell them apart from rejected ones
+	endpoints := []struct {
+		method string
+		path   string
+		body   string
+		status int
+	}{
+		{"POST", "/wallet", `{"parties": 1, "threshold": 1}`, http.StatusBadRequest},
+		{"GET", "/wallets", "", http.StatusOK},
+		{"POST", "/sign", `{}`, http.StatusBadRequest},
+	}
+	headers := []struct {
+		name          string
+		authorization string
+		authorized    bool
+	}{
+		{"missing key", "", false},
+		{"wrong key", "Bearer wrong-key", false},
+		{"key without scheme", "first-key", false},
+		{"valid key", "Bearer first-key", true},
+		{"other valid key", "Bearer second-key", true},
+	}
+
+	for _, endpoint := range endpoints {
+		for _, header := range headers {
+			t.Run(endpoint.method+" "+endpoint.path+" "+header.name, func(t *testing.T) {
+				w := httptest.NewRecorder()
+				req, _ := http.NewRequest(endpoint.method, endpoint.path, bytes.NewBufferString(endpoint.body))
+				req.Header.Set("Content-Type", "application/json")
+				if header.authorization != "" {
+					req.Header.Set("Authorization", header.authorization)
+				}
+				router.ServeHTTP(w, req)
+
+				if header.authorized {
+					assert.Equal(t, endpoint.status, w.Code)
+				} else {
+					assert.Equal(t, http.StatusUnauthorized, w.Code)
+					assert.Equal(t, "Bearer", w.Header().Get("WWW-Authenticate"))
+					assert.Contains(t, w.Body.String(), "invalid or missing API key")
+				}
+			})
+		}
+	}
+}
+
+func TestAPIKeySet(t *testing.T) {
+	keys := newAPIKeySet([]string{"key", " padded ", ""})
+	assert.Len(t, keys.hashes, 2)
+	assert.True(t, keys.contains("key"))
+	assert.True(t, keys.contains("padded"))
+	assert.False(t, keys.contains(""))
+	assert.False(t, keys.contains("ke"))
+	assert.False(t, keys.contains("key2"))
+}
+
+func TestLoadAPIKeys(t *testing.T) {
+	t.Setenv(apiKeysEnv, "env-key-1,env-key-2")
+
+	path := filepath.Join(t.TempDir(), "api-keys")
+	err := os.WriteFile(path, []byte("# comment\nfile-key-1\n\n  file-key-2  \n"), 0o600)
+	assert.NoError(t, err)
+
+	keys, err := loadAPIKeys(path)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"env-key-1", "env-key-2", "file-key-1", "file-key-2"}, keys)
+
+	_, err = loadAPIKeys(filepath.Join(t.TempDir(), "missing"))
+	assert.Error(t, err)
+}
+
+func TestRotateAPIKeys(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	previous := adminAPIKeys
+	adminAPIKeys = newAPIKeySet([]string{"admin"})
+	t.Cleanup(func() { adminAPIKeys = previous })
+
+	router := newRouter(newAPIKeySet([]string{"first-key"}))
+	serve := func(method, path, key, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(method, path, bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+key)
+		router.ServeHTTP(w, req)
+		return w
+	}
+	const newKey = "rotated-key-0123456789"
+
+	// Only admin keys manage API keys
+	assert.Equal(t, http.StatusUnauthorized, serve("POST", "/admin/api-keys", "first-key", `{"key": "`+newKey+`"}`).Code)
+	assert.Equal(t, http.StatusBadRequest, serve("POST", "/admin/api-keys", "admin", `{"key": "short"}`).Code)
+	assert.Equal(t, http.StatusBadRequest, serve("POST", "/admin/api-keys", "admin", `{}`).Code)
+
+	assert.Equal(t, http.StatusUnauthorized, serve("GET", "/wallets", newKey, "").Code)
+	w := serve("POST", "/admin/api-keys", "admin", `{"key": "`+newKey+`"}`)
+	assert.Equal(t, http.StatusCreated, w.Code)
+	var added struct {
+		ID string `json:"id"`
+	}
+	if err := decodeData(w.Body.Bytes(), &added); err != nil {
+		t.Fatalf("Failed to parse add API key response: %v", err)
+	}
+	assert.Equal(t, apiKeyID(newKey), added.ID)
+	assert.Equal(t, http.StatusOK, serve("GET", "/wallets", newKey, "").Code, "An added key should be accepted right away")
+	assert.Equal(t, http.StatusOK, serve("POST", "/admin/api-keys", "admin", `{"key": "`+newKey+`"}`).Code, "Adding a key twice should be a no-op")
+
+	w = serve("GET", "/admin/api-keys", "admin", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	var listed struct {
+		Keys []string `json:"keys"`
+	}
+	if err := decodeData(w.Body.Bytes(), &listed); err != nil {
+		t.Fatalf("Failed to parse list API keys response: %v", err)
+	}
+	assert.Equal(t, []string{apiKeyID("first-key"), apiKeyID(newKey)}, listed.Keys)
+
+	// A revoked key is rejected from the next request on
+	assert.Equal(t, http.StatusOK, serve("DELETE", "/admin/api-keys/"+apiKeyID("first-key"), "admin", "").Code)
+	w = serve("GET", "/wallets", "first-key", "")
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Equal(t, "Bearer", w.Header().Get("WWW-Authenticate"))
+	assert.Equal(t, http.StatusOK, serve("GET", "/wallets", newKey, "").Code, "Other keys should still be accepted")
+	assert.Equal(t, http.StatusNotFound, serve("DELETE", "/admin/api-keys/"+apiKeyID("first-key"), "admin", "").Code)
+
+	// Without authentication there are no API keys to manage
+	router = newRouter(nil)
+	assert.Equal(t, http.StatusNotFound, serve("GET", "/admin/api-keys", "admin", "").Code)
+}
+
+func TestAPIKeySetConcurrentRotation(t *testing.T) {
+	keys := newAPIKeySet([]string{"key"})
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				keys.add(fmt.Sprintf("key-%d-%d", i, j))
+				keys.contains("key")
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				keys.revoke(apiKeyID(fmt.Sprintf("key-%d-%d", i, j)))
+				keys.ids()
+			}
+		}()
+	}
+	wg.Wait()
+	assert.True(t, keys.contains("key"))
+}
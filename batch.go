@@ -0,0 +1,132 @@
+package main
+
+import (
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/bnb-chain/tss-lib/common"
+	"github.com/gin-gonic/gin"
+)
+
+// Limits of the batch signing endpoint: the number of items per request and
+// how many signing ceremonies of a batch run at the same time
+const (
+	maxBatchSize     = 100
+	batchConcurrency = 4
+)
+
+// signBatchItem is one message of a batch
+type signBatchItem struct {
+	Data string `json:"data"`
+	// Hash applied to data before signing: none, keccak256 (default), sha256,
+	// sha3-256 or blake2b-256
+	Hash string `json:"hash"`
+}
+
+// signBatchRequest represents the request body for signBatch endpoint
+type signBatchRequest struct {
+	Wallet string          `json:"wallet"`
+	Items  []signBatchItem `json:"items"`
+	// RawRecoveryID returns v as 0/1 instead of the Ethereum 27/28
+	RawRecoveryID bool `json:"rawRecoveryId"`
+	// Encoding of the signature fields: raw (default), der or eth65
+	Encoding string `json:"encoding"`
+}
+
+// signBatch signs several messages with the same wallet and returns the
+// signatures in the order of the items
+func signBatch(c *gin.Context) {
+	var requestBody signBatchRequest
+
+	if err := c.BindJSON(&requestBody); err != nil {
+		respondError(c, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	if requestBody.Wallet == "" || len(requestBody.Items) == 0 {
+		respondError(c, http.StatusBadRequest, "wallet and items are required")
+		return
+	}
+	if len(requestBody.Items) > maxBatchSize {
+		respondError(c, http.StatusBadRequest, fmt.Sprintf("a batch holds at most %d items", maxBatchSize))
+		return
+	}
+	if err := validateEncoding(requestBody.Encoding); err != nil {
+		respondError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	items := make([][]byte, len(requestBody.Items))
+	for i, item := range requestBody.Items {
+		data, err := decodeHexData(item.Data)
+		if err != nil || len(data) == 0 {
+			respondError(c, http.StatusBadRequest, fmt.Sprintf("item %d: invalid data", i))
+			return
+		}
+		items[i] = data
+	}
+
+	wallet, err := lookupWallet(requestBody.Wallet)
+	if err != nil {
+		respondServiceError(c, err)
+		return
+	}
+	if err := validateWalletEncoding(wallet, requestBody.Encoding); err != nil {
+		respondServiceError(c, err)
+		return
+	}
+	digests := make([][]byte, len(items))
+	for i, data := range items {
+		digests[i], err = walletMessage(wallet, data, "", requestBody.Items[i].Hash)
+		if err != nil {
+			respondError(c, http.StatusBadRequest, fmt.Sprintf("item %d: %s", i, err))
+			return
+		}
+		if err := wallet.checkPolicy(data, "", requestBody.Items[i].Hash); err != nil {
+			respondError(c, serviceErrorStatus(err), fmt.Sprintf("item %d: %s", i, err))
+			return
+		}
+	}
+
+	if !chargeRateLimit(c, len(digests)) {
+		return
+	}
+
+	// Each item runs its own signing ceremony, a few of them at a time
+	signatures := make([]*common.SignatureData, len(digests))
+	errs := make([]error, len(digests))
+	semaphore := make(chan struct{}, batchConcurrency)
+	var wg sync.WaitGroup
+	for i, digest := range digests {
+		wg.Add(1)
+		semaphore <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-semaphore }()
+			signatures[i], errs[i] = signDigest(wallet, nil, digest, nil)
+		}()
+	}
+	wg.Wait()
+
+	responses := make([]gin.H, len(digests))
+	for i, digest := range digests {
+		if errs[i] != nil {
+			respondError(c, ceremonyErrorStatus(errs[i]), fmt.Sprintf("item %d: %s", i, errs[i]))
+			return
+		}
+		response, err := signatureResponse(signatures[i], digest, wallet.PubKey.Curve, requestBody.Encoding, requestBody.RawRecoveryID)
+		if err != nil {
+			respondError(c, http.StatusInternalServerError, err.Error())
+			return
+		}
+		responses[i] = response
+	}
+	respond(c, http.StatusOK, gin.H{"signatures": responses})
+}
+
+// decodeHexData decodes hex encoded data, with or without the 0x prefix
+func decodeHexData(dataHex string) ([]byte, error) {
+	return hex.DecodeString(strings.TrimPrefix(dataHex, "0x"))
+}
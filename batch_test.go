@@ -0,0 +1,132 @@
+package main
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSignBatch(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	useDeterministicKeygen(t, "batch signing")
+
+	router := gin.Default()
+	router.POST("/wallet", createWallet)
+	router.POST("/sign/batch", signBatch)
+
+	jsonBody, _ := json.Marshal(createWalletRequest{Parties: 2, Threshold: 1})
+	w1 := httptest.NewRecorder()
+	req1, _ := http.NewRequest("POST", "/wallet", bytes.NewBuffer(jsonBody))
+	req1.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w1, req1)
+	assert.Equal(t, http.StatusOK, w1.Code)
+
+	var createResponse stringFields
+	err := decodeData(w1.Body.Bytes(), &createResponse)
+	if err != nil {
+		t.Fatalf("Failed to parse create wallet response: %v", err)
+	}
+	walletAddress := createResponse["address"]
+
+	first := []byte("first")
+	second := []byte("second")
+	third := crypto.Keccak256([]byte("third"))
+	sha := sha256.Sum256(second)
+	expectedDigests := [][]byte{crypto.Keccak256(first), sha[:], third}
+
+	requestBody := signBatchRequest{
+		Wallet: walletAddress,
+		Items: []signBatchItem{
+			{Data: "0x" + hex.EncodeToString(first)},
+			{Data: hex.EncodeToString(second), Hash: hashSHA256},
+			{Data: "0x" + hex.EncodeToString(third), Hash: hashNone},
+		},
+	}
+	jsonBody, _ = json.Marshal(requestBody)
+
+	w2 := httptest.NewRecorder()
+	req2, _ := http.NewRequest("POST", "/sign/batch", bytes.NewBuffer(jsonBody))
+	req2.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w2, req2)
+	assert.Equal(t, http.StatusOK, w2.Code)
+
+	var batchResponse struct {
+		Signatures []map[string]string `json:"signatures"`
+	}
+	err = decodeData(w2.Body.Bytes(), &batchResponse)
+	if err != nil {
+		t.Fatalf("Failed to parse batch response: %v", err)
+	}
+	if !assert.Len(t, batchResponse.Signatures, 3) {
+		return
+	}
+
+	walletsMutex.Lock()
+	pubKey := wallets[walletAddress].PubKey
+	walletsMutex.Unlock()
+	for i, response := range batchResponse.Signatures {
+		assert.Equal(t, hex.EncodeToString(expectedDigests[i]), response["digest"], "Signatures should be in the order of the items")
+
+		signature, err := hex.DecodeString(response["signature"])
+		assert.NoError(t, err)
+		r := new(big.Int).SetBytes(signature[:32])
+		s := new(big.Int).SetBytes(signature[32:])
+		assert.True(t, ecdsa.Verify(pubKey, expectedDigests[i], r, s), "Signature %d should verify", i)
+	}
+}
+
+func TestSignBatchInvalidInput(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	router := gin.Default()
+	router.POST("/sign/batch", signBatch)
+
+	address := "0x00000000000000000000000000000000000000dc"
+	addFakeWallet(address)
+	eddsaAddress := "0x0000000000000000000000000000000000000099"
+	addFakeWallet(eddsaAddress).Algorithm = algorithmEdDSA
+	t.Cleanup(func() {
+		walletsMutex.Lock()
+		delete(wallets, address)
+		delete(wallets, eddsaAddress)
+		walletsMutex.Unlock()
+	})
+
+	tooMany := `{"wallet": "` + address + `", "items": [` + strings.Repeat(`{"data": "0x01"},`, maxBatchSize) + `{"data": "0x01"}]}`
+	tests := []struct {
+		name   string
+		body   string
+		status int
+	}{
+		{"invalid body", `{`, http.StatusBadRequest},
+		{"missing wallet", `{"items": [{"data": "0x01"}]}`, http.StatusBadRequest},
+		{"no items", `{"wallet": "` + address + `", "items": []}`, http.StatusBadRequest},
+		{"too many items", tooMany, http.StatusBadRequest},
+		{"invalid data", `{"wallet": "` + address + `", "items": [{"data": "0x01"}, {"data": "0xzz"}]}`, http.StatusBadRequest},
+		{"empty data", `{"wallet": "` + address + `", "items": [{"data": ""}]}`, http.StatusBadRequest},
+		{"invalid hash", `{"wallet": "` + address + `", "items": [{"data": "0x01", "hash": "md5"}]}`, http.StatusBadRequest},
+		{"unknown wallet", `{"wallet": "0x00000000000000000000000000000000000000dd", "items": [{"data": "0x01"}]}`, http.StatusNotFound},
+		// Rejected before any ceremony runs on the shares the fake wallet lacks
+		{"der encoding for eddsa", `{"wallet": "` + eddsaAddress + `", "items": [{"data": "0x01"}], "encoding": "der"}`, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("POST", "/sign/batch", bytes.NewBufferString(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			router.ServeHTTP(w, req)
+			assert.Equal(t, tt.status, w.Code)
+		})
+	}
+}
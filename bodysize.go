@@ -0,0 +1,53 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxBodySize bounds the body of POST requests, in bytes, so a huge body
+// cannot exhaust the memory of the service. 0 disables the limit.
+var maxBodySize int64 = 1 << 20
+
+// maxBodySizeEnv names the environment variable holding the body size limit,
+// the --max-body-size flag takes precedence over it
+const maxBodySizeEnv = "MAX_BODY_SIZE"
+
+// streamedBodyPaths are routes reading their body as a stream with their own
+// limit, such as the raw sign route hashing files of up to maxRawSignSize
+var streamedBodyPaths = []string{"/sign/raw"}
+
+// limitBodySize rejects POST requests whose body is larger than limit with a
+// 413. The body is read up front, so handlers binding it never see a
+// truncated body and fail with a misleading 400.
+func limitBodySize(limit int64) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if limit <= 0 || c.Request.Method != http.MethodPost || slices.Contains(streamedBodyPaths, c.FullPath()) {
+			c.Next()
+			return
+		}
+		tooLarge := fmt.Sprintf("request body must be at most %d bytes", limit)
+		if c.Request.ContentLength > limit {
+			abortWithError(c, http.StatusRequestEntityTooLarge, tooLarge)
+			return
+		}
+		body, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, limit))
+		if err != nil {
+			var maxBytesErr *http.MaxBytesError
+			if errors.As(err, &maxBytesErr) {
+				abortWithError(c, http.StatusRequestEntityTooLarge, tooLarge)
+				return
+			}
+			abortWithError(c, http.StatusBadRequest, "failed to read request body")
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+		c.Next()
+	}
+}
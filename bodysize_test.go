@@ -0,0 +1,74 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMaxBodySize(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	key, _ := crypto.GenerateKey()
+	useInstantSigning(t, key)
+	address := deriveAddress(&key.PublicKey)
+	wallet := addFakeWallet(address)
+	wallet.PubKey = &key.PublicKey
+	previousSize := maxBodySize
+	maxBodySize = 1024
+	t.Cleanup(func() {
+		maxBodySize = previousSize
+		walletsMutex.Lock()
+		delete(wallets, address)
+		walletsMutex.Unlock()
+	})
+	router := newRouter(nil)
+
+	post := func(path, contentType string, body io.Reader) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", path, body)
+		req.Header.Set("Content-Type", contentType)
+		router.ServeHTTP(w, req)
+		return w
+	}
+	assertTooLarge := func(w *httptest.ResponseRecorder) {
+		t.Helper()
+		assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+		apiErr, err := decodeError(w.Body.Bytes())
+		assert.NoError(t, err)
+		assert.Equal(t, codeTooLarge, apiErr.Code)
+		assert.Contains(t, apiErr.Message, "at most 1024 bytes")
+	}
+
+	oversized := `{"wallet": "` + address + `", "data": "0x` + strings.Repeat("ab", 1024) + `"}`
+	assertTooLarge(post("/sign", "application/json", strings.NewReader(oversized)))
+	assertTooLarge(post("/wallet/import", "application/json", strings.NewReader(oversized)))
+
+	// Without a Content-Length the body is cut off while it is read
+	assertTooLarge(post("/sign", "application/json", io.MultiReader(strings.NewReader(oversized))))
+
+	// Bodies within the limit reach the handler whole
+	w := post("/sign", "application/json", strings.NewReader(`{"wallet": "`+address+`", "data": "0x`+strings.Repeat("ab", 32)+`"}`))
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	// The raw sign route streams bodies larger than the limit
+	w = httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/sign/raw", bytes.NewReader(make([]byte, 4096)))
+	req.Header.Set("Content-Type", "application/octet-stream")
+	req.Header.Set(walletHeader, address)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	// 0 disables the limit
+	maxBodySize = 0
+	router = newRouter(nil)
+	w = post("/sign", "application/json", strings.NewReader(oversized))
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+}
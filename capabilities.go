@@ -0,0 +1,91 @@
+package main
+
+import (
+	"net/http"
+	"slices"
+
+	"github.com/bnb-chain/tss-lib/tss"
+	"github.com/gin-gonic/gin"
+)
+
+// curveCapabilities describes a curve wallets can be created on
+type curveCapabilities struct {
+	Name         string   `json:"name"`
+	Algorithm    string   `json:"algorithm"`
+	AddressTypes []string `json:"addressTypes"`
+}
+
+// capabilitiesResponse represents the response body for capabilities endpoint
+type capabilitiesResponse struct {
+	Algorithms         []string            `json:"algorithms"`
+	Curves             []curveCapabilities `json:"curves"`
+	SigningModes       []string            `json:"signingModes"`
+	HashModes          []string            `json:"hashModes"`
+	SignatureEncodings []string            `json:"signatureEncodings"`
+	DataEncodings      []string            `json:"dataEncodings"`
+}
+
+// supportedCapabilities lists what this build of the service implements.
+// Every candidate is run through the validation of the requests that use it,
+// so a curve or mode left out of the build is left out of the list as well.
+func supportedCapabilities() capabilitiesResponse {
+	response := capabilitiesResponse{
+		Algorithms:         []string{},
+		Curves:             []curveCapabilities{},
+		SigningModes:       []string{},
+		HashModes:          []string{},
+		SignatureEncodings: []string{},
+		DataEncodings:      []string{},
+	}
+	curveNames := []string{string(curveEd25519)}
+	for name := range walletCurves {
+		curveNames = append(curveNames, string(name))
+	}
+	slices.Sort(curveNames)
+	for _, algorithm := range []string{algorithmECDSA, algorithmEdDSA} {
+		for _, name := range curveNames {
+			if _, _, _, err := resolveAlgorithm(algorithm, name); err != nil {
+				continue
+			}
+			if !slices.Contains(response.Algorithms, algorithm) {
+				response.Algorithms = append(response.Algorithms, algorithm)
+			}
+			curve := curveCapabilities{Name: name, Algorithm: algorithm, AddressTypes: []string{}}
+			for _, addressType := range []string{addressEthereum, addressBTCP2WPKH, addressBTCP2PKH, addressSolana} {
+				if validateAddressType(addressType, tss.CurveName(name)) == nil {
+					curve.AddressTypes = append(curve.AddressTypes, addressType)
+				}
+			}
+			response.Curves = append(response.Curves, curve)
+		}
+	}
+
+	digest := make([]byte, digestLen)
+	for _, mode := range []string{modeRaw, modeEIP191} {
+		if _, err := signingDigest(digest, mode, ""); err == nil {
+			response.SigningModes = append(response.SigningModes, mode)
+		}
+	}
+	for _, hashMode := range hashModes {
+		if _, err := messageDigest(digest, hashMode); err == nil {
+			response.HashModes = append(response.HashModes, hashMode)
+		}
+	}
+	for _, encoding := range []string{encodingRaw, encodingDER, encodingEth65, encodingEIP2098} {
+		if validateEncoding(encoding) == nil {
+			response.SignatureEncodings = append(response.SignatureEncodings, encoding)
+		}
+	}
+	for _, encoding := range []string{dataEncodingHex, dataEncodingBase64} {
+		if validateDataEncoding(encoding) == nil {
+			response.DataEncodings = append(response.DataEncodings, encoding)
+		}
+	}
+	return response
+}
+
+// getCapabilities lets clients discover the algorithms, curves, address types,
+// hash modes and encodings the service supports
+func getCapabilities(c *gin.Context) {
+	respond(c, http.StatusOK, supportedCapabilities())
+}
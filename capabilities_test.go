@@ -0,0 +1,47 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetCapabilities(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	router := gin.Default()
+	router.GET("/capabilities", getCapabilities)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/capabilities", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"secp256k1"`)
+	assert.Contains(t, w.Body.String(), `"keccak256"`)
+
+	var response capabilitiesResponse
+	if err := decodeData(w.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to parse capabilities response: %v", err)
+	}
+	assert.Equal(t, []string{algorithmECDSA, algorithmEdDSA}, response.Algorithms)
+	assert.Equal(t, []curveCapabilities{
+		{Name: string(curveP256), Algorithm: algorithmECDSA, AddressTypes: []string{addressEthereum}},
+		{Name: string(curveSecp256k1), Algorithm: algorithmECDSA, AddressTypes: []string{addressEthereum, addressBTCP2WPKH, addressBTCP2PKH}},
+		{Name: string(curveEd25519), Algorithm: algorithmEdDSA, AddressTypes: []string{addressEthereum, addressSolana}},
+	}, response.Curves)
+	assert.Equal(t, []string{modeRaw, modeEIP191}, response.SigningModes)
+	assert.Equal(t, []string{hashKeccak256, hashSHA256, hashSHA3256, hashBlake2b256, hashNone}, response.HashModes)
+	assert.Equal(t, []string{encodingRaw, encodingDER, encodingEth65, encodingEIP2098}, response.SignatureEncodings)
+	assert.Equal(t, []string{dataEncodingHex, dataEncodingBase64}, response.DataEncodings)
+
+	// Every advertised combination is accepted when creating a wallet
+	for _, curve := range response.Curves {
+		for _, addressType := range curve.AddressTypes {
+			_, err := newWalletSpec(createWalletRequest{Parties: 2, Threshold: 1, Algorithm: curve.Algorithm, Curve: curve.Name, AddressType: addressType})
+			assert.NoError(t, err, "%s %s %s", curve.Algorithm, curve.Name, addressType)
+		}
+	}
+}
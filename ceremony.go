@@ -0,0 +1,295 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"runtime/debug"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/bnb-chain/tss-lib/tss"
+)
+
+// Time allowed for a ceremony to complete. Keygen and resharing generate safe
+// primes, which alone can take minutes, while signing takes seconds.
+var (
+	keygenTimeout = 5 * time.Minute
+	signTimeout   = 30 * time.Second
+)
+
+// Ceremonies allowed to run at once, and to wait for one of them to complete
+// beyond that. Every ceremony runs a goroutine per party, the limit bounds the
+// memory used under load.
+var (
+	maxCeremonies       = 8
+	maxQueuedCeremonies = 64
+)
+
+// messageBufferRounds is how many rounds of messages the channels of a
+// ceremony can hold before a sending party has to wait
+var messageBufferRounds = 2
+
+// errCeremonyTimeout is returned when the parties don't complete a ceremony in time
+var errCeremonyTimeout = errors.New("timed out waiting for the parties to complete")
+
+// errCeremonyCanceled is returned when the context of a ceremony is canceled,
+// typically because the client went away
+var errCeremonyCanceled = errors.New("ceremony canceled")
+
+// errPartyPanicked is returned when a party of a ceremony panicked, the panic
+// is logged with its stack
+var errPartyPanicked = errors.New("a party failed unexpectedly")
+
+// errTooManyCeremonies is returned when the ceremony queue is full
+var errTooManyCeremonies = errors.New("too many ceremonies in progress, try again later")
+
+// ceremonyErrorStatus returns the HTTP status for a failed ceremony
+func ceremonyErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, errCeremonyTimeout):
+		return http.StatusGatewayTimeout
+	case errors.Is(err, errTooManyCeremonies), errors.Is(err, errEntropyUnavailable):
+		return http.StatusServiceUnavailable
+	}
+	return http.StatusInternalServerError
+}
+
+// ceremonyErrorResponse returns the HTTP status and body for a failed ceremony
+func ceremonyErrorResponse(err error) (int, apiResponse) {
+	status := ceremonyErrorStatus(err)
+	return status, errorResponse(status, err.Error())
+}
+
+// ceremonyLimit bounds the ceremonies running at once
+var ceremonyLimit = newCeremonyLimiter(maxCeremonies, maxQueuedCeremonies)
+
+// ceremonyLimiter is a semaphore over running ceremonies with a bounded queue
+type ceremonyLimiter struct {
+	running chan struct{}
+	queued  chan struct{}
+}
+
+// newCeremonyLimiter allows max ceremonies at once and queues up to maxQueued
+// more, it returns nil when max is 0 to disable the limit
+func newCeremonyLimiter(max, maxQueued int) *ceremonyLimiter {
+	if max <= 0 {
+		return nil
+	}
+	return &ceremonyLimiter{
+		running: make(chan struct{}, max),
+		queued:  make(chan struct{}, maxQueued),
+	}
+}
+
+// acquire takes a slot for a ceremony, waiting for one to be released when
+// all are taken. It fails right away when the queue is full as well, and
+// leaves the queue with errCeremonyCanceled when ctx is done while waiting.
+func (l *ceremonyLimiter) acquire(ctx context.Context) error {
+	if l == nil {
+		return nil
+	}
+	select {
+	case l.running <- struct{}{}:
+		return nil
+	default:
+	}
+	select {
+	case l.queued <- struct{}{}:
+	default:
+		return errTooManyCeremonies
+	}
+	defer func() { <-l.queued }()
+	select {
+	case l.running <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return errCeremonyCanceled
+	}
+}
+
+// load returns the number of ceremonies holding a slot and waiting for one
+func (l *ceremonyLimiter) load() (running, queued int) {
+	if l == nil {
+		return 0, 0
+	}
+	return len(l.running), len(l.queued)
+}
+
+// release frees the slot of a completed ceremony
+func (l *ceremonyLimiter) release() {
+	if l != nil {
+		<-l.running
+	}
+}
+
+// ceremony tracks the channels and goroutines of a keygen or signing ceremony
+// so that all of them are released once it is over, whether it produced a
+// result or failed halfway
+type ceremony struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+	// wg counts the goroutines that drive the parties and may still send on
+	// the out channels
+	wg sync.WaitGroup
+	// errCh holds the first party error, failed is set once it was sent so
+	// that later errors are logged instead of failing the ceremony again
+	errCh    chan *tss.Error
+	failed   atomic.Bool
+	outChs   []chan tss.Message
+	messages chan tss.Message
+	limit    *ceremonyLimiter
+	// wallets hold their key shares for the parties of the ceremony
+	wallets []*Wallet
+}
+
+// messageBufferSizes returns the capacity of the out channel of every party and
+// of the messages channel shared by a ceremony of the given number of parties.
+// In a round a party sends at most a P2P message to each of the other parties
+// and a broadcast, so parties messages. Its out channel holds
+// messageBufferRounds rounds of them, as a party may start the next round
+// before its messages of the current one were forwarded, and the messages
+// channel holds a round of every party. Signing ceremonies run threshold+1
+// parties, so their buffers grow with the threshold rather than the wallet.
+func messageBufferSizes(parties int) (out, messages int) {
+	return messageBufferRounds * parties, parties * parties
+}
+
+// newCeremony creates the channels for the given number of parties and starts
+// forwarding every party's out channel to the messages channel. It waits for
+// a free slot when too many ceremonies are running, the timeout only starts
+// once it got one and the ceremony is over when it expires or ctx is done.
+func newCeremony(ctx context.Context, parties int, timeout time.Duration) (*ceremony, error) {
+	limit := ceremonyLimit
+	if err := limit.acquire(ctx); err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	outSize, messagesSize := messageBufferSizes(parties)
+	cer := &ceremony{
+		ctx:      ctx,
+		cancel:   cancel,
+		errCh:    make(chan *tss.Error, 1),
+		outChs:   make([]chan tss.Message, parties),
+		messages: make(chan tss.Message, messagesSize),
+		limit:    limit,
+	}
+	for i := range cer.outChs {
+		cer.outChs[i] = make(chan tss.Message, outSize)
+		go cer.forward(cer.outChs[i])
+	}
+	return cer, nil
+}
+
+// forward moves messages from a party's out channel to the messages channel
+// until the channel is closed. While the messages channel is full, forward
+// waits and the party blocks once its own out channel is full too: a party
+// sending faster than the ceremony routes is slowed down, while the routing
+// loop never waits on a party as it delivers messages in goroutines. Once the
+// ceremony is over messages are drained and dropped so that parties still
+// running never block on a full channel.
+func (cer *ceremony) forward(outCh chan tss.Message) {
+	for msg := range outCh {
+		select {
+		case cer.messages <- msg:
+		case <-cer.ctx.Done():
+		}
+	}
+}
+
+// err returns why the ceremony is over before the parties completed it
+func (cer *ceremony) err() error {
+	if errors.Is(cer.ctx.Err(), context.DeadlineExceeded) {
+		return errCeremonyTimeout
+	}
+	return errCeremonyCanceled
+}
+
+// run calls fn in a goroutine, fn may drive a party and send on its out
+// channel. A panic in fn fails the ceremony instead of crashing the process,
+// otherwise the ceremony would wait for the result of the party until it
+// times out.
+func (cer *ceremony) run(fn func()) {
+	cer.wg.Add(1)
+	go func() {
+		defer cer.wg.Done()
+		defer func() {
+			if r := recover(); r != nil {
+				log.Printf("ceremony party panicked: %v\n%s", r, debug.Stack())
+				cer.fail(tss.NewError(errPartyPanicked, "ceremony", 0, nil))
+			}
+		}()
+		fn()
+	}()
+}
+
+// deliver hands a message to a party in a goroutine, a failure to process it
+// fails the ceremony
+func (cer *ceremony) deliver(party tss.Party, wireBytes []byte, from *tss.PartyID, isBroadcast bool) {
+	cer.run(func() {
+		if _, err := party.UpdateFromBytes(wireBytes, from, isBroadcast); err != nil {
+			cer.fail(err)
+		}
+	})
+}
+
+// fail reports a party error. Only the first error fails the ceremony and
+// produces a response, parties often fail together and the others are logged.
+func (cer *ceremony) fail(err *tss.Error) {
+	if !cer.failed.CompareAndSwap(false, true) {
+		log.Printf("ceremony already failed, ignoring party error: %v", err)
+		return
+	}
+	cer.errCh <- err
+}
+
+// useShares keeps the key shares of the wallet from being wiped until the
+// ceremony is closed and its parties, which read the shares, have returned
+func (cer *ceremony) useShares(wallet *Wallet) error {
+	if err := wallet.holdShares(); err != nil {
+		return err
+	}
+	cer.wallets = append(cer.wallets, wallet)
+	return nil
+}
+
+// close ends the ceremony. The out channels are closed once every goroutine
+// started with run has returned, which stops the forwarding goroutines, and
+// only then the key shares in use and the slot of the ceremony are released.
+func (cer *ceremony) close() {
+	cer.cancel()
+	go func() {
+		cer.wg.Wait()
+		for _, outCh := range cer.outChs {
+			close(outCh)
+		}
+		for _, wallet := range cer.wallets {
+			wallet.releaseShares()
+		}
+		cer.limit.release()
+	}()
+}
+
+// receivePointers forwards the values sent on ch as pointers until the
+// ceremony is over. tss-lib hands results over by value and signatures embed
+// a mutex, so they are copied once here and only passed by pointer after.
+func receivePointers[T any](cer *ceremony, ch <-chan T) <-chan *T {
+	out := make(chan *T, cap(ch))
+	go func() {
+		for {
+			select {
+			case value := <-ch:
+				select {
+				case out <- &value:
+				case <-cer.ctx.Done():
+					return
+				}
+			case <-cer.ctx.Done():
+				return
+			}
+		}
+	}()
+	return out
+}
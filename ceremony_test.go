@@ -0,0 +1,682 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/bnb-chain/tss-lib/common"
+	"github.com/bnb-chain/tss-lib/ecdsa/keygen"
+	"github.com/bnb-chain/tss-lib/tss"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+// stalledParty is a party that never sends nor processes a message, as if
+// every message to and from it were dropped
+type stalledParty struct {
+	tss.Party
+}
+
+func (p *stalledParty) Start() *tss.Error {
+	return nil
+}
+
+func (p *stalledParty) UpdateFromBytes([]byte, *tss.PartyID, bool) (bool, *tss.Error) {
+	return true, nil
+}
+
+// updateFailingParty is a party that broadcasts a message when started and
+// fails to process every message it receives
+type updateFailingParty struct {
+	tss.Party
+	out chan<- tss.Message
+}
+
+func (p *updateFailingParty) Start() *tss.Error {
+	p.out <- &routedMessage{from: p.PartyID(), broadcast: true}
+	return nil
+}
+
+func (p *updateFailingParty) UpdateFromBytes([]byte, *tss.PartyID, bool) (bool, *tss.Error) {
+	return false, tss.NewError(errors.New("injected update failure"), "update", 1, p.PartyID())
+}
+
+// instantSigningParty is a signing party that outputs a signature of the
+// message with the given key on its own, after a delay. While it waits it
+// counts itself in running and records the highest count in maxRunning.
+type instantSigningParty struct {
+	tss.Party
+	key                 *ecdsa.PrivateKey
+	msg                 *big.Int
+	end                 chan<- common.SignatureData
+	running, maxRunning *atomic.Int32
+}
+
+func (p *instantSigningParty) Start() *tss.Error {
+	current := p.running.Add(1)
+	for {
+		max := p.maxRunning.Load()
+		if current <= max || p.maxRunning.CompareAndSwap(max, current) {
+			break
+		}
+	}
+	time.Sleep(50 * time.Millisecond)
+	p.running.Add(-1)
+
+	signature, err := crypto.Sign(p.msg.FillBytes(make([]byte, 32)), p.key)
+	if err != nil {
+		return tss.NewError(err, "sign", 1, p.PartyID())
+	}
+	p.end <- common.SignatureData{R: signature[:32], S: signature[32:64], SignatureRecovery: signature[64:]}
+	return nil
+}
+
+// useInstantSigning replaces the signing parties with instantSigningParty for
+// the rest of the test, so signing endpoints answer at once with key
+func useInstantSigning(t *testing.T, key *ecdsa.PrivateKey) {
+	t.Helper()
+	previousParty := newSigningParty
+	newSigningParty = func(msg *big.Int, params *tss.Parameters, save keygen.LocalPartySaveData, out chan<- tss.Message, end chan<- common.SignatureData) tss.Party {
+		return &instantSigningParty{Party: &routedParty{id: params.PartyID()}, key: key, msg: msg, end: end, running: new(atomic.Int32), maxRunning: new(atomic.Int32)}
+	}
+	t.Cleanup(func() { newSigningParty = previousParty })
+}
+
+// assertNoLeakedGoroutines waits for the goroutines started since the baseline
+// was taken to return
+func assertNoLeakedGoroutines(t *testing.T, baseline int) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for runtime.NumGoroutine() > baseline && time.Now().Before(deadline) {
+		time.Sleep(100 * time.Millisecond)
+	}
+	assert.LessOrEqual(t, runtime.NumGoroutine(), baseline, "Goroutines should be released")
+}
+
+func TestSignDataTimeout(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	previousTimeout, previousParty := signTimeout, newSigningParty
+	signTimeout = 200 * time.Millisecond
+	newSigningParty = func(msg *big.Int, params *tss.Parameters, key keygen.LocalPartySaveData, out chan<- tss.Message, end chan<- common.SignatureData) tss.Party {
+		return &stalledParty{Party: &routedParty{id: params.PartyID()}}
+	}
+	t.Cleanup(func() { signTimeout, newSigningParty = previousTimeout, previousParty })
+
+	router := gin.Default()
+	router.POST("/sign", signData)
+
+	address := "0x00000000000000000000000000000000000000db"
+	addFakeWallet(address)
+	t.Cleanup(func() {
+		walletsMutex.Lock()
+		delete(wallets, address)
+		walletsMutex.Unlock()
+	})
+
+	baseline := runtime.NumGoroutine()
+	jsonBody, _ := json.Marshal(signDataRequest{Message: "timeout", Wallet: address})
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/sign", bytes.NewBuffer(jsonBody))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusGatewayTimeout, w.Code)
+	assert.Contains(t, w.Body.String(), errCeremonyTimeout.Error())
+
+	assertNoLeakedGoroutines(t, baseline)
+}
+
+func TestSignDataMultipleUpdateErrors(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	previousParty := newSigningParty
+	newSigningParty = func(msg *big.Int, params *tss.Parameters, key keygen.LocalPartySaveData, out chan<- tss.Message, end chan<- common.SignatureData) tss.Party {
+		return &updateFailingParty{Party: &routedParty{id: params.PartyID()}, out: out}
+	}
+	t.Cleanup(func() { newSigningParty = previousParty })
+
+	router := gin.Default()
+	router.POST("/sign", signData)
+
+	// Three signers each receive two messages, every update fails at once
+	address := "0x00000000000000000000000000000000000000DE"
+	addFakeWallet(address).Threshold = 2
+	t.Cleanup(func() {
+		walletsMutex.Lock()
+		delete(wallets, address)
+		walletsMutex.Unlock()
+	})
+
+	baseline := runtime.NumGoroutine()
+	jsonBody, _ := json.Marshal(signDataRequest{Message: "fail", Wallet: address})
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/sign", bytes.NewBuffer(jsonBody))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+
+	response, err := decodeError(w.Body.Bytes())
+	assert.NoError(t, err, "Response should be a single JSON object")
+	assert.Equal(t, codeInternal, response.Code)
+	assert.Contains(t, response.Message, "injected update failure")
+
+	assertNoLeakedGoroutines(t, baseline)
+}
+
+// panickingParty is a party that panics when started
+type panickingParty struct {
+	tss.Party
+}
+
+func (p *panickingParty) Start() *tss.Error {
+	panic("injected party panic")
+}
+
+// updatePanickingParty is a party that broadcasts a message when started and
+// panics on every message it receives
+type updatePanickingParty struct {
+	updateFailingParty
+}
+
+func (p *updatePanickingParty) UpdateFromBytes([]byte, *tss.PartyID, bool) (bool, *tss.Error) {
+	panic("injected update panic")
+}
+
+func TestCeremonyPartyPanic(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	useDeterministicKeygen(t, "party panic")
+	dealt := newKeygenParty
+	newKeygenParty = func(params *tss.Parameters, out chan<- tss.Message, end chan<- keygen.LocalPartySaveData, preParams ...keygen.LocalPreParams) tss.Party {
+		if params.PartyID().Index == 1 {
+			return &panickingParty{Party: &routedParty{id: params.PartyID()}}
+		}
+		return dealt(params, out, end, preParams...)
+	}
+	previousParty := newSigningParty
+	newSigningParty = func(msg *big.Int, params *tss.Parameters, key keygen.LocalPartySaveData, out chan<- tss.Message, end chan<- common.SignatureData) tss.Party {
+		return &updatePanickingParty{updateFailingParty{Party: &routedParty{id: params.PartyID()}, out: out}}
+	}
+	t.Cleanup(func() { newSigningParty = previousParty })
+
+	router := gin.Default()
+	router.POST("/wallet", createWallet)
+	router.POST("/sign", signData)
+
+	key, _ := crypto.GenerateKey()
+	address := deriveAddress(&key.PublicKey)
+	addFakeWallet(address)
+	t.Cleanup(func() {
+		walletsMutex.Lock()
+		delete(wallets, address)
+		walletsMutex.Unlock()
+	})
+	walletsMutex.Lock()
+	count := len(wallets)
+	walletsMutex.Unlock()
+
+	// A party panicking while it starts, or while it processes a message,
+	// fails the ceremony at once rather than when it times out
+	baseline := runtime.NumGoroutine()
+	for _, request := range []struct {
+		path string
+		body any
+	}{
+		{"/wallet", createWalletRequest{Parties: 3, Threshold: 1}},
+		{"/sign", signDataRequest{Message: "panic", Wallet: address}},
+	} {
+		jsonBody, _ := json.Marshal(request.body)
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", request.path, bytes.NewBuffer(jsonBody))
+		req.Header.Set("Content-Type", "application/json")
+		start := time.Now()
+		router.ServeHTTP(w, req)
+		assert.Less(t, time.Since(start), 5*time.Second, "%s should not wait for the ceremony to time out", request.path)
+		assert.Equal(t, http.StatusInternalServerError, w.Code, request.path)
+
+		response, err := decodeError(w.Body.Bytes())
+		assert.NoError(t, err, "Response should be a single JSON object")
+		assert.Equal(t, codeInternal, response.Code)
+		assert.Contains(t, response.Message, errPartyPanicked.Error())
+	}
+	walletsMutex.Lock()
+	assert.Equal(t, count, len(wallets), "No wallet should be stored")
+	walletsMutex.Unlock()
+
+	assertNoLeakedGoroutines(t, baseline)
+}
+
+func TestCeremonyFailKeepsFirstError(t *testing.T) {
+	cer, err := newCeremony(context.Background(), 1, time.Minute)
+	if err != nil {
+		t.Fatalf("Failed to create ceremony: %v", err)
+	}
+	defer cer.close()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			cer.fail(tss.NewError(errors.New("injected failure"), "test", 1, nil))
+		}()
+	}
+	// None of the failing goroutines may block, even though the error is
+	// only received afterwards
+	wg.Wait()
+
+	assert.Len(t, cer.errCh, 1, "Only the first error should be reported")
+	assert.NotNil(t, <-cer.errCh)
+}
+
+func TestCeremonyLimit(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	const limit, requests = 2, 8
+	previousLimit, previousParty := ceremonyLimit, newSigningParty
+	ceremonyLimit = newCeremonyLimiter(limit, requests)
+	t.Cleanup(func() { ceremonyLimit, newSigningParty = previousLimit, previousParty })
+
+	address := "0x00000000000000000000000000000000000000E6"
+	wallet := addFakeWallet(address)
+	t.Cleanup(func() {
+		walletsMutex.Lock()
+		delete(wallets, address)
+		walletsMutex.Unlock()
+	})
+	key, _ := crypto.GenerateKey()
+	wallet.PubKey = &key.PublicKey
+
+	// The parties of the first signer count how many ceremonies run at once
+	var running, maxRunning, othersRunning, othersMaxRunning atomic.Int32
+	newSigningParty = func(msg *big.Int, params *tss.Parameters, save keygen.LocalPartySaveData, out chan<- tss.Message, end chan<- common.SignatureData) tss.Party {
+		party := &instantSigningParty{Party: &routedParty{id: params.PartyID()}, key: key, msg: msg, end: end, running: &running, maxRunning: &maxRunning}
+		if params.PartyID().Index != 0 {
+			party.running, party.maxRunning = &othersRunning, &othersMaxRunning
+		}
+		return party
+	}
+
+	router := gin.Default()
+	router.POST("/sign", signData)
+
+	var wg sync.WaitGroup
+	for i := 0; i < requests; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			jsonBody, _ := json.Marshal(signDataRequest{Message: "limit", Wallet: address})
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("POST", "/sign", bytes.NewBuffer(jsonBody))
+			req.Header.Set("Content-Type", "application/json")
+			router.ServeHTTP(w, req)
+			assert.Equal(t, http.StatusOK, w.Code, "Queued ceremonies should eventually complete")
+		}()
+	}
+	wg.Wait()
+	assert.LessOrEqual(t, maxRunning.Load(), int32(limit), "No more ceremonies than the limit should run at once")
+}
+
+func TestCeremonyLimitQueuedCanceled(t *testing.T) {
+	limit := newCeremonyLimiter(1, 1)
+	assert.NoError(t, limit.acquire(context.Background()))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	acquired := make(chan error, 1)
+	go func() { acquired <- limit.acquire(ctx) }()
+	assert.Eventually(t, func() bool {
+		_, queued := limit.load()
+		return queued == 1
+	}, time.Second, 10*time.Millisecond)
+
+	// A caller that goes away while queued leaves the queue right away
+	cancel()
+	select {
+	case err := <-acquired:
+		assert.ErrorIs(t, err, errCeremonyCanceled)
+	case <-time.After(time.Second):
+		t.Fatal("A canceled caller should stop waiting for a slot")
+	}
+	running, queued := limit.load()
+	assert.Equal(t, 1, running)
+	assert.Zero(t, queued, "A canceled caller should release its queue slot")
+}
+
+func TestCeremonyLimitQueueFull(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	previousLimit, previousTimeout, previousParty := ceremonyLimit, signTimeout, newSigningParty
+	ceremonyLimit = newCeremonyLimiter(1, 0)
+	signTimeout = 500 * time.Millisecond
+	newSigningParty = func(msg *big.Int, params *tss.Parameters, key keygen.LocalPartySaveData, out chan<- tss.Message, end chan<- common.SignatureData) tss.Party {
+		return &stalledParty{Party: &routedParty{id: params.PartyID()}}
+	}
+	t.Cleanup(func() { ceremonyLimit, signTimeout, newSigningParty = previousLimit, previousTimeout, previousParty })
+
+	address := "0x00000000000000000000000000000000000000e7"
+	addFakeWallet(address)
+	t.Cleanup(func() {
+		walletsMutex.Lock()
+		delete(wallets, address)
+		walletsMutex.Unlock()
+	})
+
+	router := gin.Default()
+	router.POST("/sign", signData)
+	sign := func() int {
+		jsonBody, _ := json.Marshal(signDataRequest{Message: "full", Wallet: address})
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/sign", bytes.NewBuffer(jsonBody))
+		req.Header.Set("Content-Type", "application/json")
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	// A stalled ceremony holds the only slot until it times out
+	stalled := make(chan int, 1)
+	go func() { stalled <- sign() }()
+	for len(ceremonyLimit.running) == 0 {
+		time.Sleep(10 * time.Millisecond)
+	}
+	assert.Equal(t, http.StatusServiceUnavailable, sign(), "Requests should be rejected when the queue is full")
+	assert.Equal(t, http.StatusGatewayTimeout, <-stalled)
+}
+
+func TestCreateWalletTimeout(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	previousTimeout, previousParty := keygenTimeout, newKeygenParty
+	keygenTimeout = 200 * time.Millisecond
+	newKeygenParty = func(params *tss.Parameters, out chan<- tss.Message, end chan<- keygen.LocalPartySaveData, preParams ...keygen.LocalPreParams) tss.Party {
+		return &stalledParty{Party: previousParty(params, out, end, preParams...)}
+	}
+	t.Cleanup(func() { keygenTimeout, newKeygenParty = previousTimeout, previousParty })
+
+	router := gin.Default()
+	router.POST("/wallet", createWallet)
+
+	walletsMutex.Lock()
+	walletsBefore := len(wallets)
+	walletsMutex.Unlock()
+
+	baseline := runtime.NumGoroutine()
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/wallet", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusGatewayTimeout, w.Code)
+
+	assertNoLeakedGoroutines(t, baseline)
+
+	walletsMutex.Lock()
+	defer walletsMutex.Unlock()
+	assert.Len(t, wallets, walletsBefore, "No wallet should be stored")
+}
+
+func TestCreateWalletClientDisconnect(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	previousParty := newKeygenParty
+	started := make(chan struct{}, 3)
+	newKeygenParty = func(params *tss.Parameters, out chan<- tss.Message, end chan<- keygen.LocalPartySaveData, preParams ...keygen.LocalPreParams) tss.Party {
+		started <- struct{}{}
+		return &stalledParty{Party: previousParty(params, out, end, preParams...)}
+	}
+	t.Cleanup(func() { newKeygenParty = previousParty })
+
+	router := gin.Default()
+	router.POST("/wallet", createWallet)
+
+	walletsMutex.Lock()
+	walletsBefore := len(wallets)
+	walletsMutex.Unlock()
+
+	baseline := runtime.NumGoroutine()
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		// Disconnect once the keygen is under way
+		<-started
+		cancel()
+	}()
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequestWithContext(ctx, "POST", "/wallet", nil)
+	start := time.Now()
+	router.ServeHTTP(w, req)
+	assert.Less(t, time.Since(start), keygenTimeout, "The keygen should stop when the client goes away")
+	assert.Contains(t, w.Body.String(), errCeremonyCanceled.Error())
+
+	assertNoLeakedGoroutines(t, baseline)
+
+	walletsMutex.Lock()
+	defer walletsMutex.Unlock()
+	assert.Len(t, wallets, walletsBefore, "No wallet should be stored")
+}
+
+func TestSignDataCompletesOnFirstSignature(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	useDeterministicKeygen(t, "first signature")
+
+	router := gin.Default()
+	router.POST("/wallet", createWallet)
+	router.POST("/sign", signData)
+
+	jsonBody, _ := json.Marshal(createWalletRequest{Parties: 3, Threshold: 2})
+	w1 := httptest.NewRecorder()
+	req1, _ := http.NewRequest("POST", "/wallet", bytes.NewBuffer(jsonBody))
+	req1.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w1, req1)
+	assert.Equal(t, http.StatusOK, w1.Code)
+
+	var createResponse stringFields
+	err := decodeData(w1.Body.Bytes(), &createResponse)
+	if err != nil {
+		t.Fatalf("Failed to parse create wallet response: %v", err)
+	}
+	walletAddress := createResponse["address"]
+
+	// Only the first party hands its signature over, the others keep theirs
+	previousParty := newSigningParty
+	created := 0
+	newSigningParty = func(msg *big.Int, params *tss.Parameters, key keygen.LocalPartySaveData, out chan<- tss.Message, end chan<- common.SignatureData) tss.Party {
+		created++
+		if created > 1 {
+			end = make(chan common.SignatureData, 1)
+		}
+		return previousParty(msg, params, key, out, end)
+	}
+	t.Cleanup(func() { newSigningParty = previousParty })
+
+	digest := crypto.Keccak256([]byte("first"))
+	jsonBody, _ = json.Marshal(signDataRequest{
+		Data:   "0x" + hex.EncodeToString(digest),
+		Wallet: walletAddress,
+		Hash:   hashNone,
+	})
+	w2 := httptest.NewRecorder()
+	req2, _ := http.NewRequest("POST", "/sign", bytes.NewBuffer(jsonBody))
+	req2.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w2, req2)
+	assert.Equal(t, http.StatusOK, w2.Code)
+	assert.Equal(t, 3, created)
+
+	var signResponse stringFields
+	err = decodeData(w2.Body.Bytes(), &signResponse)
+	if err != nil {
+		t.Fatalf("Failed to parse sign data response: %v", err)
+	}
+	rsv, err := hex.DecodeString(signResponse["rsv"])
+	assert.NoError(t, err)
+	rsv[64] -= 27
+	pubKey, err := crypto.SigToPub(digest, rsv)
+	assert.NoError(t, err)
+	assert.Equal(t, walletAddress, crypto.PubkeyToAddress(*pubKey).Hex())
+}
+
+func TestVerifySignature(t *testing.T) {
+	key, err := crypto.GenerateKey()
+	assert.NoError(t, err)
+	digest := crypto.Keccak256([]byte("verify"))
+	signature, err := crypto.Sign(digest, key)
+	assert.NoError(t, err)
+
+	sigData := &common.SignatureData{R: signature[:32], S: signature[32:64]}
+	assert.True(t, verifySignature(&key.PublicKey, digest, sigData))
+
+	otherDigest := crypto.Keccak256([]byte("other"))
+	assert.False(t, verifySignature(&key.PublicKey, otherDigest, sigData))
+}
+
+func TestMessageBufferSizes(t *testing.T) {
+	previous := messageBufferRounds
+	t.Cleanup(func() { messageBufferRounds = previous })
+
+	tests := []struct {
+		rounds, parties int
+		out, messages   int
+	}{
+		{2, 2, 4, 4},
+		{2, 7, 14, 49},
+		{1, 5, 5, 25},
+		{3, 3, 9, 9},
+	}
+	for _, tt := range tests {
+		messageBufferRounds = tt.rounds
+		out, messages := messageBufferSizes(tt.parties)
+		assert.Equal(t, tt.out, out, "out buffer of %d parties over %d rounds", tt.parties, tt.rounds)
+		assert.Equal(t, tt.messages, messages, "messages buffer of %d parties", tt.parties)
+	}
+}
+
+// blockingParty is a party that processes messages only once release is closed
+type blockingParty struct {
+	tss.Party
+	release <-chan struct{}
+}
+
+func (p *blockingParty) UpdateFromBytes([]byte, *tss.PartyID, bool) (bool, *tss.Error) {
+	<-p.release
+	return true, nil
+}
+
+// countingParty is a party that counts the messages it processes
+type countingParty struct {
+	tss.Party
+	received atomic.Int32
+}
+
+func (p *countingParty) UpdateFromBytes([]byte, *tss.PartyID, bool) (bool, *tss.Error) {
+	p.received.Add(1)
+	return true, nil
+}
+
+func TestCeremonyBackpressure(t *testing.T) {
+	previous := messageBufferRounds
+	messageBufferRounds = 1
+	t.Cleanup(func() { messageBufferRounds = previous })
+	baseline := runtime.NumGoroutine()
+
+	const parties, sent = 3, 200
+	cer, err := newCeremony(context.Background(), parties, time.Minute)
+	if err != nil {
+		t.Fatalf("Failed to create ceremony: %v", err)
+	}
+	ids := make([]*tss.PartyID, parties)
+	for i := range ids {
+		ids[i] = tss.NewPartyID(string(rune('0'+i)), "", big.NewInt(int64(i+1)))
+	}
+	release := make(chan struct{})
+	slow := &blockingParty{Party: &routedParty{id: ids[1]}, release: release}
+	counting := &countingParty{Party: &routedParty{id: ids[2]}}
+	router := newPartyRouter([]tss.Party{&routedParty{id: ids[0]}, slow, counting}, cer.deliver)
+
+	// The first party sends far more messages than the channels can hold
+	cer.run(func() {
+		for i := 0; i < sent; i++ {
+			cer.outChs[0] <- &routedMessage{from: ids[0], broadcast: true}
+		}
+	})
+
+	// The second party doesn't process any of them, which must not keep the
+	// third one from receiving them all
+	deadline := time.After(5 * time.Second)
+	for counting.received.Load() < sent {
+		select {
+		case msg := <-cer.messages:
+			assert.NoError(t, router.Route(msg))
+		case <-time.After(10 * time.Millisecond):
+		case <-deadline:
+			t.Fatalf("Routing stalled after %d messages", counting.received.Load())
+		}
+	}
+
+	close(release)
+	cer.close()
+	assertNoLeakedGoroutines(t, baseline)
+}
+
+func TestCeremonySevenParties(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	// A seven party ECDSA keygen takes minutes, EdDSA ceremonies exchange the
+	// same rounds of P2P and broadcast messages in a second. The smallest
+	// buffers make parties wait on each other.
+	previous := messageBufferRounds
+	messageBufferRounds = 1
+	t.Cleanup(func() { messageBufferRounds = previous })
+
+	router := gin.Default()
+	router.POST("/wallet", createWallet)
+	router.POST("/sign", signData)
+
+	jsonBody, _ := json.Marshal(createWalletRequest{Parties: 7, Threshold: 4, Algorithm: algorithmEdDSA})
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/wallet", bytes.NewBuffer(jsonBody))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var createResponse stringFields
+	if err := decodeData(w.Body.Bytes(), &createResponse); err != nil {
+		t.Fatalf("Failed to parse create wallet response: %v", err)
+	}
+	address := createResponse["address"]
+	t.Cleanup(func() {
+		walletsMutex.Lock()
+		delete(wallets, address)
+		walletsMutex.Unlock()
+	})
+	wallet, err := lookupWallet(address)
+	if err != nil {
+		t.Fatalf("Wallet %s should be stored: %v", address, err)
+	}
+	assert.Len(t, wallet.EdDSASaveData, 7)
+	pubKey := ed25519.PublicKey(ed25519PubKeyBytes(wallet.PubKey))
+
+	for _, signers := range [][]string{nil, {"6", "4", "2", "0", "5"}} {
+		jsonBody, _ := json.Marshal(signDataRequest{Data: "0x74657374", Wallet: address, Signers: signers})
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/sign", bytes.NewBuffer(jsonBody))
+		req.Header.Set("Content-Type", "application/json")
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		var signResponse stringFields
+		if err := decodeData(w.Body.Bytes(), &signResponse); err != nil {
+			t.Fatalf("Failed to parse sign data response: %v", err)
+		}
+		signature, _ := hex.DecodeString(signResponse["signature"])
+		assert.True(t, ed25519.Verify(pubKey, []byte("test"), signature), "Signers %v should produce a valid signature", signers)
+	}
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"net/http"
+	"slices"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+)
+
+// rawBodyPaths are routes whose body is not JSON, such as the raw sign route
+// checking its own content type
+var rawBodyPaths = []string{"/sign/raw"}
+
+// requireJSON rejects POST requests with a body that is not declared as
+// application/json with a 415, instead of the vague 400 of a failed binding.
+// Requests without a body, like a wallet creation with the defaults, are let
+// through, and so are unknown routes so they still get their 404.
+func requireJSON() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.Request.Method != http.MethodPost || c.Request.ContentLength == 0 || c.FullPath() == "" || slices.Contains(rawBodyPaths, c.FullPath()) {
+			c.Next()
+			return
+		}
+		if c.ContentType() != binding.MIMEJSON {
+			abortWithError(c, http.StatusUnsupportedMediaType, "Content-Type must be "+binding.MIMEJSON)
+			return
+		}
+		c.Next()
+	}
+}
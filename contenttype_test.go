@@ -0,0 +1,69 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequireJSON(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	key, _ := crypto.GenerateKey()
+	useInstantSigning(t, key)
+	address := deriveAddress(&key.PublicKey)
+	wallet := addFakeWallet(address)
+	wallet.PubKey = &key.PublicKey
+	t.Cleanup(func() {
+		walletsMutex.Lock()
+		delete(wallets, address)
+		walletsMutex.Unlock()
+	})
+	router := newRouter(nil)
+
+	post := func(path, contentType string, body io.Reader) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", path, body)
+		if contentType != "" {
+			req.Header.Set("Content-Type", contentType)
+		}
+		router.ServeHTTP(w, req)
+		return w
+	}
+	body := `{"wallet": "` + address + `", "data": "0x74657374"}`
+
+	for _, contentType := range []string{"text/plain", "application/x-www-form-urlencoded", ""} {
+		w := post("/sign", contentType, strings.NewReader(body))
+		assert.Equal(t, http.StatusUnsupportedMediaType, w.Code, "Content-Type %q", contentType)
+		apiErr, err := decodeError(w.Body.Bytes())
+		assert.NoError(t, err)
+		assert.Equal(t, codeMediaType, apiErr.Code)
+		assert.Equal(t, "Content-Type must be application/json", apiErr.Message)
+	}
+	assert.Equal(t, http.StatusUnsupportedMediaType, post("/verify", "text/plain", strings.NewReader(body)).Code)
+
+	// Parameters of the media type are fine
+	w := post("/sign", "application/json; charset=utf-8", strings.NewReader(body))
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	// Requests without a body need no content type
+	w = post("/wallet/"+address+"/reshare", "", http.NoBody)
+	assert.Equal(t, http.StatusBadRequest, w.Code, "The handler should reject the missing body itself")
+
+	// The raw sign route takes its own content type, and unknown routes are
+	// still not found
+	w = httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/sign/raw", bytes.NewReader([]byte("file")))
+	req.Header.Set("Content-Type", "application/octet-stream")
+	req.Header.Set(walletHeader, address)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(t, http.StatusNotFound, post("/nope", "text/plain", strings.NewReader(body)).Code)
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Default CORS settings. No browser origin may call the API until operators
+// list the ones that need it, allowed origins may use the methods and headers
+// of the API.
+const (
+	defaultCORSOrigins = ""
+	defaultCORSMethods = "GET,POST,DELETE,OPTIONS"
+	defaultCORSHeaders = "Authorization,Content-Type," + exportPassphraseHeader + "," + walletHeader
+)
+
+// corsMaxAge is how long browsers may cache the answer to a preflight request
+const corsMaxAge = 600
+
+// corsPolicy tells which browser origins may call the API. In strict mode
+// every origin must be listed and requests from other origins are rejected
+// with a 403 instead of only lacking the CORS headers.
+type corsPolicy struct {
+	origins   map[string]bool
+	anyOrigin bool
+	strict    bool
+	methods   string
+	headers   string
+}
+
+// cors is the policy applied by newRouter
+var cors = mustCORSPolicy(defaultCORSOrigins, defaultCORSMethods, defaultCORSHeaders, false)
+
+// newCORSPolicy creates a policy from comma separated lists of origins,
+// methods and headers. An origin of * allows any origin, which strict mode
+// refuses.
+func newCORSPolicy(origins, methods, headers string, strict bool) (*corsPolicy, error) {
+	policy := &corsPolicy{
+		origins: make(map[string]bool),
+		strict:  strict,
+		methods: strings.Join(splitList(methods), ", "),
+		headers: strings.Join(splitList(headers), ", "),
+	}
+	for _, origin := range splitList(origins) {
+		if origin == "*" {
+			policy.anyOrigin = true
+			continue
+		}
+		policy.origins[strings.TrimSuffix(origin, "/")] = true
+	}
+	if strict && policy.anyOrigin {
+		return nil, errors.New("strict CORS mode requires explicit origins, not *")
+	}
+	if strict && len(policy.origins) == 0 {
+		return nil, errors.New("strict CORS mode requires at least one origin")
+	}
+	return policy, nil
+}
+
+// mustCORSPolicy is like newCORSPolicy but panics on an invalid policy
+func mustCORSPolicy(origins, methods, headers string, strict bool) *corsPolicy {
+	policy, err := newCORSPolicy(origins, methods, headers, strict)
+	if err != nil {
+		panic(err)
+	}
+	return policy
+}
+
+// splitList splits a comma separated list, dropping empty entries
+func splitList(list string) []string {
+	var items []string
+	for _, item := range strings.Split(list, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// allows reports whether a browser at origin may call the API
+func (p *corsPolicy) allows(origin string) bool {
+	return p.anyOrigin || p.origins[origin]
+}
+
+// allowCORS answers preflight requests and adds the CORS headers to the
+// responses for allowed origins. It runs before authentication, as browsers
+// never send credentials with a preflight request.
+func allowCORS(p *corsPolicy) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		origin := c.GetHeader("Origin")
+		if origin == "" {
+			c.Next()
+			return
+		}
+		c.Header("Vary", "Origin")
+		preflight := c.Request.Method == http.MethodOptions && c.GetHeader("Access-Control-Request-Method") != ""
+
+		if !p.allows(origin) {
+			switch {
+			case p.strict:
+				abortWithError(c, http.StatusForbidden, "origin not allowed")
+			case preflight:
+				c.AbortWithStatus(http.StatusNoContent)
+			default:
+				c.Next()
+			}
+			return
+		}
+
+		if p.anyOrigin {
+			c.Header("Access-Control-Allow-Origin", "*")
+		} else {
+			c.Header("Access-Control-Allow-Origin", origin)
+		}
+		c.Header("Access-Control-Expose-Headers", "Retry-After")
+		if preflight {
+			c.Header("Access-Control-Allow-Methods", p.methods)
+			c.Header("Access-Control-Allow-Headers", p.headers)
+			c.Header("Access-Control-Max-Age", strconv.Itoa(corsMaxAge))
+			c.AbortWithStatus(http.StatusNoContent)
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+// withCORSPolicy sets the policy used by newRouter for the rest of the test
+func withCORSPolicy(t *testing.T, origins string, strict bool) {
+	t.Helper()
+	policy, err := newCORSPolicy(origins, defaultCORSMethods, defaultCORSHeaders, strict)
+	if err != nil {
+		t.Fatalf("Failed to create CORS policy: %v", err)
+	}
+	previous := cors
+	cors = policy
+	t.Cleanup(func() { cors = previous })
+}
+
+func TestCORS(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	withCORSPolicy(t, "https://app.example.com, https://other.example.com", false)
+	router := newRouter(nil)
+
+	tests := []struct {
+		name    string
+		origin  string
+		allowed bool
+	}{
+		{"allowed origin", "https://app.example.com", true},
+		{"second allowed origin", "https://other.example.com", true},
+		{"disallowed origin", "https://evil.example.com", false},
+		{"no origin", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("GET", "/wallets", nil)
+			if tt.origin != "" {
+				req.Header.Set("Origin", tt.origin)
+			}
+			router.ServeHTTP(w, req)
+
+			// Disallowed origins are still served, the browser blocks them
+			assert.Equal(t, http.StatusOK, w.Code)
+			if tt.allowed {
+				assert.Equal(t, tt.origin, w.Header().Get("Access-Control-Allow-Origin"))
+			} else {
+				assert.Empty(t, w.Header().Get("Access-Control-Allow-Origin"))
+			}
+		})
+	}
+}
+
+func TestCORSPreflight(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	withCORSPolicy(t, "https://app.example.com", false)
+	// Preflight requests carry no API key
+	router := newRouter(newAPIKeySet([]string{"key"}))
+
+	preflight := func(origin string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("OPTIONS", "/sign", nil)
+		req.Header.Set("Origin", origin)
+		req.Header.Set("Access-Control-Request-Method", "POST")
+		req.Header.Set("Access-Control-Request-Headers", "authorization, content-type")
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := preflight("https://app.example.com")
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	assert.Equal(t, "https://app.example.com", w.Header().Get("Access-Control-Allow-Origin"))
+	assert.Contains(t, w.Header().Get("Access-Control-Allow-Methods"), "POST")
+	assert.Contains(t, w.Header().Get("Access-Control-Allow-Headers"), "Authorization")
+	assert.Contains(t, w.Header().Get("Access-Control-Allow-Headers"), "Content-Type")
+	assert.NotEmpty(t, w.Header().Get("Access-Control-Max-Age"))
+
+	w = preflight("https://evil.example.com")
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	assert.Empty(t, w.Header().Get("Access-Control-Allow-Origin"))
+	assert.Empty(t, w.Header().Get("Access-Control-Allow-Methods"))
+}
+
+func TestCORSAnyOrigin(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	withCORSPolicy(t, "*", false)
+	router := newRouter(nil)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/wallets", nil)
+	req.Header.Set("Origin", "https://anything.example.com")
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "*", w.Header().Get("Access-Control-Allow-Origin"))
+}
+
+func TestCORSDefaultPolicy(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	withCORSPolicy(t, defaultCORSOrigins, false)
+	router := newRouter(nil)
+
+	// No origin is allowed unless listed
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("OPTIONS", "/wallets", nil)
+	req.Header.Set("Origin", "https://anything.example.com")
+	req.Header.Set("Access-Control-Request-Method", "GET")
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	assert.Empty(t, w.Header().Get("Access-Control-Allow-Origin"))
+	assert.Empty(t, w.Header().Get("Access-Control-Allow-Methods"))
+}
+
+func TestCORSStrict(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	withCORSPolicy(t, "https://app.example.com", true)
+	router := newRouter(nil)
+
+	serve := func(origin string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/wallets", nil)
+		if origin != "" {
+			req.Header.Set("Origin", origin)
+		}
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := serve("https://app.example.com")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "https://app.example.com", w.Header().Get("Access-Control-Allow-Origin"))
+
+	w = serve("https://evil.example.com")
+	assertEnvelope(t, w, http.StatusForbidden)
+	assert.Empty(t, w.Header().Get("Access-Control-Allow-Origin"))
+
+	// Requests from outside a browser are not affected
+	w = serve("")
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestNewCORSPolicyInvalid(t *testing.T) {
+	_, err := newCORSPolicy("*", defaultCORSMethods, defaultCORSHeaders, true)
+	assert.Error(t, err, "Strict mode should refuse any origin")
+
+	_, err = newCORSPolicy("", defaultCORSMethods, defaultCORSHeaders, true)
+	assert.Error(t, err, "Strict mode should require an origin")
+
+	_, err = newCORSPolicy("", defaultCORSMethods, defaultCORSHeaders, false)
+	assert.NoError(t, err)
+}
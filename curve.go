@@ -0,0 +1,111 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/pem"
+	"fmt"
+
+	"github.com/bnb-chain/tss-lib/tss"
+	ethcommon "github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// Curves a wallet can be created on, secp256k1 is the default
+const (
+	curveSecp256k1 = tss.Secp256k1
+	curveP256      = tss.CurveName("p256")
+)
+
+// tss-lib looks curves up by name when it decodes key shares, so P-256 must be
+// registered before any wallet is loaded
+func init() {
+	tss.RegisterCurve(curveP256, elliptic.P256())
+}
+
+// walletCurves maps the supported curve names to their implementation
+var walletCurves = map[tss.CurveName]elliptic.Curve{
+	curveSecp256k1: tss.S256(),
+	curveP256:      elliptic.P256(),
+}
+
+// curveByName returns the curve with the given name, an empty name selects
+// secp256k1
+func curveByName(name string) (tss.CurveName, elliptic.Curve, error) {
+	curveName := tss.CurveName(name)
+	if curveName == "" {
+		curveName = curveSecp256k1
+	}
+	curve, exists := walletCurves[curveName]
+	if !exists {
+		return "", nil, fmt.Errorf("unsupported curve %q", name)
+	}
+	return curveName, curve, nil
+}
+
+// pubKeyBytes returns the uncompressed encoding of the public key,
+// 0x04 || X || Y, for any of the supported curves
+func pubKeyBytes(pubKey *ecdsa.PublicKey) []byte {
+	byteLen := (pubKey.Curve.Params().BitSize + 7) / 8
+	encoded := make([]byte, 1+2*byteLen)
+	encoded[0] = 4
+	pubKey.X.FillBytes(encoded[1 : 1+byteLen])
+	pubKey.Y.FillBytes(encoded[1+byteLen:])
+	return encoded
+}
+
+// compressedPubKeyBytes returns the compressed SEC1 encoding of the public
+// key, 0x02 or 0x03 depending on the parity of Y, followed by X
+func compressedPubKeyBytes(pubKey *ecdsa.PublicKey) []byte {
+	return elliptic.MarshalCompressed(pubKey.Curve, pubKey.X, pubKey.Y)
+}
+
+// Object identifiers of a secp256k1 public key in a SubjectPublicKeyInfo,
+// crypto/x509 doesn't know the curve
+var (
+	oidPublicKeyECDSA = asn1.ObjectIdentifier{1, 2, 840, 10045, 2, 1}
+	oidCurveSecp256k1 = asn1.ObjectIdentifier{1, 3, 132, 0, 10}
+)
+
+// ecSubjectPublicKeyInfo is the ASN.1 structure of a PKIX elliptic curve
+// public key with a named curve
+type ecSubjectPublicKeyInfo struct {
+	Algorithm struct {
+		Algorithm  asn1.ObjectIdentifier
+		NamedCurve asn1.ObjectIdentifier
+	}
+	PublicKey asn1.BitString
+}
+
+// pkixPubKey returns the public key of the wallet as a DER encoded PKIX
+// SubjectPublicKeyInfo and as a PEM block of it
+func (w *Wallet) pkixPubKey() ([]byte, string, error) {
+	var der []byte
+	var err error
+	switch name, _ := tss.GetCurveName(w.PubKey.Curve); {
+	case w.Algorithm == algorithmEdDSA:
+		der, err = x509.MarshalPKIXPublicKey(ed25519.PublicKey(ed25519PubKeyBytes(w.PubKey)))
+	case name == curveP256:
+		der, err = x509.MarshalPKIXPublicKey(&ecdsa.PublicKey{Curve: elliptic.P256(), X: w.PubKey.X, Y: w.PubKey.Y})
+	default:
+		var info ecSubjectPublicKeyInfo
+		info.Algorithm.Algorithm, info.Algorithm.NamedCurve = oidPublicKeyECDSA, oidCurveSecp256k1
+		encoded := pubKeyBytes(w.PubKey)
+		info.PublicKey = asn1.BitString{Bytes: encoded, BitLength: 8 * len(encoded)}
+		der, err = asn1.Marshal(info)
+	}
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to encode public key: %w", err)
+	}
+	return der, string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})), nil
+}
+
+// deriveAddress derives the address of a wallet the way Ethereum does, from
+// the last 20 bytes of the keccak256 of the public key. For curves other than
+// secp256k1 the address only identifies the wallet.
+func deriveAddress(pubKey *ecdsa.PublicKey) string {
+	return ethcommon.BytesToAddress(crypto.Keccak256(pubKeyBytes(pubKey)[1:])[12:]).Hex()
+}
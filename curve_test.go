@@ -0,0 +1,224 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/bnb-chain/tss-lib/tss"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCreateWalletCurves(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	router := gin.Default()
+	router.POST("/wallet", createWallet)
+	router.POST("/sign", signData)
+
+	for _, curveName := range []tss.CurveName{curveSecp256k1, curveP256} {
+		t.Run(string(curveName), func(t *testing.T) {
+			jsonBody, _ := json.Marshal(createWalletRequest{Parties: 2, Threshold: 1, Curve: string(curveName)})
+			w1 := httptest.NewRecorder()
+			req1, _ := http.NewRequest("POST", "/wallet", bytes.NewBuffer(jsonBody))
+			req1.Header.Set("Content-Type", "application/json")
+			router.ServeHTTP(w1, req1)
+			assert.Equal(t, http.StatusOK, w1.Code)
+
+			var createResponse stringFields
+			err := decodeData(w1.Body.Bytes(), &createResponse)
+			if err != nil {
+				t.Fatalf("Failed to parse create wallet response: %v", err)
+			}
+			walletAddress := createResponse["address"]
+
+			walletsMutex.Lock()
+			wallet := wallets[walletAddress]
+			walletsMutex.Unlock()
+			if wallet == nil {
+				t.Fatalf("Wallet %s should be stored", walletAddress)
+			}
+			curve := walletCurves[curveName]
+			assert.Equal(t, curveName, wallet.Curve)
+			assert.Equal(t, curve.Params().Name, wallet.PubKey.Curve.Params().Name)
+			assert.True(t, curve.IsOnCurve(wallet.PubKey.X, wallet.PubKey.Y), "Public key should be on the wallet curve")
+			assert.Equal(t, walletAddress, deriveAddress(wallet.PubKey))
+
+			digest := crypto.Keccak256([]byte("curve"))
+			requestBody := signDataRequest{
+				Data:   "0x" + hex.EncodeToString(digest),
+				Wallet: walletAddress,
+				Hash:   hashNone,
+			}
+			jsonBody, _ = json.Marshal(requestBody)
+
+			w2 := httptest.NewRecorder()
+			req2, _ := http.NewRequest("POST", "/sign", bytes.NewBuffer(jsonBody))
+			req2.Header.Set("Content-Type", "application/json")
+			router.ServeHTTP(w2, req2)
+			assert.Equal(t, http.StatusOK, w2.Code)
+
+			var signResponse stringFields
+			err = decodeData(w2.Body.Bytes(), &signResponse)
+			if err != nil {
+				t.Fatalf("Failed to parse sign data response: %v", err)
+			}
+			signature, err := hex.DecodeString(signResponse["signature"])
+			assert.NoError(t, err)
+			r := new(big.Int).SetBytes(signature[:32])
+			s := new(big.Int).SetBytes(signature[32:])
+			assert.True(t, ecdsa.Verify(wallet.PubKey, digest, r, s), "Signature should verify against the wallet public key")
+
+			// The curve survives a round trip through the store
+			sw, err := newStoredWallet(wallet, nil)
+			assert.NoError(t, err)
+			payload, err := json.Marshal(sw)
+			assert.NoError(t, err)
+			var decoded storedWallet
+			assert.NoError(t, json.Unmarshal(payload, &decoded))
+			reloaded, err := decoded.toWallet(nil)
+			assert.NoError(t, err)
+			assert.Equal(t, curveName, reloaded.Curve)
+			assert.True(t, ecdsa.Verify(reloaded.PubKey, digest, r, s), "Signature should verify against the reloaded public key")
+		})
+	}
+}
+
+func TestCreateWalletUnknownCurve(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	router := gin.Default()
+	router.POST("/wallet", createWallet)
+
+	jsonBody, _ := json.Marshal(createWalletRequest{Parties: 2, Threshold: 1, Curve: "ed448"})
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/wallet", bytes.NewBuffer(jsonBody))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "unsupported curve")
+}
+
+func TestCurveByName(t *testing.T) {
+	name, curve, err := curveByName("")
+	assert.NoError(t, err)
+	assert.Equal(t, curveSecp256k1, name)
+	assert.Equal(t, tss.S256(), curve)
+
+	name, curve, err = curveByName("p256")
+	assert.NoError(t, err)
+	assert.Equal(t, curveP256, name)
+	assert.Equal(t, elliptic.P256(), curve)
+
+	_, _, err = curveByName("P-256")
+	assert.Error(t, err)
+}
+
+func TestWalletAddressMatchesEthereum(t *testing.T) {
+	key, err := crypto.GenerateKey()
+	assert.NoError(t, err)
+	assert.Equal(t, crypto.PubkeyToAddress(key.PublicKey).Hex(), deriveAddress(&key.PublicKey))
+	assert.Equal(t, crypto.FromECDSAPub(&key.PublicKey), pubKeyBytes(&key.PublicKey))
+}
+
+func TestCompressedPubKeyBytes(t *testing.T) {
+	key, err := crypto.GenerateKey()
+	assert.NoError(t, err)
+	compressed := compressedPubKeyBytes(&key.PublicKey)
+	assert.Len(t, compressed, 33)
+	assert.Equal(t, crypto.CompressPubkey(&key.PublicKey), compressed)
+
+	decompressed, err := crypto.DecompressPubkey(compressed)
+	if assert.NoError(t, err) {
+		assert.Equal(t, pubKeyBytes(&key.PublicKey), crypto.FromECDSAPub(decompressed), "compressed key should decompress to the same point")
+	}
+
+	p256Key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+	x, y := elliptic.UnmarshalCompressed(elliptic.P256(), compressedPubKeyBytes(&p256Key.PublicKey))
+	assert.Equal(t, p256Key.X, x)
+	assert.Equal(t, p256Key.Y, y)
+}
+
+func TestWalletPKIXPubKey(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	router := gin.Default()
+	router.GET("/wallet/:address", getWallet)
+
+	secp256k1Key, _ := crypto.GenerateKey()
+	secp256k1Wallet := addFakeWallet(deriveAddress(&secp256k1Key.PublicKey))
+	secp256k1Wallet.PubKey = &secp256k1Key.PublicKey
+	p256Key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	p256Wallet := addFakeWallet(deriveAddress(&p256Key.PublicKey))
+	p256Wallet.Curve, p256Wallet.PubKey = curveP256, &p256Key.PublicKey
+	eddsaWallet, err := walletService.CreateWallet(context.Background(), createWalletRequest{Parties: 2, Threshold: 1, Algorithm: algorithmEdDSA}, nil)
+	if err != nil {
+		t.Fatalf("Failed to create EdDSA wallet: %v", err)
+	}
+	t.Cleanup(func() {
+		walletsMutex.Lock()
+		delete(wallets, secp256k1Wallet.Address)
+		delete(wallets, p256Wallet.Address)
+		delete(wallets, eddsaWallet.Address)
+		walletsMutex.Unlock()
+	})
+
+	pubKey := func(wallet *Wallet) (any, []byte) {
+		t.Helper()
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/wallet/"+wallet.Address, nil)
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+		var info walletResponse
+		if err := decodeData(w.Body.Bytes(), &info); err != nil {
+			t.Fatalf("Failed to parse wallet response: %v", err)
+		}
+		block, rest := pem.Decode([]byte(info.PubKeyPEM))
+		if block == nil {
+			t.Fatalf("pubKeyPem should hold a PEM block: %q", info.PubKeyPEM)
+		}
+		assert.Empty(t, rest)
+		assert.Equal(t, "PUBLIC KEY", block.Type)
+		assert.Equal(t, "0x"+hex.EncodeToString(block.Bytes), info.PubKeyDER, "pubKeyDer should be the DER of the PEM block")
+		if wallet.Curve == curveSecp256k1 {
+			return nil, block.Bytes
+		}
+		parsed, err := x509.ParsePKIXPublicKey(block.Bytes)
+		assert.NoError(t, err)
+		return parsed, block.Bytes
+	}
+
+	parsed, _ := pubKey(p256Wallet)
+	assert.True(t, p256Key.PublicKey.Equal(parsed), "The P-256 key should decode to the wallet public key")
+	parsed, _ = pubKey(eddsaWallet)
+	assert.Equal(t, ed25519.PublicKey(ed25519PubKeyBytes(eddsaWallet.PubKey)), parsed)
+
+	// crypto/x509 doesn't parse secp256k1 keys, the structure is checked by hand
+	_, der := pubKey(secp256k1Wallet)
+	var info ecSubjectPublicKeyInfo
+	rest, err := asn1.Unmarshal(der, &info)
+	assert.NoError(t, err)
+	assert.Empty(t, rest)
+	assert.True(t, info.Algorithm.Algorithm.Equal(asn1.ObjectIdentifier{1, 2, 840, 10045, 2, 1}))
+	assert.True(t, info.Algorithm.NamedCurve.Equal(asn1.ObjectIdentifier{1, 3, 132, 0, 10}))
+	decoded, err := crypto.UnmarshalPubkey(info.PublicKey.Bytes)
+	if assert.NoError(t, err) {
+		assert.Equal(t, 0, secp256k1Key.X.Cmp(decoded.X))
+		assert.Equal(t, 0, secp256k1Key.Y.Cmp(decoded.Y))
+	}
+}
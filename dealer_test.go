@@ -0,0 +1,253 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"math/big"
+	"os"
+	"sync"
+	"testing"
+
+	tsscrypto "github.com/bnb-chain/tss-lib/crypto"
+	"github.com/bnb-chain/tss-lib/ecdsa/keygen"
+	"github.com/bnb-chain/tss-lib/tss"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/stretchr/testify/assert"
+)
+
+// seededReader is a deterministic stream of bytes standing in for
+// crypto/rand, block i is the SHA-256 of the seed and i. Like crypto/rand it
+// can be read from several goroutines.
+type seededReader struct {
+	mu      sync.Mutex
+	seed    []byte
+	counter uint64
+	pending []byte
+}
+
+// newSeededReader creates a reader whose output only depends on seed
+func newSeededReader(seed string) *seededReader {
+	return &seededReader{seed: []byte(seed)}
+}
+
+func (r *seededReader) Read(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for n := 0; n < len(p); {
+		if len(r.pending) == 0 {
+			block := sha256.New()
+			block.Write(r.seed)
+			binary.Write(block, binary.BigEndian, r.counter)
+			r.pending = block.Sum(nil)
+			r.counter++
+		}
+		copied := copy(p[n:], r.pending)
+		r.pending = r.pending[copied:]
+		n += copied
+	}
+	return len(p), nil
+}
+
+var (
+	fixedPreParamsOnce    sync.Once
+	fixedPreParamsPayload []byte
+	fixedPreParamsErr     error
+)
+
+// fixedPreParams returns the pre-parameters of testdata/preparams.json, taken
+// from the test fixtures of tss-lib, so tests never look for safe primes.
+// Every call decodes new copies: wiping the key shares of a wallet wipes its
+// pre-parameters too, which must not reach the wallets of other tests.
+func fixedPreParams(t *testing.T) []keygen.LocalPreParams {
+	t.Helper()
+	fixedPreParamsOnce.Do(func() {
+		fixedPreParamsPayload, fixedPreParamsErr = os.ReadFile("testdata/preparams.json")
+	})
+	var sets []keygen.LocalPreParams
+	err := fixedPreParamsErr
+	if err == nil {
+		err = json.Unmarshal(fixedPreParamsPayload, &sets)
+	}
+	if err != nil {
+		t.Fatalf("Failed to load fixed pre-parameters: %v", err)
+	}
+	return sets
+}
+
+// useFixedPreParams fills the pool of pre-parameters with count sets of
+// fixedPreParams, so that the new parties of a resharing take them instead of
+// looking for safe primes. A keygen run afterwards takes them too.
+func useFixedPreParams(t *testing.T, count int) {
+	t.Helper()
+	pool := newPreParamsPool(count)
+	for _, preParams := range fixedPreParams(t)[:count] {
+		pool.add(&preParams)
+	}
+	previous := keygenPreParams
+	keygenPreParams = pool
+	t.Cleanup(func() { keygenPreParams = previous })
+}
+
+// keygenDealer deals the shares of a key drawn from a seeded reader to the
+// parties of a keygen, as a trusted dealer would, instead of running the
+// ceremony. tss-lib draws its secrets from crypto/rand, a real keygen can't
+// be made reproducible.
+type keygenDealer struct {
+	mu        sync.Mutex
+	rand      io.Reader
+	preParams []keygen.LocalPreParams
+	// saves holds the save data dealt to the parties of each ceremony, by the
+	// key of the first party
+	saves map[string]map[string]keygen.LocalPartySaveData
+}
+
+// deal returns the save data of the party of params, dealing the shares of
+// every party of its ceremony the first time one of them asks
+func (d *keygenDealer) deal(params *tss.Parameters) keygen.LocalPartySaveData {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	partyIDs := params.Parties().IDs()
+	ceremony := string(partyIDs[0].Key)
+	if saves, exists := d.saves[ceremony]; exists {
+		return saves[params.PartyID().Id]
+	}
+
+	// Shares are the points of a random polynomial of degree threshold whose
+	// constant term is the private key
+	curve := params.EC()
+	order := curve.Params().N
+	coefficients := make([]*big.Int, params.Threshold()+1)
+	for i := range coefficients {
+		coefficient, err := rand.Int(d.rand, order)
+		if err != nil {
+			panic(err)
+		}
+		coefficients[i] = coefficient
+	}
+	shares := make([]*big.Int, len(partyIDs))
+	for j, partyID := range partyIDs {
+		x, share := new(big.Int).SetBytes(partyID.Key), new(big.Int)
+		for i := len(coefficients) - 1; i >= 0; i-- {
+			share.Mul(share, x).Add(share, coefficients[i]).Mod(share, order)
+		}
+		shares[j] = share
+	}
+
+	public := keygen.NewLocalPartySaveData(len(partyIDs))
+	public.Ks = partyIDs.Keys()
+	public.ECDSAPub = tsscrypto.ScalarBaseMult(curve, coefficients[0])
+	for j := range partyIDs {
+		preParams := d.preParams[j%len(d.preParams)]
+		public.BigXj[j] = tsscrypto.ScalarBaseMult(curve, shares[j])
+		public.NTildej[j], public.H1j[j], public.H2j[j] = preParams.NTildei, preParams.H1i, preParams.H2i
+		public.PaillierPKs[j] = &preParams.PaillierSK.PublicKey
+	}
+	saves := make(map[string]keygen.LocalPartySaveData, len(partyIDs))
+	for j, partyID := range partyIDs {
+		save := public
+		save.LocalPreParams = d.preParams[j%len(d.preParams)]
+		save.Xi, save.ShareID = shares[j], public.Ks[j]
+		saves[partyID.Id] = save
+	}
+	d.saves[ceremony] = saves
+	return saves[params.PartyID().Id]
+}
+
+// dealtKeygenParty is a keygen party that outputs the save data dealt to it
+// as soon as it starts
+type dealtKeygenParty struct {
+	tss.Party
+	save keygen.LocalPartySaveData
+	end  chan<- keygen.LocalPartySaveData
+}
+
+func (p *dealtKeygenParty) Start() *tss.Error {
+	p.end <- p.save
+	return nil
+}
+
+// useDeterministicKeygen makes the keygens of the rest of the test deal the
+// wallets derived from seed: the same seed always creates the same party IDs
+// and key shares, in well under a second
+func useDeterministicKeygen(t *testing.T, seed string) {
+	t.Helper()
+	dealer := &keygenDealer{
+		rand:      newSeededReader(seed + "/shares"),
+		preParams: fixedPreParams(t),
+		saves:     make(map[string]map[string]keygen.LocalPartySaveData),
+	}
+	previousParty, previousEntropy := newKeygenParty, entropySource
+	newKeygenParty = func(params *tss.Parameters, out chan<- tss.Message, end chan<- keygen.LocalPartySaveData, preParams ...keygen.LocalPreParams) tss.Party {
+		return &dealtKeygenParty{Party: &routedParty{id: params.PartyID()}, save: dealer.deal(params), end: end}
+	}
+	entropySource = newSeededReader(seed + "/party-ids")
+	t.Cleanup(func() { newKeygenParty, entropySource = previousParty, previousEntropy })
+}
+
+func TestSeededReader(t *testing.T) {
+	first, second := make([]byte, 100), make([]byte, 100)
+	_, _ = io.ReadFull(newSeededReader("seed"), first)
+	reader := newSeededReader("seed")
+	_, _ = io.ReadFull(reader, second[:7])
+	_, _ = io.ReadFull(reader, second[7:])
+	assert.Equal(t, first, second, "The stream should not depend on how it is read")
+
+	other := make([]byte, 100)
+	_, _ = io.ReadFull(newSeededReader("other seed"), other)
+	assert.NotEqual(t, first, other)
+}
+
+func TestDeterministicKeygen(t *testing.T) {
+	request := createWalletRequest{Parties: 3, Threshold: 1}
+	create := func(seed string) *Wallet {
+		t.Helper()
+		useDeterministicKeygen(t, seed)
+		wallet, err := walletService.CreateWallet(context.Background(), request, nil)
+		if err != nil {
+			t.Fatalf("Failed to create wallet: %v", err)
+		}
+		walletsMutex.Lock()
+		delete(wallets, wallet.Address)
+		walletsMutex.Unlock()
+		return wallet
+	}
+
+	first := create("seed")
+	second := create("seed")
+	assert.Equal(t, first.Address, second.Address, "The same seed should create the same wallet")
+	assert.Equal(t, first.PartyIDs.Keys(), second.PartyIDs.Keys())
+	for id, save := range first.SaveData {
+		assert.Equal(t, save.Xi, second.SaveData[id].Xi, "Party %s should get the same share", id)
+	}
+	assert.NotEqual(t, first.Address, create("other seed").Address, "Another seed should create another wallet")
+
+	// The dealt shares are a valid sharing of the key: a real signing
+	// ceremony of any quorum produces a signature of the wallet
+	walletsMutex.Lock()
+	wallets[first.Address] = first
+	walletsMutex.Unlock()
+	t.Cleanup(func() {
+		walletsMutex.Lock()
+		delete(wallets, first.Address)
+		walletsMutex.Unlock()
+	})
+	reconstructed, err := reconstructPrivateKey(first, first.PartyIDs[:2])
+	assert.NoError(t, err)
+	assert.Equal(t, first.Address, crypto.PubkeyToAddress(reconstructed.PublicKey).Hex())
+
+	for _, signers := range [][]string{nil, {"2", "0"}} {
+		digest := crypto.Keccak256([]byte("deterministic"))
+		signature, err := signDigest(first, signers, digest, nil)
+		if err != nil {
+			t.Fatalf("Failed to sign with %v: %v", signers, err)
+		}
+		sig := append(append(append([]byte{}, signature.R...), signature.S...), signature.SignatureRecovery[0])
+		pubKey, err := crypto.SigToPub(digest, sig)
+		assert.NoError(t, err)
+		assert.Equal(t, first.Address, crypto.PubkeyToAddress(*pubKey).Hex(), "Signers %v should sign for the wallet", signers)
+	}
+}
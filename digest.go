@@ -0,0 +1,120 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"slices"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/crypto"
+	"golang.org/x/crypto/blake2b"
+	"golang.org/x/crypto/sha3"
+)
+
+// Signing modes accepted by signData, raw is the default
+const (
+	modeRaw    = "raw"
+	modeEIP191 = "eip191"
+)
+
+// Hash modes accepted by signData, keccak256 is the default. Chains other
+// than Ethereum pre-hash with SHA-256, SHA3-256 or BLAKE2b-256.
+const (
+	hashNone       = "none"
+	hashKeccak256  = "keccak256"
+	hashSHA256     = "sha256"
+	hashSHA3256    = "sha3-256"
+	hashBlake2b256 = "blake2b-256"
+)
+
+// hashModes lists every hash mode, in the order they are advertised
+var hashModes = []string{hashKeccak256, hashSHA256, hashSHA3256, hashBlake2b256, hashNone}
+
+// Encodings of the data of a sign request, hex is the default
+const (
+	dataEncodingHex    = "hex"
+	dataEncodingBase64 = "base64"
+)
+
+// validateDataEncoding checks the encoding of the data of a sign request
+func validateDataEncoding(encoding string) error {
+	switch encoding {
+	case "", dataEncodingHex, dataEncodingBase64:
+		return nil
+	}
+	return fmt.Errorf("unsupported dataEncoding %q, expected %s or %s", encoding, dataEncodingHex, dataEncodingBase64)
+}
+
+// decodeSignData decodes the data of a sign request with a valid encoding.
+// Hex data may start with 0x, base64 data uses the standard alphabet.
+func decodeSignData(data, encoding string) ([]byte, error) {
+	if encoding == dataEncodingBase64 {
+		return base64.StdEncoding.DecodeString(data)
+	}
+	return decodeHexData(data)
+}
+
+// digestLen is the size in bytes of the order of every supported curve.
+// Longer digests would be truncated when signing and shorter ones are most
+// likely not a digest at all.
+const digestLen = 32
+
+// validateSigningMode checks the signing mode and hash of a sign request
+// before its wallet is looked up, what they make of the data depends on the
+// algorithm of the wallet
+func validateSigningMode(mode, hashMode string) error {
+	if mode != "" && mode != modeRaw && mode != modeEIP191 {
+		return fmt.Errorf("unsupported mode %q", mode)
+	}
+	if hashMode != "" && !slices.Contains(hashModes, hashMode) {
+		return fmt.Errorf("unsupported hash %q", hashMode)
+	}
+	if mode == modeEIP191 && hashMode != "" && hashMode != hashKeccak256 {
+		return fmt.Errorf("%s messages are always hashed with %s", modeEIP191, hashKeccak256)
+	}
+	return nil
+}
+
+// messageDigest hashes data according to the hash mode and returns the value
+// that is actually signed. With hashNone the data is signed as is, so it must
+// be exactly as long as the curve order.
+func messageDigest(data []byte, hashMode string) ([]byte, error) {
+	switch hashMode {
+	case "", hashKeccak256:
+		return crypto.Keccak256(data), nil
+	case hashSHA256:
+		digest := sha256.Sum256(data)
+		return digest[:], nil
+	case hashSHA3256:
+		digest := sha3.Sum256(data)
+		return digest[:], nil
+	case hashBlake2b256:
+		digest := blake2b.Sum256(data)
+		return digest[:], nil
+	case hashNone:
+		if len(data) != digestLen {
+			return nil, fmt.Errorf("data must be a %d byte digest when hash is %s, got %d bytes", digestLen, hashNone, len(data))
+		}
+		return data, nil
+	default:
+		return nil, fmt.Errorf("unsupported hash %q", hashMode)
+	}
+}
+
+// signingDigest returns the value to sign for the given signing mode. In
+// eip191 mode the data is prefixed with "\x19Ethereum Signed Message:\n" and
+// its length before being hashed with keccak256, matching personal_sign.
+func signingDigest(data []byte, mode, hashMode string) ([]byte, error) {
+	switch mode {
+	case "", modeRaw:
+		return messageDigest(data, hashMode)
+	case modeEIP191:
+		if hashMode != "" && hashMode != hashKeccak256 {
+			return nil, fmt.Errorf("%s messages are always hashed with %s", modeEIP191, hashKeccak256)
+		}
+		return accounts.TextHash(data), nil
+	default:
+		return nil, fmt.Errorf("unsupported mode %q", mode)
+	}
+}
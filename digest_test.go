@@ -0,0 +1,103 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMessageDigest(t *testing.T) {
+	data := []byte("test")
+
+	digest, err := messageDigest(data, "")
+	assert.NoError(t, err)
+	assert.Equal(t, crypto.Keccak256(data), digest, "keccak256 should be the default")
+
+	digest, err = messageDigest(data, hashKeccak256)
+	assert.NoError(t, err)
+	assert.Equal(t, crypto.Keccak256(data), digest)
+
+	digest, err = messageDigest(data, hashSHA256)
+	assert.NoError(t, err)
+	expected := sha256.Sum256(data)
+	assert.Equal(t, expected[:], digest)
+
+	// Known answers for "abc" keep SHA3-256 apart from keccak256, which uses
+	// another padding, and BLAKE2b-256 apart from a truncated BLAKE2b-512
+	digest, err = messageDigest([]byte("abc"), hashSHA3256)
+	assert.NoError(t, err)
+	assert.Equal(t, "3a985da74fe225b2045c172d6bd390bd855f086e3e9d525b46bfe24511431532", hex.EncodeToString(digest))
+	digest, err = messageDigest([]byte("abc"), hashBlake2b256)
+	assert.NoError(t, err)
+	assert.Equal(t, "bddd813c634239723171ef3fee98579b94964e3bb1cb3e427262c8c068d52319", hex.EncodeToString(digest))
+
+	raw := crypto.Keccak256(data)
+	digest, err = messageDigest(raw, hashNone)
+	assert.NoError(t, err)
+	assert.Equal(t, raw, digest)
+}
+
+func TestMessageDigestHashNoneLength(t *testing.T) {
+	tests := []struct {
+		name  string
+		size  int
+		valid bool
+	}{
+		{"empty", 0, false},
+		{"too short", 31, false},
+		{"exact", 32, true},
+		{"too long", 33, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := messageDigest(bytes.Repeat([]byte{0xff}, tt.size), hashNone)
+			if tt.valid {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorContains(t, err, "32 byte digest")
+			}
+		})
+	}
+}
+
+func TestMessageDigestInvalid(t *testing.T) {
+	_, err := messageDigest(bytes.Repeat([]byte{0xff}, 33), hashNone)
+	assert.Error(t, err, "Raw data longer than the curve order should be rejected")
+
+	_, err = messageDigest([]byte("test"), "md5")
+	assert.Error(t, err, "Unknown hash modes should be rejected")
+}
+
+func TestSigningDigestEIP191(t *testing.T) {
+	// personal_sign hash of "hello"
+	digest, err := signingDigest([]byte("hello"), modeEIP191, "")
+	assert.NoError(t, err)
+	assert.Equal(t, "50b2c43fd39106bafbba0da34fc430e1f91e3c96ea2acee2bc34119f92b37750", hex.EncodeToString(digest))
+
+	// Known personal_sign vector for "Some data"
+	digest, err = signingDigest([]byte("Some data"), modeEIP191, hashKeccak256)
+	assert.NoError(t, err)
+	signature := hexutil.MustDecode("0xb91467e570a6466aa9e9876cbcd013baba02900b8979d43fe208a4a4f339f5fd6007e74cd82e037b800186422fc2da167c747ef045e5d18a5f5d4300f8e1a0291c")
+	signature[64] -= 27
+	pubKey, err := crypto.SigToPub(digest, signature)
+	assert.NoError(t, err)
+	assert.Equal(t, "0x2c7536E3605D9C16a7a3D7b1898e529396a65c23", crypto.PubkeyToAddress(*pubKey).Hex())
+
+	// Raw mode keeps using the hash mode
+	digest, err = signingDigest([]byte("hello"), modeRaw, hashKeccak256)
+	assert.NoError(t, err)
+	assert.Equal(t, crypto.Keccak256([]byte("hello")), digest)
+}
+
+func TestSigningDigestInvalid(t *testing.T) {
+	_, err := signingDigest([]byte("hello"), modeEIP191, hashSHA256)
+	assert.Error(t, err, "eip191 only supports keccak256")
+
+	_, err = signingDigest([]byte("hello"), "eip712", "")
+	assert.Error(t, err, "Unknown modes should be rejected")
+}
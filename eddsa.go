@@ -0,0 +1,266 @@
+package main
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"errors"
+	"fmt"
+	"math/big"
+	"slices"
+	"time"
+
+	"github.com/bnb-chain/tss-lib/common"
+	tsscrypto "github.com/bnb-chain/tss-lib/crypto"
+	eddsakeygen "github.com/bnb-chain/tss-lib/eddsa/keygen"
+	eddsasigning "github.com/bnb-chain/tss-lib/eddsa/signing"
+	"github.com/bnb-chain/tss-lib/tss"
+)
+
+// Signing algorithms a wallet can be created for, ecdsa is the default.
+// EdDSA wallets sign with Ed25519, as used by Solana and Cardano.
+const (
+	algorithmECDSA = "ecdsa"
+	algorithmEdDSA = "eddsa"
+)
+
+// curveEd25519 is the only curve of EdDSA wallets
+const curveEd25519 = tss.Ed25519
+
+// errEdDSALeadingZero is returned when an EdDSA wallet is asked to sign data
+// starting with a zero byte. tss-lib handles messages as integers and would
+// drop the byte, producing a signature over a different message.
+var errEdDSALeadingZero = errors.New("eddsa wallets can't sign data starting with a zero byte")
+
+// resolveAlgorithm checks the algorithm and curve name of a wallet creation
+// and returns them along with the curve. An empty algorithm selects ecdsa and
+// an empty curve the default one of the algorithm.
+func resolveAlgorithm(algorithm, name string) (string, tss.CurveName, elliptic.Curve, error) {
+	switch algorithm {
+	case "", algorithmECDSA:
+		curveName, curve, err := curveByName(name)
+		return algorithmECDSA, curveName, curve, err
+	case algorithmEdDSA:
+		if name != "" && tss.CurveName(name) != curveEd25519 {
+			return "", "", nil, fmt.Errorf("eddsa wallets require the %s curve", curveEd25519)
+		}
+		return algorithmEdDSA, curveEd25519, tss.Edwards(), nil
+	default:
+		return "", "", nil, fmt.Errorf("unsupported algorithm %q", algorithm)
+	}
+}
+
+// requireECDSA rejects operations that only ECDSA wallets support
+func requireECDSA(wallet *Wallet, operation string) error {
+	if wallet.Algorithm == algorithmEdDSA {
+		return invalidRequest(fmt.Errorf("%s is not supported for eddsa wallets", operation))
+	}
+	return nil
+}
+
+// signedMessage returns the message a signature of the algorithm covers for
+// data: the digest of the signing mode and hash for ECDSA, and the data itself
+// for EdDSA as Ed25519 hashes the message it signs. Only the raw mode and no
+// hash, or hash none, apply to EdDSA.
+func signedMessage(algorithm string, data []byte, mode, hashMode string) ([]byte, error) {
+	if algorithm != algorithmEdDSA {
+		return signingDigest(data, mode, hashMode)
+	}
+	if mode != "" && mode != modeRaw {
+		return nil, fmt.Errorf("eddsa wallets only sign in %s mode", modeRaw)
+	}
+	if hashMode != "" && hashMode != hashNone {
+		return nil, fmt.Errorf("eddsa wallets sign the data itself, hash must be %s", hashNone)
+	}
+	return data, nil
+}
+
+// walletMessage returns the message the wallet signs for data, see
+// signedMessage. EdDSA data starting with a zero byte is rejected before any
+// ceremony runs, see errEdDSALeadingZero.
+func walletMessage(wallet *Wallet, data []byte, mode, hashMode string) ([]byte, error) {
+	if wallet.Algorithm == algorithmEdDSA && len(data) > 0 && data[0] == 0 {
+		return nil, errEdDSALeadingZero
+	}
+	return signedMessage(wallet.Algorithm, data, mode, hashMode)
+}
+
+// validateWalletEncoding rejects the signature encodings a wallet can't
+// produce, EdDSA signatures only have the raw one
+func validateWalletEncoding(wallet *Wallet, encoding string) error {
+	if wallet.Algorithm == algorithmEdDSA && encoding != "" && encoding != encodingRaw {
+		return invalidRequest(errors.New("eddsa signatures only have the raw encoding"))
+	}
+	return nil
+}
+
+// ed25519PubKeyBytes returns the 32 byte Ed25519 encoding of a public key,
+// Y in little endian with the parity of X in the top bit
+func ed25519PubKeyBytes(pubKey *ecdsa.PublicKey) []byte {
+	encoded := make([]byte, ed25519.PublicKeySize)
+	pubKey.Y.FillBytes(encoded)
+	slices.Reverse(encoded)
+	encoded[len(encoded)-1] |= byte(pubKey.X.Bit(0)) << 7
+	return encoded
+}
+
+// eddsaKeygenResult holds the result of an EdDSA key generation for a party
+type eddsaKeygenResult struct {
+	PartyID *tss.PartyID
+	Save    eddsakeygen.LocalPartySaveData
+}
+
+// runEdDSAKeygen runs an EdDSA keygen ceremony between the given parties and
+// returns the resulting wallet. It works like runKeygen, without the Paillier
+// keys ECDSA needs, so it completes in about a second.
+func runEdDSAKeygen(ctx context.Context, partyIDs tss.SortedPartyIDs, threshold int, onRound func(round int)) (wallet *Wallet, err error) {
+	start := time.Now()
+	defer func() {
+		if err != nil {
+			failuresTotal.WithLabelValues(operationKeygen).Inc()
+			return
+		}
+		keygenDurationSeconds.Observe(time.Since(start).Seconds())
+	}()
+
+	parties := len(partyIDs)
+	curve := tss.Edwards()
+	peerCtx := tss.NewPeerContext(partyIDs)
+	cer, err := newCeremony(ctx, parties, keygenTimeout)
+	if err != nil {
+		return nil, err
+	}
+	defer cer.close()
+	resultCh := make(chan eddsaKeygenResult, parties)
+
+	partiesList := make([]tss.Party, parties)
+	for i, partyID := range partyIDs {
+		params := tss.NewParameters(curve, peerCtx, partyID, parties, threshold)
+		endCh := make(chan eddsakeygen.LocalPartySaveData, 1)
+		party := eddsakeygen.NewLocalParty(params, cer.outChs[i], endCh)
+		partiesList[i] = party
+
+		cer.run(func() {
+			if err := party.Start(); err != nil {
+				cer.fail(err)
+				return
+			}
+			select {
+			case save := <-endCh:
+				resultCh <- eddsaKeygenResult{PartyID: partyID, Save: save}
+			case <-cer.ctx.Done():
+			}
+		})
+	}
+
+	router := newPartyRouter(partiesList, cer.deliver)
+	progress := newRoundProgress(algorithmEdDSA, operationKeygen, parties, onRound)
+	defer progress.logTimings()
+
+	saves := make(map[string]*eddsakeygen.LocalPartySaveData)
+	var pubKey *tsscrypto.ECPoint
+	for {
+		select {
+		case <-cer.ctx.Done():
+			return nil, cer.err()
+		case err := <-cer.errCh:
+			return nil, err
+		case msg := <-cer.messages:
+			if err := router.Route(msg); err != nil {
+				return nil, err
+			}
+			progress.observe(msg)
+		case result := <-resultCh:
+			saves[result.PartyID.Id] = &result.Save
+			if pubKey == nil {
+				pubKey = result.Save.EDDSAPub
+			}
+			if len(saves) == parties {
+				pubKeyECDSA := &ecdsa.PublicKey{Curve: curve, X: pubKey.X(), Y: pubKey.Y()}
+				return &Wallet{
+					Address:       deriveAddress(pubKeyECDSA),
+					PubKey:        pubKeyECDSA,
+					Algorithm:     algorithmEdDSA,
+					EdDSASaveData: saves,
+					PartyIDs:      partyIDs,
+					Parties:       parties,
+					Threshold:     threshold,
+					Curve:         curveEd25519,
+				}, nil
+			}
+		}
+	}
+}
+
+// signEdDSA runs an EdDSA signing ceremony over the message, as returned by
+// walletMessage, between the parties of the quorum, and returns the first valid Ed25519 signature
+func signEdDSA(wallet *Wallet, partyIDs tss.SortedPartyIDs, message []byte, onRound func(round int)) (*common.SignatureData, error) {
+	msg := new(big.Int).SetBytes(message)
+	peerCtx := tss.NewPeerContext(partyIDs)
+	numParties := len(partyIDs)
+
+	cer, err := newCeremony(context.Background(), numParties, signTimeout)
+	if err != nil {
+		return nil, err
+	}
+	defer cer.close()
+	if err := cer.useShares(wallet); err != nil {
+		return nil, err
+	}
+	endCh := make(chan common.SignatureData, numParties)
+
+	partiesList := make([]tss.Party, numParties)
+	for i, partyID := range partyIDs {
+		params := tss.NewParameters(tss.Edwards(), peerCtx, partyID, numParties, wallet.Threshold)
+		saveData, exists := wallet.EdDSASaveData[partyID.Id]
+		if !exists {
+			return nil, errors.New("SaveData for party not found")
+		}
+		partiesList[i] = eddsasigning.NewLocalParty(msg, params, *saveData, cer.outChs[i], endCh)
+	}
+	for _, party := range partiesList {
+		cer.run(func() {
+			if err := party.Start(); err != nil {
+				cer.fail(err)
+			}
+		})
+	}
+
+	router := newPartyRouter(partiesList, cer.deliver)
+	progress := newRoundProgress(algorithmEdDSA, operationSign, numParties, onRound)
+	defer progress.logTimings()
+	pubKey := ed25519.PublicKey(ed25519PubKeyBytes(wallet.PubKey))
+
+	signatures := receivePointers(cer, endCh)
+	invalid := 0
+	for {
+		select {
+		case <-cer.ctx.Done():
+			return nil, cer.err()
+		case err := <-cer.errCh:
+			return nil, err
+		case msg := <-cer.messages:
+			if err := router.Route(msg); err != nil {
+				return nil, err
+			}
+			progress.observe(msg)
+		case sigData := <-signatures:
+			if ed25519.Verify(pubKey, message, sigData.Signature) {
+				return sigData, nil
+			}
+			invalid++
+			if invalid == numParties {
+				return nil, errors.New("no party produced a valid signature")
+			}
+		}
+	}
+}
+
+// zeroEdDSASaveData overwrites the secret share of an EdDSA key share
+func zeroEdDSASaveData(save *eddsakeygen.LocalPartySaveData) {
+	if save.Xi != nil {
+		clear(save.Xi.Bits())
+		save.Xi.SetInt64(0)
+	}
+}
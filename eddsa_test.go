@@ -0,0 +1,166 @@
+package main
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/btcsuite/btcutil/base58"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCreateEdDSAWallet(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	router := gin.Default()
+	router.POST("/wallet", createWallet)
+	router.GET("/wallet/:address", getWallet)
+	router.POST("/sign", signData)
+	router.POST("/verify", verifyData)
+
+	jsonBody, _ := json.Marshal(createWalletRequest{Parties: 3, Threshold: 1, Algorithm: algorithmEdDSA})
+	w1 := httptest.NewRecorder()
+	req1, _ := http.NewRequest("POST", "/wallet", bytes.NewBuffer(jsonBody))
+	req1.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w1, req1)
+	assert.Equal(t, http.StatusOK, w1.Code)
+
+	var createResponse stringFields
+	if err := decodeData(w1.Body.Bytes(), &createResponse); err != nil {
+		t.Fatalf("Failed to parse create wallet response: %v", err)
+	}
+	walletAddress := createResponse["address"]
+	wallet, err := lookupWallet(walletAddress)
+	if err != nil {
+		t.Fatalf("Wallet %s should be stored: %v", walletAddress, err)
+	}
+	assert.Equal(t, algorithmEdDSA, wallet.Algorithm)
+	assert.Len(t, wallet.EdDSASaveData, 3)
+	assert.Empty(t, wallet.SaveData)
+	pubKey := ed25519.PublicKey(ed25519PubKeyBytes(wallet.PubKey))
+
+	w2 := httptest.NewRecorder()
+	req2, _ := http.NewRequest("GET", "/wallet/"+walletAddress, nil)
+	router.ServeHTTP(w2, req2)
+	assert.Equal(t, http.StatusOK, w2.Code)
+	var info walletResponse
+	if err := decodeData(w2.Body.Bytes(), &info); err != nil {
+		t.Fatalf("Failed to parse wallet response: %v", err)
+	}
+	assert.Equal(t, algorithmEdDSA, info.Algorithm)
+	assert.Equal(t, string(curveEd25519), info.Curve)
+	assert.Equal(t, "0x"+hex.EncodeToString(pubKey), info.PubKey)
+	assert.Equal(t, base58.Encode(pubKey), info.Addresses[addressSolana], "The Solana address is the base58 public key")
+
+	// Ed25519 signs the data itself, unhashed
+	data := "0x74657374" // "test" in hex
+	digest := []byte("test")
+	jsonBody, _ = json.Marshal(signDataRequest{Data: data, Wallet: walletAddress})
+	w3 := httptest.NewRecorder()
+	req3, _ := http.NewRequest("POST", "/sign", bytes.NewBuffer(jsonBody))
+	req3.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w3, req3)
+	assert.Equal(t, http.StatusOK, w3.Code)
+
+	var signResponse stringFields
+	if err := decodeData(w3.Body.Bytes(), &signResponse); err != nil {
+		t.Fatalf("Failed to parse sign data response: %v", err)
+	}
+	assert.Equal(t, hex.EncodeToString(digest), signResponse["digest"])
+	signature, err := hex.DecodeString(signResponse["signature"])
+	assert.NoError(t, err)
+	assert.Len(t, signature, ed25519.SignatureSize)
+	assert.True(t, ed25519.Verify(pubKey, digest, signature), "Signature should verify as a standard Ed25519 signature")
+
+	verifyBody, _ := json.Marshal(verifySignatureRequest{Wallet: walletAddress, Data: data, Signature: signResponse["signature"]})
+	w4 := httptest.NewRecorder()
+	req4, _ := http.NewRequest("POST", "/verify", bytes.NewBuffer(verifyBody))
+	req4.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w4, req4)
+	assert.Equal(t, http.StatusOK, w4.Code)
+	assert.Contains(t, w4.Body.String(), `"valid":true`)
+
+	// Data whose Keccak-256 starts with a zero byte signs like any other, it
+	// is not hashed
+	zeroDigestData := []byte("test 259")
+	assert.Equal(t, byte(0), crypto.Keccak256(zeroDigestData)[0])
+	result, err := walletService.Sign(signDataRequest{Data: "0x" + hex.EncodeToString(zeroDigestData), Wallet: walletAddress}, nil)
+	if assert.NoError(t, err) {
+		assert.True(t, ed25519.Verify(pubKey, zeroDigestData, result.Signature.Signature))
+	}
+
+	// tss-lib would drop a leading zero byte of the data, hashes and modes
+	// don't apply
+	for _, request := range []signDataRequest{
+		{Data: "0x00ff", Wallet: walletAddress},
+		{Data: data, Wallet: walletAddress, Hash: hashKeccak256},
+		{Data: data, Wallet: walletAddress, Mode: modeEIP191},
+		{Data: data, Wallet: walletAddress, Encoding: encodingDER},
+	} {
+		_, err = walletService.Sign(request, nil)
+		assert.ErrorIs(t, err, ErrInvalidRequest)
+	}
+
+	// The algorithm and shares survive a round trip through the store,
+	// encrypted or not
+	for _, sc := range []*shareCipher{nil, newShareCipher("eddsa passphrase")} {
+		sw, err := newStoredWallet(wallet, sc)
+		assert.NoError(t, err)
+		payload, err := json.Marshal(sw)
+		assert.NoError(t, err)
+		var decoded storedWallet
+		assert.NoError(t, json.Unmarshal(payload, &decoded))
+		reloaded, err := decoded.toWallet(sc)
+		if err != nil {
+			t.Fatalf("Failed to reload wallet: %v", err)
+		}
+		assert.Equal(t, algorithmEdDSA, reloaded.Algorithm)
+		assert.Equal(t, curveEd25519, reloaded.Curve)
+		assert.Len(t, reloaded.EdDSASaveData, 3)
+		assert.Equal(t, []byte(pubKey), ed25519PubKeyBytes(reloaded.PubKey))
+		assert.Equal(t, info.Addresses, reloaded.Addresses)
+	}
+}
+
+func TestCreateWalletInvalidAlgorithm(t *testing.T) {
+	tests := []struct {
+		name    string
+		request createWalletRequest
+	}{
+		{"unknown algorithm", createWalletRequest{Algorithm: "schnorr"}},
+		{"eddsa on secp256k1", createWalletRequest{Algorithm: algorithmEdDSA, Curve: string(curveSecp256k1)}},
+		{"ecdsa on ed25519", createWalletRequest{Algorithm: algorithmECDSA, Curve: string(curveEd25519)}},
+		{"solana address for ecdsa", createWalletRequest{AddressType: addressSolana}},
+		{"bitcoin address for eddsa", createWalletRequest{Algorithm: algorithmEdDSA, AddressType: addressBTCP2WPKH}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.request.Parties, tt.request.Threshold = 2, 1
+			_, err := newWalletSpec(tt.request)
+			assert.ErrorIs(t, err, ErrInvalidRequest)
+		})
+	}
+
+	spec, err := newWalletSpec(createWalletRequest{Parties: 2, Threshold: 1, Algorithm: algorithmEdDSA})
+	assert.NoError(t, err)
+	assert.Equal(t, curveEd25519, spec.curveName)
+	assert.Equal(t, addressSolana, spec.request.AddressType)
+}
+
+func TestEdDSAWalletUnsupportedOperations(t *testing.T) {
+	wallet := &Wallet{Algorithm: algorithmEdDSA}
+	err := requireECDSA(wallet, "resharing")
+	assert.ErrorIs(t, err, ErrInvalidRequest)
+	assert.EqualError(t, err, "resharing is not supported for eddsa wallets")
+	assert.NoError(t, requireECDSA(&Wallet{Algorithm: algorithmECDSA}, "resharing"))
+
+	_, err = walletMessage(wallet, []byte{0, 1}, "", hashNone)
+	assert.True(t, errors.Is(err, errEdDSALeadingZero))
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+// encryptionKeyEnv is the environment variable holding the passphrase used to
+// encrypt key shares at rest
+const encryptionKeyEnv = "WALLET_ENCRYPTION_KEY"
+
+// scrypt parameters recommended for interactive logins, plus the sizes of the
+// salt prepended to every encrypted payload and of the derived AES-256 key
+const (
+	scryptN      = 32768
+	scryptR      = 8
+	scryptP      = 1
+	saltLength   = 16
+	aesKeyLength = 32
+)
+
+// errDecryptionFailed is returned when a payload cannot be decrypted, either
+// because the passphrase is wrong or because the payload was tampered with
+var errDecryptionFailed = errors.New("failed to decrypt, wrong passphrase or corrupted data")
+
+// shareCipher encrypts key share material with AES-256-GCM using a key derived
+// from a passphrase. Every payload gets its own random salt and nonce.
+type shareCipher struct {
+	passphrase []byte
+}
+
+// newShareCipher creates a cipher for the given passphrase
+func newShareCipher(passphrase string) *shareCipher {
+	return &shareCipher{passphrase: []byte(passphrase)}
+}
+
+// aead derives the AES key for the given salt
+func (sc *shareCipher) aead(salt []byte) (cipher.AEAD, error) {
+	key, err := scrypt.Key(sc.passphrase, salt, scryptN, scryptR, scryptP, aesKeyLength)
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive encryption key: %w", err)
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// Encrypt returns salt || nonce || ciphertext
+func (sc *shareCipher) Encrypt(plaintext []byte) ([]byte, error) {
+	salt := make([]byte, saltLength)
+	if _, err := io.ReadFull(rand.Reader, salt); err != nil {
+		return nil, fmt.Errorf("failed to generate salt: %w", err)
+	}
+	gcm, err := sc.aead(salt)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+
+	payload := make([]byte, 0, len(salt)+len(nonce)+len(plaintext)+gcm.Overhead())
+	payload = append(payload, salt...)
+	payload = append(payload, nonce...)
+	return gcm.Seal(payload, nonce, plaintext, nil), nil
+}
+
+// Decrypt reverses Encrypt
+func (sc *shareCipher) Decrypt(payload []byte) ([]byte, error) {
+	if len(payload) < saltLength {
+		return nil, errDecryptionFailed
+	}
+	gcm, err := sc.aead(payload[:saltLength])
+	if err != nil {
+		return nil, err
+	}
+	payload = payload[saltLength:]
+	if len(payload) < gcm.NonceSize() {
+		return nil, errDecryptionFailed
+	}
+	nonce, ciphertext := payload[:gcm.NonceSize()], payload[gcm.NonceSize():]
+	plaintext, err := gcm.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return nil, errDecryptionFailed
+	}
+	return plaintext, nil
+}
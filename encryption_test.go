@@ -0,0 +1,46 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestShareCipherRoundTrip(t *testing.T) {
+	sc := newShareCipher("correct horse battery staple")
+	plaintext := []byte(`{"Xi": 1234}`)
+
+	payload, err := sc.Encrypt(plaintext)
+	assert.NoError(t, err)
+	assert.NotContains(t, string(payload), "Xi", "Payload should not contain the plaintext")
+
+	decrypted, err := sc.Decrypt(payload)
+	assert.NoError(t, err)
+	assert.Equal(t, plaintext, decrypted)
+
+	// A fresh salt and nonce are used on every call
+	other, err := sc.Encrypt(plaintext)
+	assert.NoError(t, err)
+	assert.NotEqual(t, payload, other)
+}
+
+func TestShareCipherWrongPassphrase(t *testing.T) {
+	payload, err := newShareCipher("right").Encrypt([]byte("secret share"))
+	assert.NoError(t, err)
+
+	_, err = newShareCipher("wrong").Decrypt(payload)
+	assert.ErrorIs(t, err, errDecryptionFailed)
+}
+
+func TestShareCipherTamperedPayload(t *testing.T) {
+	sc := newShareCipher("passphrase")
+	payload, err := sc.Encrypt([]byte("secret share"))
+	assert.NoError(t, err)
+
+	payload[len(payload)-1] ^= 0xff
+	_, err = sc.Decrypt(payload)
+	assert.ErrorIs(t, err, errDecryptionFailed)
+
+	_, err = sc.Decrypt(payload[:4])
+	assert.ErrorIs(t, err, errDecryptionFailed)
+}
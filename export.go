@@ -0,0 +1,50 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// exportPassphraseHeader carries the passphrase that encrypts exported key
+// shares, a header keeps it out of access logs
+const exportPassphraseHeader = "X-Export-Passphrase"
+
+// minExportPassphraseLength is the shortest passphrase accepted for exports
+const minExportPassphraseLength = 12
+
+// exportWallet returns a wallet with its key shares encrypted under the
+// passphrase given in the X-Export-Passphrase header, in the format accepted
+// by importWallet. Shares are never exported in plaintext and the request
+// must be confirmed with ?confirm=true.
+func exportWallet(c *gin.Context) {
+	if c.Query("confirm") != "true" {
+		respondError(c, http.StatusBadRequest, "exporting key shares must be confirmed with confirm=true")
+		return
+	}
+	passphrase := c.GetHeader(exportPassphraseHeader)
+	if len(passphrase) < minExportPassphraseLength {
+		respondError(c, http.StatusBadRequest, fmt.Sprintf("a passphrase of at least %d characters is required in the %s header", minExportPassphraseLength, exportPassphraseHeader))
+		return
+	}
+
+	wallet, err := lookupWallet(c.Param("address"))
+	if err != nil {
+		respondServiceError(c, err)
+		return
+	}
+
+	if err := wallet.holdShares(); err != nil {
+		respondServiceError(c, err)
+		return
+	}
+	sw, err := newStoredWallet(wallet, newShareCipher(passphrase))
+	wallet.releaseShares()
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "failed to export wallet")
+		return
+	}
+	c.Header("Cache-Control", "no-store")
+	respond(c, http.StatusOK, sw)
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExportWallet(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	useDeterministicKeygen(t, "export wallet")
+
+	router := newRouter(newAPIKeySet([]string{"key"}))
+	serve := func(method, path string, body []byte, header http.Header) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(method, path, bytes.NewBuffer(body))
+		req.Header = header
+		req.Header.Set("Content-Type", "application/json")
+		router.ServeHTTP(w, req)
+		return w
+	}
+	authorized := func() http.Header {
+		return http.Header{"Authorization": {"Bearer key"}}
+	}
+
+	jsonBody, _ := json.Marshal(createWalletRequest{Parties: 2, Threshold: 1})
+	w1 := serve("POST", "/wallet", jsonBody, authorized())
+	assert.Equal(t, http.StatusOK, w1.Code)
+
+	var createResponse stringFields
+	err := decodeData(w1.Body.Bytes(), &createResponse)
+	if err != nil {
+		t.Fatalf("Failed to parse create wallet response: %v", err)
+	}
+	walletAddress := createResponse["address"]
+	exportPath := "/wallet/" + walletAddress + "/export?confirm=true"
+	passphrase := "correct horse battery staple"
+
+	// Exports need an API key, a confirmation and a strong enough passphrase
+	header := http.Header{exportPassphraseHeader: {passphrase}}
+	assert.Equal(t, http.StatusUnauthorized, serve("GET", exportPath, nil, header).Code)
+	header = authorized()
+	header.Set(exportPassphraseHeader, passphrase)
+	assert.Equal(t, http.StatusBadRequest, serve("GET", "/wallet/"+walletAddress+"/export", nil, header).Code)
+	header = authorized()
+	header.Set(exportPassphraseHeader, "short")
+	assert.Equal(t, http.StatusBadRequest, serve("GET", exportPath, nil, header).Code)
+	assert.Equal(t, http.StatusBadRequest, serve("GET", exportPath, nil, authorized()).Code)
+	header = authorized()
+	header.Set(exportPassphraseHeader, passphrase)
+	assert.Equal(t, http.StatusNotFound, serve("GET", "/wallet/0x00000000000000000000000000000000000000DA/export?confirm=true", nil, header).Code)
+
+	w2 := serve("GET", exportPath, nil, header)
+	assert.Equal(t, http.StatusOK, w2.Code)
+	assert.Equal(t, "no-store", w2.Header().Get("Cache-Control"))
+	assert.NotContains(t, w2.Body.String(), "saveData\"", "Shares must not be exported in plaintext")
+	assert.NotContains(t, w2.Body.String(), "PaillierSK")
+
+	var exported importWalletRequest
+	err = decodeData(w2.Body.Bytes(), &exported)
+	if err != nil {
+		t.Fatalf("Failed to parse export response: %v", err)
+	}
+	assert.Nil(t, exported.SaveData)
+	assert.NotEmpty(t, exported.EncryptedSaveData)
+
+	// Import into a fresh instance
+	walletsMutex.Lock()
+	previousWallets := wallets
+	wallets = make(map[string]*Wallet)
+	walletsMutex.Unlock()
+	t.Cleanup(func() {
+		walletsMutex.Lock()
+		wallets = previousWallets
+		walletsMutex.Unlock()
+	})
+
+	exported.Passphrase = passphrase
+	importBody, _ := json.Marshal(exported)
+	w3 := serve("POST", "/wallet/import", importBody, authorized())
+	assert.Equal(t, http.StatusOK, w3.Code)
+
+	jsonBody, _ = json.Marshal(signDataRequest{Message: "export", Wallet: walletAddress})
+	w4 := serve("POST", "/sign", jsonBody, authorized())
+	assert.Equal(t, http.StatusOK, w4.Code)
+}
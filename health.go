@@ -0,0 +1,85 @@
+package main
+
+import (
+	"crypto/rand"
+	"errors"
+	"io"
+	"net/http"
+	"sync/atomic"
+
+	"github.com/gin-gonic/gin"
+)
+
+// entropySource is the randomness used to generate party keys, tests replace
+// it to simulate a broken source or to make party keys reproducible
+var entropySource io.Reader = rand.Reader
+
+// errEntropyUnavailable is returned when the entropy source can't be read.
+// The service can't create keys until it recovers, so the request is answered
+// with 503 instead of crashing the process.
+var errEntropyUnavailable = errors.New("entropy source unavailable")
+
+// ready is set once the persisted wallets have been loaded
+var ready atomic.Bool
+
+// healthCheck reports that the process is up
+func healthCheck(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
+
+// readinessCheck reports whether the service can take requests: wallets must
+// be loaded and the entropy source needed by keygen must be readable
+func readinessCheck(c *gin.Context) {
+	if !ready.Load() {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"status": "loading wallets"})
+		return
+	}
+	buf := make([]byte, 32)
+	if _, err := io.ReadFull(entropySource, buf); err != nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"status": "entropy source unavailable"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "ready"})
+}
+
+// Ceremonies in progress, including the ones waiting for a slot. Reshares
+// include refreshes.
+var activeKeygens, activeSigns, activeReshares atomic.Int64
+
+// trackActive counts an operation as active until the returned function is
+// called
+func trackActive(counter *atomic.Int64) func() {
+	counter.Add(1)
+	return func() { counter.Add(-1) }
+}
+
+// statusResponse represents the response body for the status endpoint
+type statusResponse struct {
+	Ready          bool  `json:"ready"`
+	ActiveKeygens  int64 `json:"activeKeygens"`
+	ActiveSigns    int64 `json:"activeSigns"`
+	ActiveReshares int64 `json:"activeReshares"`
+	// RunningCeremonies and QueuedCeremonies are the slots taken and awaited
+	// under --max-ceremonies, both are 0 when the limit is disabled
+	RunningCeremonies int `json:"runningCeremonies"`
+	QueuedCeremonies  int `json:"queuedCeremonies"`
+	Wallets           int `json:"wallets"`
+}
+
+// serviceStatus reports the load of the service: the ceremonies running or
+// waiting for a slot and the number of wallets
+func serviceStatus(c *gin.Context) {
+	walletsMutex.Lock()
+	count := len(wallets)
+	walletsMutex.Unlock()
+	running, queued := ceremonyLimit.load()
+	respond(c, http.StatusOK, statusResponse{
+		Ready:             ready.Load(),
+		ActiveKeygens:     activeKeygens.Load(),
+		ActiveSigns:       activeSigns.Load(),
+		ActiveReshares:    activeReshares.Load(),
+		RunningCeremonies: running,
+		QueuedCeremonies:  queued,
+		Wallets:           count,
+	})
+}
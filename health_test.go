@@ -0,0 +1,199 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/bnb-chain/tss-lib/common"
+	"github.com/bnb-chain/tss-lib/ecdsa/keygen"
+	"github.com/bnb-chain/tss-lib/tss"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+// failingReader is an entropy source that can't be read
+type failingReader struct{}
+
+func (failingReader) Read([]byte) (int, error) {
+	return 0, errors.New("read failed")
+}
+
+func TestHealthCheck(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	// Health checks don't need an API key
+	router := newRouter(newAPIKeySet([]string{"key"}))
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/health", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestReadinessCheck(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	router := newRouter(newAPIKeySet([]string{"key"}))
+	t.Cleanup(func() { ready.Store(false) })
+
+	checkReady := func(expected int) {
+		t.Helper()
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/ready", nil)
+		router.ServeHTTP(w, req)
+		assert.Equal(t, expected, w.Code)
+	}
+
+	// Not ready until the wallets are loaded
+	ready.Store(false)
+	checkReady(http.StatusServiceUnavailable)
+
+	ready.Store(true)
+	checkReady(http.StatusOK)
+
+	// Not ready when keygen can't get randomness
+	previous := entropySource
+	entropySource = failingReader{}
+	t.Cleanup(func() { entropySource = previous })
+	checkReady(http.StatusServiceUnavailable)
+}
+
+func TestCreateWalletEntropyFailure(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	router := gin.New()
+	router.POST("/wallet", createWallet)
+	router.POST("/wallet/preview", previewWallet)
+
+	previous := entropySource
+	entropySource = failingReader{}
+	t.Cleanup(func() { entropySource = previous })
+
+	walletsMutex.Lock()
+	walletCount := len(wallets)
+	walletsMutex.Unlock()
+
+	// Without the recovery middleware a panic would fail the test
+	for _, path := range []string{"/wallet", "/wallet/preview"} {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", path, nil)
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusServiceUnavailable, w.Code, path)
+
+		apiErr, err := decodeError(w.Body.Bytes())
+		assert.NoError(t, err)
+		assert.Equal(t, codeUnavailable, apiErr.Code)
+		assert.Contains(t, apiErr.Message, errEntropyUnavailable.Error())
+	}
+
+	walletsMutex.Lock()
+	defer walletsMutex.Unlock()
+	assert.Len(t, wallets, walletCount, "No wallet should be created")
+}
+
+// gatedParty is a party that only starts once its gate is closed
+type gatedParty struct {
+	tss.Party
+	gate <-chan struct{}
+}
+
+func (p *gatedParty) Start() *tss.Error {
+	<-p.gate
+	return p.Party.Start()
+}
+
+func TestServiceStatus(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	key, _ := crypto.GenerateKey()
+	address := deriveAddress(&key.PublicKey)
+	addFakeWallet(address).PubKey = &key.PublicKey
+	t.Cleanup(func() {
+		walletsMutex.Lock()
+		delete(wallets, address)
+		walletsMutex.Unlock()
+	})
+
+	// A single ceremony runs at once and the signing parties wait for the gate
+	gate := make(chan struct{})
+	previousLimit, previousParty := ceremonyLimit, newSigningParty
+	ceremonyLimit = newCeremonyLimiter(1, 4)
+	newSigningParty = func(msg *big.Int, params *tss.Parameters, save keygen.LocalPartySaveData, out chan<- tss.Message, end chan<- common.SignatureData) tss.Party {
+		signer := &instantSigningParty{Party: &routedParty{id: params.PartyID()}, key: key, msg: msg, end: end, running: new(atomic.Int32), maxRunning: new(atomic.Int32)}
+		return &gatedParty{Party: signer, gate: gate}
+	}
+	t.Cleanup(func() { ceremonyLimit, newSigningParty = previousLimit, previousParty })
+
+	router := gin.Default()
+	router.POST("/sign", signData)
+	router.GET("/status", serviceStatus)
+
+	status := func() statusResponse {
+		t.Helper()
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/status", nil)
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+		var response statusResponse
+		if err := decodeData(w.Body.Bytes(), &response); err != nil {
+			t.Fatalf("Failed to parse status response: %v", err)
+		}
+		return response
+	}
+
+	idle := status()
+	assert.Zero(t, idle.ActiveSigns)
+	assert.Zero(t, idle.QueuedCeremonies)
+	walletsMutex.Lock()
+	assert.Equal(t, len(wallets), idle.Wallets)
+	walletsMutex.Unlock()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			jsonBody, _ := json.Marshal(signDataRequest{Message: "status", Wallet: address})
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("POST", "/sign", bytes.NewBuffer(jsonBody))
+			req.Header.Set("Content-Type", "application/json")
+			router.ServeHTTP(w, req)
+			assert.Equal(t, http.StatusOK, w.Code)
+		}()
+	}
+
+	// One signing ceremony holds the slot while the other waits for it
+	var busy statusResponse
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		if busy = status(); busy.ActiveSigns == 2 && busy.QueuedCeremonies == 1 {
+			break
+		}
+	}
+	assert.Equal(t, int64(2), busy.ActiveSigns, "Both in-flight signs should be active")
+	assert.Equal(t, 1, busy.RunningCeremonies)
+	assert.Equal(t, 1, busy.QueuedCeremonies)
+	assert.Zero(t, busy.ActiveKeygens)
+
+	close(gate)
+	wg.Wait()
+	// The slot of a ceremony is only released once its parties returned,
+	// which may be after the response
+	var done statusResponse
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		if done = status(); done.RunningCeremonies == 0 {
+			break
+		}
+	}
+	assert.Zero(t, done.ActiveSigns, "Completed signs should no longer be active")
+	assert.Zero(t, done.RunningCeremonies)
+	assert.Zero(t, done.QueuedCeremonies)
+}
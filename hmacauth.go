@@ -0,0 +1,217 @@
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// hmacScheme is the Authorization scheme of signed requests:
+// "Authorization: HMAC <key id>:<hex signature>"
+const hmacScheme = "HMAC"
+
+// timestampHeader holds the Unix time in seconds at which a signed request
+// was signed
+const timestampHeader = "X-Timestamp"
+
+// hmacMaxSkew is how far the timestamp of a signed request may be from the
+// time of the service, in either direction
+var hmacMaxSkew = 5 * time.Minute
+
+// contentDigestHeader holds the hex SHA-256 of the body of a signed request.
+// The signature covers the digest rather than the body, so that the body is
+// checked as it is read and never has to be held in memory for it.
+const contentDigestHeader = "X-Content-SHA256"
+
+// errBodyDigestMismatch ends the body of a signed request whose SHA-256 is not
+// the signed one, in place of io.EOF
+var errBodyDigestMismatch = errors.New("request body does not match " + contentDigestHeader)
+
+// errRequestSignatureUsed ends the body of a signed request whose signature
+// was already used, in place of io.EOF
+var errRequestSignatureUsed = errors.New("request signature already used")
+
+// hmacSignatures remembers the signatures of the requests of every HMAC key
+// until their timestamp leaves the allowed window, so that a captured request
+// can't be replayed
+var hmacSignatures = newNonceTracker()
+
+// hmacKeySet holds the shared secrets accepted to sign requests, by key ID
+type hmacKeySet struct {
+	secrets map[string][]byte
+}
+
+// hmacKeys holds the secrets of signed requests, nil when requests can only
+// be authenticated with an API key
+var hmacKeys *hmacKeySet
+
+// parseHMACKeys reads "<key id>:<secret>" lines, as returned by
+// readKeysFile. Secrets must be at least minAPIKeyLength characters long.
+func parseHMACKeys(lines []string) (*hmacKeySet, error) {
+	set := &hmacKeySet{secrets: make(map[string][]byte, len(lines))}
+	for i, line := range lines {
+		id, secret, found := strings.Cut(line, ":")
+		id, secret = strings.TrimSpace(id), strings.TrimSpace(secret)
+		if !found || id == "" {
+			return nil, fmt.Errorf("line %d is not <key id>:<secret>", i+1)
+		}
+		if len(secret) < minAPIKeyLength {
+			return nil, fmt.Errorf("secret of HMAC key %s must be at least %d characters long", id, minAPIKeyLength)
+		}
+		if _, exists := set.secrets[id]; exists {
+			return nil, fmt.Errorf("HMAC key %s is listed more than once", id)
+		}
+		set.secrets[id] = []byte(secret)
+	}
+	return set, nil
+}
+
+// len returns the number of keys in the set
+func (s *hmacKeySet) len() int {
+	if s == nil {
+		return 0
+	}
+	return len(s.secrets)
+}
+
+// requestSignature returns the hex HMAC-SHA256 of a request, computed over
+// its timestamp, method, path with the query string and the hex SHA-256 of
+// its body, one per line
+func requestSignature(secret []byte, timestamp, method, uri, bodyDigest string) string {
+	mac := hmac.New(sha256.New, secret)
+	fmt.Fprintf(mac, "%s\n%s\n%s\n%s", timestamp, method, uri, bodyDigest)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// digestReader hashes a body as it is read and checks it against the signed
+// digest once the body is over. The body is then only over if verified, run
+// once on a matching body, returns no error.
+type digestReader struct {
+	body     io.ReadCloser
+	hash     hash.Hash
+	expected []byte
+	verified func() error
+	// end is the error ending the body once it is over
+	end error
+}
+
+func (r *digestReader) Read(p []byte) (int, error) {
+	if r.end != nil {
+		return 0, r.end
+	}
+	n, err := r.body.Read(p)
+	r.hash.Write(p[:n])
+	if err != io.EOF {
+		return n, err
+	}
+	r.end = io.EOF
+	if !bytes.Equal(r.hash.Sum(nil), r.expected) {
+		r.end = errBodyDigestMismatch
+	} else if err := r.verified(); err != nil {
+		r.end = err
+	}
+	return n, r.end
+}
+
+func (r *digestReader) Close() error {
+	return r.body.Close()
+}
+
+// requestAuth authenticates requests with one of the API keys or, when HMAC
+// keys are configured, with a request signed by one of their secrets
+func requestAuth(keys *apiKeySet) gin.HandlerFunc {
+	apiKey := apiKeyAuth(keys)
+	return func(c *gin.Context) {
+		if hmacKeys.len() > 0 && strings.HasPrefix(c.GetHeader("Authorization"), hmacScheme+" ") {
+			hmacAuth(c, hmacKeys)
+			return
+		}
+		apiKey(c)
+	}
+}
+
+// hmacAuth rejects requests whose signature does not match the path,
+// timestamp and body digest signed, whose timestamp is more than hmacMaxSkew
+// away, or whose signature was already used. The signature is checked before
+// any of the body is read. Bodies are then checked against their digest, up
+// front like limitBodySize reads them, or as they are read on the
+// streamedBodyPaths, and the signature is only used up once the body matched. The caller is identified by "hmac:" and its key ID, like
+// the ID of an API key, for rate limiting and idempotency.
+func hmacAuth(c *gin.Context, keys *hmacKeySet) {
+	reject := func(message string) {
+		c.Header("WWW-Authenticate", hmacScheme)
+		abortWithError(c, http.StatusUnauthorized, message)
+	}
+	credentials, _ := strings.CutPrefix(c.GetHeader("Authorization"), hmacScheme+" ")
+	id, signature, found := strings.Cut(credentials, ":")
+	secret, exists := keys.secrets[id]
+	if !found || !exists {
+		reject("invalid request signature")
+		return
+	}
+
+	timestamp := c.GetHeader(timestampHeader)
+	seconds, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		reject("missing or invalid " + timestampHeader + " header")
+		return
+	}
+	signedAt := time.Unix(seconds, 0)
+	if skew := time.Since(signedAt); skew > hmacMaxSkew || skew < -hmacMaxSkew {
+		reject("request timestamp is outside the allowed window")
+		return
+	}
+
+	bodyDigest := strings.ToLower(c.GetHeader(contentDigestHeader))
+	digest, err := hex.DecodeString(bodyDigest)
+	if err != nil || len(digest) != sha256.Size {
+		reject("missing or invalid " + contentDigestHeader + " header")
+		return
+	}
+	signature = strings.ToLower(signature)
+	expected := requestSignature(secret, timestamp, c.Request.Method, c.Request.URL.RequestURI(), bodyDigest)
+	if !hmac.Equal([]byte(signature), []byte(expected)) {
+		reject("invalid request signature")
+		return
+	}
+
+	// The signature is only used up by a request whose body matches, a
+	// tampered copy must not burn the signature of the genuine request
+	useSignature := func() error {
+		if !hmacSignatures.use(id, signature, signedAt.Add(hmacMaxSkew), time.Now()) {
+			return errRequestSignatureUsed
+		}
+		return nil
+	}
+	body := c.Request.Body
+	if body == nil {
+		body = http.NoBody
+	}
+	c.Request.Body = &digestReader{body: body, hash: sha256.New(), expected: digest, verified: useSignature}
+	if !slices.Contains(streamedBodyPaths, c.FullPath()) {
+		buffered, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			if errors.Is(err, errBodyDigestMismatch) || errors.Is(err, errRequestSignatureUsed) {
+				reject(err.Error())
+				return
+			}
+			abortWithError(c, http.StatusBadRequest, "failed to read request body")
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(buffered))
+	}
+	c.Set(apiKeyIDContextKey, "hmac:"+id)
+	c.Next()
+}
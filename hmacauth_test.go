@@ -0,0 +1,204 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+// signedRequest builds a request signed with the secret of an HMAC key at
+// the given time
+func signedRequest(method, uri, body, id, secret string, signedAt time.Time) *http.Request {
+	timestamp := strconv.FormatInt(signedAt.Unix(), 10)
+	digest := sha256.Sum256([]byte(body))
+	bodyDigest := hex.EncodeToString(digest[:])
+	req, _ := http.NewRequest(method, uri, bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(timestampHeader, timestamp)
+	req.Header.Set(contentDigestHeader, bodyDigest)
+	req.Header.Set("Authorization", hmacScheme+" "+id+":"+requestSignature([]byte(secret), timestamp, method, uri, bodyDigest))
+	return req
+}
+
+func TestHMACAuth(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	const secret = "a shared secret for the billing service"
+	keys, err := parseHMACKeys([]string{"billing:" + secret})
+	if err != nil {
+		t.Fatalf("Failed to parse HMAC keys: %v", err)
+	}
+	hmacKeys = keys
+	previousSignatures := hmacSignatures
+	hmacSignatures = newNonceTracker()
+	t.Cleanup(func() { hmacKeys, hmacSignatures = nil, previousSignatures })
+	router := newRouter(newAPIKeySet([]string{"first-key"}))
+
+	// The wallet creation fails validation once authenticated, so no keygen
+	// is needed to tell it apart from a rejected request
+	const body = `{"parties": 1, "threshold": 1}`
+	now := time.Now()
+	tampered := signedRequest("POST", "/wallet", body, "billing", secret, now.Add(-time.Second))
+	tamperedBody := `{"parties": 2, "threshold": 1}`
+	tampered.Body, tampered.ContentLength = io.NopCloser(strings.NewReader(tamperedBody)), int64(len(tamperedBody))
+	tamperedDigest := signedRequest("POST", "/wallet", tamperedBody, "billing", secret, now.Add(-2*time.Second))
+	tamperedDigest.Header.Set(contentDigestHeader, signedRequest("POST", "/wallet", body, "billing", secret, now).Header.Get(contentDigestHeader))
+	missingDigest := signedRequest("GET", "/wallets", "", "billing", secret, now.Add(-3*time.Second))
+	missingDigest.Header.Del(contentDigestHeader)
+	wrongTimestamp := signedRequest("GET", "/wallets", "", "billing", secret, now)
+	wrongTimestamp.Header.Set(timestampHeader, strconv.FormatInt(now.Unix()+1, 10))
+	missingTimestamp := signedRequest("GET", "/wallets", "", "billing", secret, now)
+	missingTimestamp.Header.Del(timestampHeader)
+	otherPath := signedRequest("GET", "/wallets", "", "billing", secret, now)
+	otherPath.URL.Path = "/wallets/count"
+
+	tests := []struct {
+		name    string
+		req     *http.Request
+		status  int
+		message string
+	}{
+		{"valid signature", signedRequest("POST", "/wallet", body, "billing", secret, now), http.StatusBadRequest, ""},
+		{"valid signature without body", signedRequest("GET", "/wallets?limit=1", "", "billing", secret, now), http.StatusOK, ""},
+		{"timestamp within the window", signedRequest("GET", "/wallets", "", "billing", secret, now.Add(-4*time.Minute)), http.StatusOK, ""},
+		{"tampered body", tampered, http.StatusUnauthorized, contentDigestHeader},
+		{"tampered digest", tamperedDigest, http.StatusUnauthorized, "invalid request signature"},
+		{"missing digest", missingDigest, http.StatusUnauthorized, contentDigestHeader},
+		{"replayed request", signedRequest("POST", "/wallet", body, "billing", secret, now), http.StatusUnauthorized, "already used"},
+		{"expired timestamp", signedRequest("GET", "/wallets", "", "billing", secret, now.Add(-6*time.Minute)), http.StatusUnauthorized, "outside the allowed window"},
+		{"timestamp in the future", signedRequest("GET", "/wallets", "", "billing", secret, now.Add(6*time.Minute)), http.StatusUnauthorized, "outside the allowed window"},
+		{"changed timestamp", wrongTimestamp, http.StatusUnauthorized, "invalid request signature"},
+		{"missing timestamp", missingTimestamp, http.StatusUnauthorized, timestampHeader},
+		{"other path", otherPath, http.StatusUnauthorized, "invalid request signature"},
+		{"wrong secret", signedRequest("GET", "/wallets", "", "billing", "not the shared secret at all", now), http.StatusUnauthorized, "invalid request signature"},
+		{"unknown key", signedRequest("GET", "/wallets", "", "payroll", secret, now), http.StatusUnauthorized, "invalid request signature"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, test.req)
+			assert.Equal(t, test.status, w.Code, w.Body.String())
+			if test.status == http.StatusUnauthorized {
+				assert.Equal(t, hmacScheme, w.Header().Get("WWW-Authenticate"))
+				assert.Contains(t, w.Body.String(), test.message)
+			}
+		})
+	}
+
+	t.Run("tampered copy sent first", func(t *testing.T) {
+		// The copy does not use up the signature of the genuine request
+		signedAt := now.Add(-10 * time.Second)
+		copied := signedRequest("POST", "/wallet", body, "billing", secret, signedAt)
+		copied.Body, copied.ContentLength = io.NopCloser(strings.NewReader(tamperedBody)), int64(len(tamperedBody))
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, copied)
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		assert.Contains(t, w.Body.String(), contentDigestHeader)
+
+		w = httptest.NewRecorder()
+		router.ServeHTTP(w, signedRequest("POST", "/wallet", body, "billing", secret, signedAt))
+		assert.Equal(t, http.StatusBadRequest, w.Code, w.Body.String())
+		w = httptest.NewRecorder()
+		router.ServeHTTP(w, signedRequest("POST", "/wallet", body, "billing", secret, signedAt))
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		assert.Contains(t, w.Body.String(), "already used")
+	})
+
+	t.Run("api key", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/wallets", nil)
+		req.Header.Set("Authorization", "Bearer first-key")
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+}
+
+func TestHMACAuthStreamedBody(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	const secret = "a shared secret for the billing service"
+	keys, err := parseHMACKeys([]string{"billing:" + secret})
+	if err != nil {
+		t.Fatalf("Failed to parse HMAC keys: %v", err)
+	}
+	hmacKeys = keys
+	previousSignatures := hmacSignatures
+	hmacSignatures = newNonceTracker()
+	t.Cleanup(func() { hmacKeys, hmacSignatures = nil, previousSignatures })
+	router := newRouter(newAPIKeySet([]string{"first-key"}))
+
+	key, _ := crypto.GenerateKey()
+	useInstantSigning(t, key)
+	address := "0x00000000000000000000000000000000000000E8"
+	addFakeWallet(address).PubKey = &key.PublicKey
+	t.Cleanup(func() {
+		walletsMutex.Lock()
+		delete(wallets, address)
+		walletsMutex.Unlock()
+	})
+
+	signedAt := time.Now()
+	signFile := func(body string) *httptest.ResponseRecorder {
+		req := signedRequest("POST", "/sign/raw", "the signed file", "billing", secret, signedAt)
+		req.Body, req.ContentLength = io.NopCloser(strings.NewReader(body)), int64(len(body))
+		req.Header.Set("Content-Type", "application/octet-stream")
+		req.Header.Set(walletHeader, address)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	// The raw body is checked against its digest as it is hashed, a body
+	// that does not match is rejected before any ceremony runs
+	w := signFile("another file")
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Contains(t, w.Body.String(), contentDigestHeader)
+
+	// The signature is only used up by the body that matches it
+	w = signFile("the signed file")
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	w = signFile("the signed file")
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Contains(t, w.Body.String(), "already used")
+}
+
+func TestHMACAuthDisabled(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := newRouter(newAPIKeySet([]string{"first-key"}))
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, signedRequest("GET", "/wallets", "", "billing", "a shared secret for the billing service", time.Now()))
+	assert.Equal(t, http.StatusUnauthorized, w.Code, "Signed requests should be rejected without HMAC keys")
+	assert.Equal(t, "Bearer", w.Header().Get("WWW-Authenticate"))
+}
+
+func TestParseHMACKeys(t *testing.T) {
+	keys, err := parseHMACKeys([]string{"billing:a shared secret for billing", " payroll : secret:with:colons "})
+	if assert.NoError(t, err) {
+		assert.Equal(t, 2, keys.len())
+		assert.Equal(t, []byte("secret:with:colons"), keys.secrets["payroll"])
+	}
+
+	invalid := map[string][]string{
+		"missing secret": {"billing"},
+		"missing id":     {":a shared secret for billing"},
+		"short secret":   {"billing:short"},
+		"duplicate id":   {"billing:a shared secret for billing", "billing:another shared secret"},
+	}
+	for name, lines := range invalid {
+		t.Run(name, func(t *testing.T) {
+			_, err := parseHMACKeys(lines)
+			assert.Error(t, err)
+		})
+	}
+}
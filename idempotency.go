@@ -0,0 +1,134 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// idempotencyKeyHeader lets a client retry a wallet creation without creating
+// a second wallet
+const idempotencyKeyHeader = "Idempotency-Key"
+
+// Idempotency keys are remembered for idempotencyKeyTTL after their wallet was
+// created. Expired keys are swept at most once a nonceSweepInterval.
+const (
+	idempotencyKeyTTL       = 24 * time.Hour
+	maxIdempotencyKeyLength = 128
+)
+
+// errIdempotencyKeyReused is answered with a 409 when a key is sent again
+// along with a different wallet creation
+var errIdempotencyKeyReused = errors.New("idempotency key already used for a different request")
+
+// idempotencyScope is an idempotency key of a client, keys of different API
+// keys never clash
+type idempotencyScope struct {
+	client string
+	key    string
+}
+
+// idempotentCreation is a wallet creation made with an idempotency key. done
+// is closed once it completes, the key is forgotten if it failed.
+type idempotentCreation struct {
+	fingerprint [sha256.Size]byte
+	done        chan struct{}
+	address     string
+	err         error
+	expiresAt   time.Time
+}
+
+// idempotencyKeys remembers the wallets created with an idempotency key
+type idempotencyKeys struct {
+	mu        sync.Mutex
+	creations map[idempotencyScope]*idempotentCreation
+	lastSweep time.Time
+}
+
+// newIdempotencyKeys creates an empty set of keys
+func newIdempotencyKeys() *idempotencyKeys {
+	return &idempotencyKeys{creations: make(map[idempotencyScope]*idempotentCreation)}
+}
+
+// walletIdempotencyKeys holds the idempotency keys of wallet creations
+var walletIdempotencyKeys = newIdempotencyKeys()
+
+// validateIdempotencyKey checks the length of an idempotency key
+func validateIdempotencyKey(key string) error {
+	if len(key) > maxIdempotencyKeyLength {
+		return fmt.Errorf("%s must be at most %d bytes", idempotencyKeyHeader, maxIdempotencyKeyLength)
+	}
+	return nil
+}
+
+// requestFingerprint hashes a wallet creation so a reused key can be told
+// apart from a retry
+func requestFingerprint(request createWalletRequest) [sha256.Size]byte {
+	payload, _ := json.Marshal(request)
+	return sha256.Sum256(payload)
+}
+
+// create runs create once per client and key, and returns the address of the
+// wallet it created. A retry made while the first creation runs waits for it,
+// and takes over if it fails, e.g. because its client went away.
+func (k *idempotencyKeys) create(ctx context.Context, client, key string, request createWalletRequest, create func() (string, error)) (string, error) {
+	scope := idempotencyScope{client: client, key: key}
+	fingerprint := requestFingerprint(request)
+	for {
+		k.mu.Lock()
+		now := time.Now()
+		if now.Sub(k.lastSweep) >= nonceSweepInterval {
+			k.sweep(now)
+		}
+		creation, exists := k.creations[scope]
+		if !exists {
+			creation = &idempotentCreation{fingerprint: fingerprint, done: make(chan struct{})}
+			k.creations[scope] = creation
+			k.mu.Unlock()
+			return k.run(scope, creation, create)
+		}
+		k.mu.Unlock()
+
+		if creation.fingerprint != fingerprint {
+			return "", errIdempotencyKeyReused
+		}
+		select {
+		case <-creation.done:
+		case <-ctx.Done():
+			return "", errCeremonyCanceled
+		}
+		if creation.err == nil {
+			return creation.address, nil
+		}
+	}
+}
+
+// run runs the creation claimed for the scope and records its outcome
+func (k *idempotencyKeys) run(scope idempotencyScope, creation *idempotentCreation, create func() (string, error)) (string, error) {
+	address, err := create()
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	creation.address, creation.err = address, err
+	if err != nil {
+		delete(k.creations, scope)
+	} else {
+		creation.expiresAt = time.Now().Add(idempotencyKeyTTL)
+	}
+	close(creation.done)
+	return address, err
+}
+
+// sweep forgets the expired keys, creations still running have no expiry yet
+func (k *idempotencyKeys) sweep(now time.Time) {
+	for scope, creation := range k.creations {
+		if !creation.expiresAt.IsZero() && !now.Before(creation.expiresAt) {
+			delete(k.creations, scope)
+		}
+	}
+	k.lastSweep = now
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCreateWalletIdempotencyKey(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	router := gin.Default()
+	router.POST("/wallet", createWallet)
+
+	walletsMutex.Lock()
+	walletCount := len(wallets)
+	walletsMutex.Unlock()
+
+	// EdDSA keygen is fast enough to run twice
+	create := func(request createWalletRequest, key string) *httptest.ResponseRecorder {
+		jsonBody, _ := json.Marshal(request)
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/wallet", bytes.NewBuffer(jsonBody))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set(idempotencyKeyHeader, key)
+		router.ServeHTTP(w, req)
+		return w
+	}
+	request := createWalletRequest{Parties: 2, Threshold: 1, Algorithm: algorithmEdDSA}
+
+	addresses := make([]string, 2)
+	for i := range addresses {
+		w := create(request, "create-once")
+		assert.Equal(t, http.StatusOK, w.Code)
+		var response stringFields
+		if err := decodeData(w.Body.Bytes(), &response); err != nil {
+			t.Fatalf("Failed to parse create wallet response: %v", err)
+		}
+		addresses[i] = response["address"]
+	}
+	t.Cleanup(func() {
+		walletsMutex.Lock()
+		delete(wallets, addresses[0])
+		walletsMutex.Unlock()
+	})
+	assert.NotEmpty(t, addresses[0])
+	assert.Equal(t, addresses[0], addresses[1], "The retry should get the first wallet")
+	walletsMutex.Lock()
+	assert.Len(t, wallets, walletCount+1, "Only one wallet should be created")
+	walletsMutex.Unlock()
+
+	// The key can't be reused for another wallet
+	request.Label = "other"
+	w := create(request, "create-once")
+	assert.Equal(t, http.StatusConflict, w.Code)
+
+	w = create(request, string(make([]byte, maxIdempotencyKeyLength+1)))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestIdempotencyKeysConcurrentRetry(t *testing.T) {
+	keys := newIdempotencyKeys()
+	request := createWalletRequest{Parties: 2, Threshold: 1}
+	release := make(chan struct{})
+	var calls atomic.Int32
+	create := func() (string, error) {
+		calls.Add(1)
+		<-release
+		return "0xwallet", nil
+	}
+
+	var wg sync.WaitGroup
+	addresses := make([]string, 3)
+	for i := range addresses {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			address, err := keys.create(context.Background(), "client", "key", request, create)
+			assert.NoError(t, err)
+			addresses[i] = address
+		}()
+	}
+	assert.Eventually(t, func() bool { return calls.Load() == 1 }, time.Second, 10*time.Millisecond)
+	close(release)
+	wg.Wait()
+	assert.Equal(t, int32(1), calls.Load(), "Retries should wait for the running creation")
+	assert.Equal(t, []string{"0xwallet", "0xwallet", "0xwallet"}, addresses)
+
+	// Keys of other clients are independent
+	_, err := keys.create(context.Background(), "other client", "key", request, create)
+	assert.NoError(t, err)
+	assert.Equal(t, int32(2), calls.Load())
+}
+
+func TestIdempotencyKeysFailedCreation(t *testing.T) {
+	keys := newIdempotencyKeys()
+	request := createWalletRequest{Parties: 2, Threshold: 1}
+
+	_, err := keys.create(context.Background(), "", "key", request, func() (string, error) {
+		return "", errCeremonyCanceled
+	})
+	assert.ErrorIs(t, err, errCeremonyCanceled)
+
+	// A failed creation doesn't hold the key
+	address, err := keys.create(context.Background(), "", "key", request, func() (string, error) {
+		return "0xwallet", nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "0xwallet", address)
+}
+
+func TestIdempotencyKeysExpire(t *testing.T) {
+	keys := newIdempotencyKeys()
+	request := createWalletRequest{Parties: 2, Threshold: 1}
+	_, err := keys.create(context.Background(), "", "key", request, func() (string, error) {
+		return "0xwallet", nil
+	})
+	assert.NoError(t, err)
+
+	keys.mu.Lock()
+	keys.sweep(time.Now())
+	assert.Len(t, keys.creations, 1, "Keys should be kept until they expire")
+	keys.sweep(time.Now().Add(idempotencyKeyTTL))
+	assert.Empty(t, keys.creations, "Expired keys should be swept")
+	keys.mu.Unlock()
+
+	address, err := keys.create(context.Background(), "", "key", request, func() (string, error) {
+		return "", errors.New("keygen failed")
+	})
+	assert.Error(t, err, "An expired key should create a new wallet")
+	assert.Empty(t, address)
+}
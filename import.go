@@ -0,0 +1,150 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// importWalletRequest represents the request body for importWallet endpoint.
+// The wallet uses the same format as the store, with the key shares either in
+// saveData or encrypted in encryptedSaveData.
+type importWalletRequest struct {
+	storedWallet
+	// Passphrase decrypts encryptedSaveData
+	Passphrase string `json:"passphrase"`
+}
+
+// importWallet loads a wallet whose key shares were generated elsewhere. The
+// public key and address are recomputed from the shares.
+func importWallet(c *gin.Context) {
+	var requestBody importWalletRequest
+
+	if err := c.BindJSON(&requestBody); err != nil {
+		respondError(c, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	if err := validateWalletConfig(requestBody.Parties, requestBody.Threshold); err != nil {
+		respondError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err := validateLabels(requestBody.Label, requestBody.Metadata); err != nil {
+		respondError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err := validatePolicy(requestBody.Policy); err != nil {
+		respondError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	if len(requestBody.PartyIDs) != requestBody.Parties {
+		respondError(c, http.StatusBadRequest, "partyIds must list every party")
+		return
+	}
+
+	var sc *shareCipher
+	if requestBody.Passphrase != "" {
+		sc = newShareCipher(requestBody.Passphrase)
+	} else if requestBody.EncryptedSaveData != nil {
+		respondError(c, http.StatusBadRequest, "passphrase is required to decrypt encryptedSaveData")
+		return
+	}
+	wallet, err := requestBody.toWallet(sc)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	if wallet.shareCount() != wallet.Parties {
+		respondError(c, http.StatusBadRequest, "saveData must hold one share per party")
+		return
+	}
+
+	// The address is optional, when given it must match the key shares
+	wallet.Address = deriveAddress(wallet.PubKey)
+	if requestBody.Address != "" && !strings.EqualFold(requestBody.Address, wallet.Address) {
+		respondError(c, http.StatusBadRequest, "address does not match the key shares")
+		return
+	}
+	// Wallets exported from this service keep their creation time
+	if wallet.CreatedAt.IsZero() {
+		wallet.CreatedAt = time.Now().UTC()
+	}
+
+	if err := addWallet(wallet); err != nil {
+		if errors.Is(err, errWalletExists) {
+			respondError(c, http.StatusConflict, err.Error())
+			return
+		}
+		if errors.Is(err, errWalletLimitReached) {
+			respondError(c, http.StatusInsufficientStorage, err.Error())
+			return
+		}
+		respondError(c, http.StatusInternalServerError, "failed to persist wallet")
+		return
+	}
+	respond(c, http.StatusOK, newWalletResponse(wallet))
+}
+
+// errWalletExists is returned when adding a wallet whose address is taken
+var errWalletExists = errors.New("wallet already exists")
+
+// errWalletLimitReached is returned when adding a wallet while the service
+// already holds maxWallets wallets
+var errWalletLimitReached = errors.New("wallet limit reached")
+
+// maxWallets caps the number of wallets held by the service to bound its
+// memory, 0 disables the limit
+var maxWallets = 0
+
+// maxWalletsEnv names the environment variable holding the wallet limit, the
+// --max-wallets flag takes precedence over it
+const maxWalletsEnv = "MAX_WALLETS"
+
+// checkWalletLimit returns errWalletLimitReached when no wallet can be added,
+// so that a creation fails before running its keygen ceremony
+func checkWalletLimit() error {
+	walletsMutex.Lock()
+	defer walletsMutex.Unlock()
+	return walletLimitError()
+}
+
+// walletLimitError is checkWalletLimit with walletsMutex held
+func walletLimitError() error {
+	if maxWallets > 0 && len(wallets) >= maxWallets {
+		return errWalletLimitReached
+	}
+	return nil
+}
+
+// addWallet persists a new wallet and makes it available for signing. The
+// store write completes before the wallet is registered, so a wallet that can
+// be looked up is always committed to the store and a failed write leaves no
+// wallet behind. The write holds storeMutex only, lookups and lists are not
+// held up by it, and no other wallet can be added meanwhile.
+func addWallet(wallet *Wallet) error {
+	storeMutex.Lock()
+	defer storeMutex.Unlock()
+
+	walletsMutex.Lock()
+	_, exists := wallets[wallet.Address]
+	limitErr := walletLimitError()
+	walletsMutex.Unlock()
+	if exists {
+		return errWalletExists
+	}
+	if limitErr != nil {
+		return limitErr
+	}
+	if store != nil {
+		if err := store.Save(wallet); err != nil {
+			return err
+		}
+	}
+
+	walletsMutex.Lock()
+	defer walletsMutex.Unlock()
+	wallets[wallet.Address] = wallet
+	return nil
+}
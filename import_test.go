@@ -0,0 +1,172 @@
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	tsscrypto "github.com/bnb-chain/tss-lib/crypto"
+	"github.com/bnb-chain/tss-lib/tss"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestImportWallet(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	useDeterministicKeygen(t, "import wallet")
+
+	router := gin.Default()
+	router.POST("/wallet", createWallet)
+	router.POST("/wallet/import", importWallet)
+	router.POST("/sign", signData)
+
+	jsonBody, _ := json.Marshal(createWalletRequest{Parties: 2, Threshold: 1})
+	w1 := httptest.NewRecorder()
+	req1, _ := http.NewRequest("POST", "/wallet", bytes.NewBuffer(jsonBody))
+	req1.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w1, req1)
+	assert.Equal(t, http.StatusOK, w1.Code)
+
+	var createResponse stringFields
+	err := decodeData(w1.Body.Bytes(), &createResponse)
+	if err != nil {
+		t.Fatalf("Failed to parse create wallet response: %v", err)
+	}
+	walletAddress := createResponse["address"]
+
+	// Export the wallet, encrypted and in plaintext
+	walletsMutex.Lock()
+	wallet := wallets[walletAddress]
+	walletsMutex.Unlock()
+	plain, err := newStoredWallet(wallet, nil)
+	assert.NoError(t, err)
+	plainBody, _ := json.Marshal(importWalletRequest{storedWallet: *plain})
+	encrypted, err := newStoredWallet(wallet, newShareCipher("passphrase"))
+	assert.NoError(t, err)
+	encryptedBody, _ := json.Marshal(importWalletRequest{storedWallet: *encrypted, Passphrase: "passphrase"})
+
+	importBody := func(body []byte) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/wallet/import", bytes.NewBuffer(body))
+		req.Header.Set("Content-Type", "application/json")
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	// The wallet already exists
+	assert.Equal(t, http.StatusConflict, importBody(plainBody).Code)
+
+	for name, body := range map[string][]byte{"plaintext": plainBody, "encrypted": encryptedBody} {
+		t.Run(name, func(t *testing.T) {
+			walletsMutex.Lock()
+			delete(wallets, walletAddress)
+			walletsMutex.Unlock()
+
+			w := importBody(body)
+			assert.Equal(t, http.StatusOK, w.Code)
+
+			var importResponse walletResponse
+			err := decodeData(w.Body.Bytes(), &importResponse)
+			if err != nil {
+				t.Fatalf("Failed to parse import response: %v", err)
+			}
+			assert.Equal(t, walletAddress, importResponse.Address)
+			assert.Equal(t, 2, importResponse.Parties)
+			assert.Equal(t, 1, importResponse.Threshold)
+
+			digest := crypto.Keccak256([]byte("import"))
+			requestBody := signDataRequest{
+				Data:   "0x" + hex.EncodeToString(digest),
+				Wallet: walletAddress,
+				Hash:   hashNone,
+			}
+			jsonBody, _ := json.Marshal(requestBody)
+
+			w2 := httptest.NewRecorder()
+			req2, _ := http.NewRequest("POST", "/sign", bytes.NewBuffer(jsonBody))
+			req2.Header.Set("Content-Type", "application/json")
+			router.ServeHTTP(w2, req2)
+			assert.Equal(t, http.StatusOK, w2.Code)
+
+			var signResponse stringFields
+			err = decodeData(w2.Body.Bytes(), &signResponse)
+			if err != nil {
+				t.Fatalf("Failed to parse sign data response: %v", err)
+			}
+			rsv, err := hex.DecodeString(signResponse["rsv"])
+			assert.NoError(t, err)
+			rsv[64] -= 27
+			pubKey, err := crypto.SigToPub(digest, rsv)
+			assert.NoError(t, err)
+			assert.Equal(t, walletAddress, crypto.PubkeyToAddress(*pubKey).Hex())
+		})
+	}
+
+	walletsMutex.Lock()
+	delete(wallets, walletAddress)
+	walletsMutex.Unlock()
+
+	// Shares that don't agree on the public key are rejected
+	var mismatched importWalletRequest
+	assert.NoError(t, json.Unmarshal(plainBody, &mismatched))
+	otherKey, _ := crypto.GenerateKey()
+	otherPub, err := tsscrypto.NewECPoint(tss.S256(), otherKey.X, otherKey.Y)
+	assert.NoError(t, err)
+	mismatched.SaveData[mismatched.PartyIDs[1].ID].ECDSAPub = otherPub
+	mismatchedBody, _ := json.Marshal(mismatched)
+	w := importBody(mismatchedBody)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "do not agree on the public key")
+
+	// Encrypted shares need the right passphrase
+	var wrongPassphrase importWalletRequest
+	assert.NoError(t, json.Unmarshal(encryptedBody, &wrongPassphrase))
+	wrongPassphrase.Passphrase = "wrong"
+	wrongBody, _ := json.Marshal(wrongPassphrase)
+	assert.Equal(t, http.StatusBadRequest, importBody(wrongBody).Code)
+
+	wrongPassphrase.Passphrase = ""
+	wrongBody, _ = json.Marshal(wrongPassphrase)
+	assert.Equal(t, http.StatusBadRequest, importBody(wrongBody).Code)
+
+	// A given address must match the shares
+	var wrongAddress importWalletRequest
+	assert.NoError(t, json.Unmarshal(plainBody, &wrongAddress))
+	wrongAddress.Address = "0x00000000000000000000000000000000000000D9"
+	wrongBody, _ = json.Marshal(wrongAddress)
+	assert.Equal(t, http.StatusBadRequest, importBody(wrongBody).Code)
+
+	walletsMutex.Lock()
+	defer walletsMutex.Unlock()
+	assert.NotContains(t, wallets, walletAddress, "Rejected imports should not add the wallet")
+}
+
+func TestImportWalletInvalidInput(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	router := gin.Default()
+	router.POST("/wallet/import", importWallet)
+
+	tests := []struct {
+		name string
+		body string
+	}{
+		{"invalid body", `{`},
+		{"invalid config", `{"parties": 1, "threshold": 1}`},
+		{"missing party IDs", `{"parties": 2, "threshold": 1}`},
+		{"missing shares", `{"parties": 2, "threshold": 1, "partyIds": [{"id": "0", "key": "1"}, {"id": "1", "key": "2"}]}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("POST", "/wallet/import", bytes.NewBufferString(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			router.ServeHTTP(w, req)
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+		})
+	}
+}
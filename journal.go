@@ -0,0 +1,206 @@
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// journalDirName is the directory of the data dir holding the signing
+// journals, one file per wallet
+const journalDirName = "signatures"
+
+// journalFileExt is the extension of a journal file, which holds one JSON
+// entry per line
+const journalFileExt = ".jsonl"
+
+// Results of a journaled signing
+const (
+	journalResultSigned = "signed"
+	journalResultFailed = "failed"
+)
+
+// journalEntry records a signing ceremony of a wallet
+type journalEntry struct {
+	// Sequence numbers the entries of a wallet from 1, without gaps
+	Sequence int       `json:"sequence"`
+	Time     time.Time `json:"time"`
+	Digest   string    `json:"digest"`
+	Signers  []string  `json:"signers"`
+	Result   string    `json:"result"`
+	Error    string    `json:"error,omitempty"`
+}
+
+// signingJournal is an append-only log of the signing ceremonies of every
+// wallet, kept in memory and, when it has a directory, appended to a file per
+// wallet. Entries are kept after their wallet is deleted.
+type signingJournal struct {
+	mu      sync.Mutex
+	dir     string
+	entries map[string][]journalEntry
+}
+
+// newSigningJournal creates an empty journal kept in memory only
+func newSigningJournal() *signingJournal {
+	return &signingJournal{entries: make(map[string][]journalEntry)}
+}
+
+// signatureJournal records every signing ceremony
+var signatureJournal = newSigningJournal()
+
+// openSigningJournal creates a journal persisted in dir, creating it if needed,
+// and loads the entries already there
+func openSigningJournal(dir string) (*signingJournal, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create journal dir: %w", err)
+	}
+	journal := newSigningJournal()
+	journal.dir = dir
+
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read journal dir: %w", err)
+	}
+	for _, file := range files {
+		address, found := strings.CutSuffix(file.Name(), journalFileExt)
+		if file.IsDir() || !found {
+			continue
+		}
+		entries, err := readJournalFile(filepath.Join(dir, file.Name()))
+		if err != nil {
+			return nil, err
+		}
+		journal.entries[address] = entries
+	}
+	return journal, nil
+}
+
+// readJournalFile reads the entries of a journal file. A crash while writing
+// can only leave the last line incomplete, it is cut off so the next entry
+// starts on a line of its own.
+func readJournalFile(path string) ([]journalEntry, error) {
+	payload, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read journal: %w", err)
+	}
+
+	var entries []journalEntry
+	complete := 0
+	for complete < len(payload) {
+		end := bytes.IndexByte(payload[complete:], '\n')
+		if end < 0 {
+			break
+		}
+		var entry journalEntry
+		if err := json.Unmarshal(payload[complete:complete+end], &entry); err != nil {
+			return nil, fmt.Errorf("failed to parse journal %s: %w", filepath.Base(path), err)
+		}
+		entries = append(entries, entry)
+		complete += end + 1
+	}
+	if complete < len(payload) {
+		if err := os.Truncate(path, int64(complete)); err != nil {
+			return nil, fmt.Errorf("failed to repair journal: %w", err)
+		}
+	}
+	return entries, nil
+}
+
+// record appends an entry for a signing ceremony of the wallet. A failure to
+// persist it is logged, the entry is still kept in memory.
+func (j *signingJournal) record(address string, digest []byte, signers []string, signErr error) {
+	entry := journalEntry{
+		Time:    time.Now().UTC(),
+		Digest:  hex.EncodeToString(digest),
+		Signers: signers,
+		Result:  journalResultSigned,
+	}
+	if signErr != nil {
+		entry.Result, entry.Error = journalResultFailed, signErr.Error()
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	entry.Sequence = len(j.entries[address]) + 1
+	if err := j.persist(address, entry); err != nil {
+		log.Printf("failed to persist signing journal entry %d of wallet %s: %v", entry.Sequence, address, err)
+	}
+	j.entries[address] = append(j.entries[address], entry)
+}
+
+// persist appends the entry to the journal file of the wallet
+func (j *signingJournal) persist(address string, entry journalEntry) error {
+	if j.dir == "" {
+		return nil
+	}
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	file, err := os.OpenFile(filepath.Join(j.dir, address+journalFileExt), os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(append(line, '\n')); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// page returns the entries of the wallet from offset, oldest first, along
+// with the total number of entries
+func (j *signingJournal) page(address string, limit, offset int) ([]journalEntry, int) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	entries := j.entries[address]
+	start := min(offset, len(entries))
+	return append([]journalEntry(nil), entries[start:min(start+limit, len(entries))]...), len(entries)
+}
+
+// listSignaturesResponse represents the response body of listSignatures
+type listSignaturesResponse struct {
+	Signatures []journalEntry `json:"signatures"`
+	Total      int            `json:"total"`
+	Limit      int            `json:"limit"`
+	Offset     int            `json:"offset"`
+}
+
+// listSignatures returns a page of the signing journal of a wallet
+func listSignatures(c *gin.Context) {
+	limit, offset, err := pagination(c)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	wallet, err := lookupWallet(c.Param("address"))
+	if err != nil {
+		respondServiceError(c, err)
+		return
+	}
+
+	entries, total := signatureJournal.page(wallet.Address, limit, offset)
+	if entries == nil {
+		entries = []journalEntry{}
+	}
+	respond(c, http.StatusOK, listSignaturesResponse{
+		Signatures: entries,
+		Total:      total,
+		Limit:      limit,
+		Offset:     offset,
+	})
+}
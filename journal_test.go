@@ -0,0 +1,166 @@
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+// useSigningJournal replaces the signing journal for the duration of the test
+func useSigningJournal(t *testing.T, journal *signingJournal) {
+	t.Helper()
+	previous := signatureJournal
+	signatureJournal = journal
+	t.Cleanup(func() { signatureJournal = previous })
+}
+
+func TestSigningJournal(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	useSigningJournal(t, newSigningJournal())
+
+	key, _ := crypto.GenerateKey()
+	useInstantSigning(t, key)
+	address := deriveAddress(&key.PublicKey)
+	addFakeWallet(address).PubKey = &key.PublicKey
+	t.Cleanup(func() {
+		walletsMutex.Lock()
+		delete(wallets, address)
+		walletsMutex.Unlock()
+	})
+
+	router := gin.Default()
+	router.POST("/sign", signData)
+	router.GET("/wallet/:address/signatures", listSignatures)
+
+	sign := func(request signDataRequest) *httptest.ResponseRecorder {
+		jsonBody, _ := json.Marshal(request)
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/sign", bytes.NewBuffer(jsonBody))
+		req.Header.Set("Content-Type", "application/json")
+		router.ServeHTTP(w, req)
+		return w
+	}
+	list := func(query string) (int, listSignaturesResponse) {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/wallet/"+address+"/signatures"+query, nil)
+		router.ServeHTTP(w, req)
+		var response listSignaturesResponse
+		if w.Code == http.StatusOK {
+			if err := decodeData(w.Body.Bytes(), &response); err != nil {
+				t.Fatalf("Failed to parse list signatures response: %v", err)
+			}
+		}
+		return w.Code, response
+	}
+
+	code, response := list("")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Empty(t, response.Signatures)
+	assert.NotNil(t, response.Signatures, "An empty journal should be listed as an empty array")
+
+	assert.Equal(t, http.StatusOK, sign(signDataRequest{Data: "0x74657374", Wallet: address}).Code)
+	assert.Equal(t, http.StatusOK, sign(signDataRequest{Data: "0x74657374", Wallet: address, Hash: hashSHA256, Signers: []string{"2", "1"}}).Code)
+	wallet, _ := lookupWallet(address)
+	_, err := signDigest(wallet, []string{"7", "0"}, []byte{0x01}, nil)
+	assert.Error(t, err)
+
+	code, response = list("")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, 3, response.Total)
+	if assert.Len(t, response.Signatures, 3) {
+		signed := response.Signatures[0]
+		assert.Equal(t, 1, signed.Sequence)
+		assert.Equal(t, hex.EncodeToString(crypto.Keccak256([]byte("test"))), signed.Digest, "The entry should record the signed digest")
+		assert.Equal(t, []string{"0", "1"}, signed.Signers)
+		assert.Equal(t, journalResultSigned, signed.Result)
+		assert.Empty(t, signed.Error)
+
+		digest, _ := messageDigest([]byte("test"), hashSHA256)
+		assert.Equal(t, 2, response.Signatures[1].Sequence)
+		assert.Equal(t, hex.EncodeToString(digest), response.Signatures[1].Digest)
+		assert.Equal(t, []string{"1", "2"}, response.Signatures[1].Signers)
+
+		failed := response.Signatures[2]
+		assert.Equal(t, 3, failed.Sequence)
+		assert.Equal(t, "01", failed.Digest)
+		assert.Equal(t, []string{"7", "0"}, failed.Signers, "The requested signers should be recorded when there is no quorum")
+		assert.Equal(t, journalResultFailed, failed.Result)
+		assert.NotEmpty(t, failed.Error)
+		assert.False(t, failed.Time.Before(signed.Time), "Entries should be in signing order")
+	}
+
+	code, response = list("?limit=1&offset=1")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, 3, response.Total)
+	if assert.Len(t, response.Signatures, 1) {
+		assert.Equal(t, 2, response.Signatures[0].Sequence)
+	}
+	code, response = list("?offset=5")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Empty(t, response.Signatures)
+
+	code, _ = list("?limit=0")
+	assert.Equal(t, http.StatusBadRequest, code)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/wallet/0x00000000000000000000000000000000000000DA/signatures", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestSigningJournalPersisted(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), journalDirName)
+	journal, err := openSigningJournal(dir)
+	if err != nil {
+		t.Fatalf("Failed to open signing journal: %v", err)
+	}
+	journal.record("0xwallet", []byte{0x01, 0x02}, []string{"0", "1"}, nil)
+	journal.record("0xwallet", []byte{0x03}, []string{"0", "2"}, errors.New("signing failed"))
+	journal.record("0xother", []byte{0x04}, []string{"1", "2"}, nil)
+
+	// A crash while appending leaves an incomplete last line
+	path := filepath.Join(dir, "0xwallet"+journalFileExt)
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		t.Fatalf("Failed to open journal file: %v", err)
+	}
+	_, _ = file.WriteString(`{"sequence":3,"dig`)
+	file.Close()
+
+	reopened, err := openSigningJournal(dir)
+	if err != nil {
+		t.Fatalf("Failed to reopen signing journal: %v", err)
+	}
+	entries, total := reopened.page("0xwallet", maxListLimit, 0)
+	assert.Equal(t, 2, total)
+	if assert.Len(t, entries, 2) {
+		assert.Equal(t, "0102", entries[0].Digest)
+		assert.Equal(t, []string{"0", "1"}, entries[0].Signers)
+		assert.Equal(t, journalResultFailed, entries[1].Result)
+		assert.Equal(t, "signing failed", entries[1].Error)
+	}
+	_, total = reopened.page("0xother", maxListLimit, 0)
+	assert.Equal(t, 1, total)
+
+	// Sequence numbers carry on after a restart
+	reopened.record("0xwallet", []byte{0x05}, []string{"0", "1"}, nil)
+	reopened, err = openSigningJournal(dir)
+	if err != nil {
+		t.Fatalf("Failed to reopen signing journal: %v", err)
+	}
+	entries, _ = reopened.page("0xwallet", maxListLimit, 0)
+	if assert.Len(t, entries, 3) {
+		assert.Equal(t, 3, entries[2].Sequence)
+		assert.Equal(t, "05", entries[2].Digest)
+	}
+}
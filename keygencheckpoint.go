@@ -0,0 +1,244 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/bnb-chain/tss-lib/ecdsa/keygen"
+	"github.com/bnb-chain/tss-lib/tss"
+)
+
+// keygenDirName is the directory of the data dir holding the checkpoints of
+// the keygens in progress, one file per ceremony
+const keygenDirName = "keygens"
+
+// keygenCheckpointStore keeps the checkpoints of the keygens in progress in a
+// directory. Key shares are encrypted when a cipher is configured.
+type keygenCheckpointStore struct {
+	dir    string
+	cipher *shareCipher
+}
+
+// keygenCheckpoints holds the checkpoints of the keygens in progress, nil when
+// persistence is disabled
+var keygenCheckpoints *keygenCheckpointStore
+
+// newKeygenCheckpointStore creates a checkpoint store, creating the directory
+// if needed
+func newKeygenCheckpointStore(dir string, sc *shareCipher) (*keygenCheckpointStore, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create keygen checkpoint dir: %w", err)
+	}
+	return &keygenCheckpointStore{dir: dir, cipher: sc}, nil
+}
+
+// storedKeygenCheckpoint is the serializable form of a checkpoint: the wallet
+// being created, with the key shares of the parties that completed so far,
+// and the request creating it
+type storedKeygenCheckpoint struct {
+	Request createWalletRequest `json:"request"`
+	Wallet  *storedWallet       `json:"wallet"`
+}
+
+// keygenCheckpoint records the key share of every party of a keygen as soon
+// as the party completes. tss-lib keeps the state of the rounds in unexported
+// fields and cannot serialize it, so an interrupted ceremony can't pick up
+// where it stopped. The key shares of the parties that completed are enough
+// to reshare the key to a full set of parties though, as long as there are
+// threshold+1 of them. A nil checkpoint records nothing.
+type keygenCheckpoint struct {
+	store   *keygenCheckpointStore
+	path    string
+	request createWalletRequest
+	wallet  *Wallet
+}
+
+// start creates the checkpoint of a new wallet creation. Nothing is written
+// until a party completes.
+func (s *keygenCheckpointStore) start(request createWalletRequest) *keygenCheckpoint {
+	if s == nil {
+		return nil
+	}
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		log.Printf("failed to name keygen checkpoint, the keygen won't be resumable: %v", err)
+		return nil
+	}
+	return &keygenCheckpoint{
+		store:   s,
+		path:    filepath.Join(s.dir, hex.EncodeToString(id)+".json"),
+		request: request,
+	}
+}
+
+// begin starts recording a new attempt of the keygen, dropping the key
+// shares of the previous attempt which belong to other parties
+func (cp *keygenCheckpoint) begin(partyIDs tss.SortedPartyIDs, threshold int, curveName tss.CurveName) {
+	if cp == nil {
+		return
+	}
+	cp.remove()
+	cp.wallet = &Wallet{
+		PartyIDs:  partyIDs,
+		Parties:   len(partyIDs),
+		Threshold: threshold,
+		Algorithm: algorithmECDSA,
+		Curve:     curveName,
+		SaveData:  make(map[string]*keygen.LocalPartySaveData),
+	}
+}
+
+// record adds the key share of a party that completed and writes the
+// checkpoint. A failure to write it is logged, the keygen goes on without it.
+func (cp *keygenCheckpoint) record(partyID *tss.PartyID, save *keygen.LocalPartySaveData) {
+	if cp == nil || cp.wallet == nil {
+		return
+	}
+	cp.wallet.SaveData[partyID.Id] = save
+	if cp.wallet.Address == "" {
+		pubKey := save.ECDSAPub.ToECDSAPubKey()
+		cp.wallet.Address = deriveAddress(pubKey)
+	}
+	if err := cp.write(); err != nil {
+		log.Printf("failed to checkpoint keygen of wallet %s: %v", cp.wallet.Address, err)
+	}
+}
+
+// write replaces the checkpoint file, through a temporary file like the
+// wallets
+func (cp *keygenCheckpoint) write() error {
+	sw, err := newStoredWallet(cp.wallet, cp.store.cipher)
+	if err != nil {
+		return err
+	}
+	payload, err := json.Marshal(storedKeygenCheckpoint{Request: cp.request, Wallet: sw})
+	if err != nil {
+		return fmt.Errorf("failed to serialize checkpoint: %w", err)
+	}
+	tmpPath := cp.path + ".tmp"
+	if err := writeFileSync(tmpPath, payload, 0o600); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := os.Rename(tmpPath, cp.path); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	return syncDir(cp.store.dir)
+}
+
+// remove deletes the checkpoint file, once the keygen is over
+func (cp *keygenCheckpoint) remove() {
+	if cp == nil {
+		return
+	}
+	if err := removeCheckpointFile(cp.path); err != nil {
+		log.Printf("failed to remove keygen checkpoint: %v", err)
+	}
+}
+
+// removeCheckpointFile deletes a checkpoint file that may not exist
+func removeCheckpointFile(path string) error {
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// resumeKeygens completes the keygens interrupted by the death of the
+// process, found in the checkpoint store. Checkpoints that failed to resume
+// are kept for the next start, unless too few parties completed for the
+// keygen to ever be resumed.
+func resumeKeygens(s *keygenCheckpointStore) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		log.Printf("failed to read keygen checkpoint dir: %v", err)
+		return
+	}
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".json") {
+			continue
+		}
+		if err := s.resume(filepath.Join(s.dir, entry.Name())); err != nil {
+			log.Printf("failed to resume keygen %s: %v", entry.Name(), err)
+		}
+	}
+}
+
+// resume completes the keygen of a checkpoint and stores its wallet. The key
+// shares of the parties that did not complete died with the process, the
+// parties that completed reshare the key to a new set of parties instead.
+// The wallet keeps its public key and address.
+func (s *keygenCheckpointStore) resume(path string) error {
+	payload, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var checkpoint storedKeygenCheckpoint
+	if err := json.Unmarshal(payload, &checkpoint); err != nil {
+		return fmt.Errorf("failed to parse checkpoint: %w", err)
+	}
+	if checkpoint.Wallet == nil {
+		return errors.New("checkpoint has no wallet")
+	}
+	wallet, err := checkpoint.Wallet.rebuild(s.cipher, true)
+	if err != nil {
+		return err
+	}
+	if wallet.Algorithm != algorithmECDSA {
+		return fmt.Errorf("%s keygens cannot be resumed", wallet.Algorithm)
+	}
+	if _, err := lookupWallet(wallet.Address); err == nil {
+		// The process died after storing the wallet, before removing the
+		// checkpoint
+		return removeCheckpointFile(path)
+	}
+
+	var completed []string
+	for _, partyID := range wallet.PartyIDs {
+		if _, exists := wallet.SaveData[partyID.Id]; exists {
+			completed = append(completed, partyID.Id)
+		}
+	}
+	quorumSize := wallet.Threshold + 1
+	if len(completed) < quorumSize {
+		log.Printf("keygen of wallet %s cannot be resumed, %d parties completed and %d are needed", wallet.Address, len(completed), quorumSize)
+		return removeCheckpointFile(path)
+	}
+
+	resumed := wallet
+	if len(completed) < wallet.Parties {
+		partyIDs, err := newPartyIDs(wallet.Parties, wallet.PubKey.Curve, wallet.PartyIDs...)
+		if err != nil {
+			return err
+		}
+		if err := applyPartySpecs(partyIDs, checkpoint.Request.PartySpecs); err != nil {
+			return err
+		}
+		resumed, err = runResharing(wallet, completed[:quorumSize], partyIDs, wallet.Threshold)
+		if err != nil {
+			return fmt.Errorf("failed to reshare: %w", err)
+		}
+		for _, saveData := range wallet.SaveData {
+			zeroSaveData(saveData)
+		}
+	}
+	if err := applyWalletRequest(resumed, checkpoint.Request); err != nil {
+		return err
+	}
+	resumed.CreatedAt = time.Now().UTC()
+	if err := addWallet(resumed); err != nil {
+		return fmt.Errorf("failed to store wallet: %w", err)
+	}
+	walletsCreatedTotal.Inc()
+	log.Printf("resumed keygen of wallet %s, %d of %d parties had completed", resumed.Address, len(completed), wallet.Parties)
+	return removeCheckpointFile(path)
+}
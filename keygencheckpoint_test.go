@@ -0,0 +1,155 @@
+package main
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"encoding/json"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/bnb-chain/tss-lib/ecdsa/keygen"
+	"github.com/bnb-chain/tss-lib/tss"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/stretchr/testify/assert"
+)
+
+// useKeygenCheckpoints records the keygens of the rest of the test in a new
+// checkpoint store
+func useKeygenCheckpoints(t *testing.T, sc *shareCipher) *keygenCheckpointStore {
+	t.Helper()
+	checkpoints, err := newKeygenCheckpointStore(filepath.Join(t.TempDir(), keygenDirName), sc)
+	if err != nil {
+		t.Fatalf("Failed to create checkpoint store: %v", err)
+	}
+	keygenCheckpoints = checkpoints
+	t.Cleanup(func() { keygenCheckpoints = nil })
+	return checkpoints
+}
+
+// interruptKeygen runs a keygen in which only the first parties complete and
+// leaves its checkpoint behind, as if the process died during the ceremony.
+// It returns the checkpoint written.
+func interruptKeygen(t *testing.T, checkpoints *keygenCheckpointStore, request createWalletRequest, completed int) storedKeygenCheckpoint {
+	t.Helper()
+	dealt := newKeygenParty
+	newKeygenParty = func(params *tss.Parameters, out chan<- tss.Message, end chan<- keygen.LocalPartySaveData, preParams ...keygen.LocalPreParams) tss.Party {
+		if params.PartyID().Index >= completed {
+			end = make(chan keygen.LocalPartySaveData, 1)
+		}
+		return dealt(params, out, end, preParams...)
+	}
+	defer func() { newKeygenParty = dealt }()
+
+	spec, err := newWalletSpec(request)
+	if err != nil {
+		t.Fatalf("Invalid request: %v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	_, err = generateWallet(ctx, spec, checkpoints.start(request), nil)
+	assert.Error(t, err, "The keygen should never complete")
+
+	files, _ := filepath.Glob(filepath.Join(checkpoints.dir, "*.json"))
+	if len(files) != 1 {
+		t.Fatalf("Expected one checkpoint, found %d", len(files))
+	}
+	payload, err := os.ReadFile(files[0])
+	if err != nil {
+		t.Fatalf("Failed to read checkpoint: %v", err)
+	}
+	var checkpoint storedKeygenCheckpoint
+	if err := json.Unmarshal(payload, &checkpoint); err != nil {
+		t.Fatalf("Failed to parse checkpoint: %v", err)
+	}
+	return checkpoint
+}
+
+func TestResumeKeygen(t *testing.T) {
+	useDeterministicKeygen(t, "resume keygen")
+	checkpoints := useKeygenCheckpoints(t, newShareCipher("checkpoint passphrase"))
+
+	const parties = 4
+	request := createWalletRequest{Parties: parties, Threshold: 1, Label: "resumed"}
+	checkpoint := interruptKeygen(t, checkpoints, request, 2)
+	assert.NotEmpty(t, checkpoint.Wallet.EncryptedSaveData, "Key shares should be encrypted")
+	assert.Empty(t, checkpoint.Wallet.SaveData)
+	address := checkpoint.Wallet.Address
+	assert.NotEmpty(t, address)
+
+	// The new parties take fixed pre-parameters instead of looking for safe
+	// primes
+	useFixedPreParams(t, parties)
+
+	resumeKeygens(checkpoints)
+	wallet, err := lookupWallet(address)
+	if err != nil {
+		t.Fatalf("Resumed wallet not found: %v", err)
+	}
+	t.Cleanup(func() {
+		walletsMutex.Lock()
+		delete(wallets, address)
+		walletsMutex.Unlock()
+	})
+	files, _ := os.ReadDir(checkpoints.dir)
+	assert.Empty(t, files, "The checkpoint should be removed once resumed")
+
+	assert.Equal(t, parties, wallet.Parties)
+	assert.Equal(t, 1, wallet.Threshold)
+	assert.Equal(t, "resumed", wallet.Label)
+	assert.Len(t, wallet.SaveData, parties, "Every party should hold a share")
+	interrupted := make(map[string]bool)
+	for _, partyID := range checkpoint.Wallet.PartyIDs {
+		interrupted[partyID.Key] = true
+	}
+	for _, partyID := range wallet.PartyIDs {
+		assert.False(t, interrupted[partyID.KeyInt().Text(16)], "Shares should be reshared to new parties")
+	}
+
+	digest := crypto.Keccak256([]byte("resumed"))
+	sigData, err := signDigest(wallet, nil, digest, nil)
+	if err != nil {
+		t.Fatalf("Failed to sign: %v", err)
+	}
+	r := new(big.Int).SetBytes(sigData.R)
+	s := new(big.Int).SetBytes(sigData.S)
+	assert.True(t, ecdsa.Verify(wallet.PubKey, digest, r, s), "The resumed wallet should sign for its address")
+
+	// Resuming again finds nothing to do
+	resumeKeygens(checkpoints)
+	resumed, _ := lookupWallet(address)
+	assert.Same(t, wallet, resumed)
+}
+
+func TestResumeKeygenTooFewParties(t *testing.T) {
+	useDeterministicKeygen(t, "resume keygen too few parties")
+	checkpoints := useKeygenCheckpoints(t, nil)
+
+	checkpoint := interruptKeygen(t, checkpoints, createWalletRequest{Parties: 3, Threshold: 1}, 1)
+	assert.Len(t, checkpoint.Wallet.SaveData, 1)
+
+	resumeKeygens(checkpoints)
+	_, err := lookupWallet(checkpoint.Wallet.Address)
+	assert.ErrorIs(t, err, ErrWalletNotFound)
+	files, _ := os.ReadDir(checkpoints.dir)
+	assert.Empty(t, files, "A checkpoint that can't be resumed should be removed")
+}
+
+func TestCreateWalletRemovesCheckpoint(t *testing.T) {
+	useDeterministicKeygen(t, "create wallet removes checkpoint")
+	checkpoints := useKeygenCheckpoints(t, nil)
+
+	wallet, err := walletService.CreateWallet(context.Background(), createWalletRequest{Parties: 3, Threshold: 1}, nil)
+	if err != nil {
+		t.Fatalf("Failed to create wallet: %v", err)
+	}
+	t.Cleanup(func() {
+		walletsMutex.Lock()
+		delete(wallets, wallet.Address)
+		walletsMutex.Unlock()
+	})
+	files, _ := os.ReadDir(checkpoints.dir)
+	assert.Empty(t, files, "The checkpoint should be removed once the wallet is stored")
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Limits on the label and metadata attached to a wallet
+const (
+	maxLabelLength         = 128
+	maxMetadataEntries     = 32
+	maxMetadataKeyLength   = 64
+	maxMetadataValueLength = 256
+)
+
+// validateLabels checks the label and metadata of a wallet against the limits
+func validateLabels(label string, metadata map[string]string) error {
+	if len(label) > maxLabelLength {
+		return fmt.Errorf("label must be at most %d bytes", maxLabelLength)
+	}
+	if len(metadata) > maxMetadataEntries {
+		return fmt.Errorf("metadata holds at most %d entries", maxMetadataEntries)
+	}
+	for key, value := range metadata {
+		if key == "" {
+			return errors.New("metadata keys must not be empty")
+		}
+		if len(key) > maxMetadataKeyLength {
+			return fmt.Errorf("metadata keys must be at most %d bytes", maxMetadataKeyLength)
+		}
+		if len(value) > maxMetadataValueLength {
+			return fmt.Errorf("metadata values must be at most %d bytes", maxMetadataValueLength)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCreateWalletLabels(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	useDeterministicKeygen(t, "wallet labels")
+
+	fileStore, err := newFileStore(t.TempDir(), nil)
+	if err != nil {
+		t.Fatalf("Failed to create file store: %v", err)
+	}
+	store = fileStore
+	t.Cleanup(func() { store = nil })
+
+	router := gin.Default()
+	router.POST("/wallet", createWallet)
+	router.GET("/wallet/:address", getWallet)
+
+	metadata := map[string]string{"team": "treasury", "env": "staging"}
+	jsonBody, _ := json.Marshal(createWalletRequest{Parties: 2, Threshold: 1, Label: "hot wallet", Metadata: metadata})
+	w1 := httptest.NewRecorder()
+	req1, _ := http.NewRequest("POST", "/wallet", bytes.NewBuffer(jsonBody))
+	req1.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w1, req1)
+	assert.Equal(t, http.StatusOK, w1.Code)
+
+	var createResponse stringFields
+	err = decodeData(w1.Body.Bytes(), &createResponse)
+	if err != nil {
+		t.Fatalf("Failed to parse create wallet response: %v", err)
+	}
+	walletAddress := createResponse["address"]
+
+	w2 := httptest.NewRecorder()
+	req2, _ := http.NewRequest("GET", "/wallet/"+walletAddress, nil)
+	router.ServeHTTP(w2, req2)
+	var getResponse walletResponse
+	err = decodeData(w2.Body.Bytes(), &getResponse)
+	if err != nil {
+		t.Fatalf("Failed to parse get wallet response: %v", err)
+	}
+	assert.Equal(t, "hot wallet", getResponse.Label)
+	assert.Equal(t, metadata, getResponse.Metadata)
+
+	// Labels survive a reload from the store
+	loaded, err := fileStore.LoadAll()
+	if !assert.NoError(t, err) || !assert.Len(t, loaded, 1) {
+		return
+	}
+	assert.Equal(t, "hot wallet", loaded[0].Label)
+	assert.Equal(t, metadata, loaded[0].Metadata)
+}
+
+func TestCreateWalletInvalidLabels(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	router := gin.Default()
+	router.POST("/wallet", createWallet)
+
+	tooMany := make(map[string]string)
+	for i := 0; i <= maxMetadataEntries; i++ {
+		tooMany[strings.Repeat("k", i+1)] = "v"
+	}
+	for _, requestBody := range []createWalletRequest{
+		{Parties: 2, Threshold: 1, Label: strings.Repeat("a", maxLabelLength+1)},
+		{Parties: 2, Threshold: 1, Metadata: tooMany},
+		{Parties: 2, Threshold: 1, Metadata: map[string]string{"": "value"}},
+		{Parties: 2, Threshold: 1, Metadata: map[string]string{strings.Repeat("k", maxMetadataKeyLength+1): "value"}},
+		{Parties: 2, Threshold: 1, Metadata: map[string]string{"key": strings.Repeat("v", maxMetadataValueLength+1)}},
+	} {
+		jsonBody, _ := json.Marshal(requestBody)
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/wallet", bytes.NewBuffer(jsonBody))
+		req.Header.Set("Content-Type", "application/json")
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	}
+}
+
+func TestListWalletsLabelFilter(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	router := gin.Default()
+	router.GET("/wallets", listWallets)
+
+	labels := map[string]string{
+		"0x00000000000000000000000000000000000000E6": "cold",
+		"0x00000000000000000000000000000000000000e7": "hot",
+		"0x00000000000000000000000000000000000000E8": "cold",
+		"0x00000000000000000000000000000000000000E9": "",
+	}
+	for address, label := range labels {
+		wallet := addFakeWallet(address)
+		wallet.Label = label
+		wallet.Metadata = map[string]string{"label": label}
+	}
+	t.Cleanup(func() {
+		walletsMutex.Lock()
+		for address := range labels {
+			delete(wallets, address)
+		}
+		walletsMutex.Unlock()
+	})
+
+	list := func(query string) listWalletsResponse {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/wallets"+query, nil)
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+		var response listWalletsResponse
+		err := decodeData(w.Body.Bytes(), &response)
+		if err != nil {
+			t.Fatalf("Failed to parse response: %v", err)
+		}
+		return response
+	}
+
+	cold := list("?label=cold")
+	assert.Equal(t, 2, cold.Total)
+	if assert.Len(t, cold.Wallets, 2) {
+		assert.Equal(t, "0x00000000000000000000000000000000000000E6", cold.Wallets[0].Address)
+		assert.Equal(t, "0x00000000000000000000000000000000000000E8", cold.Wallets[1].Address)
+		for _, wallet := range cold.Wallets {
+			assert.Equal(t, "cold", wallet.Label)
+			assert.Equal(t, map[string]string{"label": "cold"}, wallet.Metadata)
+		}
+	}
+
+	hot := list("?label=hot&limit=1")
+	assert.Equal(t, 1, hot.Total)
+	assert.Len(t, hot.Wallets, 1)
+
+	assert.Equal(t, 0, list("?label=warm").Total)
+}
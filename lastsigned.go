@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+)
+
+// lastSignedFlushInterval is how often the last signing times are persisted.
+// Signing only updates the time in memory, a wallet signing often is written
+// once per interval instead of after every signature.
+var lastSignedFlushInterval = 10 * time.Second
+
+// lastSignedWriter persists the last signing time of the wallets that signed
+// since its previous flush
+type lastSignedWriter struct {
+	mu      sync.Mutex
+	pending map[*Wallet]bool
+}
+
+// newLastSignedWriter creates a writer with no pending wallet
+func newLastSignedWriter() *lastSignedWriter {
+	return &lastSignedWriter{pending: make(map[*Wallet]bool)}
+}
+
+// lastSignedTimes persists the last signing times of the wallets
+var lastSignedTimes = newLastSignedWriter()
+
+// mark queues the wallet for the next flush
+func (lw *lastSignedWriter) mark(wallet *Wallet) {
+	lw.mu.Lock()
+	defer lw.mu.Unlock()
+	lw.pending[wallet] = true
+}
+
+// flush writes every queued wallet, unless it was replaced or deleted
+// meanwhile. A failure is only logged as the signatures were produced.
+func (lw *lastSignedWriter) flush() {
+	lw.mu.Lock()
+	pending := lw.pending
+	lw.pending = make(map[*Wallet]bool)
+	lw.mu.Unlock()
+
+	for wallet := range pending {
+		if err := persistLastSigned(wallet); err != nil {
+			log.Printf("failed to persist last signing time of wallet %s: %v", wallet.Address, err)
+		}
+	}
+}
+
+// run flushes the queued wallets every lastSignedFlushInterval until ctx is
+// done. The wallets queued after that are written by a last flush on shutdown.
+func (lw *lastSignedWriter) run(ctx context.Context) {
+	ticker := time.NewTicker(lastSignedFlushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			lw.flush()
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// persistLastSigned writes a wallet to the store for its last signing time.
+// storeMutex keeps it from being deleted or replaced during the write, while
+// walletsMutex is only held to check it is still the current wallet.
+func persistLastSigned(wallet *Wallet) error {
+	if store == nil {
+		return nil
+	}
+	storeMutex.Lock()
+	defer storeMutex.Unlock()
+	walletsMutex.Lock()
+	current := wallets[wallet.Address] == wallet
+	walletsMutex.Unlock()
+	if !current {
+		return nil
+	}
+	return store.Save(wallet)
+}
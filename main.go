@@ -1,43 +1,239 @@
 package main
 
 import (
+	"context"
 	"crypto/ecdsa"
 	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
 	"encoding/hex"
+	"errors"
+	"flag"
 	"fmt"
+	"log"
 	"math/big"
 	"net/http"
-	"strings"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
 	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
 
 	"github.com/bnb-chain/tss-lib/common"
 	tsscrypto "github.com/bnb-chain/tss-lib/crypto"
 	"github.com/bnb-chain/tss-lib/ecdsa/keygen"
 	"github.com/bnb-chain/tss-lib/ecdsa/signing"
+	eddsakeygen "github.com/bnb-chain/tss-lib/eddsa/keygen"
 	"github.com/bnb-chain/tss-lib/tss"
-	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
+// createWalletRequest represents the request body for createWallet endpoint
+type createWalletRequest struct {
+	Parties   int `json:"parties"`
+	Threshold int `json:"threshold"`
+	// Algorithm is either ecdsa (default) or eddsa
+	Algorithm string `json:"algorithm"`
+	// Curve is either secp256k1 (default) or p256 for ECDSA, ed25519 for EdDSA
+	Curve string `json:"curve"`
+	// AddressType is ethereum (default), btc-p2wpkh, btc-p2pkh or solana
+	AddressType string `json:"addressType"`
+	// Label and Metadata are free form information for operators
+	Label    string            `json:"label"`
+	Metadata map[string]string `json:"metadata"`
+	// Policy restricts what the wallet may sign, when set
+	Policy *SigningPolicy `json:"policy"`
+	// PartySpecs describe the parties when parties is given as an array
+	PartySpecs []partySpec `json:"-"`
+}
+
 // signDataRequest represents the request body for signData endpoint
 type signDataRequest struct {
 	Data   string `json:"data"`
 	Wallet string `json:"wallet"`
+	// Message is a text alternative to the encoded data
+	Message string `json:"message"`
+	// DataEncoding of data: hex (default) or base64
+	DataEncoding string `json:"dataEncoding"`
+	// Mode is either raw (default) or eip191 to sign like personal_sign
+	Mode string `json:"mode"`
+	// Hash applied to data before signing: none, keccak256 (default), sha256,
+	// sha3-256 or blake2b-256
+	Hash string `json:"hash"`
+	// RawRecoveryID returns v as 0/1 instead of the Ethereum 27/28
+	RawRecoveryID bool `json:"rawRecoveryId"`
+	// Encoding of the signature field: raw (default), der or eth65
+	Encoding string `json:"encoding"`
+	// Signers names the threshold+1 party IDs taking part in the signing,
+	// the first parties of the wallet are used when empty
+	Signers []string `json:"signers"`
+	// Nonce is used at most once per wallet and Expiry is a Unix timestamp
+	// after which the request is refused, both guard against replays
+	Nonce  string `json:"nonce"`
+	Expiry int64  `json:"expiry"`
 }
 
 // walletsResponse represents the response body for list wallets endpoint
 type walletsResponse struct {
-	Address string `json:"address"`
-	PubKey  string `json:"pubKey"`
+	Address          string            `json:"address"`
+	Addresses        map[string]string `json:"addresses"`
+	PubKey           string            `json:"pubKey"`
+	PubKeyCompressed string            `json:"pubKeyCompressed"`
+	PubKeyFull       string            `json:"pubKeyFull,omitempty"`
+	Algorithm        string            `json:"algorithm"`
+	Curve            string            `json:"curve"`
+	Parties          int               `json:"parties"`
+	Threshold        int               `json:"threshold"`
+	Label            string            `json:"label,omitempty"`
+	Metadata         map[string]string `json:"metadata,omitempty"`
+	CreatedAt        *time.Time        `json:"createdAt,omitempty"`
+	LastSignedAt     *time.Time        `json:"lastSignedAt,omitempty"`
+}
+
+// walletResponse represents the response body for get wallet endpoint
+type walletResponse struct {
+	Address          string            `json:"address"`
+	Addresses        map[string]string `json:"addresses"`
+	PubKey           string            `json:"pubKey"`
+	PubKeyCompressed string            `json:"pubKeyCompressed"`
+	PubKeyFull       string            `json:"pubKeyFull,omitempty"`
+	PubKeyDER        string            `json:"pubKeyDer,omitempty"`
+	PubKeyPEM        string            `json:"pubKeyPem,omitempty"`
+	Algorithm        string            `json:"algorithm"`
+	Curve            string            `json:"curve"`
+	Parties          int               `json:"parties"`
+	Threshold        int               `json:"threshold"`
+	Label            string            `json:"label,omitempty"`
+	Metadata         map[string]string `json:"metadata,omitempty"`
+	Policy           *SigningPolicy    `json:"policy,omitempty"`
+	CreatedAt        *time.Time        `json:"createdAt,omitempty"`
+	LastSignedAt     *time.Time        `json:"lastSignedAt,omitempty"`
 }
 
 // Wallet represents a TSS wallet with its associated data
 type Wallet struct {
 	Address   string
 	PartyIDs  tss.SortedPartyIDs
+	Parties   int
 	Threshold int
+	Curve     tss.CurveName
 	PubKey    *ecdsa.PublicKey
-	SaveData  map[string]*keygen.LocalPartySaveData
+	// Algorithm is ecdsa or eddsa, the key shares of the wallet are in
+	// SaveData or EdDSASaveData accordingly
+	Algorithm     string
+	SaveData      map[string]*keygen.LocalPartySaveData
+	EdDSASaveData map[string]*eddsakeygen.LocalPartySaveData
+	// AddressType is the kind of address the wallet was created for and
+	// Addresses holds the derived addresses by type
+	AddressType string
+	Addresses   map[string]string
+	// Label and Metadata are free form information set by operators
+	Label    string
+	Metadata map[string]string
+	// Policy restricts what the wallet may sign, nil when it may sign anything
+	Policy *SigningPolicy
+	// CreatedAt is when the wallet was created or imported
+	CreatedAt time.Time
+	// lastSignedAt is when the wallet last produced a signature, in Unix
+	// nanoseconds. It changes while the wallet is in use so it is atomic.
+	lastSignedAt atomic.Int64
+	// sharesMutex guards shareHolds, the number of ceremonies using the key
+	// shares, and retired, set once the wallet was replaced or deleted. The
+	// shares of a retired wallet are wiped once no ceremony uses them.
+	sharesMutex sync.Mutex
+	shareHolds  int
+	retired     bool
+}
+
+// LastSignedAt returns when the wallet last produced a signature, the zero
+// time if it never did
+func (w *Wallet) LastSignedAt() time.Time {
+	if nanos := w.lastSignedAt.Load(); nanos != 0 {
+		return time.Unix(0, nanos).UTC()
+	}
+	return time.Time{}
+}
+
+// setLastSignedAt records when the wallet last produced a signature
+func (w *Wallet) setLastSignedAt(t time.Time) {
+	if t.IsZero() {
+		w.lastSignedAt.Store(0)
+		return
+	}
+	w.lastSignedAt.Store(t.UnixNano())
+}
+
+// shareCount returns the number of key shares held by the wallet
+func (w *Wallet) shareCount() int {
+	if w.Algorithm == algorithmEdDSA {
+		return len(w.EdDSASaveData)
+	}
+	return len(w.SaveData)
+}
+
+// zeroShares wipes every key share of the wallet from memory
+func (w *Wallet) zeroShares() {
+	for _, saveData := range w.SaveData {
+		zeroSaveData(saveData)
+	}
+	for _, saveData := range w.EdDSASaveData {
+		zeroEdDSASaveData(saveData)
+	}
+}
+
+// holdShares keeps the key shares of the wallet from being wiped until
+// releaseShares is called. It fails once the wallet was replaced or deleted.
+func (w *Wallet) holdShares() error {
+	w.sharesMutex.Lock()
+	defer w.sharesMutex.Unlock()
+	if w.retired {
+		return errWalletRetired
+	}
+	w.shareHolds++
+	return nil
+}
+
+// releaseShares ends a holdShares, wiping the shares of a retired wallet
+// once no ceremony uses them anymore
+func (w *Wallet) releaseShares() {
+	w.sharesMutex.Lock()
+	defer w.sharesMutex.Unlock()
+	w.shareHolds--
+	if w.retired && w.shareHolds == 0 {
+		w.zeroShares()
+	}
+}
+
+// retire wipes the key shares of a wallet that was replaced or deleted. The
+// ceremonies still using them complete first, the last one wipes them.
+func (w *Wallet) retire() {
+	w.sharesMutex.Lock()
+	defer w.sharesMutex.Unlock()
+	w.retired = true
+	if w.shareHolds == 0 {
+		w.zeroShares()
+	}
+}
+
+// pubKeyHex returns the public key of the wallet along with its compressed
+// and full forms. ECDSA keys are X || Y, the SEC1 compressed point and the 65
+// byte uncompressed point with its 0x04 prefix, as expected by Ethereum
+// tooling. EdDSA keys only have their 32 byte Ed25519 encoding and no full
+// form.
+func (w *Wallet) pubKeyHex() (string, string, string) {
+	if w.Algorithm == algorithmEdDSA {
+		encoded := fmt.Sprintf("0x%x", ed25519PubKeyBytes(w.PubKey))
+		return encoded, encoded, ""
+	}
+	// The first byte of the uncompressed key is a prefix, it is left out of
+	// pubKey
+	uncompressed := pubKeyBytes(w.PubKey)
+	return fmt.Sprintf("0x%x", uncompressed[1:]), fmt.Sprintf("0x%x", compressedPubKeyBytes(w.PubKey)), fmt.Sprintf("0x%x", uncompressed)
 }
 
 // keygenResult holds the result of the key generation for a party
@@ -46,174 +242,912 @@ type keygenResult struct {
 	Save    keygen.LocalPartySaveData
 }
 
-// Global variables to store wallets and synchronize access
+// Global variables to store wallets and synchronize access. storeMutex
+// orders the writes of the store, it is taken before walletsMutex so a wallet
+// can be written while walletsMutex is released, without being deleted or
+// replaced meanwhile. Lookups and lists only take walletsMutex, briefly.
 var (
 	wallets      = make(map[string]*Wallet)
 	walletsMutex sync.Mutex
+	storeMutex   sync.Mutex
+)
+
+// Default wallet configuration used when createWallet gets no request body
+const (
+	defaultParties   = 3
+	defaultThreshold = 1
 )
 
 func main() {
-	r := gin.Default()
-	r.POST("/wallet", createWallet)
-	r.GET("/wallets", listWallets)
-	r.POST("/sign", signData)
-	r.Run(":8080")
+	if len(os.Args) > 1 && os.Args[1] == signCommand {
+		useTSSLogger(levelWarning, log.Default())
+		os.Exit(runSignCommand(os.Args[2:], os.Stdout, os.Stderr))
+	}
+	defaultAddr := defaultListenAddr
+	if addr := os.Getenv(listenAddrEnv); addr != "" {
+		defaultAddr = addr
+	}
+	addr := flag.String("addr", defaultAddr, "address to listen on, also read from "+listenAddrEnv)
+	defaultGinMode := gin.ReleaseMode
+	if mode := os.Getenv(ginModeEnv); mode != "" {
+		defaultGinMode = mode
+	}
+	ginMode := flag.String("gin-mode", defaultGinMode, "gin mode, debug logs every route and request, also read from "+ginModeEnv)
+	defaultMaxWallets := maxWallets
+	if value := os.Getenv(maxWalletsEnv); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil {
+			log.Fatalf("invalid %s: %v", maxWalletsEnv, err)
+		}
+		defaultMaxWallets = limit
+	}
+	flag.IntVar(&maxWallets, "max-wallets", defaultMaxWallets, "wallets the service may hold, creations and imports beyond it get a 507, 0 disables the limit, also read from "+maxWalletsEnv)
+	defaultMaxBodySize := maxBodySize
+	if value := os.Getenv(maxBodySizeEnv); value != "" {
+		limit, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			log.Fatalf("invalid %s: %v", maxBodySizeEnv, err)
+		}
+		defaultMaxBodySize = limit
+	}
+	flag.Int64Var(&maxBodySize, "max-body-size", defaultMaxBodySize, "bytes accepted in the body of a POST request, larger bodies get a 413, 0 disables the limit, also read from "+maxBodySizeEnv)
+	defaultTSSLevel := defaultTSSLogLevel
+	if value := os.Getenv(tssLogLevelEnv); value != "" {
+		defaultTSSLevel = value
+	}
+	tssLogLevelName := flag.String("tss-log-level", defaultTSSLevel, "lowest level of the tss-lib messages kept in the log: debug, info, warning or error, also read from "+tssLogLevelEnv)
+	dataDir := flag.String("data-dir", "", "directory where wallets are persisted, persistence is disabled when empty")
+	apiKeysFile := flag.String("api-keys-file", "", "file listing the accepted API keys, one per line")
+	adminKeysFile := flag.String("admin-keys-file", "", "file listing the admin keys allowed to manage API keys and reconstruct private keys, admin routes are disabled when empty")
+	hmacKeysFile := flag.String("hmac-keys-file", "", "file listing the secrets accepted to sign requests, one <key id>:<secret> per line")
+	flag.DurationVar(&hmacMaxSkew, "hmac-max-skew", hmacMaxSkew, "how far the timestamp of a signed request may be from the time of the service")
+	disableAuth := flag.Bool("disable-auth", false, "accept requests without an API key, for local development only")
+	flag.DurationVar(&keygenTimeout, "keygen-timeout", keygenTimeout, "time allowed for a keygen or resharing ceremony")
+	flag.DurationVar(&signTimeout, "sign-timeout", signTimeout, "time allowed for a signing ceremony")
+	flag.IntVar(&keygenAttempts, "keygen-attempts", keygenAttempts, "keygen ceremonies run for a wallet creation when one of the parties fails, with a backoff between them")
+	flag.Float64Var(&apiRateLimit, "rate-limit", apiRateLimit, "requests per minute allowed for each API key, 0 disables the limit")
+	flag.Float64Var(&keygenRateLimit, "keygen-rate-limit", keygenRateLimit, "wallet creations, reshares and refreshes per minute allowed for each API key, 0 disables the limit")
+	flag.IntVar(&maxCeremonies, "max-ceremonies", maxCeremonies, "keygen, signing and resharing ceremonies allowed to run at once, 0 disables the limit")
+	flag.IntVar(&maxQueuedCeremonies, "max-queued-ceremonies", maxQueuedCeremonies, "ceremonies allowed to wait for a free slot, requests beyond it get a 503")
+	flag.IntVar(&messageBufferRounds, "message-buffer-rounds", messageBufferRounds, "rounds of messages buffered for each party of a ceremony before it has to wait")
+	flag.DurationVar(&lastSignedFlushInterval, "last-signed-flush-interval", lastSignedFlushInterval, "how often the last signing times of the wallets are persisted")
+	flag.DurationVar(&shutdownTimeout, "shutdown-timeout", shutdownTimeout, "time allowed for in-flight requests to complete on shutdown")
+	flag.DurationVar(&readHeaderTimeout, "read-header-timeout", readHeaderTimeout, "time allowed for a client to send the headers of a request, 0 uses --read-timeout")
+	flag.DurationVar(&readTimeout, "read-timeout", readTimeout, "time allowed for a client to send a whole request, 0 disables the timeout")
+	flag.DurationVar(&writeTimeout, "write-timeout", writeTimeout, "time allowed to respond to a request, ceremonies included, 0 disables the timeout")
+	flag.DurationVar(&idleTimeout, "idle-timeout", idleTimeout, "time an idle keep-alive connection is kept open, 0 uses --read-timeout")
+	corsOrigins := flag.String("cors-origins", defaultCORSOrigins, "comma separated origins allowed to call the API from a browser, * allows any, none by default")
+	corsMethods := flag.String("cors-methods", defaultCORSMethods, "comma separated methods allowed in cross-origin requests")
+	corsHeaders := flag.String("cors-headers", defaultCORSHeaders, "comma separated headers allowed in cross-origin requests")
+	corsStrict := flag.Bool("cors-strict", false, "require explicit CORS origins and reject requests from other origins with a 403")
+	tlsCert := flag.String("tls-cert", "", "PEM certificate served over TLS, the service speaks plain HTTP when empty")
+	tlsKey := flag.String("tls-key", "", "PEM private key of the TLS certificate")
+	tlsClientCA := flag.String("tls-client-ca", "", "PEM CAs verifying client certificates, API routes then require one")
+	preParamsPoolSize := flag.Int("preparams-pool", 0, "keygen pre-parameters generated ahead of time, each party of a keygen uses one, 0 disables the pool")
+	preParamsDir := flag.String("preparams-dir", "", "directory of keygen pre-parameters, loaded into the pool at startup")
+	generatePreParamsCount := flag.Int("generate-preparams", 0, "generate this many keygen pre-parameters into --preparams-dir and exit")
+	flag.Parse()
+	if err := setGinMode(*ginMode); err != nil {
+		log.Fatalf("invalid --gin-mode: %v", err)
+	}
+	tssLevel, err := parseLogLevel(*tssLogLevelName)
+	if err != nil {
+		log.Fatalf("invalid --tss-log-level: %v", err)
+	}
+	useTSSLogger(tssLevel, log.Default())
+
+	var sc *shareCipher
+	if passphrase := os.Getenv(encryptionKeyEnv); passphrase != "" {
+		sc = newShareCipher(passphrase)
+	}
+	if *generatePreParamsCount > 0 {
+		if *preParamsDir == "" {
+			log.Fatalf("--generate-preparams requires --preparams-dir")
+		}
+		if err := generatePreParamsFiles(*preParamsDir, *generatePreParamsCount, sc); err != nil {
+			log.Fatalf("failed to generate pre-parameters: %v", err)
+		}
+		return
+	}
+	ceremonyLimit = newCeremonyLimiter(maxCeremonies, maxQueuedCeremonies)
+	if messageBufferRounds < 1 {
+		log.Fatalf("--message-buffer-rounds must be at least 1")
+	}
+	if keygenAttempts < 1 {
+		log.Fatalf("--keygen-attempts must be at least 1")
+	}
+	if err := validateServerTimeouts(); err != nil {
+		log.Fatalf("invalid server timeouts: %v", err)
+	}
+	if maxWallets < 0 {
+		log.Fatalf("--max-wallets must not be negative")
+	}
+	if maxBodySize < 0 {
+		log.Fatalf("--max-body-size must not be negative")
+	}
+	if hmacMaxSkew <= 0 {
+		log.Fatalf("--hmac-max-skew must be positive")
+	}
+	if lastSignedFlushInterval <= 0 {
+		log.Fatalf("--last-signed-flush-interval must be positive")
+	}
+
+	policy, err := newCORSPolicy(*corsOrigins, *corsMethods, *corsHeaders, *corsStrict)
+	if err != nil {
+		log.Fatalf("invalid CORS settings: %v", err)
+	}
+	cors = policy
+
+	var tlsConfig *tls.Config
+	if *tlsCert != "" || *tlsKey != "" {
+		tlsConfig, err = newTLSConfig(*tlsCert, *tlsKey, *tlsClientCA)
+		if err != nil {
+			log.Fatalf("invalid TLS settings: %v", err)
+		}
+		requireClientCerts = *tlsClientCA != ""
+	} else if *tlsClientCA != "" {
+		log.Fatalf("--tls-client-ca requires --tls-cert and --tls-key")
+	}
+
+	var keys *apiKeySet
+	if *disableAuth {
+		log.Printf("warning: authentication is disabled, anyone can reach the API")
+	} else {
+		apiKeys, err := loadAPIKeys(*apiKeysFile)
+		if err != nil {
+			log.Fatalf("failed to load API keys: %v", err)
+		}
+		keys = newAPIKeySet(apiKeys)
+		if *hmacKeysFile != "" {
+			lines, err := readKeysFile(*hmacKeysFile)
+			if err != nil {
+				log.Fatalf("failed to load HMAC keys: %v", err)
+			}
+			hmacKeys, err = parseHMACKeys(lines)
+			if err != nil {
+				log.Fatalf("invalid HMAC keys: %v", err)
+			}
+		}
+		if keys.len() == 0 && hmacKeys.len() == 0 {
+			log.Fatalf("no API keys configured, set %s, --api-keys-file or --hmac-keys-file, or pass --disable-auth", apiKeysEnv)
+		}
+	}
+
+	if *adminKeysFile != "" {
+		adminKeys, err := readKeysFile(*adminKeysFile)
+		if err != nil {
+			log.Fatalf("failed to load admin keys: %v", err)
+		}
+		adminAPIKeys = newAPIKeySet(adminKeys)
+		if adminAPIKeys.len() == 0 {
+			log.Fatalf("--admin-keys-file lists no admin key")
+		}
+		log.Printf("warning: admin routes are enabled, admin keys can reconstruct private keys")
+	}
+
+	if *dataDir != "" {
+		if sc == nil {
+			log.Printf("warning: %s is not set, key shares are stored unencrypted", encryptionKeyEnv)
+		}
+		fileStore, err := newFileStore(*dataDir, sc)
+		if err != nil {
+			log.Fatalf("failed to open wallet store: %v", err)
+		}
+		if err := loadWallets(fileStore); err != nil {
+			log.Fatalf("failed to load wallets: %v", err)
+		}
+		store = fileStore
+		signatureJournal, err = openSigningJournal(filepath.Join(*dataDir, journalDirName))
+		if err != nil {
+			log.Fatalf("failed to open signing journal: %v", err)
+		}
+		keygenCheckpoints, err = newKeygenCheckpointStore(filepath.Join(*dataDir, keygenDirName), sc)
+		if err != nil {
+			log.Fatalf("failed to open keygen checkpoints: %v", err)
+		}
+	}
+	ready.Store(true)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	var loadedPreParams []*keygen.LocalPreParams
+	if *preParamsDir != "" {
+		loadedPreParams, err = loadPreParams(*preParamsDir, sc)
+		if err != nil {
+			log.Fatalf("failed to load pre-parameters: %v", err)
+		}
+		log.Printf("loaded %d keygen pre-parameters", len(loadedPreParams))
+	}
+	if *preParamsPoolSize > 0 || len(loadedPreParams) > 0 {
+		keygenPreParams = newPreParamsPool(max(*preParamsPoolSize, len(loadedPreParams)))
+		for _, preParams := range loadedPreParams {
+			keygenPreParams.add(preParams)
+		}
+	}
+	if *preParamsPoolSize > 0 {
+		go keygenPreParams.fill(ctx)
+	}
+	if keygenCheckpoints != nil {
+		// Interrupted keygens are resumed in the background, their wallets
+		// show up once resharing is over
+		go resumeKeygens(keygenCheckpoints)
+	}
+
+	ln, err := listen(*addr)
+	if err != nil {
+		log.Fatalf("failed to listen: %v", err)
+	}
+	if tlsConfig != nil {
+		log.Printf("listening on %s with TLS", ln.Addr())
+	} else {
+		log.Printf("listening on %s", ln.Addr())
+	}
+	if store != nil {
+		go lastSignedTimes.run(ctx)
+	}
+	srv := newServer(newRouter(keys), tlsConfig)
+	err = serve(ctx, srv, ln)
+	// The signatures of the requests drained by the shutdown are persisted
+	// before exiting
+	lastSignedTimes.flush()
+	if err != nil {
+		log.Fatalf("server failed: %v", err)
+	}
+}
+
+// newRouter registers the API routes. When keys is not nil every route but
+// the health checks and metrics requires one of the API keys, or a request
+// signed with one of the HMAC keys. API routes are
+// rate limited, with a stricter limit on the ceremonies creating key shares.
+// Browser origins are checked against the cors policy, and with
+// requireClientCerts API routes need a verified client certificate. POST
+// bodies are bounded by maxBodySize and must be JSON. Only debug mode logs
+// the probes.
+func newRouter(keys *apiKeySet) *gin.Engine {
+	r := gin.New()
+	r.Use(requestLogger(), gin.Recovery())
+	r.Use(instrumentHandlers())
+	r.Use(allowCORS(cors))
+	r.Use(limitBodySize(maxBodySize))
+	r.Use(requireJSON())
+	r.GET("/metrics", gin.WrapH(promhttp.Handler()))
+	r.GET("/health", healthCheck)
+	r.GET("/ready", readinessCheck)
+	r.NoRoute(func(c *gin.Context) {
+		respondError(c, http.StatusNotFound, "route not found")
+	})
+
+	api := r.Group("/")
+	if requireClientCerts {
+		api.Use(requireClientCert())
+	}
+	if keys != nil {
+		api.Use(requestAuth(keys))
+	}
+	api.Use(rateLimit(newRateLimiter(apiRateLimit)))
+	keygenLimit := rateLimit(newRateLimiter(keygenRateLimit))
+	api.POST("/wallet", keygenLimit, createWallet)
+	api.POST("/wallet/preview", keygenLimit, previewWallet)
+	api.POST("/wallet/import", importWallet)
+	api.GET("/wallet/:address", getWallet)
+	api.GET("/wallet/:address/export", exportWallet)
+	api.GET("/wallet/:address/signatures", listSignatures)
+	api.DELETE("/wallet/:address", deleteWallet)
+	api.POST("/wallet/:address/reshare", keygenLimit, reshareWallet)
+	api.POST("/wallet/:address/refresh", keygenLimit, refreshWallet)
+	api.POST("/wallet/:address/recover", keygenLimit, recoverWallet)
+	api.GET("/wallets", listWallets)
+	api.GET("/wallets/count", countWallets)
+	api.GET("/status", serviceStatus)
+	api.GET("/capabilities", getCapabilities)
+	api.POST("/sign", signData)
+	api.POST("/sign/typed-data", signTypedData)
+	api.POST("/sign/batch", signBatch)
+	api.POST("/sign/multi", signMulti)
+	api.POST("/sign/tx", signTx)
+	api.POST("/sign/raw", signRaw)
+	api.POST("/verify", verifyData)
+	api.POST("/verify/pubkey", verifyPubKey)
+	api.GET("/ws", signSocket)
+
+	if adminAPIKeys != nil {
+		admin := r.Group("/admin")
+		if requireClientCerts {
+			admin.Use(requireClientCert())
+		}
+		admin.Use(apiKeyAuth(adminAPIKeys))
+		admin.POST("/wallet/:address/reconstruct", reconstructKey)
+		admin.GET("/wallet/:address/shares", getPublicShares)
+		if keys != nil {
+			admin.GET("/api-keys", listAPIKeys(keys))
+			admin.POST("/api-keys", addAPIKey(keys))
+			admin.DELETE("/api-keys/:id", revokeAPIKey(keys))
+		}
+	}
+	return r
 }
 
-// createWallet handles the creation of a new TSS wallet
+// createWallet handles the creation of a new TSS wallet. A request carrying
+// an Idempotency-Key already used by the client gets the address of the wallet
+// created then instead of a new wallet.
 func createWallet(c *gin.Context) {
-	parties := 3
-	threshold := 1
+	spec, err := bindWalletSpec(c)
+	if err != nil {
+		respondServiceError(c, err)
+		return
+	}
+	idempotencyKey := c.GetHeader(idempotencyKeyHeader)
+	if err := validateIdempotencyKey(idempotencyKey); err != nil {
+		respondError(c, http.StatusBadRequest, err.Error())
+		return
+	}
 
-	// Lock wallets map to get the current count and avoid race conditions
-	walletsMutex.Lock()
-	existingWallets := len(wallets)
-	walletsMutex.Unlock()
+	ctx := c.Request.Context()
+	apiKeyID := c.GetString(apiKeyIDContextKey)
+	streamCeremony(c, func(onRound func(round int)) (int, any) {
+		create := func() (string, error) {
+			wallet, err := walletService.create(ctx, spec, onRound)
+			if err != nil {
+				return "", err
+			}
+			return wallet.Address, nil
+		}
+		if idempotencyKey == "" {
+			address, err := create()
+			if err != nil {
+				return serviceErrorResponse(err)
+			}
+			return http.StatusOK, dataResponse(createWalletResponse(address))
+		}
 
-	// Generate unique party IDs
-	partyIDs := make([]*tss.PartyID, parties)
-	key := common.MustGetRandomInt(256)
-	for i := 0; i < parties; i++ {
-		id := fmt.Sprintf("%d", existingWallets+i)
-		moniker := fmt.Sprintf("P[%d]", existingWallets+i)
-		// Ensure unique key for each party
-		keyShare := new(big.Int).Sub(key, big.NewInt(int64(existingWallets)-int64(i)))
-		partyIDs[i] = tss.NewPartyID(id, moniker, keyShare)
+		address, err := walletIdempotencyKeys.create(ctx, apiKeyID, idempotencyKey, spec.request, create)
+		if err != nil {
+			return serviceErrorResponse(err)
+		}
+		return http.StatusOK, dataResponse(createWalletResponse(address))
+	})
+}
+
+// partyResponse identifies a party of a wallet, so that operators can map it
+// to the node holding its key share
+type partyResponse struct {
+	ID      string `json:"id"`
+	Moniker string `json:"moniker"`
+}
+
+// createWalletResponse returns the response body of a wallet creation: the
+// address of the wallet and its parties, unless the wallet was deleted since
+func createWalletResponse(address string) gin.H {
+	response := gin.H{"address": address}
+	if wallet, err := lookupWallet(address); err == nil {
+		parties := make([]partyResponse, len(wallet.PartyIDs))
+		for i, partyID := range wallet.PartyIDs {
+			parties[i] = partyResponse{ID: partyID.Id, Moniker: partyID.Moniker}
+		}
+		response["parties"] = parties
 	}
-	partyIDs = tss.SortPartyIDs(partyIDs)
-	ctx := tss.NewPeerContext(partyIDs)
+	return response
+}
+
+// bindWalletSpec reads and validates the optional body of a wallet creation,
+// the default configuration is used without a body
+func bindWalletSpec(c *gin.Context) (walletSpec, error) {
+	requestBody := defaultCreateWalletRequest()
+	if c.Request.Body != nil && c.Request.ContentLength != 0 {
+		if err := c.BindJSON(&requestBody); err != nil {
+			return walletSpec{}, invalidRequest(errors.New("invalid request body"))
+		}
+	}
+	return newWalletSpec(requestBody)
+}
+
+// Attempts of a keygen ceremony failing with a party error, which may be
+// transient, and the wait before the first retry, doubled on every retry
+var (
+	keygenAttempts     = 3
+	keygenRetryBackoff = 500 * time.Millisecond
+)
+
+// generateWallet runs a keygen ceremony for a validated wallet creation and
+// returns the new wallet without storing it. A ceremony failed by one of its
+// parties is run again from scratch, with new party IDs and new secrets, up
+// to keygenAttempts times. The parties completing each attempt are recorded
+// in the checkpoint, when not nil.
+func generateWallet(ctx context.Context, spec walletSpec, checkpoint *keygenCheckpoint, onRound func(round int)) (*Wallet, error) {
+	defer trackActive(&activeKeygens)()
+	backoff := keygenRetryBackoff
+	var wallet *Wallet
+	var err error
+	for attempt := 1; ; attempt++ {
+		wallet, err = runKeygenAttempt(ctx, spec, checkpoint, onRound)
+		var partyErr *tss.Error
+		if err == nil || attempt >= keygenAttempts || !errors.As(err, &partyErr) {
+			break
+		}
+		log.Printf("keygen attempt %d of %d failed, retrying in %s: %v", attempt, keygenAttempts, backoff, err)
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return nil, err
+		}
+		backoff *= 2
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := applyWalletRequest(wallet, spec.request); err != nil {
+		return nil, err
+	}
+	return wallet, nil
+}
+
+// applyWalletRequest sets the options of a wallet creation that are not part
+// of the keygen on the new wallet
+func applyWalletRequest(wallet *Wallet, request createWalletRequest) error {
+	addresses, err := deriveAddresses(wallet.PubKey, request.AddressType)
+	if err != nil {
+		return err
+	}
+	wallet.Label = request.Label
+	wallet.Metadata = request.Metadata
+	wallet.Policy = request.Policy
+	wallet.AddressType = request.AddressType
+	wallet.Addresses = addresses
+	return nil
+}
+
+// runKeygenAttempt runs a single keygen ceremony between new parties. Only
+// ECDSA keygens, the long ones, are checkpointed, as only ECDSA wallets can be
+// reshared when resuming.
+func runKeygenAttempt(ctx context.Context, spec walletSpec, checkpoint *keygenCheckpoint, onRound func(round int)) (*Wallet, error) {
+	partyIDs, err := newPartyIDs(spec.request.Parties, spec.curve)
+	if err != nil {
+		return nil, err
+	}
+	if err := applyPartySpecs(partyIDs, spec.request.PartySpecs); err != nil {
+		return nil, err
+	}
+	if spec.algorithm == algorithmEdDSA {
+		return runEdDSAKeygen(ctx, partyIDs, spec.request.Threshold, onRound)
+	}
+	checkpoint.begin(partyIDs, spec.request.Threshold, spec.curveName)
+	return runKeygen(ctx, partyIDs, spec.request.Threshold, spec.curveName, spec.curve, checkpoint, onRound)
+}
+
+// validateWalletConfig checks the number of parties and the threshold of a wallet
+func validateWalletConfig(parties, threshold int) error {
+	if parties < 2 {
+		return errors.New("parties must be at least 2")
+	}
+	if threshold <= 0 || threshold >= parties {
+		return errors.New("threshold must be greater than 0 and less than parties")
+	}
+	return nil
+}
+
+// newKeygenParty creates a keygen party, using the pre-parameters when given
+// one set. Tests replace it to inject failures, or to deal reproducible key
+// shares without running the ceremony.
+var newKeygenParty = func(params *tss.Parameters, out chan<- tss.Message, end chan<- keygen.LocalPartySaveData, preParams ...keygen.LocalPreParams) tss.Party {
+	return keygen.NewLocalParty(params, out, end, preParams...)
+}
+
+// newSigningParty creates a signing party, tests replace it to stall signing
+var newSigningParty = func(msg *big.Int, params *tss.Parameters, key keygen.LocalPartySaveData, out chan<- tss.Message, end chan<- common.SignatureData) tss.Party {
+	return signing.NewLocalParty(msg, params, key, out, end)
+}
+
+// runKeygen runs a keygen ceremony between the given parties and returns the
+// resulting wallet. onRound, when set, is called as each round completes, and
+// the key share of every party is recorded in the checkpoint, when not nil, as
+// soon as it completes. The ceremony is abandoned once ctx is done.
+func runKeygen(ctx context.Context, partyIDs tss.SortedPartyIDs, threshold int, curveName tss.CurveName, curve elliptic.Curve, checkpoint *keygenCheckpoint, onRound func(round int)) (wallet *Wallet, err error) {
+	start := time.Now()
+	defer func() {
+		if err != nil {
+			failuresTotal.WithLabelValues(operationKeygen).Inc()
+			return
+		}
+		keygenDurationSeconds.Observe(time.Since(start).Seconds())
+	}()
+
+	parties := len(partyIDs)
+	peerCtx := tss.NewPeerContext(partyIDs)
 
 	// Channels for communication
-	errCh := make(chan *tss.Error)
-	outChs := make([]chan tss.Message, parties)
-	endChs := make([]chan keygen.LocalPartySaveData, parties)
+	cer, err := newCeremony(ctx, parties, keygenTimeout)
+	if err != nil {
+		return nil, err
+	}
+	defer cer.close()
 	resultCh := make(chan keygenResult, parties)
-	messages := make(chan tss.Message, parties*parties)
 
 	// Start key generation parties
-	partiesList := make([]*keygen.LocalParty, parties)
+	partiesList := make([]tss.Party, parties)
 	for i, partyID := range partyIDs {
-		params := tss.NewParameters(tss.S256(), ctx, partyID, parties, threshold)
-		outCh := make(chan tss.Message, parties*parties)
+		params := tss.NewParameters(curve, peerCtx, partyID, parties, threshold)
 		endCh := make(chan keygen.LocalPartySaveData, 1)
-		outChs[i] = outCh
-		endChs[i] = endCh
-		party := keygen.NewLocalParty(params, outCh, endCh).(*keygen.LocalParty)
+		var preParams []keygen.LocalPreParams
+		if pooled := keygenPreParams.take(); pooled != nil {
+			preParams = append(preParams, *pooled)
+		}
+		party := newKeygenParty(params, cer.outChs[i], endCh, preParams...)
 		partiesList[i] = party
 
 		// Start each party in a separate goroutine
-		go func(p *keygen.LocalParty, partyID *tss.PartyID) {
-			if err := p.Start(); err != nil {
-				errCh <- err
+		cer.run(func() {
+			if err := party.Start(); err != nil {
+				cer.fail(err)
 				return
 			}
-			save := <-endCh
-			resultCh <- keygenResult{PartyID: partyID, Save: save}
-		}(party, partyID)
-	}
-
-	// Forward messages from parties to the messages channel
-	for _, outCh := range outChs {
-		go func(ch chan tss.Message) {
-			for msg := range ch {
-				messages <- msg
+			select {
+			case save := <-endCh:
+				resultCh <- keygenResult{PartyID: partyID, Save: save}
+			case <-cer.ctx.Done():
 			}
-		}(outCh)
+		})
 	}
 
+	router := newPartyRouter(partiesList, cer.deliver)
+	progress := newRoundProgress(algorithmECDSA, operationKeygen, parties, onRound)
+	defer progress.logTimings()
+
 	// Handle message passing and collect results
-	var wg sync.WaitGroup
-	wg.Add(1)
-	go func() {
-		defer wg.Done()
-		saves := make(map[string]*keygen.LocalPartySaveData)
-		var pubKey *tsscrypto.ECPoint
-		for {
-			select {
-			case err := <-errCh:
-				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-				return
-			case msg := <-messages:
-				wireBytes, _, err := msg.WireBytes()
-				if err != nil {
-					errCh <- tss.NewError(err, "failed to serialize wire bytes", 0, msg.GetFrom(), nil)
-					return
-				}
-				dest := msg.GetTo()
-				if dest == nil { // Broadcast message
-					for _, p := range partiesList {
-						if p.PartyID().Id == msg.GetFrom().Id {
-							continue
-						}
-						go func(p *keygen.LocalParty) {
-							if _, err := p.UpdateFromBytes(wireBytes, msg.GetFrom(), msg.IsBroadcast()); err != nil {
-								errCh <- err
-							}
-						}(p)
-					}
-				} else { // Point-to-point message
-					for _, to := range dest {
-						for _, p := range partiesList {
-							if p.PartyID().Id == to.Id {
-								go func(p *keygen.LocalParty) {
-									if _, err := p.UpdateFromBytes(wireBytes, msg.GetFrom(), msg.IsBroadcast()); err != nil {
-										errCh <- err
-									}
-								}(p)
-								break
-							}
-						}
-					}
-				}
-			case result := <-resultCh:
-				partyIDStr := result.PartyID.Id
-				saves[partyIDStr] = &result.Save
-				if pubKey == nil {
-					pubKey = result.Save.ECDSAPub
-				}
-				if len(saves) == parties {
-					// All parties have completed keygen
-					x, y := pubKey.X(), pubKey.Y()
-					pubKeyECDSA := ecdsa.PublicKey{
-						Curve: elliptic.P256(),
-						X:     x,
-						Y:     y,
-					}
-					address := crypto.PubkeyToAddress(pubKeyECDSA).Hex()
-
-					wallet := &Wallet{
-						Address:   address,
-						PubKey:    &pubKeyECDSA,
-						SaveData:  saves,
-						PartyIDs:  partyIDs,
-						Threshold: threshold,
-					}
-					walletsMutex.Lock()
-					wallets[address] = wallet
-					walletsMutex.Unlock()
-					c.JSON(http.StatusOK, gin.H{"address": address})
-					return
+	saves := make(map[string]*keygen.LocalPartySaveData)
+	var pubKey *tsscrypto.ECPoint
+	for {
+		select {
+		case <-cer.ctx.Done():
+			return nil, cer.err()
+		case err := <-cer.errCh:
+			return nil, err
+		case msg := <-cer.messages:
+			if err := router.Route(msg); err != nil {
+				return nil, err
+			}
+			progress.observe(msg)
+		case result := <-resultCh:
+			partyIDStr := result.PartyID.Id
+			saves[partyIDStr] = &result.Save
+			checkpoint.record(result.PartyID, &result.Save)
+			if pubKey == nil {
+				pubKey = result.Save.ECDSAPub
+			}
+			if len(saves) == parties {
+				// All parties have completed keygen
+				x, y := pubKey.X(), pubKey.Y()
+				pubKeyECDSA := ecdsa.PublicKey{
+					Curve: curve,
+					X:     x,
+					Y:     y,
 				}
+
+				return &Wallet{
+					Address:   deriveAddress(&pubKeyECDSA),
+					PubKey:    &pubKeyECDSA,
+					Algorithm: algorithmECDSA,
+					SaveData:  saves,
+					PartyIDs:  partyIDs,
+					Parties:   parties,
+					Threshold: threshold,
+					Curve:     curveName,
+				}, nil
 			}
 		}
-	}()
-	wg.Wait()
+	}
 }
 
-// listWallets returns a list of all created wallets
-func listWallets(c *gin.Context) {
+// maxPartyKeyAttempts is how many keys are drawn for a party before giving up
+// when each of them collides with the key of another party
+const maxPartyKeyAttempts = 5
+
+// errPartyKeyCollision is returned when no unique key could be drawn for a
+// party, which only a broken entropy source makes happen
+var errPartyKeyCollision = errors.New("failed to generate a unique party key")
+
+// countWallets returns the number of wallets, without the cost of listing them
+func countWallets(c *gin.Context) {
 	walletsMutex.Lock()
-	defer walletsMutex.Unlock()
+	count := len(wallets)
+	walletsMutex.Unlock()
+	respond(c, http.StatusOK, gin.H{"count": count})
+}
+
+// newPartyIDs generates the sorted party IDs of a new wallet. Every party gets
+// its own random key, so the IDs never depend on how many wallets already
+// exist and concurrent keygens can't collide. tss-lib requires distinct keys,
+// a key already taken by another party or by one of the reserved parties,
+// such as the old committee of a resharing, is drawn again.
+func newPartyIDs(parties int, curve elliptic.Curve, reserved ...*tss.PartyID) (tss.SortedPartyIDs, error) {
+	// Keys are used as VSS share indexes, so they must be in [1, N-1]
+	maxKey := new(big.Int).Sub(curve.Params().N, big.NewInt(1))
+	taken := make(map[string]bool, parties+len(reserved))
+	for _, partyID := range reserved {
+		taken[partyID.KeyInt().String()] = true
+	}
+
+	partyIDs := make(tss.UnSortedPartyIDs, parties)
+	for i := 0; i < parties; i++ {
+		key, err := newPartyKey(maxKey, taken)
+		if err != nil {
+			return nil, err
+		}
+		taken[key.String()] = true
+		partyIDs[i] = tss.NewPartyID(fmt.Sprintf("%d", i), fmt.Sprintf("P[%d]", i), key)
+	}
+	return tss.SortPartyIDs(partyIDs), nil
+}
 
-	walletsResp := make([]walletsResponse, 0, len(wallets))
-	for addr, wallet := range wallets {
+// newPartyKey draws a party key in [1, maxKey] that is not taken
+func newPartyKey(maxKey *big.Int, taken map[string]bool) (*big.Int, error) {
+	for attempt := 1; attempt <= maxPartyKeyAttempts; attempt++ {
+		key, err := rand.Int(entropySource, maxKey)
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate party key: %w: %v", errEntropyUnavailable, err)
+		}
+		key.Add(key, big.NewInt(1))
+		if !taken[key.String()] {
+			return key, nil
+		}
+		log.Printf("party key collision, drawing again (attempt %d of %d)", attempt, maxPartyKeyAttempts)
+	}
+	return nil, errPartyKeyCollision
+}
+
+// Pagination of listWallets
+const (
+	defaultListLimit = 100
+	maxListLimit     = 1000
+)
+
+// listWalletsResponse represents the response body for list wallets endpoint
+type listWalletsResponse struct {
+	Wallets []walletsResponse `json:"wallets"`
+	Total   int               `json:"total"`
+	Limit   int               `json:"limit"`
+	Offset  int               `json:"offset"`
+}
+
+// listWallets returns a page of the created wallets ordered by address. The
+// page is selected with the limit and offset query parameters, and the
+// wallets can be filtered by label, threshold and number of parties.
+func listWallets(c *gin.Context) {
+	limit, offset, err := pagination(c)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	threshold, err := positiveQuery(c, "threshold")
+	if err != nil {
+		respondError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	parties, err := positiveQuery(c, "parties")
+	if err != nil {
+		respondError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	label, filterByLabel := c.GetQuery("label")
+	page, total, err := walletService.ListWallets(walletQuery{
+		Limit:         limit,
+		Offset:        offset,
+		Label:         label,
+		FilterByLabel: filterByLabel,
+		Threshold:     threshold,
+		Parties:       parties,
+	})
+	if err != nil {
+		respondServiceError(c, err)
+		return
+	}
+
+	walletsResp := make([]walletsResponse, 0, len(page))
+	for _, wallet := range page {
+		pubKey, pubKeyCompressed, pubKeyFull := wallet.pubKeyHex()
 		walletsResp = append(walletsResp, walletsResponse{
-			Address: addr,
-			// Removing the first byte as it is not necesary since its a prefix
-			PubKey: fmt.Sprintf("0x%x", crypto.FromECDSAPub(wallet.PubKey)[1:]),
+			Address:          wallet.Address,
+			Addresses:        wallet.Addresses,
+			PubKey:           pubKey,
+			PubKeyCompressed: pubKeyCompressed,
+			PubKeyFull:       pubKeyFull,
+			Algorithm:        wallet.Algorithm,
+			Curve:            string(wallet.Curve),
+			Parties:          wallet.Parties,
+			Threshold:        wallet.Threshold,
+			Label:            wallet.Label,
+			Metadata:         wallet.Metadata,
+			CreatedAt:        optionalTime(wallet.CreatedAt),
+			LastSignedAt:     optionalTime(wallet.LastSignedAt()),
 		})
 	}
-	c.JSON(http.StatusOK, gin.H{"wallets": walletsResp})
+	respond(c, http.StatusOK, listWalletsResponse{
+		Wallets: walletsResp,
+		Total:   total,
+		Limit:   limit,
+		Offset:  offset,
+	})
+}
+
+// pagination parses the limit and offset query parameters
+func pagination(c *gin.Context) (limit, offset int, err error) {
+	limit, offset = defaultListLimit, 0
+	if value := c.Query("limit"); value != "" {
+		limit, err = strconv.Atoi(value)
+		if err != nil || limit < 1 || limit > maxListLimit {
+			return 0, 0, fmt.Errorf("limit must be between 1 and %d", maxListLimit)
+		}
+	}
+	if value := c.Query("offset"); value != "" {
+		offset, err = strconv.Atoi(value)
+		if err != nil || offset < 0 {
+			return 0, 0, errors.New("offset must be a non-negative integer")
+		}
+	}
+	return limit, offset, nil
+}
+
+// positiveQuery parses an optional positive integer query parameter, it
+// returns 0 when the parameter is not set
+func positiveQuery(c *gin.Context, name string) (int, error) {
+	value := c.Query(name)
+	if value == "" {
+		return 0, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 1 {
+		return 0, fmt.Errorf("%s must be a positive integer", name)
+	}
+	return n, nil
+}
+
+// getWallet returns the public information of a single wallet
+func getWallet(c *gin.Context) {
+	wallet, err := lookupWallet(c.Param("address"))
+	if err != nil {
+		respondServiceError(c, err)
+		return
+	}
+
+	respond(c, http.StatusOK, newWalletResponse(wallet))
+}
+
+// newWalletResponse returns the public information of a wallet
+func newWalletResponse(wallet *Wallet) walletResponse {
+	pubKey, pubKeyCompressed, pubKeyFull := wallet.pubKeyHex()
+	response := walletResponse{
+		Address:          wallet.Address,
+		Addresses:        wallet.Addresses,
+		PubKey:           pubKey,
+		PubKeyCompressed: pubKeyCompressed,
+		PubKeyFull:       pubKeyFull,
+		Algorithm:        wallet.Algorithm,
+		Curve:            string(wallet.Curve),
+		Parties:          wallet.Parties,
+		Threshold:        wallet.Threshold,
+		Label:            wallet.Label,
+		Metadata:         wallet.Metadata,
+		Policy:           wallet.Policy,
+		CreatedAt:        optionalTime(wallet.CreatedAt),
+		LastSignedAt:     optionalTime(wallet.LastSignedAt()),
+	}
+	if der, pemBlock, err := wallet.pkixPubKey(); err == nil {
+		response.PubKeyDER, response.PubKeyPEM = fmt.Sprintf("0x%x", der), pemBlock
+	}
+	return response
+}
+
+// optionalTime returns nil for the zero time so it is left out of responses
+func optionalTime(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
+
+// deleteWallet removes a wallet and wipes its key shares from memory
+func deleteWallet(c *gin.Context) {
+	address := c.Param("address")
+	if err := validateWalletAddress(address); err != nil {
+		respondError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	address = checksumAddress(address)
+
+	storeMutex.Lock()
+	defer storeMutex.Unlock()
+	walletsMutex.Lock()
+	defer walletsMutex.Unlock()
+
+	wallet, exists := wallets[address]
+	if !exists {
+		respondError(c, http.StatusNotFound, "wallet not found")
+		return
+	}
+	if store != nil {
+		if err := store.Delete(address); err != nil {
+			respondError(c, http.StatusInternalServerError, "failed to delete persisted wallet")
+			return
+		}
+	}
+	delete(wallets, address)
+	wallet.retire()
+	c.Status(http.StatusNoContent)
+}
+
+// zeroSaveData overwrites the secret material of a key share so it does not
+// linger in memory once the share is discarded
+func zeroSaveData(save *keygen.LocalPartySaveData) {
+	secrets := []*big.Int{save.Xi, save.P, save.Q, save.Alpha, save.Beta}
+	if save.PaillierSK != nil {
+		secrets = append(secrets, save.PaillierSK.LambdaN, save.PaillierSK.PhiN, save.PaillierSK.P, save.PaillierSK.Q)
+	}
+	for _, secret := range secrets {
+		if secret == nil {
+			continue
+		}
+		clear(secret.Bits())
+		secret.SetInt64(0)
+	}
+}
+
+// recoveryV turns the recovery id produced by tss-lib into the v value of an
+// Ethereum signature, 27/28 by default or 0/1 when raw is set. tss-lib only
+// sets the second bit when R.X overflows the curve order, which ecrecover
+// can't represent, so only the parity bit is kept.
+func recoveryV(signatureRecovery []byte, raw bool) byte {
+	var v byte
+	if len(signatureRecovery) > 0 {
+		v = signatureRecovery[0] & 1
+	}
+	if !raw {
+		v += 27
+	}
+	return v
+}
+
+// signingQuorum returns the threshold+1 parties taking part in a signing
+// ceremony. When no signer IDs are given the first parties of the wallet are
+// used. The returned party IDs are copies, as sorting them assigns indexes
+// that must not leak into the wallet's own party IDs.
+func signingQuorum(wallet *Wallet, signerIDs []string) (tss.SortedPartyIDs, error) {
+	quorumSize := wallet.Threshold + 1
+	if len(signerIDs) == 0 {
+		for _, partyID := range wallet.PartyIDs[:quorumSize] {
+			signerIDs = append(signerIDs, partyID.Id)
+		}
+	}
+	if len(signerIDs) != quorumSize {
+		return nil, fmt.Errorf("signing requires exactly %d parties", quorumSize)
+	}
+
+	walletPartyIDs := make(map[string]*tss.PartyID, len(wallet.PartyIDs))
+	for _, partyID := range wallet.PartyIDs {
+		walletPartyIDs[partyID.Id] = partyID
+	}
+
+	signers := make(tss.UnSortedPartyIDs, 0, quorumSize)
+	seen := make(map[string]bool, quorumSize)
+	for _, id := range signerIDs {
+		partyID, exists := walletPartyIDs[id]
+		if !exists {
+			return nil, fmt.Errorf("party %s is not part of the wallet", id)
+		}
+		if seen[id] {
+			return nil, fmt.Errorf("party %s is listed more than once", id)
+		}
+		seen[id] = true
+		signers = append(signers, tss.NewPartyID(partyID.Id, partyID.Moniker, partyID.KeyInt()))
+	}
+	return tss.SortPartyIDs(signers), nil
+}
+
+// partyIDStrings returns the IDs of the parties
+func partyIDStrings(partyIDs tss.SortedPartyIDs) []string {
+	ids := make([]string, len(partyIDs))
+	for i, partyID := range partyIDs {
+		ids[i] = partyID.Id
+	}
+	return ids
 }
 
 // signData handles the signing of data using a specified wallet
@@ -221,133 +1155,198 @@ func signData(c *gin.Context) {
 	var requestBody signDataRequest
 
 	if err := c.BindJSON(&requestBody); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
+		respondError(c, http.StatusBadRequest, "invalid request body")
 		return
 	}
 
-	dataHex := requestBody.Data
-	walletAddress := requestBody.Wallet
-	if dataHex == "" || walletAddress == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "data and wallet are required"})
+	job, err := walletService.prepareSign(requestBody)
+	if err != nil {
+		respondServiceError(c, err)
 		return
 	}
+	streamCeremony(c, func(onRound func(round int)) (int, any) {
+		response, err := job.sign(onRound)
+		if err != nil {
+			return serviceErrorResponse(err)
+		}
+		return http.StatusOK, dataResponse(response)
+	})
+}
 
-	dataHex = strings.TrimPrefix(dataHex, "0x")
-	data, err := hex.DecodeString(dataHex)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid data"})
-		return
+// signatureResponse builds the response body for a produced signature. The
+// signature is normalized to a low s and the signature field uses the
+// requested encoding.
+func signatureResponse(sigData *common.SignatureData, digest []byte, curve elliptic.Curve, encoding string, rawRecoveryID bool) (gin.H, error) {
+	// Ed25519 signatures are R || S in their own encoding, with no recovery id
+	if name, _ := tss.GetCurveName(curve); name == curveEd25519 {
+		if encoding != "" && encoding != encodingRaw {
+			return nil, errors.New("eddsa signatures only have the raw encoding")
+		}
+		return gin.H{
+			"signature": hex.EncodeToString(sigData.Signature),
+			"digest":    hex.EncodeToString(digest),
+		}, nil
+	}
+	var recoveryID byte
+	if len(sigData.SignatureRecovery) > 0 {
+		recoveryID = sigData.SignatureRecovery[0]
 	}
+	r := new(big.Int).SetBytes(sigData.R)
+	s, recoveryID := normalizeLowS(new(big.Int).SetBytes(sigData.S), recoveryID, curve)
 
-	walletsMutex.Lock()
-	wallet, exists := wallets[walletAddress]
-	walletsMutex.Unlock()
-	if !exists {
-		c.JSON(http.StatusNotFound, gin.H{"error": "wallet not found"})
-		return
+	size := (curve.Params().BitSize + 7) / 8
+	signature := make([]byte, 2*size)
+	r.FillBytes(signature[:size])
+	s.FillBytes(signature[size:])
+	v := recoveryV([]byte{recoveryID}, rawRecoveryID)
+	rsv := append(append([]byte{}, signature...), v)
+
+	encoded := signature
+	switch encoding {
+	case encodingDER:
+		var err error
+		encoded, err = encodeDER(r, s, curve)
+		if err != nil {
+			return nil, err
+		}
+	case encodingEth65:
+		encoded = rsv
+	case encodingEIP2098:
+		var err error
+		encoded, err = encodeEIP2098(r, s, recoveryID)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return gin.H{
+		"signature": hex.EncodeToString(encoded),
+		"v":         hexutil.EncodeUint64(uint64(v)),
+		"rsv":       hex.EncodeToString(rsv),
+		"digest":    hex.EncodeToString(digest),
+	}, nil
+}
+
+// verifySignature checks a signature produced by tss-lib against the digest
+func verifySignature(pubKey *ecdsa.PublicKey, digest []byte, sigData *common.SignatureData) bool {
+	r := new(big.Int).SetBytes(sigData.R)
+	s := new(big.Int).SetBytes(sigData.S)
+	return ecdsa.Verify(pubKey, digest, r, s)
+}
+
+// recordSigning updates when the wallet last signed. The time is kept in
+// memory and persisted in the background by lastSignedTimes, so signing never
+// waits on the store.
+func recordSigning(wallet *Wallet) {
+	wallet.setLastSignedAt(time.Now())
+	if store != nil {
+		lastSignedTimes.mark(wallet)
 	}
+}
 
-	partyIDs := wallet.PartyIDs
+// signDigest runs a signing ceremony over the digest with a quorum of the
+// wallet's parties and returns the produced signature. The quorum is made of
+// the given signer IDs, or of the first parties of the wallet when empty.
+// onRound, when set, is called as each round completes. Every ceremony is
+// recorded in the signing journal.
+//
+// Signatures are not deterministic: the parties draw the nonce k jointly at
+// random in each ceremony, no party can derive it from the digest and its
+// share like RFC 6979 does with a whole key. Signing the same digest twice
+// gives two different signatures, both valid for the wallet.
+func signDigest(wallet *Wallet, signerIDs []string, digest []byte, onRound func(round int)) (signature *common.SignatureData, err error) {
+	defer trackActive(&activeSigns)()
+	start := time.Now()
+	signers := signerIDs
+	defer func() {
+		signatureJournal.record(wallet.Address, digest, signers, err)
+		if err != nil {
+			failuresTotal.WithLabelValues(operationSign).Inc()
+			return
+		}
+		signaturesTotal.Inc()
+		signDurationSeconds.Observe(time.Since(start).Seconds())
+		recordSigning(wallet)
+	}()
+
+	// Only a quorum of threshold+1 parties takes part in the signing
+	partyIDs, err := signingQuorum(wallet, signerIDs)
+	if err != nil {
+		return nil, err
+	}
+	signers = partyIDStrings(partyIDs)
+	if wallet.Algorithm == algorithmEdDSA {
+		return signEdDSA(wallet, partyIDs, digest, onRound)
+	}
 	ctx := tss.NewPeerContext(partyIDs)
 
-	// Convert data to *big.Int for signing
-	msgToSign := new(big.Int).SetBytes(data)
+	// Convert the digest to *big.Int for signing
+	msgToSign := new(big.Int).SetBytes(digest)
 
 	numParties := len(partyIDs)
 	threshold := wallet.Threshold
 
 	// Channels for communication
-	errCh := make(chan *tss.Error)
-	outChs := make([]chan tss.Message, numParties)
+	cer, err := newCeremony(context.Background(), numParties, signTimeout)
+	if err != nil {
+		return nil, err
+	}
+	defer cer.close()
+	if err := cer.useShares(wallet); err != nil {
+		return nil, err
+	}
 	endCh := make(chan common.SignatureData, numParties)
-	messages := make(chan tss.Message, numParties*numParties)
 
 	// Start signing parties.
-	partiesList := make([]*signing.LocalParty, numParties)
+	partiesList := make([]tss.Party, numParties)
 	for i, partyID := range partyIDs {
-		params := tss.NewParameters(tss.S256(), ctx, partyID, numParties, threshold)
+		params := tss.NewParameters(wallet.PubKey.Curve, ctx, partyID, numParties, threshold)
 		partyIDStr := partyID.Id
 		saveData, exists := wallet.SaveData[partyIDStr]
 		if !exists {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "SaveData for party not found"})
-			return
+			return nil, errors.New("SaveData for party not found")
 		}
-		outCh := make(chan tss.Message, numParties*numParties)
-		outChs[i] = outCh
-		party := signing.NewLocalParty(msgToSign, params, *saveData, outCh, endCh).(*signing.LocalParty)
+		party := newSigningParty(msgToSign, params, *saveData, cer.outChs[i], endCh)
 		partiesList[i] = party
-
-		// Start each party in a separate goroutine
-		go func(p *signing.LocalParty) {
-			if err := p.Start(); err != nil {
-				errCh <- err
-			}
-		}(party)
 	}
 
-	// Forward messages from parties to the messages channel
-	for _, outCh := range outChs {
-		go func(ch chan tss.Message) {
-			for msg := range ch {
-				messages <- msg
+	// Start each party in a separate goroutine, only once all of them exist
+	// so an early return never leaves a started party behind
+	for _, party := range partiesList {
+		cer.run(func() {
+			if err := party.Start(); err != nil {
+				cer.fail(err)
 			}
-		}(outCh)
+		})
 	}
 
-	// Handle message passing and collect signatures
-	var wg sync.WaitGroup
-	wg.Add(1)
-	go func() {
-		defer wg.Done()
-		signatures := make([]*common.SignatureData, 0, numParties)
-		for {
-			select {
-			case err := <-errCh:
-				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-				return
-			case msg := <-messages:
-				wireBytes, _, err := msg.WireBytes()
-				if err != nil {
-					errCh <- tss.NewError(err, "failed to serialize wire bytes", 0, msg.GetFrom(), nil)
-					return
-				}
-				dest := msg.GetTo()
-				if dest == nil { // Broadcast message
-					for _, p := range partiesList {
-						if p.PartyID().Id == msg.GetFrom().Id {
-							continue
-						}
-						go func(p *signing.LocalParty) {
-							if _, err := p.UpdateFromBytes(wireBytes, msg.GetFrom(), msg.IsBroadcast()); err != nil {
-								errCh <- err
-							}
-						}(p)
-					}
-				} else { // Point-to-point message
-					for _, to := range dest {
-						for _, p := range partiesList {
-							if p.PartyID().Id == to.Id {
-								go func(p *signing.LocalParty) {
-									if _, err := p.UpdateFromBytes(wireBytes, msg.GetFrom(), msg.IsBroadcast()); err != nil {
-										errCh <- err
-									}
-								}(p)
-								break
-							}
-						}
-					}
-				}
-			case sigData := <-endCh:
-				signatures = append(signatures, &sigData)
-				if len(signatures) == numParties {
-					// All parties have completed signing
-					r, s := sigData.R, sigData.S
-					signature := append(r, s...)
-					c.JSON(http.StatusOK, gin.H{"signature": hex.EncodeToString(signature)})
-					return
-				}
+	router := newPartyRouter(partiesList, cer.deliver)
+	progress := newRoundProgress(algorithmECDSA, operationSign, numParties, onRound)
+	defer progress.logTimings()
+
+	// Handle message passing until a party outputs a valid signature
+	signatures := receivePointers(cer, endCh)
+	invalid := 0
+	for {
+		select {
+		case <-cer.ctx.Done():
+			return nil, cer.err()
+		case err := <-cer.errCh:
+			return nil, err
+		case msg := <-cer.messages:
+			if err := router.Route(msg); err != nil {
+				return nil, err
+			}
+			progress.observe(msg)
+		case sigData := <-signatures:
+			// Every party outputs the same signature, the first one that
+			// verifies is enough and the others are dropped with the ceremony
+			if verifySignature(wallet.PubKey, digest, sigData) {
+				return sigData, nil
+			}
+			invalid++
+			if invalid == numParties {
+				return nil, errors.New("no party produced a valid signature")
 			}
 		}
-	}()
-	wg.Wait()
+	}
 }
@@ -2,15 +2,34 @@ package main
 
 import (
 	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
 	"net/http"
 	"net/http/httptest"
+	"runtime"
+	"sort"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 
+	"github.com/bnb-chain/tss-lib/ecdsa/keygen"
+	"github.com/bnb-chain/tss-lib/tss"
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/blake2b"
+	"golang.org/x/crypto/sha3"
 )
 
 func TestCreateWallet(t *testing.T) {
@@ -25,8 +44,8 @@ func TestCreateWallet(t *testing.T) {
 
 	assert.Equal(t, http.StatusOK, w.Code)
 
-	var response map[string]string
-	err := json.Unmarshal(w.Body.Bytes(), &response)
+	var response stringFields
+	err := decodeData(w.Body.Bytes(), &response)
 	if err != nil {
 		t.Fatalf("Failed to parse response: %v", err)
 	}
@@ -36,13 +55,106 @@ func TestCreateWallet(t *testing.T) {
 	assert.NotEmpty(t, address, "Address should not be empty")
 }
 
+func TestCreateWalletCustomConfig(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	useDeterministicKeygen(t, "custom wallet config")
+
+	router := gin.Default()
+	router.POST("/wallet", createWallet)
+	router.POST("/sign", signData)
+
+	// 2-of-2 wallet
+	jsonBody, _ := json.Marshal(createWalletRequest{Parties: 2, Threshold: 1})
+	w1 := httptest.NewRecorder()
+	req1, _ := http.NewRequest("POST", "/wallet", bytes.NewBuffer(jsonBody))
+	req1.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w1, req1)
+	assert.Equal(t, http.StatusOK, w1.Code)
+
+	var createResponse stringFields
+	err := decodeData(w1.Body.Bytes(), &createResponse)
+	if err != nil {
+		t.Fatalf("Failed to parse create wallet response: %v", err)
+	}
+	walletAddress := createResponse["address"]
+
+	walletsMutex.Lock()
+	wallet, exists := wallets[walletAddress]
+	walletsMutex.Unlock()
+	assert.True(t, exists, "Wallet should be stored")
+	assert.Equal(t, 2, wallet.Parties)
+	assert.Equal(t, 1, wallet.Threshold)
+	assert.Len(t, wallet.SaveData, 2)
+
+	requestBody := signDataRequest{
+		Data:   "0x74657374", // "test" in hex
+		Wallet: walletAddress,
+	}
+	jsonBody, _ = json.Marshal(requestBody)
+
+	w2 := httptest.NewRecorder()
+	req2, _ := http.NewRequest("POST", "/sign", bytes.NewBuffer(jsonBody))
+	req2.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w2, req2)
+	assert.Equal(t, http.StatusOK, w2.Code)
+}
+
+func TestCreateWalletInvalidConfig(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	router := gin.Default()
+	router.POST("/wallet", createWallet)
+
+	invalidConfigs := []createWalletRequest{
+		{Parties: 1, Threshold: 1},
+		{Parties: 0, Threshold: 0},
+		{Parties: 3, Threshold: 0},
+		{Parties: 3, Threshold: 3},
+		{Parties: 3, Threshold: 5},
+		{Parties: 5, Threshold: -1},
+	}
+	for _, config := range invalidConfigs {
+		jsonBody, _ := json.Marshal(config)
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/wallet", bytes.NewBuffer(jsonBody))
+		req.Header.Set("Content-Type", "application/json")
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code, "parties=%d threshold=%d should be rejected", config.Parties, config.Threshold)
+
+		response, err := decodeError(w.Body.Bytes())
+		assert.NoError(t, err)
+		assert.Equal(t, codeInvalidRequest, response.Code)
+		assert.NotEmpty(t, response.Message)
+	}
+
+	// Malformed body
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/wallet", strings.NewReader("{parties"))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
 func TestListWallets(t *testing.T) {
 	gin.SetMode(gin.TestMode)
+	useDeterministicKeygen(t, "list wallets")
 
 	router := gin.Default()
 	router.POST("/wallet", createWallet)
 	router.GET("/wallets", listWallets)
 
+	// Run against an empty set of wallets so that earlier tests don't count
+	walletsMutex.Lock()
+	saved := wallets
+	wallets = make(map[string]*Wallet)
+	walletsMutex.Unlock()
+	t.Cleanup(func() {
+		walletsMutex.Lock()
+		wallets = saved
+		walletsMutex.Unlock()
+	})
+
 	// Create a wallet to ensure the list is not empty
 	w1 := httptest.NewRecorder()
 	req1, _ := http.NewRequest("POST", "/wallet", nil)
@@ -55,14 +167,13 @@ func TestListWallets(t *testing.T) {
 
 	assert.Equal(t, http.StatusOK, w2.Code)
 
-	var response map[string][]walletsResponse
-	err := json.Unmarshal(w2.Body.Bytes(), &response)
+	var response listWalletsResponse
+	err := decodeData(w2.Body.Bytes(), &response)
 	if err != nil {
 		t.Fatalf("Failed to parse response: %v", err)
 	}
 
-	walletsResp, exists := response["wallets"]
-	assert.True(t, exists, "Response should contain 'wallets' key")
+	walletsResp := response.Wallets
 	assert.NotEmpty(t, walletsResp, "Wallets list should not be empty")
 	assert.Len(t, walletsResp, 1, "Wallets list should contain only one wallet")
 	assert.True(t, strings.HasPrefix(walletsResp[0].Address, "0x"), "Address should start with '0x'")
@@ -77,8 +188,385 @@ func TestListWallets(t *testing.T) {
 	assert.Len(t, pubKey, 64, "Public key should be 64 bytes long")
 }
 
+func TestListWalletsPagination(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	router := gin.Default()
+	router.GET("/wallets", listWallets)
+
+	// Run against an empty set of wallets so that totals are exact
+	walletsMutex.Lock()
+	saved := wallets
+	wallets = make(map[string]*Wallet)
+	walletsMutex.Unlock()
+	t.Cleanup(func() {
+		walletsMutex.Lock()
+		wallets = saved
+		walletsMutex.Unlock()
+	})
+
+	addresses := []string{
+		"0x00000000000000000000000000000000000000f3",
+		"0x00000000000000000000000000000000000000f1",
+		"0x00000000000000000000000000000000000000F5",
+		"0x00000000000000000000000000000000000000F2",
+		"0x00000000000000000000000000000000000000F4",
+	}
+	for _, address := range addresses {
+		addFakeWallet(address)
+	}
+	// Addresses are listed by their lowercase form, whatever their case
+	sorted := append([]string{}, addresses...)
+	sort.Slice(sorted, func(i, j int) bool {
+		return strings.ToLower(sorted[i]) < strings.ToLower(sorted[j])
+	})
+
+	tests := []struct {
+		name     string
+		query    string
+		expected []string
+	}{
+		{"default", "", sorted},
+		{"first page", "?limit=2", sorted[:2]},
+		{"second page", "?limit=2&offset=2", sorted[2:4]},
+		{"last partial page", "?limit=2&offset=4", sorted[4:]},
+		{"offset at end", "?offset=5", []string{}},
+		{"offset past end", "?limit=2&offset=100", []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Listing twice must give the same order
+			for i := 0; i < 2; i++ {
+				w := httptest.NewRecorder()
+				req, _ := http.NewRequest("GET", "/wallets"+tt.query, nil)
+				router.ServeHTTP(w, req)
+				assert.Equal(t, http.StatusOK, w.Code)
+
+				var response listWalletsResponse
+				err := decodeData(w.Body.Bytes(), &response)
+				if err != nil {
+					t.Fatalf("Failed to parse response: %v", err)
+				}
+				listed := make([]string, 0, len(response.Wallets))
+				for _, wallet := range response.Wallets {
+					listed = append(listed, wallet.Address)
+				}
+				assert.Equal(t, tt.expected, listed)
+				assert.Equal(t, len(addresses), response.Total)
+			}
+		})
+	}
+}
+
+func TestCountWallets(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	useDeterministicKeygen(t, "count wallets")
+
+	router := gin.Default()
+	router.POST("/wallet", createWallet)
+	router.GET("/wallets/count", countWallets)
+
+	count := func() int {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/wallets/count", nil)
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+		var response struct {
+			Count int `json:"count"`
+		}
+		if err := decodeData(w.Body.Bytes(), &response); err != nil {
+			t.Fatalf("Failed to parse count response: %v", err)
+		}
+		return response.Count
+	}
+
+	before := count()
+	jsonBody, _ := json.Marshal(createWalletRequest{Parties: 2, Threshold: 1})
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/wallet", bytes.NewBuffer(jsonBody))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	var createResponse stringFields
+	if err := decodeData(w.Body.Bytes(), &createResponse); err != nil {
+		t.Fatalf("Failed to parse create wallet response: %v", err)
+	}
+	t.Cleanup(func() {
+		walletsMutex.Lock()
+		delete(wallets, createResponse["address"])
+		walletsMutex.Unlock()
+	})
+
+	assert.Equal(t, before+1, count(), "The count should include the new wallet")
+}
+
+func TestCreateWalletReturnsParties(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	useDeterministicKeygen(t, "create wallet parties")
+
+	router := gin.Default()
+	router.POST("/wallet", createWallet)
+
+	jsonBody, _ := json.Marshal(createWalletRequest{Parties: 4, Threshold: 2})
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/wallet", bytes.NewBuffer(jsonBody))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response struct {
+		Address string           `json:"address"`
+		Parties []map[string]any `json:"parties"`
+	}
+	if err := decodeData(w.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to parse create wallet response: %v", err)
+	}
+	wallet, err := lookupWallet(response.Address)
+	if err != nil {
+		t.Fatalf("Wallet %s should be stored: %v", response.Address, err)
+	}
+	t.Cleanup(func() {
+		walletsMutex.Lock()
+		delete(wallets, wallet.Address)
+		walletsMutex.Unlock()
+	})
+
+	if assert.Len(t, response.Parties, 4, "Every party of the wallet should be returned") {
+		for i, party := range response.Parties {
+			assert.Equal(t, map[string]any{"id": wallet.PartyIDs[i].Id, "moniker": wallet.PartyIDs[i].Moniker}, party, "Only the identity of the party should be returned")
+		}
+	}
+}
+
+// startFailingParty is a party that fails as soon as it is started
+type startFailingParty struct {
+	tss.Party
+}
+
+func (p *startFailingParty) Start() *tss.Error {
+	return tss.NewError(errors.New("injected round error"), "keygen", 1, p.PartyID())
+}
+
+func TestCreateWalletRetriesKeygen(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	useDeterministicKeygen(t, "keygen retry")
+	previousAttempts, previousBackoff := keygenAttempts, keygenRetryBackoff
+	keygenRetryBackoff = 10 * time.Millisecond
+	t.Cleanup(func() { keygenAttempts, keygenRetryBackoff = previousAttempts, previousBackoff })
+
+	// The first failing ceremonies have a party failing in its first round
+	var failures, ceremonies int
+	var partyKeys [][]*big.Int
+	dealt := newKeygenParty
+	newKeygenParty = func(params *tss.Parameters, out chan<- tss.Message, end chan<- keygen.LocalPartySaveData, preParams ...keygen.LocalPreParams) tss.Party {
+		if params.PartyID().Index == 0 {
+			ceremonies++
+			partyKeys = append(partyKeys, params.Parties().IDs().Keys())
+		}
+		if ceremonies <= failures && params.PartyID().Index == 1 {
+			return &startFailingParty{Party: &routedParty{id: params.PartyID()}}
+		}
+		return dealt(params, out, end, preParams...)
+	}
+
+	router := gin.Default()
+	router.POST("/wallet", createWallet)
+	create := func() *httptest.ResponseRecorder {
+		jsonBody, _ := json.Marshal(createWalletRequest{Parties: 3, Threshold: 1})
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/wallet", bytes.NewBuffer(jsonBody))
+		req.Header.Set("Content-Type", "application/json")
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	failures = 1
+	w := create()
+	assert.Equal(t, http.StatusOK, w.Code, "A wallet should be created once a ceremony succeeds")
+	var createResponse stringFields
+	if err := decodeData(w.Body.Bytes(), &createResponse); err != nil {
+		t.Fatalf("Failed to parse create wallet response: %v", err)
+	}
+	t.Cleanup(func() {
+		walletsMutex.Lock()
+		delete(wallets, createResponse["address"])
+		walletsMutex.Unlock()
+	})
+	assert.Equal(t, 2, ceremonies)
+	if assert.Len(t, partyKeys, 2) {
+		assert.NotEqual(t, partyKeys[0], partyKeys[1], "A retry should draw new parties")
+	}
+
+	// Failures beyond the attempts are reported
+	ceremonies, failures, keygenAttempts = 0, 5, 2
+	w = create()
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.Contains(t, w.Body.String(), "injected round error")
+	assert.Equal(t, 2, ceremonies, "No more ceremonies than the attempts should run")
+}
+
+func TestMaxWallets(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	useDeterministicKeygen(t, "max wallets")
+	keygens := 0
+	dealt := newKeygenParty
+	newKeygenParty = func(params *tss.Parameters, out chan<- tss.Message, end chan<- keygen.LocalPartySaveData, preParams ...keygen.LocalPreParams) tss.Party {
+		keygens++
+		return dealt(params, out, end, preParams...)
+	}
+
+	router := gin.Default()
+	router.POST("/wallet", createWallet)
+	router.POST("/sign", signData)
+	router.POST("/import", importWallet)
+
+	create := func() *httptest.ResponseRecorder {
+		jsonBody, _ := json.Marshal(createWalletRequest{Parties: 2, Threshold: 1})
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/wallet", bytes.NewBuffer(jsonBody))
+		req.Header.Set("Content-Type", "application/json")
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	// Leave room for a single wallet
+	walletsMutex.Lock()
+	previous := maxWallets
+	maxWallets = len(wallets) + 1
+	walletsMutex.Unlock()
+	t.Cleanup(func() { maxWallets = previous })
+
+	w := create()
+	assert.Equal(t, http.StatusOK, w.Code)
+	var createResponse stringFields
+	if err := decodeData(w.Body.Bytes(), &createResponse); err != nil {
+		t.Fatalf("Failed to parse create wallet response: %v", err)
+	}
+	address := createResponse["address"]
+	t.Cleanup(func() {
+		walletsMutex.Lock()
+		delete(wallets, address)
+		walletsMutex.Unlock()
+	})
+	assert.Equal(t, 2, keygens)
+
+	w = create()
+	assert.Equal(t, http.StatusInsufficientStorage, w.Code)
+	apiErr, err := decodeError(w.Body.Bytes())
+	assert.NoError(t, err)
+	assert.Equal(t, codeWalletLimit, apiErr.Code)
+	assert.Equal(t, 2, keygens, "No keygen should run once the limit is reached")
+
+	// Imports count against the same limit
+	spec, _ := newWalletSpec(createWalletRequest{Parties: 2, Threshold: 1})
+	other, err := generateWallet(context.Background(), spec, nil, nil)
+	if err != nil {
+		t.Fatalf("Failed to generate wallet: %v", err)
+	}
+	stored, _ := newStoredWallet(other, nil)
+	jsonBody, _ := json.Marshal(importWalletRequest{storedWallet: *stored})
+	w = httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/import", bytes.NewBuffer(jsonBody))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusInsufficientStorage, w.Code)
+	_, err = lookupWallet(other.Address)
+	assert.ErrorIs(t, err, ErrWalletNotFound)
+
+	// The existing wallets still sign
+	jsonBody, _ = json.Marshal(signDataRequest{Data: "0x74657374", Wallet: address})
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("POST", "/sign", bytes.NewBuffer(jsonBody))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+}
+
+func TestListWalletsConfigFilter(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	router := gin.Default()
+	router.GET("/wallets", listWallets)
+
+	// Run against an empty set of wallets so that totals are exact
+	walletsMutex.Lock()
+	saved := wallets
+	wallets = make(map[string]*Wallet)
+	walletsMutex.Unlock()
+	t.Cleanup(func() {
+		walletsMutex.Lock()
+		wallets = saved
+		walletsMutex.Unlock()
+	})
+
+	configs := map[string][2]int{
+		"0x00000000000000000000000000000000000000A1": {5, 2},
+		"0x00000000000000000000000000000000000000a2": {3, 2},
+		"0x00000000000000000000000000000000000000A3": {5, 2},
+		"0x00000000000000000000000000000000000000A4": {5, 3},
+		"0x00000000000000000000000000000000000000a5": {3, 1},
+	}
+	for address, config := range configs {
+		wallet := addFakeWallet(address)
+		wallet.Parties, wallet.Threshold = config[0], config[1]
+		wallet.Label = "custody"
+	}
+
+	tests := []struct {
+		name     string
+		query    string
+		expected []string
+	}{
+		{"threshold and parties", "?threshold=2&parties=5", []string{"0x00000000000000000000000000000000000000A1", "0x00000000000000000000000000000000000000A3"}},
+		{"threshold", "?threshold=2", []string{"0x00000000000000000000000000000000000000A1", "0x00000000000000000000000000000000000000a2", "0x00000000000000000000000000000000000000A3"}},
+		{"parties", "?parties=3", []string{"0x00000000000000000000000000000000000000a2", "0x00000000000000000000000000000000000000a5"}},
+		{"with label and pagination", "?threshold=2&label=custody&limit=1&offset=1", []string{"0x00000000000000000000000000000000000000a2"}},
+		{"no match", "?threshold=4&parties=5", []string{}},
+		{"no match with label", "?parties=5&label=hot", []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("GET", "/wallets"+tt.query, nil)
+			router.ServeHTTP(w, req)
+			assert.Equal(t, http.StatusOK, w.Code)
+
+			var response listWalletsResponse
+			if err := decodeData(w.Body.Bytes(), &response); err != nil {
+				t.Fatalf("Failed to parse response: %v", err)
+			}
+			addresses := make([]string, 0, len(response.Wallets))
+			for _, wallet := range response.Wallets {
+				addresses = append(addresses, wallet.Address)
+				config := configs[wallet.Address]
+				assert.Equal(t, config[0], wallet.Parties)
+				assert.Equal(t, config[1], wallet.Threshold)
+			}
+			assert.Equal(t, tt.expected, addresses)
+			if !strings.Contains(tt.query, "limit") {
+				assert.Equal(t, len(tt.expected), response.Total)
+			}
+		})
+	}
+}
+
+func TestListWalletsInvalidPagination(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	router := gin.Default()
+	router.GET("/wallets", listWallets)
+
+	for _, query := range []string{"?limit=0", "?limit=-1", "?limit=1001", "?limit=abc", "?offset=-1", "?offset=abc", "?threshold=0", "?threshold=abc", "?parties=-2", "?parties=2.5"} {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/wallets"+query, nil)
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusBadRequest, w.Code, query)
+	}
+}
+
 func TestSignData(t *testing.T) {
 	gin.SetMode(gin.TestMode)
+	useDeterministicKeygen(t, "sign data")
 
 	router := gin.Default()
 	router.POST("/wallet", createWallet)
@@ -91,8 +579,8 @@ func TestSignData(t *testing.T) {
 	assert.Equal(t, http.StatusOK, w1.Code)
 
 	// Get the wallet address
-	var createResponse map[string]string
-	err := json.Unmarshal(w1.Body.Bytes(), &createResponse)
+	var createResponse stringFields
+	err := decodeData(w1.Body.Bytes(), &createResponse)
 	if err != nil {
 		t.Fatalf("Failed to parse create wallet response: %v", err)
 	}
@@ -113,8 +601,8 @@ func TestSignData(t *testing.T) {
 
 	assert.Equal(t, http.StatusOK, w2.Code)
 
-	var response map[string]string
-	err = json.Unmarshal(w2.Body.Bytes(), &response)
+	var response stringFields
+	err = decodeData(w2.Body.Bytes(), &response)
 	if err != nil {
 		t.Fatalf("Failed to parse response: %v", err)
 	}
@@ -164,7 +652,31 @@ func TestSignDataNonExistentWallet(t *testing.T) {
 	req.Header.Set("Content-Type", "application/json")
 	router.ServeHTTP(w, req)
 
-	assert.Equal(t, http.StatusBadRequest, w.Code)
+	// A well formed address matching no wallet is not found
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestSignDataMalformedWallet(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	router := gin.Default()
+	router.POST("/sign", signData)
+
+	for _, address := range []string{
+		"0xNonExistentWallet",
+		"adcdf1cc67362d0d61ad8954d077b78a1d80087b",
+		"0xadcdf1cc67362d0d61ad8954d077b78a1d80087",
+		"0xadcdf1cc67362d0d61ad8954d077b78a1d80087bb",
+		"0xzdcdf1cc67362d0d61ad8954d077b78a1d80087b",
+	} {
+		jsonBody, _ := json.Marshal(signDataRequest{Data: "0x74657374", Wallet: address})
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/sign", bytes.NewBuffer(jsonBody))
+		req.Header.Set("Content-Type", "application/json")
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code, "Address %s should be rejected as malformed", address)
+	}
 }
 
 func TestSignDataInvalidData(t *testing.T) {
@@ -184,11 +696,67 @@ func TestSignDataInvalidData(t *testing.T) {
 	req.Header.Set("Content-Type", "application/json")
 	router.ServeHTTP(w, req)
 
-	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestSignDataEncoding(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	key, _ := crypto.GenerateKey()
+	useInstantSigning(t, key)
+	address := deriveAddress(&key.PublicKey)
+	addFakeWallet(address).PubKey = &key.PublicKey
+	t.Cleanup(func() {
+		walletsMutex.Lock()
+		delete(wallets, address)
+		walletsMutex.Unlock()
+	})
+
+	router := gin.Default()
+	router.POST("/sign", signData)
+
+	sign := func(request signDataRequest) *httptest.ResponseRecorder {
+		request.Wallet = address
+		jsonBody, _ := json.Marshal(request)
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/sign", bytes.NewBuffer(jsonBody))
+		req.Header.Set("Content-Type", "application/json")
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	// The same bytes sign the same way whatever their encoding
+	digest := crypto.Keccak256([]byte("encoding"))
+	var signatures []string
+	for _, request := range []signDataRequest{
+		{Data: "0x" + hex.EncodeToString(digest), Hash: hashNone},
+		{Data: hex.EncodeToString(digest), Hash: hashNone, DataEncoding: dataEncodingHex},
+		{Data: base64.StdEncoding.EncodeToString(digest), Hash: hashNone, DataEncoding: dataEncodingBase64},
+	} {
+		w := sign(request)
+		assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var response stringFields
+		if err := decodeData(w.Body.Bytes(), &response); err != nil {
+			t.Fatalf("Failed to parse sign data response: %v", err)
+		}
+		assert.Equal(t, hex.EncodeToString(digest), response["digest"])
+		signatures = append(signatures, response["signature"])
+	}
+	assert.Equal(t, signatures[0], signatures[1])
+	assert.Equal(t, signatures[0], signatures[2], "Base64 data should produce the signature of the same bytes in hex")
+
+	for _, request := range []signDataRequest{
+		{Data: "0x74657374", DataEncoding: dataEncodingBase64},
+		{Data: "dGVzdA==", DataEncoding: dataEncodingHex},
+		{Data: "dGVzdA==", DataEncoding: "base58"},
+	} {
+		assert.Equal(t, http.StatusBadRequest, sign(request).Code, "%+v should be rejected", request)
+	}
 }
 
 func TestIntegrationWorkflow(t *testing.T) {
 	gin.SetMode(gin.TestMode)
+	useDeterministicKeygen(t, "integration workflow")
 
 	router := gin.Default()
 	router.POST("/wallet", createWallet)
@@ -201,8 +769,8 @@ func TestIntegrationWorkflow(t *testing.T) {
 	router.ServeHTTP(w1, req1)
 	assert.Equal(t, http.StatusOK, w1.Code)
 
-	var createResponse map[string]string
-	err := json.Unmarshal(w1.Body.Bytes(), &createResponse)
+	var createResponse stringFields
+	err := decodeData(w1.Body.Bytes(), &createResponse)
 	if err != nil {
 		t.Fatalf("Failed to parse create wallet response: %v", err)
 	}
@@ -216,14 +784,12 @@ func TestIntegrationWorkflow(t *testing.T) {
 	router.ServeHTTP(w2, req2)
 	assert.Equal(t, http.StatusOK, w2.Code)
 
-	var listResponse map[string][]walletsResponse
-	err = json.Unmarshal(w2.Body.Bytes(), &listResponse)
+	var listResponse listWalletsResponse
+	err = decodeData(w2.Body.Bytes(), &listResponse)
 	if err != nil {
 		t.Fatalf("Failed to parse list wallets response: %v", err)
 	}
-	walletsList, exists := listResponse["wallets"]
-	assert.True(t, exists)
-	assert.NotEmpty(t, walletsList)
+	assert.NotEmpty(t, listResponse.Wallets)
 
 	// Sign Data
 	requestBody := signDataRequest{
@@ -238,8 +804,8 @@ func TestIntegrationWorkflow(t *testing.T) {
 	router.ServeHTTP(w3, req3)
 	assert.Equal(t, http.StatusOK, w3.Code)
 
-	var signResponse map[string]string
-	err = json.Unmarshal(w3.Body.Bytes(), &signResponse)
+	var signResponse stringFields
+	err = decodeData(w3.Body.Bytes(), &signResponse)
 	if err != nil {
 		t.Fatalf("Failed to parse sign data response: %v", err)
 	}
@@ -247,3 +813,993 @@ func TestIntegrationWorkflow(t *testing.T) {
 	assert.True(t, exists)
 	assert.NotEmpty(t, signature)
 }
+
+func TestSignDataRecoversWalletAddress(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	useDeterministicKeygen(t, "recover wallet address")
+
+	router := gin.Default()
+	router.POST("/wallet", createWallet)
+	router.POST("/sign", signData)
+
+	// Create a wallet
+	w1 := httptest.NewRecorder()
+	req1, _ := http.NewRequest("POST", "/wallet", nil)
+	router.ServeHTTP(w1, req1)
+	assert.Equal(t, http.StatusOK, w1.Code)
+
+	var createResponse stringFields
+	err := decodeData(w1.Body.Bytes(), &createResponse)
+	if err != nil {
+		t.Fatalf("Failed to parse create wallet response: %v", err)
+	}
+	walletAddress := createResponse["address"]
+
+	// Sign a 32 byte digest so it can be fed to ecrecover as is
+	digest := crypto.Keccak256([]byte("test"))
+	requestBody := signDataRequest{
+		Data:   "0x" + hex.EncodeToString(digest),
+		Wallet: walletAddress,
+		Hash:   hashNone,
+	}
+	jsonBody, _ := json.Marshal(requestBody)
+
+	w2 := httptest.NewRecorder()
+	req2, _ := http.NewRequest("POST", "/sign", bytes.NewBuffer(jsonBody))
+	req2.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w2, req2)
+	assert.Equal(t, http.StatusOK, w2.Code)
+
+	var signResponse stringFields
+	err = decodeData(w2.Body.Bytes(), &signResponse)
+	if err != nil {
+		t.Fatalf("Failed to parse sign data response: %v", err)
+	}
+	signature, err := hex.DecodeString(signResponse["signature"])
+	assert.NoError(t, err)
+	assert.Len(t, signature, 64, "Signature should be r||s")
+
+	// The recovery id is not returned, so try both candidates
+	recovered := false
+	for v := byte(0); v < 2; v++ {
+		pubKey, err := crypto.SigToPub(digest, append(signature, v))
+		if err != nil {
+			continue
+		}
+		if crypto.PubkeyToAddress(*pubKey).Hex() == walletAddress {
+			recovered = true
+			break
+		}
+	}
+	assert.True(t, recovered, "Signature should recover the wallet address")
+}
+
+func TestSigningQuorum(t *testing.T) {
+	partyIDs := make(tss.UnSortedPartyIDs, 5)
+	for i := range partyIDs {
+		partyIDs[i] = tss.NewPartyID(fmt.Sprintf("%d", i), fmt.Sprintf("P[%d]", i), big.NewInt(int64(i+1)))
+	}
+	wallet := &Wallet{
+		PartyIDs:  tss.SortPartyIDs(partyIDs),
+		Parties:   5,
+		Threshold: 2,
+	}
+
+	// Defaults to the first threshold+1 parties
+	signers, err := signingQuorum(wallet, nil)
+	assert.NoError(t, err)
+	assert.Len(t, signers, 3)
+	for i, signer := range signers {
+		assert.Equal(t, wallet.PartyIDs[i].Id, signer.Id)
+		assert.Equal(t, i, signer.Index)
+	}
+
+	// Named subset is re-indexed without touching the wallet's party IDs
+	signers, err = signingQuorum(wallet, []string{"4", "1", "3"})
+	assert.NoError(t, err)
+	assert.Equal(t, "1", signers[0].Id)
+	assert.Equal(t, "3", signers[1].Id)
+	assert.Equal(t, "4", signers[2].Id)
+	assert.Equal(t, 2, signers[2].Index)
+	for i, partyID := range wallet.PartyIDs {
+		assert.Equal(t, i, partyID.Index, "Wallet party indexes should not change")
+	}
+
+	// Wrong quorum size, unknown and duplicated parties
+	_, err = signingQuorum(wallet, []string{"0", "1"})
+	assert.Error(t, err)
+	_, err = signingQuorum(wallet, []string{"0", "1", "9"})
+	assert.Error(t, err)
+	_, err = signingQuorum(wallet, []string{"0", "1", "1"})
+	assert.Error(t, err)
+}
+
+func TestSignDataMinimumQuorum(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	useDeterministicKeygen(t, "minimum quorum")
+
+	router := gin.Default()
+	router.POST("/wallet", createWallet)
+	router.POST("/sign", signData)
+
+	// 2-of-3 wallet, signing only needs two of the parties
+	jsonBody, _ := json.Marshal(createWalletRequest{Parties: 3, Threshold: 1})
+	w1 := httptest.NewRecorder()
+	req1, _ := http.NewRequest("POST", "/wallet", bytes.NewBuffer(jsonBody))
+	req1.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w1, req1)
+	assert.Equal(t, http.StatusOK, w1.Code)
+
+	var createResponse stringFields
+	err := decodeData(w1.Body.Bytes(), &createResponse)
+	if err != nil {
+		t.Fatalf("Failed to parse create wallet response: %v", err)
+	}
+	walletAddress := createResponse["address"]
+
+	digest := crypto.Keccak256([]byte("quorum"))
+	requestBody := signDataRequest{
+		Data:   "0x" + hex.EncodeToString(digest),
+		Wallet: walletAddress,
+		Hash:   hashNone,
+	}
+	jsonBody, _ = json.Marshal(requestBody)
+
+	w2 := httptest.NewRecorder()
+	req2, _ := http.NewRequest("POST", "/sign", bytes.NewBuffer(jsonBody))
+	req2.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w2, req2)
+	assert.Equal(t, http.StatusOK, w2.Code)
+
+	var signResponse stringFields
+	err = decodeData(w2.Body.Bytes(), &signResponse)
+	if err != nil {
+		t.Fatalf("Failed to parse sign data response: %v", err)
+	}
+	signature, err := hex.DecodeString(signResponse["signature"])
+	assert.NoError(t, err)
+	assert.Len(t, signature, 64)
+
+	walletsMutex.Lock()
+	pubKey := wallets[walletAddress].PubKey
+	walletsMutex.Unlock()
+	r := new(big.Int).SetBytes(signature[:32])
+	s := new(big.Int).SetBytes(signature[32:])
+	assert.True(t, ecdsa.Verify(pubKey, digest, r, s), "Quorum signature should verify against the wallet public key")
+
+	// The caller can name the parties taking part
+	requestBody.Signers = []string{"0", "2"}
+	jsonBody, _ = json.Marshal(requestBody)
+
+	w3 := httptest.NewRecorder()
+	req3, _ := http.NewRequest("POST", "/sign", bytes.NewBuffer(jsonBody))
+	req3.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w3, req3)
+	assert.Equal(t, http.StatusOK, w3.Code)
+
+	err = decodeData(w3.Body.Bytes(), &signResponse)
+	if err != nil {
+		t.Fatalf("Failed to parse sign data response: %v", err)
+	}
+	signature, err = hex.DecodeString(signResponse["signature"])
+	assert.NoError(t, err)
+	r = new(big.Int).SetBytes(signature[:32])
+	s = new(big.Int).SetBytes(signature[32:])
+	assert.True(t, ecdsa.Verify(pubKey, digest, r, s), "Signature of the named signers should verify against the wallet public key")
+}
+
+func TestSignDataInvalidSigners(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	router := gin.Default()
+	router.POST("/sign", signData)
+
+	address := "0x00000000000000000000000000000000000000dF"
+	addFakeWallet(address)
+	t.Cleanup(func() {
+		walletsMutex.Lock()
+		delete(wallets, address)
+		walletsMutex.Unlock()
+	})
+
+	tests := []struct {
+		name    string
+		signers []string
+	}{
+		{"too few", []string{"0"}},
+		{"too many", []string{"0", "1", "2"}},
+		{"unknown party", []string{"0", "9"}},
+		{"duplicated party", []string{"1", "1"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			jsonBody, _ := json.Marshal(signDataRequest{Message: "signers", Wallet: address, Signers: tt.signers})
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("POST", "/sign", bytes.NewBuffer(jsonBody))
+			req.Header.Set("Content-Type", "application/json")
+			router.ServeHTTP(w, req)
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+		})
+	}
+}
+
+func TestSignDataReportsQuorum(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	key, _ := crypto.GenerateKey()
+	useInstantSigning(t, key)
+	address := deriveAddress(&key.PublicKey)
+	addFakeWallet(address).PubKey = &key.PublicKey
+	t.Cleanup(func() {
+		walletsMutex.Lock()
+		delete(wallets, address)
+		walletsMutex.Unlock()
+	})
+
+	router := gin.Default()
+	router.POST("/sign", signData)
+
+	tests := []struct {
+		name     string
+		signers  []string
+		expected []string
+	}{
+		{"default quorum", nil, []string{"0", "1"}},
+		{"named signers", []string{"2", "0"}, []string{"0", "2"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			jsonBody, _ := json.Marshal(signDataRequest{Data: "0x74657374", Wallet: address, Signers: tt.signers})
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("POST", "/sign", bytes.NewBuffer(jsonBody))
+			req.Header.Set("Content-Type", "application/json")
+			router.ServeHTTP(w, req)
+			assert.Equal(t, http.StatusOK, w.Code)
+
+			var response struct {
+				Signature string   `json:"signature"`
+				Signers   []string `json:"signers"`
+				Threshold int      `json:"threshold"`
+			}
+			if err := decodeData(w.Body.Bytes(), &response); err != nil {
+				t.Fatalf("Failed to parse sign data response: %v", err)
+			}
+			assert.NotEmpty(t, response.Signature)
+			assert.Equal(t, tt.expected, response.Signers, "The parties of the quorum should be reported")
+			assert.Equal(t, 1, response.Threshold)
+		})
+	}
+}
+
+// decodeData decodes the data of an API response into v
+func decodeData(body []byte, v any) error {
+	var response struct {
+		Data json.RawMessage `json:"data"`
+	}
+	if err := json.Unmarshal(body, &response); err != nil {
+		return err
+	}
+	return json.Unmarshal(response.Data, v)
+}
+
+// stringFields decodes the string fields of a JSON object and skips the
+// others, such as the signers and threshold of a sign response
+type stringFields map[string]string
+
+func (f *stringFields) UnmarshalJSON(data []byte) error {
+	var fields map[string]any
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return err
+	}
+	*f = make(stringFields, len(fields))
+	for name, value := range fields {
+		if s, ok := value.(string); ok {
+			(*f)[name] = s
+		}
+	}
+	return nil
+}
+
+// decodeError decodes the error of an API response
+func decodeError(body []byte) (apiError, error) {
+	var response struct {
+		Data  any       `json:"data"`
+		Error *apiError `json:"error"`
+	}
+	if err := json.Unmarshal(body, &response); err != nil {
+		return apiError{}, err
+	}
+	if response.Error == nil || response.Data != nil {
+		return apiError{}, errors.New("response is not an error")
+	}
+	return *response.Error, nil
+}
+
+// addFakeWallet stores a wallet with dummy share material, for tests that
+// don't need to run a real keygen ceremony
+func addFakeWallet(address string) *Wallet {
+	saveData := make(map[string]*keygen.LocalPartySaveData)
+	partyIDs := make(tss.UnSortedPartyIDs, 0, 3)
+	for i, id := range []string{"0", "1", "2"} {
+		save := keygen.NewLocalPartySaveData(3)
+		save.Xi = big.NewInt(12345)
+		save.P, save.Q = big.NewInt(7), big.NewInt(11)
+		saveData[id] = &save
+		partyIDs = append(partyIDs, tss.NewPartyID(id, "P["+id+"]", big.NewInt(int64(i+1))))
+	}
+	key, _ := crypto.GenerateKey()
+	wallet := &Wallet{
+		Address:     address,
+		PartyIDs:    tss.SortPartyIDs(partyIDs),
+		Parties:     3,
+		Threshold:   1,
+		Curve:       curveSecp256k1,
+		PubKey:      &key.PublicKey,
+		Algorithm:   algorithmECDSA,
+		SaveData:    saveData,
+		AddressType: addressEthereum,
+		Addresses:   map[string]string{addressEthereum: address},
+	}
+	walletsMutex.Lock()
+	wallets[address] = wallet
+	walletsMutex.Unlock()
+	return wallet
+}
+
+func TestDeleteWallet(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	router := gin.Default()
+	router.DELETE("/wallet/:address", deleteWallet)
+	router.POST("/sign", signData)
+
+	address := "0x00000000000000000000000000000000000000D1"
+	wallet := addFakeWallet(address)
+
+	w1 := httptest.NewRecorder()
+	req1, _ := http.NewRequest("DELETE", "/wallet/"+address, nil)
+	router.ServeHTTP(w1, req1)
+	assert.Equal(t, http.StatusNoContent, w1.Code)
+
+	walletsMutex.Lock()
+	_, exists := wallets[address]
+	walletsMutex.Unlock()
+	assert.False(t, exists, "Wallet should be removed")
+	for _, saveData := range wallet.SaveData {
+		assert.Equal(t, 0, saveData.Xi.Sign(), "Share should be zeroed")
+		assert.Equal(t, 0, saveData.P.Sign(), "Safe prime should be zeroed")
+	}
+
+	// Signing with a deleted wallet fails
+	requestBody := signDataRequest{
+		Data:   "0x74657374", // "test" in hex
+		Wallet: address,
+	}
+	jsonBody, _ := json.Marshal(requestBody)
+
+	w2 := httptest.NewRecorder()
+	req2, _ := http.NewRequest("POST", "/sign", bytes.NewBuffer(jsonBody))
+	req2.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w2, req2)
+	assert.Equal(t, http.StatusNotFound, w2.Code)
+}
+
+func TestGetWallet(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	router := gin.Default()
+	router.GET("/wallet/:address", getWallet)
+
+	address := "0x00000000000000000000000000000000000000D4"
+	wallet := addFakeWallet(address)
+	t.Cleanup(func() {
+		walletsMutex.Lock()
+		delete(wallets, address)
+		walletsMutex.Unlock()
+	})
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/wallet/"+address, nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response map[string]interface{}
+	err := decodeData(w.Body.Bytes(), &response)
+	if err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+	pubKeyDER, pubKeyPEM, err := wallet.pkixPubKey()
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{
+		"address":          address,
+		"addresses":        map[string]interface{}{"ethereum": address},
+		"pubKey":           fmt.Sprintf("0x%x", crypto.FromECDSAPub(wallet.PubKey)[1:]),
+		"pubKeyCompressed": fmt.Sprintf("0x%x", crypto.CompressPubkey(wallet.PubKey)),
+		"pubKeyFull":       fmt.Sprintf("0x%x", crypto.FromECDSAPub(wallet.PubKey)),
+		"pubKeyDer":        fmt.Sprintf("0x%x", pubKeyDER),
+		"pubKeyPem":        pubKeyPEM,
+		"algorithm":        "ecdsa",
+		"curve":            "secp256k1",
+		"parties":          float64(3),
+		"threshold":        float64(1),
+	}, response, "Only public wallet information should be returned")
+}
+
+func TestWalletPubKeyFull(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	router := gin.Default()
+	router.GET("/wallet/:address", getWallet)
+	router.GET("/wallets", listWallets)
+
+	key, _ := crypto.GenerateKey()
+	address := deriveAddress(&key.PublicKey)
+	wallet := addFakeWallet(address)
+	wallet.PubKey = &key.PublicKey
+	t.Cleanup(func() {
+		walletsMutex.Lock()
+		delete(wallets, address)
+		walletsMutex.Unlock()
+	})
+
+	w1 := httptest.NewRecorder()
+	req1, _ := http.NewRequest("GET", "/wallet/"+address, nil)
+	router.ServeHTTP(w1, req1)
+	assert.Equal(t, http.StatusOK, w1.Code)
+	var getResponse walletResponse
+	if err := decodeData(w1.Body.Bytes(), &getResponse); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+
+	w2 := httptest.NewRecorder()
+	req2, _ := http.NewRequest("GET", "/wallets?limit=1000", nil)
+	router.ServeHTTP(w2, req2)
+	assert.Equal(t, http.StatusOK, w2.Code)
+	var listResponse listWalletsResponse
+	if err := decodeData(w2.Body.Bytes(), &listResponse); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+	var listed walletsResponse
+	for _, item := range listResponse.Wallets {
+		if item.Address == address {
+			listed = item
+		}
+	}
+
+	for _, pubKeyFull := range []string{getResponse.PubKeyFull, listed.PubKeyFull} {
+		full, err := hexutil.Decode(pubKeyFull)
+		if !assert.NoError(t, err) {
+			continue
+		}
+		assert.Len(t, full, 65)
+		assert.Equal(t, byte(0x04), full[0], "The uncompressed prefix should be kept")
+		assert.Equal(t, fmt.Sprintf("0x%x", full[1:]), getResponse.PubKey, "pubKey is the full key without its prefix")
+		parsed, err := crypto.UnmarshalPubkey(full)
+		if assert.NoError(t, err) {
+			assert.True(t, parsed.Equal(&key.PublicKey))
+		}
+	}
+
+	// Ed25519 keys have no uncompressed form
+	wallet.Algorithm = algorithmEdDSA
+	_, _, pubKeyFull := wallet.pubKeyHex()
+	assert.Empty(t, pubKeyFull)
+}
+
+func TestGetWalletNonExistent(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	router := gin.Default()
+	router.GET("/wallet/:address", getWallet)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/wallet/0x00000000000000000000000000000000000000D5", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestDeleteWalletNonExistent(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	router := gin.Default()
+	router.DELETE("/wallet/:address", deleteWallet)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("DELETE", "/wallet/0x00000000000000000000000000000000000000D2", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestSignDataRecoveryID(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	useDeterministicKeygen(t, "recovery id")
+
+	router := gin.Default()
+	router.POST("/wallet", createWallet)
+	router.POST("/sign", signData)
+
+	// Create a wallet
+	w1 := httptest.NewRecorder()
+	req1, _ := http.NewRequest("POST", "/wallet", nil)
+	router.ServeHTTP(w1, req1)
+	assert.Equal(t, http.StatusOK, w1.Code)
+
+	var createResponse stringFields
+	err := decodeData(w1.Body.Bytes(), &createResponse)
+	if err != nil {
+		t.Fatalf("Failed to parse create wallet response: %v", err)
+	}
+	walletAddress := createResponse["address"]
+
+	walletsMutex.Lock()
+	walletPubKey := crypto.FromECDSAPub(wallets[walletAddress].PubKey)
+	walletsMutex.Unlock()
+
+	digest := crypto.Keccak256([]byte("recovery"))
+	for _, raw := range []bool{false, true} {
+		requestBody := signDataRequest{
+			Data:          "0x" + hex.EncodeToString(digest),
+			Wallet:        walletAddress,
+			Hash:          hashNone,
+			RawRecoveryID: raw,
+		}
+		jsonBody, _ := json.Marshal(requestBody)
+
+		w2 := httptest.NewRecorder()
+		req2, _ := http.NewRequest("POST", "/sign", bytes.NewBuffer(jsonBody))
+		req2.Header.Set("Content-Type", "application/json")
+		router.ServeHTTP(w2, req2)
+		assert.Equal(t, http.StatusOK, w2.Code)
+
+		var signResponse stringFields
+		err = decodeData(w2.Body.Bytes(), &signResponse)
+		if err != nil {
+			t.Fatalf("Failed to parse sign data response: %v", err)
+		}
+
+		rsv, err := hex.DecodeString(signResponse["rsv"])
+		assert.NoError(t, err)
+		assert.Len(t, rsv, 65)
+		assert.Equal(t, signResponse["signature"], hex.EncodeToString(rsv[:64]))
+		v, err := hexutil.DecodeUint64(signResponse["v"])
+		assert.NoError(t, err)
+		assert.Equal(t, uint64(rsv[64]), v)
+
+		recoveryID := rsv[64]
+		if raw {
+			assert.Contains(t, []byte{0, 1}, recoveryID)
+		} else {
+			assert.Contains(t, []byte{27, 28}, recoveryID)
+			recoveryID -= 27
+		}
+
+		recovered, err := crypto.Ecrecover(digest, append(rsv[:64], recoveryID))
+		assert.NoError(t, err)
+		assert.Equal(t, walletPubKey, recovered, "Ecrecover should return the wallet public key")
+	}
+}
+
+func TestRecoveryV(t *testing.T) {
+	assert.Equal(t, byte(27), recoveryV([]byte{0}, false))
+	assert.Equal(t, byte(28), recoveryV([]byte{1}, false))
+	assert.Equal(t, byte(1), recoveryV([]byte{1}, true))
+	// Only the parity bit is kept
+	assert.Equal(t, byte(28), recoveryV([]byte{3}, false))
+	assert.Equal(t, byte(0), recoveryV(nil, true))
+}
+
+func TestSignDataHashModes(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	useDeterministicKeygen(t, "hash modes")
+
+	router := gin.Default()
+	router.POST("/wallet", createWallet)
+	router.POST("/sign", signData)
+
+	// Create a wallet
+	w1 := httptest.NewRecorder()
+	req1, _ := http.NewRequest("POST", "/wallet", nil)
+	router.ServeHTTP(w1, req1)
+	assert.Equal(t, http.StatusOK, w1.Code)
+
+	var createResponse stringFields
+	err := decodeData(w1.Body.Bytes(), &createResponse)
+	if err != nil {
+		t.Fatalf("Failed to parse create wallet response: %v", err)
+	}
+	walletAddress := createResponse["address"]
+
+	walletsMutex.Lock()
+	pubKey := wallets[walletAddress].PubKey
+	walletsMutex.Unlock()
+
+	message := []byte("hello")
+	sha256Digest := sha256.Sum256(message)
+	sha3Digest := sha3.Sum256(message)
+	blake2bDigest := blake2b.Sum256(message)
+	expectedDigests := map[string][]byte{
+		"":             crypto.Keccak256(message),
+		hashKeccak256:  crypto.Keccak256(message),
+		hashSHA256:     sha256Digest[:],
+		hashSHA3256:    sha3Digest[:],
+		hashBlake2b256: blake2bDigest[:],
+	}
+	for hashMode, expectedDigest := range expectedDigests {
+		requestBody := signDataRequest{
+			Data:   "0x" + hex.EncodeToString(message),
+			Wallet: walletAddress,
+			Hash:   hashMode,
+		}
+		jsonBody, _ := json.Marshal(requestBody)
+
+		w2 := httptest.NewRecorder()
+		req2, _ := http.NewRequest("POST", "/sign", bytes.NewBuffer(jsonBody))
+		req2.Header.Set("Content-Type", "application/json")
+		router.ServeHTTP(w2, req2)
+		assert.Equal(t, http.StatusOK, w2.Code)
+
+		var signResponse stringFields
+		err = decodeData(w2.Body.Bytes(), &signResponse)
+		if err != nil {
+			t.Fatalf("Failed to parse sign data response: %v", err)
+		}
+		assert.Equal(t, hex.EncodeToString(expectedDigest), signResponse["digest"])
+
+		signature, err := hex.DecodeString(signResponse["signature"])
+		assert.NoError(t, err)
+		r := new(big.Int).SetBytes(signature[:32])
+		s := new(big.Int).SetBytes(signature[32:])
+		assert.True(t, ecdsa.Verify(pubKey, expectedDigest, r, s), "Signature should verify against the %q digest", hashMode)
+	}
+}
+
+func TestSignDataInvalidHash(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	router := gin.Default()
+	router.POST("/sign", signData)
+
+	// The length of raw data depends on the algorithm of the wallet, it is
+	// checked once the wallet is found
+	address := "0x0000000000000000000000000000000000000097"
+	addFakeWallet(address)
+	t.Cleanup(func() {
+		walletsMutex.Lock()
+		delete(wallets, address)
+		walletsMutex.Unlock()
+	})
+
+	invalidRequests := []signDataRequest{
+		// Unknown hash mode
+		{Data: "0x74657374", Wallet: "0xadcdf1cc67362d0d61ad8954d077b78a1d80087b", Hash: "md5"},
+		{Data: "0x74657374", Wallet: "0xadcdf1cc67362d0d61ad8954d077b78a1d80087b", Hash: "sha3-512"},
+		// Raw data longer than the curve order
+		{Data: "0x" + strings.Repeat("ff", 33), Wallet: address, Hash: hashNone},
+		// Raw data shorter than the curve order
+		{Data: "0x" + strings.Repeat("ff", 31), Wallet: address, Hash: hashNone},
+	}
+	for _, requestBody := range invalidRequests {
+		jsonBody, _ := json.Marshal(requestBody)
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/sign", bytes.NewBuffer(jsonBody))
+		req.Header.Set("Content-Type", "application/json")
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	}
+}
+
+func TestSignDataEIP191(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	useDeterministicKeygen(t, "eip191 signing")
+
+	router := gin.Default()
+	router.POST("/wallet", createWallet)
+	router.POST("/sign", signData)
+
+	// Create a wallet
+	w1 := httptest.NewRecorder()
+	req1, _ := http.NewRequest("POST", "/wallet", nil)
+	router.ServeHTTP(w1, req1)
+	assert.Equal(t, http.StatusOK, w1.Code)
+
+	var createResponse stringFields
+	err := decodeData(w1.Body.Bytes(), &createResponse)
+	if err != nil {
+		t.Fatalf("Failed to parse create wallet response: %v", err)
+	}
+	walletAddress := createResponse["address"]
+
+	requestBody := signDataRequest{
+		Message: "hello",
+		Mode:    modeEIP191,
+		Wallet:  walletAddress,
+	}
+	jsonBody, _ := json.Marshal(requestBody)
+
+	w2 := httptest.NewRecorder()
+	req2, _ := http.NewRequest("POST", "/sign", bytes.NewBuffer(jsonBody))
+	req2.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w2, req2)
+	assert.Equal(t, http.StatusOK, w2.Code)
+
+	var signResponse stringFields
+	err = decodeData(w2.Body.Bytes(), &signResponse)
+	if err != nil {
+		t.Fatalf("Failed to parse sign data response: %v", err)
+	}
+	assert.Equal(t, "50b2c43fd39106bafbba0da34fc430e1f91e3c96ea2acee2bc34119f92b37750", signResponse["digest"])
+
+	// Recover the signer the way ecrecover does for a personal_sign signature
+	rsv, err := hex.DecodeString(signResponse["rsv"])
+	assert.NoError(t, err)
+	rsv[64] -= 27
+	pubKey, err := crypto.SigToPub(accounts.TextHash([]byte("hello")), rsv)
+	assert.NoError(t, err)
+	assert.Equal(t, walletAddress, crypto.PubkeyToAddress(*pubKey).Hex())
+}
+
+func TestSignDataInvalidMode(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	router := gin.Default()
+	router.POST("/sign", signData)
+
+	invalidRequests := []signDataRequest{
+		// Unknown mode
+		{Message: "hello", Mode: "eip712", Wallet: "0xadcdf1cc67362d0d61ad8954d077b78a1d80087b"},
+		// personal_sign is always keccak256
+		{Message: "hello", Mode: modeEIP191, Hash: hashSHA256, Wallet: "0xadcdf1cc67362d0d61ad8954d077b78a1d80087b"},
+		// Both data and message
+		{Data: "0x74657374", Message: "hello", Mode: modeEIP191, Wallet: "0xadcdf1cc67362d0d61ad8954d077b78a1d80087b"},
+	}
+	for _, requestBody := range invalidRequests {
+		jsonBody, _ := json.Marshal(requestBody)
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/sign", bytes.NewBuffer(jsonBody))
+		req.Header.Set("Content-Type", "application/json")
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	}
+}
+
+func TestNewPartyIDs(t *testing.T) {
+	partyIDs, err := newPartyIDs(5, tss.S256())
+	assert.NoError(t, err)
+	assert.Len(t, partyIDs, 5)
+
+	ids := make(map[string]bool)
+	keys := make(map[string]bool)
+	for i, partyID := range partyIDs {
+		assert.Equal(t, i, partyID.Index, "Party IDs should be sorted and indexed")
+		assert.Equal(t, 1, partyID.KeyInt().Sign(), "Party keys should be positive")
+		assert.Equal(t, -1, partyID.KeyInt().Cmp(tss.S256().Params().N), "Party keys should be below the curve order")
+		ids[partyID.Id] = true
+		keys[partyID.KeyInt().String()] = true
+	}
+	assert.Len(t, ids, 5, "Party IDs should be unique")
+	assert.Len(t, keys, 5, "Party keys should be unique")
+}
+
+// repeatingReader returns the same bytes for its first reads, then reads
+// from crypto/rand
+type repeatingReader struct {
+	block   []byte
+	repeats int
+}
+
+func (r *repeatingReader) Read(p []byte) (int, error) {
+	if r.repeats == 0 {
+		return rand.Read(p)
+	}
+	r.repeats--
+	for i := range p {
+		p[i] = r.block[i%len(r.block)]
+	}
+	return len(p), nil
+}
+
+func TestNewPartyIDsCollision(t *testing.T) {
+	previous := entropySource
+	t.Cleanup(func() { entropySource = previous })
+
+	// The first party takes the repeated key, the second draws it twice more
+	// before getting another one
+	entropySource = &repeatingReader{block: []byte{0x42}, repeats: 3}
+	partyIDs, err := newPartyIDs(3, tss.S256())
+	assert.NoError(t, err)
+	keys := make(map[string]bool)
+	for _, partyID := range partyIDs {
+		keys[partyID.KeyInt().String()] = true
+	}
+	assert.Len(t, keys, 3, "Colliding keys should be drawn again")
+
+	// Reserved keys are never reused
+	entropySource = &repeatingReader{block: []byte{0x42}, repeats: 1}
+	reserved, err := newPartyIDs(1, tss.S256())
+	assert.NoError(t, err)
+	entropySource = &repeatingReader{block: []byte{0x42}, repeats: 2}
+	partyIDs, err = newPartyIDs(1, tss.S256(), reserved...)
+	assert.NoError(t, err)
+	assert.NotEqual(t, reserved[0].KeyInt(), partyIDs[0].KeyInt(), "A reserved key should be drawn again")
+
+	// A source that keeps returning the same key is given up on
+	entropySource = &repeatingReader{block: []byte{0x42}, repeats: 1 + maxPartyKeyAttempts}
+	_, err = newPartyIDs(2, tss.S256())
+	assert.ErrorIs(t, err, errPartyKeyCollision)
+}
+
+func TestCreateWalletPartyKeyCollision(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	previous := entropySource
+	entropySource = &repeatingReader{block: []byte{0x42}, repeats: 1000}
+	t.Cleanup(func() { entropySource = previous })
+
+	router := gin.New()
+	router.POST("/wallet", createWallet)
+	jsonBody, _ := json.Marshal(createWalletRequest{Parties: 2, Threshold: 1})
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/wallet", bytes.NewBuffer(jsonBody))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+
+	response, err := decodeError(w.Body.Bytes())
+	assert.NoError(t, err)
+	assert.Contains(t, response.Message, errPartyKeyCollision.Error())
+}
+
+func TestNewPartyIDsConcurrent(t *testing.T) {
+	const wallets = 20
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	keys := make(map[string]bool)
+	for i := 0; i < wallets; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			partyIDs, err := newPartyIDs(3, tss.S256())
+			assert.NoError(t, err)
+			mu.Lock()
+			defer mu.Unlock()
+			for _, partyID := range partyIDs {
+				keys[partyID.KeyInt().String()] = true
+			}
+		}()
+	}
+	wg.Wait()
+	assert.Len(t, keys, wallets*3, "Party keys should never collide across wallets")
+}
+
+func TestCreateWalletConcurrent(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	useDeterministicKeygen(t, "concurrent wallets")
+
+	router := gin.Default()
+	router.POST("/wallet", createWallet)
+
+	const concurrentWallets = 20
+	addresses := make(chan string, concurrentWallets)
+	var wg sync.WaitGroup
+	for i := 0; i < concurrentWallets; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("POST", "/wallet", nil)
+			router.ServeHTTP(w, req)
+			assert.Equal(t, http.StatusOK, w.Code)
+
+			var response stringFields
+			if err := decodeData(w.Body.Bytes(), &response); err != nil {
+				t.Errorf("Failed to parse response: %v", err)
+				return
+			}
+			addresses <- response["address"]
+		}()
+	}
+	wg.Wait()
+	close(addresses)
+
+	distinct := make(map[string]bool)
+	for address := range addresses {
+		assert.NotEmpty(t, address)
+		distinct[address] = true
+	}
+	assert.Len(t, distinct, concurrentWallets, "Every wallet should get its own address")
+
+	walletsMutex.Lock()
+	defer walletsMutex.Unlock()
+	for address := range distinct {
+		assert.Contains(t, wallets, address)
+	}
+}
+
+func TestSignDataReleasesGoroutines(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	useDeterministicKeygen(t, "sign releases goroutines")
+
+	router := gin.Default()
+	router.POST("/wallet", createWallet)
+	router.POST("/sign", signData)
+
+	jsonBody, _ := json.Marshal(createWalletRequest{Parties: 2, Threshold: 1})
+	w1 := httptest.NewRecorder()
+	req1, _ := http.NewRequest("POST", "/wallet", bytes.NewBuffer(jsonBody))
+	req1.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w1, req1)
+	assert.Equal(t, http.StatusOK, w1.Code)
+
+	var createResponse stringFields
+	err := decodeData(w1.Body.Bytes(), &createResponse)
+	if err != nil {
+		t.Fatalf("Failed to parse create wallet response: %v", err)
+	}
+	walletAddress := createResponse["address"]
+
+	// Let the keygen goroutines wind down before taking the baseline
+	time.Sleep(500 * time.Millisecond)
+	baseline := runtime.NumGoroutine()
+
+	const signOperations = 10
+	for i := 0; i < signOperations; i++ {
+		requestBody := signDataRequest{
+			Message: fmt.Sprintf("message %d", i),
+			Wallet:  walletAddress,
+		}
+		jsonBody, _ = json.Marshal(requestBody)
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/sign", bytes.NewBuffer(jsonBody))
+		req.Header.Set("Content-Type", "application/json")
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+	}
+
+	// Parties and forwarders are released asynchronously
+	assertNoLeakedGoroutines(t, baseline)
+}
+
+// failingParty is a keygen party that fails as soon as it is started
+type failingParty struct {
+	tss.Party
+}
+
+func (p *failingParty) Start() *tss.Error {
+	return p.WrapError(errors.New("injected failure"))
+}
+
+func TestCreateWalletKeygenFailure(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	previous := newKeygenParty
+	newKeygenParty = func(params *tss.Parameters, out chan<- tss.Message, end chan<- keygen.LocalPartySaveData, preParams ...keygen.LocalPreParams) tss.Party {
+		return &failingParty{Party: previous(params, out, end, preParams...)}
+	}
+	t.Cleanup(func() { newKeygenParty = previous })
+
+	router := gin.Default()
+	router.POST("/wallet", createWallet)
+
+	walletsMutex.Lock()
+	walletsBefore := len(wallets)
+	walletsMutex.Unlock()
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/wallet", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+
+	// Every party fails, yet a single error response must be written
+	response, err := decodeError(w.Body.Bytes())
+	assert.NoError(t, err, "Response should be a single JSON object")
+	assert.Equal(t, codeInternal, response.Code)
+	assert.Contains(t, response.Message, "injected failure")
+
+	walletsMutex.Lock()
+	defer walletsMutex.Unlock()
+	assert.Len(t, wallets, walletsBefore, "No wallet should be stored")
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Operations reported by the failures counter
+const (
+	operationKeygen  = "keygen"
+	operationSign    = "sign"
+	operationReshare = "reshare"
+)
+
+var (
+	walletsCreatedTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "tss_wallets_created_total",
+		Help: "Number of wallets created.",
+	})
+	signaturesTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "tss_signatures_total",
+		Help: "Number of signatures produced.",
+	})
+	failuresTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "tss_failures_total",
+		Help: "Number of failed keygen, signing and resharing ceremonies.",
+	}, []string{"operation"})
+	// Keygen generates safe primes for the Paillier keys and takes from a few
+	// seconds up to several minutes
+	keygenDurationSeconds = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Name:    "tss_keygen_duration_seconds",
+		Help:    "Duration of successful keygen ceremonies.",
+		Buckets: prometheus.ExponentialBuckets(1, 2, 10),
+	})
+	signDurationSeconds = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Name:    "tss_sign_duration_seconds",
+		Help:    "Duration of successful signing ceremonies.",
+		Buckets: prometheus.ExponentialBuckets(0.05, 2, 10),
+	})
+	// Rounds are numbered by tss-lib, their count and cost depend on the
+	// algorithm and operation
+	roundDurationSeconds = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "tss_round_duration_seconds",
+		Help:    "Duration of the rounds of successful and failed ceremonies, by algorithm, operation and round.",
+		Buckets: prometheus.ExponentialBuckets(0.005, 4, 10),
+	}, []string{"algorithm", "operation", "round"})
+	preParamsReady = prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Name: "tss_preparams_pool_size",
+		Help: "Number of keygen pre-parameters ready in the pool.",
+	}, func() float64 { return float64(keygenPreParams.size()) })
+	httpRequestsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "tss_http_requests_total",
+		Help: "Number of HTTP requests handled, by route and status code.",
+	}, []string{"method", "route", "code"})
+	httpRequestDurationSeconds = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "tss_http_request_duration_seconds",
+		Help:    "Duration of HTTP requests, by route.",
+		Buckets: prometheus.ExponentialBuckets(0.005, 4, 10),
+	}, []string{"method", "route"})
+)
+
+func init() {
+	prometheus.MustRegister(
+		walletsCreatedTotal,
+		signaturesTotal,
+		failuresTotal,
+		keygenDurationSeconds,
+		signDurationSeconds,
+		roundDurationSeconds,
+		preParamsReady,
+		httpRequestsTotal,
+		httpRequestDurationSeconds,
+	)
+}
+
+// instrumentHandlers records the count and duration of every request. Routes
+// are reported by their pattern so wallet addresses don't end up as labels.
+func instrumentHandlers() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		httpRequestsTotal.WithLabelValues(c.Request.Method, route, strconv.Itoa(c.Writer.Status())).Inc()
+		httpRequestDurationSeconds.WithLabelValues(c.Request.Method, route).Observe(time.Since(start).Seconds())
+	}
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+// scrapeMetric fetches /metrics and returns the value of the given sample,
+// name includes the labels if the metric has any
+func scrapeMetric(t *testing.T, router *gin.Engine, name string) float64 {
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/metrics", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	scanner := bufio.NewScanner(w.Body)
+	for scanner.Scan() {
+		value, found := strings.CutPrefix(scanner.Text(), name+" ")
+		if !found {
+			continue
+		}
+		parsed, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			t.Fatalf("Failed to parse %s: %v", name, err)
+		}
+		return parsed
+	}
+	return 0
+}
+
+func TestMetrics(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	useDeterministicKeygen(t, "metrics")
+
+	router := newRouter(nil)
+
+	walletsBefore := scrapeMetric(t, router, "tss_wallets_created_total")
+	jsonBody, _ := json.Marshal(createWalletRequest{Parties: 2, Threshold: 1})
+	w1 := httptest.NewRecorder()
+	req1, _ := http.NewRequest("POST", "/wallet", bytes.NewBuffer(jsonBody))
+	req1.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w1, req1)
+	assert.Equal(t, http.StatusOK, w1.Code)
+	assert.Equal(t, walletsBefore+1, scrapeMetric(t, router, "tss_wallets_created_total"))
+
+	var createResponse stringFields
+	err := decodeData(w1.Body.Bytes(), &createResponse)
+	if err != nil {
+		t.Fatalf("Failed to parse create wallet response: %v", err)
+	}
+
+	signaturesBefore := scrapeMetric(t, router, "tss_signatures_total")
+	signsBefore := scrapeMetric(t, router, "tss_sign_duration_seconds_count")
+	requestsBefore := scrapeMetric(t, router, `tss_http_requests_total{code="200",method="POST",route="/sign"}`)
+
+	jsonBody, _ = json.Marshal(signDataRequest{Message: "metrics", Wallet: createResponse["address"]})
+	w2 := httptest.NewRecorder()
+	req2, _ := http.NewRequest("POST", "/sign", bytes.NewBuffer(jsonBody))
+	req2.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w2, req2)
+	assert.Equal(t, http.StatusOK, w2.Code)
+
+	assert.Equal(t, signaturesBefore+1, scrapeMetric(t, router, "tss_signatures_total"))
+	assert.Equal(t, signsBefore+1, scrapeMetric(t, router, "tss_sign_duration_seconds_count"))
+	assert.Equal(t, requestsBefore+1, scrapeMetric(t, router, `tss_http_requests_total{code="200",method="POST",route="/sign"}`))
+}
+
+func TestRoundTimings(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	router := newRouter(nil)
+	var output bytes.Buffer
+	log.SetOutput(&output)
+	t.Cleanup(func() { log.SetOutput(os.Stderr) })
+
+	round := func(n int) string {
+		return fmt.Sprintf(`tss_round_duration_seconds_count{algorithm="eddsa",operation="keygen",round="%d"}`, n)
+	}
+	before := []float64{scrapeMetric(t, router, round(1)), scrapeMetric(t, router, round(2))}
+
+	// EdDSA keygen runs real rounds in well under a second
+	wallet, err := walletService.CreateWallet(context.Background(), createWalletRequest{Parties: 3, Threshold: 1, Algorithm: algorithmEdDSA}, nil)
+	if err != nil {
+		t.Fatalf("Failed to create wallet: %v", err)
+	}
+	t.Cleanup(func() {
+		walletsMutex.Lock()
+		delete(wallets, wallet.Address)
+		walletsMutex.Unlock()
+	})
+
+	assert.Equal(t, before[0]+1, scrapeMetric(t, router, round(1)), "Round 1 should be timed once")
+	assert.Equal(t, before[1]+1, scrapeMetric(t, router, round(2)), "Round 2 should be timed once")
+	assert.Regexp(t, `eddsa keygen ceremony rounds: round 1 [0-9.]+[µnm]?s, round 2 [0-9.]+[µnm]?s`, output.String())
+}
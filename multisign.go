@@ -0,0 +1,105 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxMultiSignWallets is the number of wallets a multi-wallet sign request
+// can name. The ceremonies run batchConcurrency at a time, like a batch.
+const maxMultiSignWallets = 20
+
+// signMultiRequest represents the request body for signMulti endpoint
+type signMultiRequest struct {
+	Data    string   `json:"data"`
+	Wallets []string `json:"wallets"`
+	// DataEncoding of data: hex (default) or base64
+	DataEncoding string `json:"dataEncoding"`
+	// Mode is either raw (default) or eip191 to sign like personal_sign
+	Mode string `json:"mode"`
+	// Hash applied to data before signing: none, keccak256 (default), sha256,
+	// sha3-256 or blake2b-256
+	Hash string `json:"hash"`
+	// RawRecoveryID returns v as 0/1 instead of the Ethereum 27/28
+	RawRecoveryID bool `json:"rawRecoveryId"`
+	// Encoding of the signature fields: raw (default), der or eth65
+	Encoding string `json:"encoding"`
+}
+
+// signMulti signs the same data with several wallets and returns the
+// signatures keyed by wallet address. Every wallet is checked before any
+// ceremony starts, so a bad wallet fails the request without signing.
+func signMulti(c *gin.Context) {
+	var requestBody signMultiRequest
+
+	if err := c.BindJSON(&requestBody); err != nil {
+		respondError(c, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	if requestBody.Data == "" || len(requestBody.Wallets) == 0 {
+		respondError(c, http.StatusBadRequest, "data and wallets are required")
+		return
+	}
+	if len(requestBody.Wallets) > maxMultiSignWallets {
+		respondError(c, http.StatusBadRequest, fmt.Sprintf("at most %d wallets can sign together", maxMultiSignWallets))
+		return
+	}
+
+	jobs := make([]*signJob, len(requestBody.Wallets))
+	seen := make(map[string]bool, len(requestBody.Wallets))
+	for i, address := range requestBody.Wallets {
+		job, err := walletService.prepareSign(signDataRequest{
+			Data:          requestBody.Data,
+			Wallet:        address,
+			DataEncoding:  requestBody.DataEncoding,
+			Mode:          requestBody.Mode,
+			Hash:          requestBody.Hash,
+			RawRecoveryID: requestBody.RawRecoveryID,
+			Encoding:      requestBody.Encoding,
+		})
+		if err != nil {
+			status, _ := serviceErrorResponse(err)
+			respondError(c, status, fmt.Sprintf("wallet %s: %s", address, err))
+			return
+		}
+		if seen[job.wallet.Address] {
+			respondError(c, http.StatusBadRequest, fmt.Sprintf("wallet %s is listed more than once", job.wallet.Address))
+			return
+		}
+		seen[job.wallet.Address] = true
+		jobs[i] = job
+	}
+
+	if !chargeRateLimit(c, len(jobs)) {
+		return
+	}
+
+	responses := make([]gin.H, len(jobs))
+	errs := make([]error, len(jobs))
+	semaphore := make(chan struct{}, batchConcurrency)
+	var wg sync.WaitGroup
+	for i, job := range jobs {
+		wg.Add(1)
+		semaphore <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-semaphore }()
+			responses[i], errs[i] = job.sign(nil)
+		}()
+	}
+	wg.Wait()
+
+	signatures := make(map[string]gin.H, len(jobs))
+	for i, job := range jobs {
+		if errs[i] != nil {
+			status, _ := serviceErrorResponse(errs[i])
+			respondError(c, status, fmt.Sprintf("wallet %s: %s", job.wallet.Address, errs[i]))
+			return
+		}
+		signatures[job.wallet.Address] = responses[i]
+	}
+	respond(c, http.StatusOK, gin.H{"signatures": signatures})
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"encoding/hex"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSignMulti(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	useDeterministicKeygen(t, "multi wallet signing")
+
+	signers := make([]*Wallet, 2)
+	for i := range signers {
+		wallet, err := walletService.CreateWallet(context.Background(), createWalletRequest{Parties: 2, Threshold: 1}, nil)
+		if err != nil {
+			t.Fatalf("Failed to create wallet: %v", err)
+		}
+		t.Cleanup(func() {
+			walletsMutex.Lock()
+			delete(wallets, wallet.Address)
+			walletsMutex.Unlock()
+		})
+		signers[i] = wallet
+	}
+	assert.NotEqual(t, signers[0].Address, signers[1].Address)
+
+	router := gin.Default()
+	router.POST("/sign/multi", signMulti)
+
+	data := []byte("aggregate me")
+	digest := crypto.Keccak256(data)
+	jsonBody, _ := json.Marshal(signMultiRequest{
+		Data:    "0x" + hex.EncodeToString(data),
+		Wallets: []string{signers[0].Address, strings.ToLower(signers[1].Address)},
+	})
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/sign/multi", bytes.NewBuffer(jsonBody))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	var multiResponse struct {
+		Signatures map[string]stringFields `json:"signatures"`
+	}
+	err := decodeData(w.Body.Bytes(), &multiResponse)
+	if err != nil {
+		t.Fatalf("Failed to parse multi sign response: %v", err)
+	}
+	if !assert.Len(t, multiResponse.Signatures, 2) {
+		return
+	}
+	for _, wallet := range signers {
+		response, ok := multiResponse.Signatures[wallet.Address]
+		if !assert.True(t, ok, "Signatures should be keyed by the checksummed address") {
+			continue
+		}
+		assert.Equal(t, hex.EncodeToString(digest), response["digest"])
+		signature, err := hex.DecodeString(response["signature"])
+		if !assert.NoError(t, err) || !assert.Len(t, signature, 64) {
+			continue
+		}
+		r := new(big.Int).SetBytes(signature[:32])
+		s := new(big.Int).SetBytes(signature[32:])
+		assert.True(t, ecdsa.Verify(wallet.PubKey, digest, r, s), "Signature of %s should verify", wallet.Address)
+		assert.False(t, ecdsa.Verify(otherWallet(signers, wallet).PubKey, digest, r, s), "Signature of %s should not verify with the other wallet", wallet.Address)
+	}
+}
+
+// otherWallet returns the wallet of the pair that is not wallet
+func otherWallet(pair []*Wallet, wallet *Wallet) *Wallet {
+	if pair[0] == wallet {
+		return pair[1]
+	}
+	return pair[0]
+}
+
+func TestSignMultiInvalidInput(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	router := gin.Default()
+	router.POST("/sign/multi", signMulti)
+
+	key, _ := crypto.GenerateKey()
+	address := deriveAddress(&key.PublicKey)
+	addFakeWallet(address)
+	t.Cleanup(func() {
+		walletsMutex.Lock()
+		delete(wallets, address)
+		walletsMutex.Unlock()
+	})
+
+	tooMany := `{"data": "0x01", "wallets": [` + strings.Repeat(`"`+address+`",`, maxMultiSignWallets) + `"` + address + `"]}`
+	tests := []struct {
+		name   string
+		body   string
+		status int
+	}{
+		{"invalid body", `{`, http.StatusBadRequest},
+		{"missing data", `{"wallets": ["` + address + `"]}`, http.StatusBadRequest},
+		{"no wallets", `{"data": "0x01", "wallets": []}`, http.StatusBadRequest},
+		{"too many wallets", tooMany, http.StatusBadRequest},
+		{"invalid data", `{"data": "0xzz", "wallets": ["` + address + `"]}`, http.StatusBadRequest},
+		{"invalid hash", `{"data": "0x01", "hash": "md5", "wallets": ["` + address + `"]}`, http.StatusBadRequest},
+		{"duplicate wallet", `{"data": "0x01", "wallets": ["` + address + `", "` + strings.ToLower(address) + `"]}`, http.StatusBadRequest},
+		{"unknown wallet", `{"data": "0x01", "wallets": ["` + address + `", "0x00000000000000000000000000000000000000dd"]}`, http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("POST", "/sign/multi", bytes.NewBufferString(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			router.ServeHTTP(w, req)
+			assert.Equal(t, tt.status, w.Code, w.Body.String())
+		})
+	}
+}
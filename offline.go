@@ -0,0 +1,103 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+)
+
+// signCommand is the first argument running the offline sign command instead
+// of the server
+const signCommand = "sign"
+
+// sharesPassphraseEnv names the environment variable holding the passphrase
+// of the exported shares read by the sign command, it is kept out of the
+// command line so it does not end up in the shell history
+const sharesPassphraseEnv = "SHARES_PASSPHRASE"
+
+// loadExportedWallet reads a wallet exported by GET /wallet/:address/export,
+// either the whole response or only its data, or a wallet file of the store.
+// Encrypted shares are decrypted with passphrase.
+func loadExportedWallet(path, passphrase string) (*Wallet, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var envelope struct {
+		Data *storedWallet `json:"data"`
+	}
+	if err := json.Unmarshal(content, &envelope); err != nil {
+		return nil, fmt.Errorf("invalid shares file: %w", err)
+	}
+	sw := envelope.Data
+	if sw == nil {
+		sw = new(storedWallet)
+		if err := json.Unmarshal(content, sw); err != nil {
+			return nil, fmt.Errorf("invalid shares file: %w", err)
+		}
+	}
+	if sw.Address == "" {
+		return nil, errors.New("invalid shares file: no wallet found")
+	}
+
+	var sc *shareCipher
+	if sw.EncryptedSaveData != nil {
+		if passphrase == "" {
+			return nil, fmt.Errorf("the shares are encrypted, set %s to their passphrase", sharesPassphraseEnv)
+		}
+		sc = newShareCipher(passphrase)
+	}
+	return sw.toWallet(sc)
+}
+
+// runSignCommand signs data offline for air-gapped setups: the wallet is
+// loaded from exported shares and the signing ceremony runs in process, with
+// no server. The response of the sign endpoint is printed to stdout. It
+// returns the exit code of the command.
+func runSignCommand(args []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet(signCommand, flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	sharesFile := flags.String("shares", "", "wallet exported with its encrypted key shares, the passphrase is read from "+sharesPassphraseEnv)
+	data := flags.String("data", "", "hex encoded data to sign")
+	hashMode := flags.String("hash", "", "hash applied to data before signing: none, keccak256 (default), sha256, sha3-256 or blake2b-256")
+	encoding := flags.String("encoding", "", "encoding of the signature: raw (default), der, eth65 or eip2098")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if *sharesFile == "" || *data == "" {
+		fmt.Fprintln(stderr, "--shares and --data are required")
+		flags.Usage()
+		return 2
+	}
+
+	wallet, err := loadExportedWallet(*sharesFile, os.Getenv(sharesPassphraseEnv))
+	if err != nil {
+		fmt.Fprintf(stderr, "failed to load shares: %v\n", err)
+		return 1
+	}
+	if err := addWallet(wallet); err != nil {
+		fmt.Fprintf(stderr, "failed to load shares: %v\n", err)
+		return 1
+	}
+
+	request := signDataRequest{Data: *data, Wallet: wallet.Address, Hash: *hashMode, Encoding: *encoding}
+	result, err := walletService.Sign(request, nil)
+	if err != nil {
+		fmt.Fprintf(stderr, "failed to sign: %v\n", err)
+		return 1
+	}
+	response, err := result.response(request)
+	if err != nil {
+		fmt.Fprintf(stderr, "failed to sign: %v\n", err)
+		return 1
+	}
+	response["wallet"] = wallet.Address
+	if err := json.NewEncoder(stdout).Encode(response); err != nil {
+		fmt.Fprintf(stderr, "failed to write signature: %v\n", err)
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"encoding/hex"
+	"encoding/json"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSignCommand(t *testing.T) {
+	useDeterministicKeygen(t, "offline signing")
+	wallet, err := walletService.CreateWallet(context.Background(), createWalletRequest{Parties: 3, Threshold: 1}, nil)
+	if err != nil {
+		t.Fatalf("Failed to create wallet: %v", err)
+	}
+
+	// Export the shares like GET /wallet/:address/export, then forget the
+	// wallet so it is only known from the file
+	passphrase := "correct horse battery staple"
+	exported, err := newStoredWallet(wallet, newShareCipher(passphrase))
+	if err != nil {
+		t.Fatalf("Failed to export wallet: %v", err)
+	}
+	content, _ := json.Marshal(dataResponse(exported))
+	sharesFile := filepath.Join(t.TempDir(), "shares.json")
+	if err := os.WriteFile(sharesFile, content, 0o600); err != nil {
+		t.Fatalf("Failed to write shares: %v", err)
+	}
+	removeWallet := func() {
+		walletsMutex.Lock()
+		delete(wallets, wallet.Address)
+		walletsMutex.Unlock()
+	}
+	removeWallet()
+	t.Cleanup(removeWallet)
+
+	data := []byte("air-gapped")
+	t.Setenv(sharesPassphraseEnv, passphrase)
+	var stdout, stderr bytes.Buffer
+	code := runSignCommand([]string{"--shares", sharesFile, "--data", "0x" + hex.EncodeToString(data)}, &stdout, &stderr)
+	if !assert.Equal(t, 0, code, stderr.String()) {
+		return
+	}
+
+	var response map[string]interface{}
+	if err := json.Unmarshal(stdout.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to parse signature %q: %v", stdout.String(), err)
+	}
+	digest := crypto.Keccak256(data)
+	assert.Equal(t, wallet.Address, response["wallet"])
+	assert.Equal(t, hex.EncodeToString(digest), response["digest"])
+	signature, err := hex.DecodeString(response["signature"].(string))
+	if !assert.NoError(t, err) || !assert.Len(t, signature, 64) {
+		return
+	}
+	r := new(big.Int).SetBytes(signature[:32])
+	s := new(big.Int).SetBytes(signature[32:])
+	assert.True(t, ecdsa.Verify(wallet.PubKey, digest, r, s), "The offline signature should verify with the wallet public key")
+}
+
+func TestSignCommandErrors(t *testing.T) {
+	dir := t.TempDir()
+	encrypted := filepath.Join(dir, "encrypted.json")
+	content, _ := json.Marshal(storedWallet{Address: "0x00000000000000000000000000000000000000aa", EncryptedSaveData: []byte("sealed")})
+	_ = os.WriteFile(encrypted, content, 0o600)
+	invalid := filepath.Join(dir, "invalid.json")
+	_ = os.WriteFile(invalid, []byte("{"), 0o600)
+
+	tests := []struct {
+		name       string
+		args       []string
+		passphrase string
+		code       int
+		message    string
+	}{
+		{"missing shares", []string{"--data", "0x01"}, "", 2, "--shares and --data are required"},
+		{"missing data", []string{"--shares", encrypted}, "", 2, "--shares and --data are required"},
+		{"unknown flag", []string{"--nope"}, "", 2, "flag provided but not defined"},
+		{"missing file", []string{"--shares", filepath.Join(dir, "missing.json"), "--data", "0x01"}, "", 1, "failed to load shares"},
+		{"invalid file", []string{"--shares", invalid, "--data", "0x01"}, "", 1, "invalid shares file"},
+		{"no passphrase", []string{"--shares", encrypted, "--data", "0x01"}, "", 1, sharesPassphraseEnv},
+		{"wrong passphrase", []string{"--shares", encrypted, "--data", "0x01"}, "wrong passphrase", 1, "failed to load shares"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv(sharesPassphraseEnv, tt.passphrase)
+			var stdout, stderr bytes.Buffer
+			assert.Equal(t, tt.code, runSignCommand(tt.args, &stdout, &stderr))
+			assert.Contains(t, stderr.String(), tt.message)
+			assert.Empty(t, stdout.String(), "Nothing should be printed on failure")
+		})
+	}
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/bnb-chain/tss-lib/tss"
+)
+
+// maxMonikerLength bounds the moniker of a party
+const maxMonikerLength = 64
+
+// partySpec describes a party of a new wallet. The moniker names the party
+// in responses and logs, e.g. after the machine holding its key share, in
+// place of the default P[i].
+type partySpec struct {
+	Moniker string `json:"moniker"`
+}
+
+// UnmarshalJSON reads the parties of a wallet creation either as a number or
+// as an array of party descriptions, one per party
+func (r *createWalletRequest) UnmarshalJSON(data []byte) error {
+	type plain createWalletRequest
+	// Fields missing from data keep their current value, like the defaults
+	// of a creation without parties
+	var fields struct {
+		plain
+		Parties json.RawMessage `json:"parties"`
+	}
+	fields.plain = plain(*r)
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return err
+	}
+	*r = createWalletRequest(fields.plain)
+	parties := bytes.TrimSpace(fields.Parties)
+	switch {
+	case len(parties) == 0 || bytes.Equal(parties, []byte("null")):
+	case parties[0] == '[':
+		r.PartySpecs = nil
+		if err := json.Unmarshal(parties, &r.PartySpecs); err != nil {
+			return fmt.Errorf("parties: %w", err)
+		}
+		r.Parties = len(r.PartySpecs)
+	default:
+		r.PartySpecs = nil
+		if err := json.Unmarshal(parties, &r.Parties); err != nil {
+			return fmt.Errorf("parties: %w", err)
+		}
+	}
+	return nil
+}
+
+// MarshalJSON writes the parties as an array when they have descriptions, so
+// that requests differing by their monikers are told apart
+func (r createWalletRequest) MarshalJSON() ([]byte, error) {
+	type plain createWalletRequest
+	if len(r.PartySpecs) == 0 {
+		return json.Marshal(plain(r))
+	}
+	return json.Marshal(struct {
+		plain
+		Parties []partySpec `json:"parties"`
+	}{plain: plain(r), Parties: r.PartySpecs})
+}
+
+// validatePartySpecs checks the party descriptions of a wallet creation, when
+// given there is one per party and their monikers are set and distinct
+func validatePartySpecs(specs []partySpec, parties int) error {
+	if len(specs) == 0 {
+		return nil
+	}
+	if len(specs) != parties {
+		return fmt.Errorf("parties describes %d parties, expected %d", len(specs), parties)
+	}
+	seen := make(map[string]bool, len(specs))
+	for i, spec := range specs {
+		if strings.TrimSpace(spec.Moniker) == "" {
+			return fmt.Errorf("party %d: moniker is required", i)
+		}
+		if len(spec.Moniker) > maxMonikerLength {
+			return fmt.Errorf("party %d: moniker must be at most %d bytes", i, maxMonikerLength)
+		}
+		if seen[spec.Moniker] {
+			return fmt.Errorf("party %d: moniker %q is used by another party", i, spec.Moniker)
+		}
+		seen[spec.Moniker] = true
+	}
+	return nil
+}
+
+// applyPartySpecs renames the parties created by newPartyIDs after their
+// descriptions. Party IDs are the index of the party in the request, they
+// stay in the order of their keys.
+func applyPartySpecs(partyIDs tss.SortedPartyIDs, specs []partySpec) error {
+	if len(specs) == 0 {
+		return nil
+	}
+	for _, partyID := range partyIDs {
+		i, err := strconv.Atoi(partyID.Id)
+		if err != nil || i < 0 || i >= len(specs) {
+			return errors.New("party IDs do not match the party descriptions")
+		}
+		partyID.Moniker = specs[i].Moniker
+	}
+	return nil
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCreateWalletPartyMonikers(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	useDeterministicKeygen(t, "party monikers")
+
+	router := gin.Default()
+	router.POST("/wallet", createWallet)
+
+	body := `{"parties": [{"moniker": "vault-eu"}, {"moniker": "vault-us"}, {"moniker": "hsm-backup"}], "threshold": 1}`
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/wallet", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	var response struct {
+		Address string          `json:"address"`
+		Parties []partyResponse `json:"parties"`
+	}
+	if err := decodeData(w.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to parse create wallet response: %v", err)
+	}
+	wallet, err := lookupWallet(response.Address)
+	if err != nil {
+		t.Fatalf("Wallet %s should be stored: %v", response.Address, err)
+	}
+	t.Cleanup(func() {
+		walletsMutex.Lock()
+		delete(wallets, wallet.Address)
+		walletsMutex.Unlock()
+	})
+	assert.Equal(t, 3, wallet.Parties)
+
+	// Each party keeps the moniker given at its index in the request
+	expected := map[string]string{"0": "vault-eu", "1": "vault-us", "2": "hsm-backup"}
+	monikers := make(map[string]string, len(response.Parties))
+	for _, party := range response.Parties {
+		monikers[party.ID] = party.Moniker
+	}
+	assert.Equal(t, expected, monikers)
+
+	// The monikers are kept by the store
+	stored, err := newStoredWallet(wallet, nil)
+	assert.NoError(t, err)
+	loaded, err := stored.toWallet(nil)
+	assert.NoError(t, err)
+	for _, partyID := range loaded.PartyIDs {
+		assert.Equal(t, expected[partyID.Id], partyID.Moniker)
+	}
+}
+
+func TestCreateWalletPartySpecsInvalid(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	router := gin.Default()
+	router.POST("/wallet", createWallet)
+
+	tests := []struct {
+		name    string
+		body    string
+		message string
+	}{
+		{"empty moniker", `{"parties": [{"moniker": "a"}, {"moniker": " "}], "threshold": 1}`, "party 1: moniker is required"},
+		{"duplicate moniker", `{"parties": [{"moniker": "a"}, {"moniker": "a"}], "threshold": 1}`, "used by another party"},
+		{"long moniker", `{"parties": [{"moniker": "a"}, {"moniker": "` + strings.Repeat("m", maxMonikerLength+1) + `"}], "threshold": 1}`, "at most 64 bytes"},
+		{"too few parties", `{"parties": [{"moniker": "a"}], "threshold": 1}`, "parties must be at least 2"},
+		{"invalid parties", `{"parties": "three", "threshold": 1}`, "invalid request body"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("POST", "/wallet", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			router.ServeHTTP(w, req)
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+			apiErr, err := decodeError(w.Body.Bytes())
+			assert.NoError(t, err)
+			assert.Contains(t, apiErr.Message, tt.message)
+		})
+	}
+
+	_, err := newWalletSpec(createWalletRequest{Parties: 3, Threshold: 1, PartySpecs: []partySpec{{Moniker: "a"}, {Moniker: "b"}}})
+	assert.ErrorIs(t, err, ErrInvalidRequest, "Every party should be described")
+}
+
+func TestCreateWalletRequestJSON(t *testing.T) {
+	// A count keeps working and missing fields keep their defaults
+	request := defaultCreateWalletRequest()
+	assert.NoError(t, json.Unmarshal([]byte(`{"threshold": 2}`), &request))
+	assert.Equal(t, createWalletRequest{Parties: defaultParties, Threshold: 2}, request)
+	assert.NoError(t, json.Unmarshal([]byte(`{"parties": 5}`), &request))
+	assert.Equal(t, 5, request.Parties)
+	assert.Empty(t, request.PartySpecs)
+
+	var described createWalletRequest
+	assert.NoError(t, json.Unmarshal([]byte(`{"parties": [{"moniker": "a"}, {"moniker": "b"}], "label": "x"}`), &described))
+	assert.Equal(t, 2, described.Parties)
+	assert.Equal(t, []partySpec{{Moniker: "a"}, {Moniker: "b"}}, described.PartySpecs)
+	assert.Equal(t, "x", described.Label)
+
+	// Both forms round-trip, so idempotency fingerprints tell monikers apart
+	for _, original := range []createWalletRequest{request, described} {
+		encoded, err := json.Marshal(original)
+		assert.NoError(t, err)
+		var decoded createWalletRequest
+		assert.NoError(t, json.Unmarshal(encoded, &decoded))
+		assert.Equal(t, original, decoded)
+	}
+	renamed := described
+	renamed.PartySpecs = []partySpec{{Moniker: "a"}, {Moniker: "c"}}
+	assert.NotEqual(t, requestFingerprint(described), requestFingerprint(renamed))
+}
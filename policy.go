@@ -0,0 +1,89 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+)
+
+// ErrPolicyViolation is returned when a sign request is refused by the
+// signing policy of its wallet
+var ErrPolicyViolation = errors.New("signing policy violation")
+
+// SigningPolicy restricts what a wallet may sign, on every signing endpoint.
+// The zero value allows everything.
+type SigningPolicy struct {
+	// MaxDataSize is the largest data or message accepted, in bytes, 0 for no
+	// limit
+	MaxDataSize int `json:"maxDataSize,omitempty"`
+	// AllowedHashes lists the hash modes that may be requested, any when empty
+	AllowedHashes []string `json:"allowedHashes,omitempty"`
+}
+
+// validatePolicy checks the signing policy given at wallet creation
+func validatePolicy(policy *SigningPolicy) error {
+	if policy == nil {
+		return nil
+	}
+	if policy.MaxDataSize < 0 {
+		return errors.New("policy maxDataSize must not be negative")
+	}
+	for _, hashMode := range policy.AllowedHashes {
+		if !slices.Contains(hashModes, hashMode) {
+			return fmt.Errorf("policy allowedHashes holds unsupported hash %q", hashMode)
+		}
+	}
+	return nil
+}
+
+// policyViolation marks err as a refusal of the signing policy
+func policyViolation(err error) error {
+	return &requestError{kind: ErrPolicyViolation, err: err}
+}
+
+// check returns an ErrPolicyViolation when the policy refuses to sign data
+// with the given mode and hash. A nil policy allows everything.
+func (p *SigningPolicy) check(data []byte, mode, hashMode string) error {
+	if err := p.checkHash(mode, hashMode); err != nil {
+		return err
+	}
+	return p.checkSize(int64(len(data)))
+}
+
+// checkHash returns an ErrPolicyViolation when the policy refuses the mode
+// and hash, so that data streamed to a wallet can be refused before it is read
+func (p *SigningPolicy) checkHash(mode, hashMode string) error {
+	if p == nil {
+		return nil
+	}
+	// EIP-191 messages and requests without a hash are hashed with keccak256
+	if hashMode == "" || mode == modeEIP191 {
+		hashMode = hashKeccak256
+	}
+	if len(p.AllowedHashes) > 0 && !slices.Contains(p.AllowedHashes, hashMode) {
+		return policyViolation(fmt.Errorf("wallet policy does not allow hash %s", hashMode))
+	}
+	return nil
+}
+
+// checkSize returns an ErrPolicyViolation when the data is larger than the
+// policy allows
+func (p *SigningPolicy) checkSize(size int64) error {
+	if p == nil {
+		return nil
+	}
+	if p.MaxDataSize > 0 && size > int64(p.MaxDataSize) {
+		return policyViolation(fmt.Errorf("wallet policy allows at most %d bytes of data, got %d", p.MaxDataSize, size))
+	}
+	return nil
+}
+
+// checkPolicy applies the signing policy of the wallet to data signed with
+// the given mode and hash. EdDSA wallets sign the data itself, as with hash
+// none whatever was requested.
+func (w *Wallet) checkPolicy(data []byte, mode, hashMode string) error {
+	if w.Algorithm == algorithmEdDSA {
+		mode, hashMode = modeRaw, hashNone
+	}
+	return w.Policy.check(data, mode, hashMode)
+}
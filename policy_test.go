@@ -0,0 +1,172 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSigningPolicy(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	key, _ := crypto.GenerateKey()
+	useInstantSigning(t, key)
+	address := deriveAddress(&key.PublicKey)
+	wallet := addFakeWallet(address)
+	wallet.PubKey = &key.PublicKey
+	wallet.Policy = &SigningPolicy{MaxDataSize: 32, AllowedHashes: []string{hashKeccak256}}
+	t.Cleanup(func() {
+		walletsMutex.Lock()
+		delete(wallets, address)
+		walletsMutex.Unlock()
+	})
+
+	router := gin.Default()
+	router.POST("/sign", signData)
+
+	digest := "0x" + strings.Repeat("ab", 32)
+	tests := []struct {
+		name     string
+		request  signDataRequest
+		expected int
+		message  string
+	}{
+		{"default hash", signDataRequest{Data: digest, Wallet: address}, http.StatusOK, ""},
+		{"allowed hash", signDataRequest{Data: "0x74657374", Wallet: address, Hash: hashKeccak256}, http.StatusOK, ""},
+		{"eip191 message", signDataRequest{Message: "hello", Wallet: address, Mode: modeEIP191}, http.StatusOK, ""},
+		{"disallowed hash", signDataRequest{Data: digest, Wallet: address, Hash: hashNone}, http.StatusForbidden, "does not allow hash none"},
+		{"disallowed sha256", signDataRequest{Data: "0x74657374", Wallet: address, Hash: hashSHA256}, http.StatusForbidden, "does not allow hash sha256"},
+		{"data too large", signDataRequest{Data: digest + "00", Wallet: address}, http.StatusForbidden, "at most 32 bytes of data, got 33"},
+		{"message too large", signDataRequest{Message: strings.Repeat("m", 33), Wallet: address}, http.StatusForbidden, "at most 32 bytes"},
+		{"invalid request first", signDataRequest{Data: "0xzz", Wallet: address, Hash: hashNone}, http.StatusBadRequest, "invalid data"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			jsonBody, _ := json.Marshal(tt.request)
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("POST", "/sign", bytes.NewBuffer(jsonBody))
+			req.Header.Set("Content-Type", "application/json")
+			router.ServeHTTP(w, req)
+			assert.Equal(t, tt.expected, w.Code, w.Body.String())
+			if tt.expected != http.StatusOK {
+				apiErr, err := decodeError(w.Body.Bytes())
+				assert.NoError(t, err)
+				assert.Equal(t, errorCode(tt.expected), apiErr.Code)
+				assert.Contains(t, apiErr.Message, tt.message)
+			}
+		})
+	}
+
+	// Without a policy the wallet signs anything
+	wallet.Policy = nil
+	_, err := walletService.prepareSign(signDataRequest{Data: digest + "00", Wallet: address, Hash: hashSHA256})
+	assert.NoError(t, err)
+}
+
+func TestSigningPolicyEveryEndpoint(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	key, _ := crypto.GenerateKey()
+	useInstantSigning(t, key)
+	address := deriveAddress(&key.PublicKey)
+	wallet := addFakeWallet(address)
+	wallet.PubKey = &key.PublicKey
+	wallet.Policy = &SigningPolicy{AllowedHashes: []string{hashNone}}
+	t.Cleanup(func() {
+		walletsMutex.Lock()
+		delete(wallets, address)
+		walletsMutex.Unlock()
+	})
+
+	router := gin.Default()
+	router.POST("/sign/raw", signRaw)
+	router.POST("/sign/tx", signTx)
+	router.POST("/sign/batch", signBatch)
+	router.POST("/sign/typed-data", signTypedData)
+	post := func(path, contentType, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", path, strings.NewReader(body))
+		req.Header.Set("Content-Type", contentType)
+		req.Header.Set(walletHeader, address)
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	// Only digests signed as they are pass the policy, every endpoint hashing
+	// its data is refused before signing
+	digest := "0x" + strings.Repeat("ab", 32)
+	tests := []struct {
+		name        string
+		path        string
+		contentType string
+		body        string
+		message     string
+	}{
+		{"raw body", "/sign/raw", rawSignContentType, "file", "does not allow hash sha256"},
+		{"transaction", "/sign/tx", "application/json", `{"wallet": "` + address + `", "chainId": "0x1", "tx": {"gas": "0x5208", "gasPrice": "0x1"}}`, "does not allow hash keccak256"},
+		{"batch item", "/sign/batch", "application/json", `{"wallet": "` + address + `", "items": [{"data": "` + digest + `", "hash": "none"}, {"data": "0x74657374"}]}`, "item 1: wallet policy does not allow hash keccak256"},
+		{"typed data", "/sign/typed-data", "application/json", `{"wallet": "` + address + `", "typedData": ` + mailTypedData + `}`, "does not allow hash keccak256"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := post(tt.path, tt.contentType, tt.body)
+			assert.Equal(t, http.StatusForbidden, w.Code, w.Body.String())
+			apiErr, err := decodeError(w.Body.Bytes())
+			assert.NoError(t, err)
+			assert.Contains(t, apiErr.Message, tt.message)
+		})
+	}
+
+	// The size of a raw body is checked once it has been read
+	wallet.Policy = &SigningPolicy{MaxDataSize: 3, AllowedHashes: []string{hashSHA256}}
+	w := post("/sign/raw", rawSignContentType, "file")
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Contains(t, w.Body.String(), "at most 3 bytes of data, got 4")
+	assert.Equal(t, http.StatusOK, post("/sign/raw", rawSignContentType, "fil").Code)
+	batch := `{"wallet": "` + address + `", "items": [{"data": "0x74657374", "hash": "sha256"}]}`
+	assert.Equal(t, http.StatusForbidden, post("/sign/batch", "application/json", batch).Code)
+}
+
+func TestCreateWalletPolicy(t *testing.T) {
+	useDeterministicKeygen(t, "signing policy")
+	policy := &SigningPolicy{MaxDataSize: 32, AllowedHashes: []string{hashNone}}
+	wallet, err := walletService.CreateWallet(context.Background(), createWalletRequest{Parties: 2, Threshold: 1, Policy: policy}, nil)
+	if err != nil {
+		t.Fatalf("Failed to create wallet: %v", err)
+	}
+	t.Cleanup(func() {
+		walletsMutex.Lock()
+		delete(wallets, wallet.Address)
+		walletsMutex.Unlock()
+	})
+	assert.Equal(t, policy, wallet.Policy)
+	assert.Equal(t, policy, newWalletResponse(wallet).Policy)
+
+	// The policy is kept by the store
+	stored, err := newStoredWallet(wallet, nil)
+	assert.NoError(t, err)
+	loaded, err := stored.toWallet(nil)
+	assert.NoError(t, err)
+	assert.Equal(t, policy, loaded.Policy)
+
+	_, err = walletService.prepareSign(signDataRequest{Data: "0x74657374", Wallet: wallet.Address})
+	assert.True(t, errors.Is(err, ErrPolicyViolation))
+	assert.Equal(t, http.StatusForbidden, serviceErrorStatus(err))
+
+	for _, invalid := range []*SigningPolicy{
+		{MaxDataSize: -1},
+		{AllowedHashes: []string{"md5"}},
+	} {
+		_, err := walletService.CreateWallet(context.Background(), createWalletRequest{Parties: 2, Threshold: 1, Policy: invalid}, nil)
+		assert.True(t, errors.Is(err, ErrInvalidRequest), "Policy %+v should be rejected", invalid)
+	}
+}
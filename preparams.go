@@ -0,0 +1,193 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/bnb-chain/tss-lib/ecdsa/keygen"
+)
+
+// preParamsTimeout bounds the generation of one set of pre-parameters
+const preParamsTimeout = 5 * time.Minute
+
+// preParamsRetryDelay is how long the pool waits after a failed generation
+const preParamsRetryDelay = 10 * time.Second
+
+// generatePreParams finds the safe primes and Paillier key of one keygen
+// party, tests replace it to avoid the cost
+var generatePreParams = func() (*keygen.LocalPreParams, error) {
+	return keygen.GeneratePreParams(preParamsTimeout)
+}
+
+// preParamsPool holds pre-parameters generated ahead of time. Finding safe
+// primes is the slowest part of a keygen, every party of a ceremony takes a
+// set from the pool and only generates its own when the pool is empty.
+type preParamsPool struct {
+	ready chan *keygen.LocalPreParams
+}
+
+// keygenPreParams is the pool used by runKeygen and by the new parties of
+// runResharing, nil disables pooling
+var keygenPreParams *preParamsPool
+
+// newPreParamsPool creates an empty pool holding up to size sets
+func newPreParamsPool(size int) *preParamsPool {
+	return &preParamsPool{ready: make(chan *keygen.LocalPreParams, size)}
+}
+
+// fill generates pre-parameters one set at a time, keeping the pool full
+// until ctx is done
+func (p *preParamsPool) fill(ctx context.Context) {
+	for ctx.Err() == nil {
+		preParams, err := generatePreParams()
+		if err != nil {
+			log.Printf("failed to generate keygen pre-parameters: %v", err)
+			select {
+			case <-time.After(preParamsRetryDelay):
+			case <-ctx.Done():
+			}
+			continue
+		}
+		select {
+		case p.ready <- preParams:
+		case <-ctx.Done():
+		}
+	}
+}
+
+// add puts a set in the pool, it returns false when the pool is full
+func (p *preParamsPool) add(preParams *keygen.LocalPreParams) bool {
+	select {
+	case p.ready <- preParams:
+		return true
+	default:
+		return false
+	}
+}
+
+// take removes a set from the pool without waiting, it returns nil when the
+// pool is nil or empty
+func (p *preParamsPool) take() *keygen.LocalPreParams {
+	if p == nil {
+		return nil
+	}
+	select {
+	case preParams := <-p.ready:
+		return preParams
+	default:
+		return nil
+	}
+}
+
+// size returns the number of sets ready in the pool
+func (p *preParamsPool) size() int {
+	if p == nil {
+		return 0
+	}
+	return len(p.ready)
+}
+
+// preParamsFileExt is the extension of the files holding one set of
+// pre-parameters
+const preParamsFileExt = ".preparams"
+
+// generatePreParamsFiles generates count sets of pre-parameters into dir, one
+// file each, so a fresh instance can start with a warm pool. Each set is
+// written as soon as it is generated. The sets hold Paillier private keys and
+// are encrypted when sc is not nil.
+func generatePreParamsFiles(dir string, count int, sc *shareCipher) error {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return fmt.Errorf("failed to create pre-parameters dir: %w", err)
+	}
+	for i := 0; i < count; i++ {
+		preParams, err := generatePreParams()
+		if err != nil {
+			return fmt.Errorf("failed to generate pre-parameters: %w", err)
+		}
+		if err := savePreParams(dir, preParams, sc); err != nil {
+			return err
+		}
+		log.Printf("generated keygen pre-parameters %d/%d", i+1, count)
+	}
+	return nil
+}
+
+// savePreParams writes one set to a new file in dir
+func savePreParams(dir string, preParams *keygen.LocalPreParams, sc *shareCipher) error {
+	payload, err := json.Marshal(preParams)
+	if err != nil {
+		return fmt.Errorf("failed to serialize pre-parameters: %w", err)
+	}
+	if sc != nil {
+		if payload, err = sc.Encrypt(payload); err != nil {
+			return err
+		}
+	}
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return fmt.Errorf("failed to name pre-parameters: %w", err)
+	}
+	path := filepath.Join(dir, hex.EncodeToString(id)+preParamsFileExt)
+	tmpPath := path + ".tmp"
+	if err := writeFileSync(tmpPath, payload, 0o600); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to write pre-parameters: %w", err)
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to write pre-parameters: %w", err)
+	}
+	return nil
+}
+
+// loadPreParams reads every set of pre-parameters in dir and removes their
+// files, so a set is never used by two keygens even across restarts. Nothing
+// is removed when a file cannot be read.
+func loadPreParams(dir string, sc *shareCipher) ([]*keygen.LocalPreParams, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read pre-parameters dir: %w", err)
+	}
+
+	var paths []string
+	var loaded []*keygen.LocalPreParams
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), preParamsFileExt) {
+			continue
+		}
+		path := filepath.Join(dir, entry.Name())
+		payload, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", entry.Name(), err)
+		}
+		if sc != nil {
+			if payload, err = sc.Decrypt(payload); err != nil {
+				return nil, fmt.Errorf("failed to decrypt %s: %w", entry.Name(), err)
+			}
+		}
+		var preParams keygen.LocalPreParams
+		if err := json.Unmarshal(payload, &preParams); err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", entry.Name(), err)
+		}
+		if !preParams.ValidateWithProof() {
+			return nil, fmt.Errorf("%s holds incomplete pre-parameters", entry.Name())
+		}
+		paths = append(paths, path)
+		loaded = append(loaded, &preParams)
+	}
+
+	for _, path := range paths {
+		if err := os.Remove(path); err != nil {
+			return nil, fmt.Errorf("failed to remove %s: %w", filepath.Base(path), err)
+		}
+	}
+	return loaded, nil
+}
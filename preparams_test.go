@@ -0,0 +1,177 @@
+package main
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/bnb-chain/tss-lib/ecdsa/keygen"
+	"github.com/bnb-chain/tss-lib/tss"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPreParamsPool(t *testing.T) {
+	var disabled *preParamsPool
+	assert.Nil(t, disabled.take(), "A nil pool should hand out nothing")
+	assert.Equal(t, 0, disabled.size())
+
+	pool := newPreParamsPool(2)
+	first, second := &keygen.LocalPreParams{}, &keygen.LocalPreParams{}
+	assert.True(t, pool.add(first))
+	assert.True(t, pool.add(second))
+	assert.False(t, pool.add(&keygen.LocalPreParams{}), "A full pool should refuse more sets")
+	assert.Equal(t, 2, pool.size())
+
+	assert.Same(t, first, pool.take())
+	assert.Same(t, second, pool.take())
+	assert.Nil(t, pool.take(), "An empty pool should not wait for a set")
+}
+
+func TestPreParamsPoolFill(t *testing.T) {
+	previous := generatePreParams
+	var generated atomic.Int32
+	generatePreParams = func() (*keygen.LocalPreParams, error) {
+		generated.Add(1)
+		return &keygen.LocalPreParams{}, nil
+	}
+	t.Cleanup(func() { generatePreParams = previous })
+
+	pool := newPreParamsPool(3)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		pool.fill(ctx)
+		close(done)
+	}()
+
+	assert.Eventually(t, func() bool { return pool.size() == 3 }, time.Second, 10*time.Millisecond)
+	assert.NotNil(t, pool.take())
+	assert.Eventually(t, func() bool { return pool.size() == 3 }, time.Second, 10*time.Millisecond, "A taken set should be replaced")
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("fill should return once its context is done")
+	}
+	// One set waits for room in the full pool when the context is cancelled
+	assert.LessOrEqual(t, generated.Load(), int32(5))
+}
+
+func TestKeygenWithPreParamsPool(t *testing.T) {
+	if testing.Short() {
+		t.Skip("runs two keygen ceremonies")
+	}
+	const parties = 2
+
+	pool := newPreParamsPool(parties)
+	for i := 0; i < parties; i++ {
+		preParams, err := generatePreParams()
+		if err != nil {
+			t.Fatalf("Failed to generate pre-parameters: %v", err)
+		}
+		assert.True(t, pool.add(preParams))
+	}
+	previous := keygenPreParams
+	keygenPreParams = pool
+	t.Cleanup(func() { keygenPreParams = previous })
+
+	keygenWallet := func() (*Wallet, time.Duration) {
+		partyIDs, err := newPartyIDs(parties, tss.S256())
+		if err != nil {
+			t.Fatalf("Failed to create party IDs: %v", err)
+		}
+		start := time.Now()
+		wallet, err := runKeygen(context.Background(), partyIDs, parties-1, curveSecp256k1, tss.S256(), nil, nil)
+		if err != nil {
+			t.Fatalf("Keygen failed: %v", err)
+		}
+		return wallet, time.Since(start)
+	}
+
+	warmWallet, warm := keygenWallet()
+	assert.Equal(t, 0, pool.size(), "Every party should take a set from the pool")
+	_, cold := keygenWallet()
+	t.Logf("keygen took %s with a warm pool and %s with an empty one", warm, cold)
+	assert.Less(t, warm, cold, "Keygen should be faster with a warm pool")
+
+	digest := crypto.Keccak256([]byte("pooled"))
+	sigData, err := signDigest(warmWallet, nil, digest, nil)
+	if err != nil {
+		t.Fatalf("Failed to sign: %v", err)
+	}
+	r := new(big.Int).SetBytes(sigData.R)
+	s := new(big.Int).SetBytes(sigData.S)
+	assert.True(t, ecdsa.Verify(warmWallet.PubKey, digest, r, s), "Wallets created from pooled pre-parameters should sign")
+}
+
+func TestLoadPreParamsInvalidFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "broken"+preParamsFileExt)
+	assert.NoError(t, os.WriteFile(path, []byte("{not json"), 0o600))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "notes.txt"), []byte("ignored"), 0o600))
+
+	_, err := loadPreParams(dir, nil)
+	assert.Error(t, err)
+	assert.FileExists(t, path, "Files should be kept when loading fails")
+
+	assert.NoError(t, os.WriteFile(path, []byte("{}"), 0o600))
+	_, err = loadPreParams(dir, nil)
+	assert.ErrorContains(t, err, "incomplete")
+
+	_, err = loadPreParams(filepath.Join(dir, "missing"), nil)
+	assert.Error(t, err)
+}
+
+func TestKeygenWithPersistedPreParams(t *testing.T) {
+	if testing.Short() {
+		t.Skip("generates pre-parameters and runs a keygen ceremony")
+	}
+	const parties = 2
+	dir := filepath.Join(t.TempDir(), "preparams")
+	sc := newShareCipher("preparams passphrase")
+	if err := generatePreParamsFiles(dir, parties, sc); err != nil {
+		t.Fatalf("Failed to generate pre-parameters: %v", err)
+	}
+	files, _ := filepath.Glob(filepath.Join(dir, "*"+preParamsFileExt))
+	assert.Len(t, files, parties)
+
+	_, err := loadPreParams(dir, newShareCipher("wrong passphrase"))
+	assert.ErrorIs(t, err, errDecryptionFailed)
+
+	loaded, err := loadPreParams(dir, sc)
+	if err != nil {
+		t.Fatalf("Failed to load pre-parameters: %v", err)
+	}
+	assert.Len(t, loaded, parties)
+	files, _ = filepath.Glob(filepath.Join(dir, "*"))
+	assert.Empty(t, files, "Loaded pre-parameters should be removed from disk")
+
+	pool := newPreParamsPool(parties)
+	moduli := make(map[string]bool)
+	for _, preParams := range loaded {
+		pool.add(preParams)
+		moduli[preParams.PaillierSK.N.String()] = true
+	}
+	previous := keygenPreParams
+	keygenPreParams = pool
+	t.Cleanup(func() { keygenPreParams = previous })
+
+	partyIDs, err := newPartyIDs(parties, tss.S256())
+	if err != nil {
+		t.Fatalf("Failed to create party IDs: %v", err)
+	}
+	wallet, err := runKeygen(context.Background(), partyIDs, parties-1, curveSecp256k1, tss.S256(), nil, nil)
+	if err != nil {
+		t.Fatalf("Keygen failed: %v", err)
+	}
+	for id, save := range wallet.SaveData {
+		assert.True(t, moduli[save.PaillierSK.N.String()], "Party %s should use persisted pre-parameters", id)
+	}
+}
@@ -0,0 +1,28 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// previewWallet runs a keygen ceremony like createWallet but only returns the
+// public information of the resulting wallet. The wallet is not stored and
+// its key shares are wiped, it can never sign.
+func previewWallet(c *gin.Context) {
+	spec, err := bindWalletSpec(c)
+	if err != nil {
+		respondServiceError(c, err)
+		return
+	}
+
+	ctx := c.Request.Context()
+	streamCeremony(c, func(onRound func(round int)) (int, any) {
+		wallet, err := generateWallet(ctx, spec, nil, onRound)
+		if err != nil {
+			return serviceErrorResponse(err)
+		}
+		wallet.zeroShares()
+		return http.StatusOK, dataResponse(newWalletResponse(wallet))
+	})
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPreviewWallet(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	useDeterministicKeygen(t, "preview wallet")
+
+	router := gin.Default()
+	router.POST("/wallet/preview", previewWallet)
+	router.GET("/wallet/:address", getWallet)
+	router.GET("/wallets", listWallets)
+
+	jsonBody, _ := json.Marshal(createWalletRequest{Parties: 2, Threshold: 1, AddressType: addressBTCP2WPKH})
+	w1 := httptest.NewRecorder()
+	req1, _ := http.NewRequest("POST", "/wallet/preview", bytes.NewBuffer(jsonBody))
+	req1.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w1, req1)
+	assert.Equal(t, http.StatusOK, w1.Code)
+
+	var preview walletResponse
+	err := decodeData(w1.Body.Bytes(), &preview)
+	if err != nil {
+		t.Fatalf("Failed to parse preview response: %v", err)
+	}
+	assert.NotEmpty(t, preview.Address)
+	assert.NotEmpty(t, preview.PubKey)
+	assert.Contains(t, preview.Addresses, addressBTCP2WPKH)
+	assert.Equal(t, 2, preview.Parties)
+
+	// The previewed wallet is not stored
+	w2 := httptest.NewRecorder()
+	req2, _ := http.NewRequest("GET", "/wallet/"+preview.Address, nil)
+	router.ServeHTTP(w2, req2)
+	assert.Equal(t, http.StatusNotFound, w2.Code)
+
+	w3 := httptest.NewRecorder()
+	req3, _ := http.NewRequest("GET", "/wallets?limit=1000", nil)
+	router.ServeHTTP(w3, req3)
+	assert.Equal(t, http.StatusOK, w3.Code)
+
+	var listResponse listWalletsResponse
+	err = decodeData(w3.Body.Bytes(), &listResponse)
+	if err != nil {
+		t.Fatalf("Failed to parse list response: %v", err)
+	}
+	for _, wallet := range listResponse.Wallets {
+		assert.NotEqual(t, preview.Address, wallet.Address, "Previewed wallet should not be listed")
+	}
+}
+
+func TestPreviewWalletInvalidInput(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	router := gin.Default()
+	router.POST("/wallet/preview", previewWallet)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/wallet/preview", bytes.NewBufferString(`{"parties": 1, "threshold": 1}`))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+
+	tsscrypto "github.com/bnb-chain/tss-lib/crypto"
+	"github.com/gin-gonic/gin"
+)
+
+// publicShareResponse is the public part of the key share of a party
+type publicShareResponse struct {
+	ID      string `json:"id"`
+	Moniker string `json:"moniker"`
+	// ShareID is the x-coordinate of the share, the key of the party
+	ShareID string `json:"shareId"`
+	// PublicShare is the share times the generator, encoded like
+	// pubKeyCompressed
+	PublicShare string `json:"publicShare"`
+}
+
+// publicSharesResponse represents the response body of getPublicShares
+type publicSharesResponse struct {
+	Address          string                `json:"address"`
+	Algorithm        string                `json:"algorithm"`
+	Curve            string                `json:"curve"`
+	Threshold        int                   `json:"threshold"`
+	PubKeyCompressed string                `json:"pubKeyCompressed"`
+	Shares           []publicShareResponse `json:"shares"`
+}
+
+// getPublicShares returns the public share of every party of a wallet so
+// auditors can check the shares are consistent with the wallet public key:
+// interpolating any threshold+1 public shares at x = 0 gives the public key.
+// tss-lib keeps no VSS commitments once keygen is over, the public shares are
+// what every party derived from them. No secret is returned.
+func getPublicShares(c *gin.Context) {
+	wallet, err := lookupWallet(c.Param("address"))
+	if err != nil {
+		respondServiceError(c, err)
+		return
+	}
+	shares, err := publicShares(wallet)
+	if errors.Is(err, errWalletRetired) {
+		respondServiceError(c, err)
+		return
+	}
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	_, pubKeyCompressed, _ := wallet.pubKeyHex()
+	response := publicSharesResponse{
+		Address:          wallet.Address,
+		Algorithm:        wallet.Algorithm,
+		Curve:            string(wallet.Curve),
+		Threshold:        wallet.Threshold,
+		PubKeyCompressed: pubKeyCompressed,
+		Shares:           make([]publicShareResponse, len(wallet.PartyIDs)),
+	}
+	for i, partyID := range wallet.PartyIDs {
+		response.Shares[i] = publicShareResponse{
+			ID:          partyID.Id,
+			Moniker:     partyID.Moniker,
+			ShareID:     fmt.Sprintf("0x%x", partyID.KeyInt()),
+			PublicShare: encodePublicPoint(wallet, shares[i]),
+		}
+	}
+	respond(c, http.StatusOK, response)
+}
+
+// publicShares returns the public share of every party of the wallet, in the
+// order of its parties. Every key share holds the public shares of all the
+// parties, they must all agree. It fails with errWalletRetired once the
+// wallet was replaced or deleted.
+func publicShares(wallet *Wallet) ([]*tsscrypto.ECPoint, error) {
+	if err := wallet.holdShares(); err != nil {
+		return nil, err
+	}
+	defer wallet.releaseShares()
+	var shareIDs []*big.Int
+	var points []*tsscrypto.ECPoint
+	for _, partyID := range wallet.PartyIDs {
+		ks, bigXj := partyPublicShares(wallet, partyID.Id)
+		if len(ks) == 0 || len(ks) != len(bigXj) {
+			return nil, fmt.Errorf("missing public shares of party %s", partyID.Id)
+		}
+		if shareIDs == nil {
+			shareIDs, points = ks, bigXj
+			continue
+		}
+		if !samePublicShares(shareIDs, points, ks, bigXj) {
+			return nil, errors.New("key shares do not agree on the public shares")
+		}
+	}
+
+	shares := make([]*tsscrypto.ECPoint, len(wallet.PartyIDs))
+	for i, partyID := range wallet.PartyIDs {
+		for j, shareID := range shareIDs {
+			if shareID != nil && shareID.Cmp(partyID.KeyInt()) == 0 {
+				shares[i] = points[j]
+			}
+		}
+		if shares[i] == nil {
+			return nil, fmt.Errorf("missing public share of party %s", partyID.Id)
+		}
+	}
+	return shares, nil
+}
+
+// partyPublicShares returns the share IDs and public shares of every party
+// as held by the key share of one party
+func partyPublicShares(wallet *Wallet, partyID string) ([]*big.Int, []*tsscrypto.ECPoint) {
+	if wallet.Algorithm == algorithmEdDSA {
+		if saveData, exists := wallet.EdDSASaveData[partyID]; exists {
+			return saveData.Ks, saveData.BigXj
+		}
+		return nil, nil
+	}
+	if saveData, exists := wallet.SaveData[partyID]; exists {
+		return saveData.Ks, saveData.BigXj
+	}
+	return nil, nil
+}
+
+// samePublicShares reports whether two views of the public shares agree
+func samePublicShares(ks []*big.Int, bigXj []*tsscrypto.ECPoint, otherKs []*big.Int, otherBigXj []*tsscrypto.ECPoint) bool {
+	if len(ks) != len(otherKs) {
+		return false
+	}
+	for i := range ks {
+		if ks[i] == nil || otherKs[i] == nil || ks[i].Cmp(otherKs[i]) != 0 {
+			return false
+		}
+		if bigXj[i] == nil || otherBigXj[i] == nil || !bigXj[i].Equals(otherBigXj[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// encodePublicPoint encodes a point of the wallet curve like its public key:
+// 32 bytes for Ed25519 and the compressed SEC1 form otherwise
+func encodePublicPoint(wallet *Wallet, point *tsscrypto.ECPoint) string {
+	pubKey := &ecdsa.PublicKey{Curve: wallet.PubKey.Curve, X: point.X(), Y: point.Y()}
+	if wallet.Algorithm == algorithmEdDSA {
+		return fmt.Sprintf("0x%x", ed25519PubKeyBytes(pubKey))
+	}
+	return fmt.Sprintf("0x%x", compressedPubKeyBytes(pubKey))
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"context"
+	"crypto/elliptic"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	tsscrypto "github.com/bnb-chain/tss-lib/crypto"
+	"github.com/bnb-chain/tss-lib/tss"
+	"github.com/decred/dcrd/dcrec/edwards/v2"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+// interpolateInExponent interpolates the public shares at x = 0, as an
+// auditor holding only public data would: sum of lambda_i * X_i with the
+// Lagrange coefficients lambda_i of the share IDs
+func interpolateInExponent(t *testing.T, curve elliptic.Curve, shareIDs []*big.Int, points []*tsscrypto.ECPoint) *tsscrypto.ECPoint {
+	t.Helper()
+	n := curve.Params().N
+	var sum *tsscrypto.ECPoint
+	for i, point := range points {
+		lambda := big.NewInt(1)
+		for j, other := range shareIDs {
+			if j == i {
+				continue
+			}
+			denominator := new(big.Int).Sub(other, shareIDs[i])
+			denominator.ModInverse(denominator.Mod(denominator, n), n)
+			lambda.Mul(lambda, other).Mul(lambda, denominator).Mod(lambda, n)
+		}
+		term := point.ScalarMult(lambda)
+		if sum == nil {
+			sum = term
+			continue
+		}
+		var err error
+		if sum, err = sum.Add(term); err != nil {
+			t.Fatalf("Failed to add public shares: %v", err)
+		}
+	}
+	return sum
+}
+
+func TestGetPublicShares(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	previous := adminAPIKeys
+	adminAPIKeys = newAPIKeySet([]string{"admin"})
+	t.Cleanup(func() { adminAPIKeys = previous })
+	router := newRouter(nil)
+
+	get := func(address, adminKey string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/admin/wallet/"+address+"/shares", nil)
+		if adminKey != "" {
+			req.Header.Set("Authorization", "Bearer "+adminKey)
+		}
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	key, _ := crypto.GenerateKey()
+	ecdsaWallet := addSharedKeyWallet(t, key, 4, 2)
+	eddsaWallet, err := walletService.CreateWallet(context.Background(), createWalletRequest{Parties: 3, Threshold: 1, Algorithm: algorithmEdDSA}, nil)
+	if err != nil {
+		t.Fatalf("Failed to create wallet: %v", err)
+	}
+	t.Cleanup(func() {
+		walletsMutex.Lock()
+		delete(wallets, eddsaWallet.Address)
+		walletsMutex.Unlock()
+	})
+
+	assert.Equal(t, http.StatusUnauthorized, get(ecdsaWallet.Address, "").Code)
+	assert.Equal(t, http.StatusNotFound, get("0x00000000000000000000000000000000000000DB", "admin").Code)
+
+	tests := []struct {
+		name   string
+		wallet *Wallet
+		curve  elliptic.Curve
+		decode func(encoded []byte) (*big.Int, *big.Int, error)
+	}{
+		{"secp256k1", ecdsaWallet, tss.S256(), func(encoded []byte) (*big.Int, *big.Int, error) {
+			pubKey, err := crypto.DecompressPubkey(encoded)
+			if err != nil {
+				return nil, nil, err
+			}
+			return pubKey.X, pubKey.Y, nil
+		}},
+		{"ed25519", eddsaWallet, tss.Edwards(), func(encoded []byte) (*big.Int, *big.Int, error) {
+			pubKey, err := edwards.ParsePubKey(encoded)
+			if err != nil {
+				return nil, nil, err
+			}
+			return pubKey.X, pubKey.Y, nil
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := get(tt.wallet.Address, "admin")
+			if !assert.Equal(t, http.StatusOK, w.Code, w.Body.String()) {
+				return
+			}
+			assert.NotContains(t, w.Body.String(), "xi", "No secret should be returned")
+			var response publicSharesResponse
+			if err := decodeData(w.Body.Bytes(), &response); err != nil {
+				t.Fatalf("Failed to parse response: %v", err)
+			}
+			assert.Equal(t, tt.wallet.Address, response.Address)
+			assert.Equal(t, tt.wallet.Threshold, response.Threshold)
+			if !assert.Len(t, response.Shares, tt.wallet.Parties) {
+				return
+			}
+
+			shareIDs := make([]*big.Int, len(response.Shares))
+			points := make([]*tsscrypto.ECPoint, len(response.Shares))
+			for i, share := range response.Shares {
+				assert.Equal(t, tt.wallet.PartyIDs[i].Id, share.ID)
+				shareID, err := hexutil.DecodeBig(share.ShareID)
+				if !assert.NoError(t, err) {
+					return
+				}
+				encoded, err := hexutil.Decode(share.PublicShare)
+				if !assert.NoError(t, err) {
+					return
+				}
+				x, y, err := tt.decode(encoded)
+				if !assert.NoError(t, err) {
+					return
+				}
+				shareIDs[i] = shareID
+				points[i], err = tsscrypto.NewECPoint(tt.curve, x, y)
+				if !assert.NoError(t, err) {
+					return
+				}
+			}
+
+			// Every quorum of threshold+1 public shares interpolates to the
+			// public key, fewer shares don't
+			pubKey, err := hexutil.Decode(response.PubKeyCompressed)
+			assert.NoError(t, err)
+			quorum := tt.wallet.Threshold + 1
+			for start := 0; start+quorum <= len(points); start++ {
+				interpolated := interpolateInExponent(t, tt.curve, shareIDs[start:start+quorum], points[start:start+quorum])
+				assert.Equal(t, pubKey, hexutil.MustDecode(encodePublicPoint(tt.wallet, interpolated)), "Shares from %d should interpolate to the public key", start)
+			}
+			partial := interpolateInExponent(t, tt.curve, shareIDs[:quorum-1], points[:quorum-1])
+			assert.NotEqual(t, pubKey, hexutil.MustDecode(encodePublicPoint(tt.wallet, partial)))
+		})
+	}
+
+	// Key shares disagreeing on the public shares are reported
+	tampered := ecdsaWallet.SaveData[ecdsaWallet.PartyIDs[1].Id]
+	tampered.BigXj[0] = tsscrypto.ScalarBaseMult(tss.S256(), big.NewInt(7))
+	assert.Equal(t, http.StatusInternalServerError, get(ecdsaWallet.Address, "admin").Code)
+}
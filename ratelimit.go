@@ -0,0 +1,136 @@
+package main
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/time/rate"
+)
+
+// Requests per minute allowed for each API key, or client IP when
+// authentication is disabled. Ceremonies creating key shares get a stricter
+// limit. Zero disables the limit.
+var (
+	apiRateLimit    = 600.0
+	keygenRateLimit = 12.0
+)
+
+// maxRateLimitedClients bounds the number of tracked clients, idle ones are
+// dropped once it is reached
+const maxRateLimitedClients = 10000
+
+// apiKeyIDContextKey is where apiKeyAuth stores an identifier of the caller's
+// API key for the middlewares that follow
+const apiKeyIDContextKey = "apiKeyID"
+
+// rateLimitContextKey is where rateLimit stores a rateLimitCharge for the
+// caller, so that handlers running several ceremonies in one request charge
+// each of them
+const rateLimitContextKey = "rateLimit"
+
+// rateLimitCharge takes a token from the caller's bucket, like allow
+type rateLimitCharge func() (allowed bool, retryAfter time.Duration)
+
+// rateLimiter keeps a token bucket per client. Buckets hold up to a quarter
+// of the per-minute limit so short bursts are allowed.
+type rateLimiter struct {
+	mu       sync.Mutex
+	limit    rate.Limit
+	burst    int
+	limiters map[string]*rate.Limiter
+}
+
+// newRateLimiter creates a limiter allowing perMinute requests per client, it
+// returns nil when perMinute is zero
+func newRateLimiter(perMinute float64) *rateLimiter {
+	if perMinute <= 0 {
+		return nil
+	}
+	return &rateLimiter{
+		limit:    rate.Limit(perMinute / 60),
+		burst:    max(1, int(perMinute/4)),
+		limiters: make(map[string]*rate.Limiter),
+	}
+}
+
+// allow takes a token from the client's bucket. When the bucket is empty it
+// returns how long to wait for the next token.
+func (rl *rateLimiter) allow(client string) (bool, time.Duration) {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	limiter, exists := rl.limiters[client]
+	if !exists {
+		if len(rl.limiters) >= maxRateLimitedClients {
+			rl.dropIdle()
+		}
+		limiter = rate.NewLimiter(rl.limit, rl.burst)
+		rl.limiters[client] = limiter
+	}
+
+	reservation := limiter.Reserve()
+	if delay := reservation.Delay(); delay > 0 {
+		reservation.Cancel()
+		return false, delay
+	}
+	return true, 0
+}
+
+// dropIdle forgets the clients whose bucket is full again, they are in the
+// same state as a client that was never seen
+func (rl *rateLimiter) dropIdle() {
+	for client, limiter := range rl.limiters {
+		if limiter.Tokens() >= float64(rl.burst) {
+			delete(rl.limiters, client)
+		}
+	}
+}
+
+// rateLimit rejects requests with a 429 once the caller exceeds the limit.
+// Callers are identified by API key, or by IP when authentication is
+// disabled. A nil limiter lets every request through. The caller's bucket is
+// stored under rateLimitContextKey for the handlers.
+func rateLimit(rl *rateLimiter) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if rl == nil {
+			c.Next()
+			return
+		}
+		client := c.GetString(apiKeyIDContextKey)
+		if client == "" {
+			client = "ip:" + c.ClientIP()
+		}
+		if allowed, retryAfter := rl.allow(client); !allowed {
+			c.Header("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+			abortWithError(c, http.StatusTooManyRequests, "rate limit exceeded")
+			return
+		}
+		c.Set(rateLimitContextKey, rateLimitCharge(func() (bool, time.Duration) {
+			return rl.allow(client)
+		}))
+		c.Next()
+	}
+}
+
+// chargeRateLimit takes a token from the caller's bucket for each of the
+// extra ceremonies a handler is about to run, the request itself paid for the
+// first one. It responds with a 429 and returns false once the bucket is empty.
+func chargeRateLimit(c *gin.Context, ceremonies int) bool {
+	value, _ := c.Get(rateLimitContextKey)
+	charge, _ := value.(rateLimitCharge)
+	if charge == nil {
+		return true
+	}
+	for range ceremonies - 1 {
+		if allowed, retryAfter := charge(); !allowed {
+			c.Header("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+			respondError(c, http.StatusTooManyRequests, "rate limit exceeded")
+			return false
+		}
+	}
+	return true
+}
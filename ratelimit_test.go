@@ -0,0 +1,135 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRateLimitPerAPIKey(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	previous := keygenRateLimit
+	keygenRateLimit = 4
+	t.Cleanup(func() { keygenRateLimit = previous })
+	router := newRouter(newAPIKeySet([]string{"first-key", "second-key"}))
+
+	request := func(method, path, key string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		// An invalid wallet config is rejected before any keygen runs
+		req, _ := http.NewRequest(method, path, bytes.NewBufferString(`{"parties": 1}`))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+key)
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	// 4 per minute allows a burst of a single request
+	assert.Equal(t, http.StatusBadRequest, request("POST", "/wallet", "first-key").Code)
+	w := request("POST", "/wallet", "first-key")
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	retryAfter, err := strconv.Atoi(w.Header().Get("Retry-After"))
+	assert.NoError(t, err)
+	assert.InDelta(t, 15, retryAfter, 1, "Retry-After should be the time until the next token")
+
+	// Other keys and other endpoints have their own buckets
+	assert.Equal(t, http.StatusBadRequest, request("POST", "/wallet", "second-key").Code)
+	assert.Equal(t, http.StatusOK, request("GET", "/wallets", "first-key").Code)
+}
+
+func TestRateLimitBurst(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	router := gin.Default()
+	router.GET("/limited", rateLimit(newRateLimiter(40)), func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{})
+	})
+
+	// 40 per minute allows a burst of 10 requests per client IP
+	codes := make(map[int]int)
+	for i := 0; i < 15; i++ {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/limited", nil)
+		req.RemoteAddr = "192.0.2.1:1234"
+		router.ServeHTTP(w, req)
+		codes[w.Code]++
+		if w.Code == http.StatusTooManyRequests {
+			assert.NotEmpty(t, w.Header().Get("Retry-After"))
+		}
+	}
+	assert.Equal(t, 10, codes[http.StatusOK])
+	assert.Equal(t, 5, codes[http.StatusTooManyRequests])
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/limited", nil)
+	req.RemoteAddr = "192.0.2.2:1234"
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code, "Another client should not be limited")
+}
+
+func TestRateLimitDisabled(t *testing.T) {
+	assert.Nil(t, newRateLimiter(0))
+
+	gin.SetMode(gin.TestMode)
+	router := gin.Default()
+	router.GET("/unlimited", rateLimit(nil), func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{})
+	})
+	for i := 0; i < 100; i++ {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/unlimited", nil)
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+	}
+}
+
+func TestRateLimitChargesEveryCeremony(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	key, _ := crypto.GenerateKey()
+	useInstantSigning(t, key)
+	first, second := addFakeWallet(deriveAddress(&key.PublicKey)), addFakeWallet("0x0000000000000000000000000000000000000098")
+	first.PubKey, second.PubKey = &key.PublicKey, &key.PublicKey
+	t.Cleanup(func() {
+		walletsMutex.Lock()
+		delete(wallets, first.Address)
+		delete(wallets, second.Address)
+		walletsMutex.Unlock()
+	})
+
+	router := gin.Default()
+	limiter := newRateLimiter(40)
+	router.POST("/sign/batch", rateLimit(limiter), signBatch)
+	router.POST("/sign/multi", rateLimit(limiter), signMulti)
+	post := func(path, body, remoteAddr string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", path, bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.RemoteAddr = remoteAddr
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	// The burst of 10 pays for 8 batch items and 2 wallets, every ceremony
+	// takes a token and not only the request
+	batch := `{"wallet": "` + first.Address + `", "items": [` + strings.Repeat(`{"data": "0x01"},`, 7) + `{"data": "0x01"}]}`
+	assert.Equal(t, http.StatusOK, post("/sign/batch", batch, "192.0.2.1:1234").Code)
+	multi := `{"wallets": ["` + first.Address + `", "` + second.Address + `"], "data": "0x01"}`
+	assert.Equal(t, http.StatusOK, post("/sign/multi", multi, "192.0.2.1:1234").Code)
+	w := post("/sign/batch", `{"wallet": "`+first.Address+`", "items": [{"data": "0x01"}]}`, "192.0.2.1:1234")
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+
+	// A batch larger than the burst is rejected before its ceremonies run
+	batch = `{"wallet": "` + first.Address + `", "items": [` + strings.Repeat(`{"data": "0x01"},`, 10) + `{"data": "0x01"}]}`
+	w = post("/sign/batch", batch, "192.0.2.2:1234")
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.NotEmpty(t, w.Header().Get("Retry-After"))
+	assert.NotContains(t, w.Body.String(), "signatures")
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// walletHeader names the wallet signing a raw body, as the body holds nothing
+// but the data to sign
+const walletHeader = "X-Wallet-Address"
+
+// rawSignContentType is the content type of a raw body to sign
+const rawSignContentType = "application/octet-stream"
+
+// maxRawSignSize bounds the size of a raw body, it is hashed as it is read so
+// the limit only protects the ceremony slots from endless uploads
+const maxRawSignSize = 256 << 20
+
+// signRaw signs the SHA-256 of the request body. The body is hashed while it
+// is read, so large files can be signed without encoding them into JSON. The
+// signature encoding and recovery id format are given as query parameters.
+func signRaw(c *gin.Context) {
+	if c.ContentType() != rawSignContentType {
+		respondError(c, http.StatusBadRequest, "Content-Type must be "+rawSignContentType)
+		return
+	}
+	walletAddress := c.GetHeader(walletHeader)
+	if walletAddress == "" {
+		respondError(c, http.StatusBadRequest, walletHeader+" header is required")
+		return
+	}
+	encoding := c.Query("encoding")
+	if err := validateEncoding(encoding); err != nil {
+		respondError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	rawRecoveryID := c.Query("rawRecoveryId") == "true"
+
+	wallet, err := lookupWallet(walletAddress)
+	if err != nil {
+		respondServiceError(c, err)
+		return
+	}
+	if err := validateWalletEncoding(wallet, encoding); err != nil {
+		respondServiceError(c, err)
+		return
+	}
+	// EdDSA wallets sign the data itself, not a digest of it
+	if err := requireECDSA(wallet, "signing a body digest"); err != nil {
+		respondServiceError(c, err)
+		return
+	}
+	// The body is the data, hashed with sha256, its size is only known once
+	// it has been read
+	if err := wallet.Policy.checkHash(modeRaw, hashSHA256); err != nil {
+		respondServiceError(c, err)
+		return
+	}
+
+	hash := sha256.New()
+	size, err := io.Copy(hash, http.MaxBytesReader(c.Writer, c.Request.Body, maxRawSignSize))
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			respondError(c, http.StatusRequestEntityTooLarge, fmt.Sprintf("body must be at most %d bytes", maxRawSignSize))
+			return
+		}
+		if errors.Is(err, errBodyDigestMismatch) || errors.Is(err, errRequestSignatureUsed) {
+			respondError(c, http.StatusUnauthorized, err.Error())
+			return
+		}
+		respondError(c, http.StatusBadRequest, "failed to read body")
+		return
+	}
+	if size == 0 {
+		respondError(c, http.StatusBadRequest, "body is empty")
+		return
+	}
+	if err := wallet.Policy.checkSize(size); err != nil {
+		respondServiceError(c, err)
+		return
+	}
+	digest := hash.Sum(nil)
+
+	streamCeremony(c, func(onRound func(round int)) (int, any) {
+		sigData, err := signDigest(wallet, nil, digest, onRound)
+		if err != nil {
+			return ceremonyErrorResponse(err)
+		}
+		response, err := signatureResponse(sigData, digest, wallet.PubKey.Curve, encoding, rawRecoveryID)
+		if err != nil {
+			return ceremonyErrorResponse(err)
+		}
+		response["size"] = size
+		return http.StatusOK, dataResponse(response)
+	})
+}
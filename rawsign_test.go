@@ -0,0 +1,108 @@
+package main
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSignRaw(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	key, _ := crypto.GenerateKey()
+	useInstantSigning(t, key)
+	address := "0x00000000000000000000000000000000000000eA"
+	wallet := addFakeWallet(address)
+	wallet.PubKey = &key.PublicKey
+	t.Cleanup(func() {
+		walletsMutex.Lock()
+		delete(wallets, address)
+		walletsMutex.Unlock()
+	})
+
+	payload := make([]byte, 1<<20)
+	if _, err := rand.Read(payload); err != nil {
+		t.Fatalf("Failed to generate payload: %v", err)
+	}
+
+	router := gin.Default()
+	router.POST("/sign/raw", signRaw)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/sign/raw", bytes.NewReader(payload))
+	req.Header.Set("Content-Type", rawSignContentType)
+	req.Header.Set(walletHeader, address)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var response struct {
+		Signature string `json:"signature"`
+		Digest    string `json:"digest"`
+		Size      int    `json:"size"`
+	}
+	if err := decodeData(w.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+
+	digest := sha256.Sum256(payload)
+	assert.Equal(t, hex.EncodeToString(digest[:]), response.Digest)
+	assert.Equal(t, len(payload), response.Size)
+	signature, err := hex.DecodeString(response.Signature)
+	assert.NoError(t, err)
+	if assert.Len(t, signature, 64) {
+		r := new(big.Int).SetBytes(signature[:32])
+		s := new(big.Int).SetBytes(signature[32:])
+		assert.True(t, ecdsa.Verify(&key.PublicKey, digest[:], r, s), "Signature should verify against the SHA-256 of the payload")
+	}
+}
+
+func TestSignRawInvalidRequests(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	address := "0x00000000000000000000000000000000000000Eb"
+	addFakeWallet(address)
+	t.Cleanup(func() {
+		walletsMutex.Lock()
+		delete(wallets, address)
+		walletsMutex.Unlock()
+	})
+
+	tests := []struct {
+		name        string
+		contentType string
+		wallet      string
+		body        string
+		query       string
+		status      int
+	}{
+		{"JSON body", "application/json", address, `{"data": "0x01"}`, "", http.StatusBadRequest},
+		{"missing wallet", rawSignContentType, "", "data", "", http.StatusBadRequest},
+		{"unknown wallet", rawSignContentType, "0x00000000000000000000000000000000000000ec", "data", "", http.StatusNotFound},
+		{"empty body", rawSignContentType, address, "", "", http.StatusBadRequest},
+		{"invalid encoding", rawSignContentType, address, "data", "?encoding=base58", http.StatusBadRequest},
+	}
+
+	router := gin.Default()
+	router.POST("/sign/raw", signRaw)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("POST", "/sign/raw"+tt.query, bytes.NewBufferString(tt.body))
+			req.Header.Set("Content-Type", tt.contentType)
+			if tt.wallet != "" {
+				req.Header.Set(walletHeader, tt.wallet)
+			}
+			router.ServeHTTP(w, req)
+			assert.Equal(t, tt.status, w.Code)
+		})
+	}
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+
+	tsscrypto "github.com/bnb-chain/tss-lib/crypto"
+	"github.com/bnb-chain/tss-lib/crypto/vss"
+	"github.com/bnb-chain/tss-lib/tss"
+	"github.com/gin-gonic/gin"
+)
+
+// adminAPIKeys are the keys allowed to call the admin routes, which are only
+// registered when it is set. They are separate from the API keys and are
+// required even when authentication of the API is disabled.
+var adminAPIKeys *apiKeySet
+
+// reconstructKeyRequest represents the optional request body of reconstructKey
+type reconstructKeyRequest struct {
+	// Parties lists the threshold+1 parties whose shares are combined, the
+	// first parties of the wallet by default
+	Parties []string `json:"parties"`
+}
+
+// reconstructKey is the break-glass recovery of a wallet: it combines the key
+// shares of a quorum into the full private key, returned encrypted under the
+// passphrase given in the X-Export-Passphrase header. It needs an admin key
+// and ?confirm=true, and every attempt is logged.
+func reconstructKey(c *gin.Context) {
+	if c.Query("confirm") != "true" {
+		respondError(c, http.StatusBadRequest, "reconstructing a private key must be confirmed with confirm=true")
+		return
+	}
+	passphrase := c.GetHeader(exportPassphraseHeader)
+	if len(passphrase) < minExportPassphraseLength {
+		respondError(c, http.StatusBadRequest, fmt.Sprintf("a passphrase of at least %d characters is required in the %s header", minExportPassphraseLength, exportPassphraseHeader))
+		return
+	}
+	var requestBody reconstructKeyRequest
+	if c.Request.Body != nil && c.Request.ContentLength != 0 {
+		if err := c.BindJSON(&requestBody); err != nil {
+			respondError(c, http.StatusBadRequest, "invalid request body")
+			return
+		}
+	}
+
+	wallet, err := lookupWallet(c.Param("address"))
+	if err != nil {
+		respondServiceError(c, err)
+		return
+	}
+	if err := requireECDSA(wallet, "private key reconstruction"); err != nil {
+		respondServiceError(c, err)
+		return
+	}
+	partyIDs, err := signingQuorum(wallet, requestBody.Parties)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	admin := c.GetString(apiKeyIDContextKey)
+	log.Printf("admin %s from %s is reconstructing the private key of wallet %s from parties %v", admin, c.ClientIP(), wallet.Address, partyIDStrings(partyIDs))
+	key, err := reconstructPrivateKey(wallet, partyIDs)
+	if errors.Is(err, errWalletRetired) {
+		respondServiceError(c, err)
+		return
+	}
+	if err != nil {
+		log.Printf("failed to reconstruct the private key of wallet %s: %v", wallet.Address, err)
+		respondError(c, http.StatusInternalServerError, "failed to reconstruct private key")
+		return
+	}
+	encrypted, err := newShareCipher(passphrase).Encrypt(key.D.FillBytes(make([]byte, (key.Params().BitSize+7)/8)))
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "failed to encrypt private key")
+		return
+	}
+	clear(key.D.Bits())
+	log.Printf("admin %s reconstructed the private key of wallet %s", admin, wallet.Address)
+
+	c.Header("Cache-Control", "no-store")
+	respond(c, http.StatusOK, gin.H{
+		"address":             wallet.Address,
+		"curve":               wallet.Curve,
+		"encryptedPrivateKey": encrypted,
+	})
+}
+
+// reconstructPrivateKey interpolates the secret shares of the parties at
+// x = 0. Each share is checked against the public share the other parties
+// committed to during keygen, and the result against the wallet public key.
+// It fails with errWalletRetired once the wallet was replaced or deleted.
+func reconstructPrivateKey(wallet *Wallet, partyIDs tss.SortedPartyIDs) (*ecdsa.PrivateKey, error) {
+	if err := wallet.holdShares(); err != nil {
+		return nil, err
+	}
+	defer wallet.releaseShares()
+	curve := wallet.PubKey.Curve
+	shares := make(vss.Shares, len(partyIDs))
+	for i, partyID := range partyIDs {
+		save, exists := wallet.SaveData[partyID.Id]
+		if !exists || save.Xi == nil || save.ShareID == nil {
+			return nil, fmt.Errorf("missing share of party %s", partyID.Id)
+		}
+		index, err := save.OriginalIndex()
+		if err != nil || index >= len(save.BigXj) || save.BigXj[index] == nil {
+			return nil, fmt.Errorf("missing public share of party %s", partyID.Id)
+		}
+		if !tsscrypto.ScalarBaseMult(curve, save.Xi).Equals(save.BigXj[index]) {
+			return nil, fmt.Errorf("share of party %s does not match its public share", partyID.Id)
+		}
+		shares[i] = &vss.Share{Threshold: wallet.Threshold, ID: save.ShareID, Share: save.Xi}
+	}
+
+	secret, err := shares.ReConstruct(curve)
+	if err != nil {
+		return nil, err
+	}
+	key := &ecdsa.PrivateKey{PublicKey: ecdsa.PublicKey{Curve: curve}, D: secret}
+	key.X, key.Y = curve.ScalarBaseMult(secret.Bytes())
+	if !key.PublicKey.Equal(wallet.PubKey) {
+		return nil, errors.New("reconstructed key does not match the wallet public key")
+	}
+	return key, nil
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	tsscrypto "github.com/bnb-chain/tss-lib/crypto"
+	"github.com/bnb-chain/tss-lib/crypto/vss"
+	"github.com/bnb-chain/tss-lib/ecdsa/keygen"
+	"github.com/bnb-chain/tss-lib/tss"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+// addSharedKeyWallet stores a wallet whose key shares are a Shamir sharing of
+// key, as a keygen would leave them, without running the ceremony
+func addSharedKeyWallet(t *testing.T, key *ecdsa.PrivateKey, parties, threshold int) *Wallet {
+	t.Helper()
+	curve := tss.S256()
+	partyIDs, err := newPartyIDs(parties, curve)
+	if err != nil {
+		t.Fatalf("Failed to create party IDs: %v", err)
+	}
+	_, shares, err := vss.Create(curve, threshold, key.D, partyIDs.Keys())
+	if err != nil {
+		t.Fatalf("Failed to share the key: %v", err)
+	}
+
+	bigXj := make([]*tsscrypto.ECPoint, parties)
+	for j, share := range shares {
+		bigXj[j] = tsscrypto.ScalarBaseMult(curve, share.Share)
+	}
+	saveData := make(map[string]*keygen.LocalPartySaveData, parties)
+	for i, partyID := range partyIDs {
+		save := keygen.NewLocalPartySaveData(parties)
+		save.Xi, save.ShareID = shares[i].Share, shares[i].ID
+		save.Ks = partyIDs.Keys()
+		copy(save.BigXj, bigXj)
+		saveData[partyID.Id] = &save
+	}
+
+	wallet := &Wallet{
+		Address:     deriveAddress(&key.PublicKey),
+		PartyIDs:    partyIDs,
+		Parties:     parties,
+		Threshold:   threshold,
+		Curve:       curveSecp256k1,
+		PubKey:      &ecdsa.PublicKey{Curve: curve, X: key.X, Y: key.Y},
+		Algorithm:   algorithmECDSA,
+		SaveData:    saveData,
+		AddressType: addressEthereum,
+	}
+	walletsMutex.Lock()
+	wallets[wallet.Address] = wallet
+	walletsMutex.Unlock()
+	t.Cleanup(func() {
+		walletsMutex.Lock()
+		delete(wallets, wallet.Address)
+		walletsMutex.Unlock()
+	})
+	return wallet
+}
+
+func TestReconstructKey(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	key, _ := crypto.GenerateKey()
+	wallet := addSharedKeyWallet(t, key, 3, 1)
+
+	previous := adminAPIKeys
+	adminAPIKeys = newAPIKeySet([]string{"admin"})
+	t.Cleanup(func() { adminAPIKeys = previous })
+
+	// Admin keys are needed even when the API has no authentication
+	router := newRouter(nil)
+	passphrase := "correct horse battery staple"
+	path := "/admin/wallet/" + wallet.Address + "/reconstruct?confirm=true"
+	serve := func(path, adminKey, passphrase string, body any) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		var jsonBody []byte
+		if body != nil {
+			jsonBody, _ = json.Marshal(body)
+		}
+		req, _ := http.NewRequest("POST", path, bytes.NewBuffer(jsonBody))
+		req.Header.Set("Content-Type", "application/json")
+		if adminKey != "" {
+			req.Header.Set("Authorization", "Bearer "+adminKey)
+		}
+		req.Header.Set(exportPassphraseHeader, passphrase)
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	assert.Equal(t, http.StatusUnauthorized, serve(path, "", passphrase, nil).Code)
+	assert.Equal(t, http.StatusUnauthorized, serve(path, "key", passphrase, nil).Code)
+	assert.Equal(t, http.StatusBadRequest, serve("/admin/wallet/"+wallet.Address+"/reconstruct", "admin", passphrase, nil).Code)
+	assert.Equal(t, http.StatusBadRequest, serve(path, "admin", "short", nil).Code)
+	assert.Equal(t, http.StatusBadRequest, serve(path, "admin", passphrase, reconstructKeyRequest{Parties: []string{"0"}}).Code)
+	assert.Equal(t, http.StatusNotFound, serve("/admin/wallet/0x00000000000000000000000000000000000000DA/reconstruct?confirm=true", "admin", passphrase, nil).Code)
+
+	for _, request := range []*reconstructKeyRequest{nil, {Parties: []string{"2", "0"}}} {
+		var body any
+		if request != nil {
+			body = request
+		}
+		w := serve(path, "admin", passphrase, body)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "no-store", w.Header().Get("Cache-Control"))
+
+		var response struct {
+			Address             string `json:"address"`
+			EncryptedPrivateKey []byte `json:"encryptedPrivateKey"`
+		}
+		if err := decodeData(w.Body.Bytes(), &response); err != nil {
+			t.Fatalf("Failed to parse reconstruct response: %v", err)
+		}
+		assert.Equal(t, wallet.Address, response.Address)
+		plaintext, err := newShareCipher(passphrase).Decrypt(response.EncryptedPrivateKey)
+		if err != nil {
+			t.Fatalf("Failed to decrypt private key: %v", err)
+		}
+		reconstructed, err := crypto.ToECDSA(plaintext)
+		if err != nil {
+			t.Fatalf("Invalid private key: %v", err)
+		}
+		assert.Equal(t, wallet.Address, crypto.PubkeyToAddress(reconstructed.PublicKey).Hex(), "The reconstructed key should control the wallet address")
+	}
+
+	// Without admin keys the route does not exist
+	adminAPIKeys = nil
+	router = newRouter(nil)
+	assert.Equal(t, http.StatusNotFound, serve(path, "admin", passphrase, nil).Code)
+}
+
+func TestReconstructPrivateKeyTamperedShare(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	wallet := addSharedKeyWallet(t, key, 3, 1)
+	partyIDs, err := signingQuorum(wallet, nil)
+	assert.NoError(t, err)
+
+	reconstructed, err := reconstructPrivateKey(wallet, partyIDs)
+	assert.NoError(t, err)
+	assert.Equal(t, key.D, reconstructed.D)
+
+	save := wallet.SaveData[partyIDs[0].Id]
+	save.Xi = new(big.Int).Add(save.Xi, big.NewInt(1))
+	_, err = reconstructPrivateKey(wallet, partyIDs)
+	assert.ErrorContains(t, err, "does not match its public share")
+}
+
+func TestReconstructPrivateKeyRetiredWallet(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	wallet := addSharedKeyWallet(t, key, 3, 1)
+	partyIDs, err := signingQuorum(wallet, nil)
+	assert.NoError(t, err)
+
+	// A replaced or deleted wallet has its shares wiped, they are not read
+	wallet.retire()
+	_, err = reconstructPrivateKey(wallet, partyIDs)
+	assert.ErrorIs(t, err, errWalletRetired)
+	_, err = publicShares(wallet)
+	assert.ErrorIs(t, err, errWalletRetired)
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Nonces are remembered until the expiry of their request, or for nonceTTL
+// when the request has none. Expiries further than nonceTTL in the future are
+// refused so the memory held by nonces stays bounded.
+const (
+	nonceTTL           = 24 * time.Hour
+	maxNonceLength     = 128
+	nonceSweepInterval = time.Minute
+)
+
+// Errors answered with a 409, the request may have been captured and replayed
+var (
+	errNonceUsed      = errors.New("nonce already used")
+	errRequestExpired = errors.New("request expired")
+)
+
+// nonceTracker remembers the nonces of the sign requests of every wallet, or
+// the signatures of the requests of every HMAC key, until they expire.
+// Expired nonces are swept at most once a nonceSweepInterval, when a new
+// nonce is used.
+type nonceTracker struct {
+	mu        sync.Mutex
+	seen      map[string]map[string]time.Time
+	lastSweep time.Time
+}
+
+// newNonceTracker creates an empty tracker
+func newNonceTracker() *nonceTracker {
+	return &nonceTracker{seen: make(map[string]map[string]time.Time)}
+}
+
+// signNonces tracks the nonces of sign requests
+var signNonces = newNonceTracker()
+
+// use records the nonce of a request to the wallet, valid until expiresAt. It
+// returns false when the nonce was already used and has not expired yet.
+func (t *nonceTracker) use(wallet, nonce string, expiresAt, now time.Time) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if now.Sub(t.lastSweep) >= nonceSweepInterval {
+		t.sweep(now)
+	}
+	nonces := t.seen[wallet]
+	if until, exists := nonces[nonce]; exists && now.Before(until) {
+		return false
+	}
+	if nonces == nil {
+		nonces = make(map[string]time.Time)
+		t.seen[wallet] = nonces
+	}
+	nonces[nonce] = expiresAt
+	return true
+}
+
+// sweep forgets the expired nonces
+func (t *nonceTracker) sweep(now time.Time) {
+	for wallet, nonces := range t.seen {
+		for nonce, until := range nonces {
+			if !now.Before(until) {
+				delete(nonces, nonce)
+			}
+		}
+		if len(nonces) == 0 {
+			delete(t.seen, wallet)
+		}
+	}
+	t.lastSweep = now
+}
+
+// size returns the number of remembered nonces
+func (t *nonceTracker) size() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	count := 0
+	for _, nonces := range t.seen {
+		count += len(nonces)
+	}
+	return count
+}
+
+// validateReplayFields checks the nonce and expiry of a request, an expiry is
+// a Unix timestamp in seconds and zero means none
+func validateReplayFields(nonce string, expiry int64, now time.Time) error {
+	if len(nonce) > maxNonceLength {
+		return fmt.Errorf("nonce must be at most %d bytes", maxNonceLength)
+	}
+	if expiry != 0 && time.Unix(expiry, 0).After(now.Add(nonceTTL)) {
+		return fmt.Errorf("expiry must be at most %s in the future", nonceTTL)
+	}
+	return nil
+}
+
+// checkReplay rejects an expired request, or one whose nonce was already used
+// with the wallet. The nonce is used up even if the signing fails afterwards.
+func checkReplay(tracker *nonceTracker, wallet, nonce string, expiry int64, now time.Time) error {
+	expiresAt := now.Add(nonceTTL)
+	if expiry != 0 {
+		expiresAt = time.Unix(expiry, 0)
+		if !now.Before(expiresAt) {
+			return errRequestExpired
+		}
+	}
+	if nonce != "" && !tracker.use(wallet, nonce, expiresAt, now) {
+		return errNonceUsed
+	}
+	return nil
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSignDataReplay(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	key, _ := crypto.GenerateKey()
+	useInstantSigning(t, key)
+	address := "0x00000000000000000000000000000000000000eD"
+	other := "0x00000000000000000000000000000000000000eE"
+	for _, wallet := range []string{address, other} {
+		addFakeWallet(wallet).PubKey = &key.PublicKey
+	}
+	t.Cleanup(func() {
+		walletsMutex.Lock()
+		delete(wallets, address)
+		delete(wallets, other)
+		walletsMutex.Unlock()
+	})
+
+	router := gin.Default()
+	router.POST("/sign", signData)
+	sign := func(request signDataRequest) *httptest.ResponseRecorder {
+		jsonBody, _ := json.Marshal(request)
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/sign", bytes.NewBuffer(jsonBody))
+		req.Header.Set("Content-Type", "application/json")
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	request := signDataRequest{Data: "0x74657374", Wallet: address, Nonce: "replay-test-nonce"}
+	assert.Equal(t, http.StatusOK, sign(request).Code)
+
+	w := sign(request)
+	assert.Equal(t, http.StatusConflict, w.Code, "A replayed nonce should be rejected")
+	responseError, err := decodeError(w.Body.Bytes())
+	if assert.NoError(t, err) {
+		assert.Equal(t, codeConflict, responseError.Code)
+	}
+
+	// Nonces are tracked per wallet
+	request.Wallet = other
+	assert.Equal(t, http.StatusOK, sign(request).Code)
+
+	// Requests without a nonce can be repeated
+	request.Nonce = ""
+	assert.Equal(t, http.StatusOK, sign(request).Code)
+	assert.Equal(t, http.StatusOK, sign(request).Code)
+}
+
+func TestSignDataExpiry(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	key, _ := crypto.GenerateKey()
+	useInstantSigning(t, key)
+	address := "0x00000000000000000000000000000000000000EF"
+	addFakeWallet(address).PubKey = &key.PublicKey
+	t.Cleanup(func() {
+		walletsMutex.Lock()
+		delete(wallets, address)
+		walletsMutex.Unlock()
+	})
+
+	now := time.Now()
+	tests := []struct {
+		name   string
+		nonce  string
+		expiry int64
+		status int
+	}{
+		{"not expired", "expiry-1", now.Add(time.Minute).Unix(), http.StatusOK},
+		{"expired", "expiry-2", now.Add(-time.Minute).Unix(), http.StatusConflict},
+		{"expired without nonce", "", now.Add(-time.Minute).Unix(), http.StatusConflict},
+		{"expiry too far", "expiry-3", now.Add(2 * nonceTTL).Unix(), http.StatusBadRequest},
+		{"nonce too long", string(make([]byte, maxNonceLength+1)), 0, http.StatusBadRequest},
+	}
+
+	router := gin.Default()
+	router.POST("/sign", signData)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			jsonBody, _ := json.Marshal(signDataRequest{Data: "0x74657374", Wallet: address, Nonce: tt.nonce, Expiry: tt.expiry})
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("POST", "/sign", bytes.NewBuffer(jsonBody))
+			req.Header.Set("Content-Type", "application/json")
+			router.ServeHTTP(w, req)
+			assert.Equal(t, tt.status, w.Code)
+		})
+	}
+}
+
+func TestNonceTrackerExpiry(t *testing.T) {
+	tracker := newNonceTracker()
+	now := time.Now()
+
+	assert.True(t, tracker.use("wallet", "a", now.Add(time.Hour), now))
+	assert.True(t, tracker.use("wallet", "b", now.Add(2*time.Minute), now))
+	assert.False(t, tracker.use("wallet", "a", now.Add(time.Hour), now.Add(time.Minute)))
+	assert.Equal(t, 2, tracker.size())
+
+	// Once its request has expired a nonce is swept
+	later := now.Add(3 * time.Minute)
+	assert.True(t, tracker.use("wallet", "c", later.Add(time.Hour), later))
+	assert.Equal(t, 2, tracker.size(), "The expired nonce should be swept")
+	assert.False(t, tracker.use("wallet", "a", later.Add(time.Hour), later))
+
+	// Once its request has expired a nonce can be used again
+	afterA := now.Add(time.Hour + time.Second)
+	assert.True(t, tracker.use("wallet", "a", afterA.Add(time.Hour), afterA))
+}
@@ -0,0 +1,314 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"slices"
+
+	"github.com/bnb-chain/tss-lib/ecdsa/keygen"
+	"github.com/bnb-chain/tss-lib/ecdsa/resharing"
+	"github.com/bnb-chain/tss-lib/tss"
+	"github.com/gin-gonic/gin"
+)
+
+// reshareWalletRequest represents the request body for reshareWallet endpoint
+type reshareWalletRequest struct {
+	Parties   int `json:"parties"`
+	Threshold int `json:"threshold"`
+}
+
+// errWalletChanged is returned when a wallet is replaced or deleted while a
+// ceremony was running on its shares
+var errWalletChanged = errors.New("wallet changed while resharing, try again")
+
+// errWalletRetired is returned when a ceremony starts on a wallet that was
+// replaced or deleted after it was looked up
+var errWalletRetired = errors.New("wallet was replaced or deleted, try again")
+
+// reshareWallet moves a wallet to a new set of parties and threshold. The
+// address and public key stay the same, the previous key shares are wiped.
+func reshareWallet(c *gin.Context) {
+	var requestBody reshareWalletRequest
+
+	if err := c.BindJSON(&requestBody); err != nil {
+		respondError(c, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	if err := validateWalletConfig(requestBody.Parties, requestBody.Threshold); err != nil {
+		respondError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	address := c.Param("address")
+	wallet, err := lookupWallet(address)
+	if err != nil {
+		respondServiceError(c, err)
+		return
+	}
+
+	reshareAndReplace(c, wallet, nil, requestBody.Parties, requestBody.Threshold)
+}
+
+// refreshWallet replaces the key shares of a wallet with fresh ones for the
+// same number of parties and threshold. Leaked shares become useless as they
+// can't be combined with the new ones.
+func refreshWallet(c *gin.Context) {
+	wallet, err := lookupWallet(c.Param("address"))
+	if err != nil {
+		respondServiceError(c, err)
+		return
+	}
+
+	reshareAndReplace(c, wallet, nil, wallet.Parties, wallet.Threshold)
+}
+
+// recoverWalletRequest represents the request body for recoverWallet endpoint
+type recoverWalletRequest struct {
+	// LostParties lists the parties whose key shares are lost
+	LostParties []string `json:"lostParties"`
+}
+
+// recoverWallet restores a wallet after some of its key shares are lost. The
+// surviving parties, at least threshold+1 of them, reshare to fresh shares
+// for the same number of parties and threshold, so the wallet has a share
+// for every party again. The lost shares can't be combined with the new ones.
+func recoverWallet(c *gin.Context) {
+	var requestBody recoverWalletRequest
+
+	if err := c.BindJSON(&requestBody); err != nil {
+		respondError(c, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	wallet, err := lookupWallet(c.Param("address"))
+	if err != nil {
+		respondServiceError(c, err)
+		return
+	}
+	survivors, err := survivingQuorum(wallet, requestBody.LostParties)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	reshareAndReplace(c, wallet, survivors, wallet.Parties, wallet.Threshold)
+}
+
+// survivingQuorum returns the IDs of threshold+1 parties of the wallet that
+// still hold their key share. Parties without a share are lost along with the
+// listed ones.
+func survivingQuorum(wallet *Wallet, lostParties []string) ([]string, error) {
+	lost := make(map[string]bool, len(lostParties))
+	for _, id := range lostParties {
+		if !slices.ContainsFunc(wallet.PartyIDs, func(partyID *tss.PartyID) bool { return partyID.Id == id }) {
+			return nil, fmt.Errorf("party %s is not part of the wallet", id)
+		}
+		if lost[id] {
+			return nil, fmt.Errorf("party %s is listed more than once", id)
+		}
+		lost[id] = true
+	}
+
+	survivors := make([]string, 0, len(wallet.PartyIDs))
+	for _, partyID := range wallet.PartyIDs {
+		if _, exists := wallet.SaveData[partyID.Id]; !exists {
+			lost[partyID.Id] = true
+		}
+		if !lost[partyID.Id] {
+			survivors = append(survivors, partyID.Id)
+		}
+	}
+	if len(lost) == 0 {
+		return nil, errors.New("lostParties is required, refresh the wallet to replace shares that are not lost")
+	}
+	quorumSize := wallet.Threshold + 1
+	if len(survivors) < quorumSize {
+		return nil, fmt.Errorf("%d parties still hold their share, recovery needs at least %d", len(survivors), quorumSize)
+	}
+	return survivors[:quorumSize], nil
+}
+
+// reshareAndReplace reshares the wallet to new parties, swaps it in and
+// writes the response. quorum lists the old parties taking part, the first
+// parties of the wallet when empty.
+func reshareAndReplace(c *gin.Context, wallet *Wallet, quorum []string, parties, threshold int) {
+	if err := requireECDSA(wallet, "resharing"); err != nil {
+		respondServiceError(c, err)
+		return
+	}
+	partyIDs, err := newPartyIDs(parties, wallet.PubKey.Curve, wallet.PartyIDs...)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+	reshared, err := runResharing(wallet, quorum, partyIDs, threshold)
+	if err != nil {
+		respondError(c, ceremonyErrorStatus(err), err.Error())
+		return
+	}
+
+	if err := replaceWallet(wallet, reshared); err != nil {
+		for _, saveData := range reshared.SaveData {
+			zeroSaveData(saveData)
+		}
+		if errors.Is(err, errWalletChanged) {
+			respondError(c, http.StatusConflict, err.Error())
+			return
+		}
+		respondError(c, http.StatusInternalServerError, "failed to persist wallet")
+		return
+	}
+	respond(c, http.StatusOK, newWalletResponse(reshared))
+}
+
+// replaceWallet swaps a wallet for a copy with new key shares and wipes the
+// previous shares once the ceremonies still using them are over. It fails if
+// the wallet was replaced or deleted meanwhile.
+func replaceWallet(previous, wallet *Wallet) error {
+	storeMutex.Lock()
+	defer storeMutex.Unlock()
+	walletsMutex.Lock()
+	defer walletsMutex.Unlock()
+
+	if wallets[wallet.Address] != previous {
+		return errWalletChanged
+	}
+	if store != nil {
+		if err := store.Save(wallet); err != nil {
+			return err
+		}
+	}
+	wallets[wallet.Address] = wallet
+	previous.retire()
+	return nil
+}
+
+// runResharing runs a resharing ceremony between a quorum of the wallet's
+// parties and the new parties, and returns the wallet with the new shares.
+// The quorum is made of the given party IDs, or of the first parties of the
+// wallet when empty. New parties take their pre-parameters from the pool when
+// it has some.
+func runResharing(wallet *Wallet, quorum []string, newPartyIDs tss.SortedPartyIDs, newThreshold int) (reshared *Wallet, err error) {
+	defer trackActive(&activeReshares)()
+	defer func() {
+		if err != nil {
+			failuresTotal.WithLabelValues(operationReshare).Inc()
+		}
+	}()
+
+	// Only a quorum of threshold+1 old parties is needed to reshare
+	oldPartyIDs, err := signingQuorum(wallet, quorum)
+	if err != nil {
+		return nil, err
+	}
+	oldCtx := tss.NewPeerContext(oldPartyIDs)
+	newCtx := tss.NewPeerContext(newPartyIDs)
+	curve := wallet.PubKey.Curve
+	oldCount, newCount := len(oldPartyIDs), len(newPartyIDs)
+
+	// Channels for communication
+	cer, err := newCeremony(context.Background(), oldCount+newCount, keygenTimeout)
+	if err != nil {
+		return nil, err
+	}
+	defer cer.close()
+	if err := cer.useShares(wallet); err != nil {
+		return nil, err
+	}
+	endCh := make(chan keygen.LocalPartySaveData, oldCount+newCount)
+
+	partiesList := make([]tss.Party, 0, oldCount+newCount)
+	for i, partyID := range oldPartyIDs {
+		params := tss.NewReSharingParameters(curve, oldCtx, newCtx, partyID, wallet.Parties, wallet.Threshold, newCount, newThreshold)
+		saveData, exists := wallet.SaveData[partyID.Id]
+		if !exists {
+			return nil, errors.New("SaveData for party not found")
+		}
+		// tss-lib wipes the old share once it is reshared, hand it a copy so
+		// the wallet stays usable if the ceremony fails
+		save := *saveData
+		save.Xi = new(big.Int).Set(saveData.Xi)
+		partiesList = append(partiesList, resharing.NewLocalParty(params, save, cer.outChs[i], endCh))
+	}
+	for i, partyID := range newPartyIDs {
+		params := tss.NewReSharingParameters(curve, oldCtx, newCtx, partyID, wallet.Parties, wallet.Threshold, newCount, newThreshold)
+		save := keygen.NewLocalPartySaveData(newCount)
+		if pooled := keygenPreParams.take(); pooled != nil {
+			save.LocalPreParams = *pooled
+		}
+		partiesList = append(partiesList, resharing.NewLocalParty(params, save, cer.outChs[oldCount+i], endCh))
+	}
+	// Every resharing message is addressed to specific parties, the router
+	// matches them by key as party IDs are reused across committees
+	router := newPartyRouter(partiesList, cer.deliver)
+
+	// Start each party in a separate goroutine
+	for _, party := range partiesList {
+		cer.run(func() {
+			if err := party.Start(); err != nil {
+				cer.fail(err)
+			}
+		})
+	}
+
+	// Handle message passing and collect results
+	saves := make(map[string]*keygen.LocalPartySaveData, newCount)
+	ended := 0
+	for {
+		select {
+		case <-cer.ctx.Done():
+			return nil, cer.err()
+		case err := <-cer.errCh:
+			return nil, err
+		case msg := <-cer.messages:
+			if err := router.Route(msg); err != nil {
+				return nil, err
+			}
+		case save := <-endCh:
+			ended++
+			// Old parties end without a share, new parties with their new share
+			if save.Xi != nil {
+				index, err := save.OriginalIndex()
+				if err != nil {
+					return nil, err
+				}
+				saves[newPartyIDs[index].Id] = &save
+			}
+			if ended < oldCount+newCount {
+				continue
+			}
+
+			// All parties have completed resharing
+			if len(saves) != newCount {
+				return nil, errors.New("resharing did not produce a share for every new party")
+			}
+			pubKey := saves[newPartyIDs[0].Id].ECDSAPub.ToECDSAPubKey()
+			pubKey.Curve = curve
+			if !pubKey.Equal(wallet.PubKey) {
+				return nil, errors.New("resharing changed the wallet public key")
+			}
+
+			reshared = &Wallet{
+				Address:     wallet.Address,
+				PartyIDs:    newPartyIDs,
+				Parties:     newCount,
+				Threshold:   newThreshold,
+				Curve:       wallet.Curve,
+				PubKey:      pubKey,
+				Algorithm:   algorithmECDSA,
+				SaveData:    saves,
+				AddressType: wallet.AddressType,
+				Addresses:   wallet.Addresses,
+				Label:       wallet.Label,
+				Metadata:    wallet.Metadata,
+				Policy:      wallet.Policy,
+				CreatedAt:   wallet.CreatedAt,
+			}
+			reshared.setLastSignedAt(wallet.LastSignedAt())
+			return reshared, nil
+		}
+	}
+}
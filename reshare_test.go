@@ -0,0 +1,411 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReshareWallet(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	useDeterministicKeygen(t, "reshare wallet")
+
+	router := gin.Default()
+	router.POST("/wallet", createWallet)
+	router.POST("/wallet/:address/reshare", reshareWallet)
+	router.POST("/sign", signData)
+
+	jsonBody, _ := json.Marshal(createWalletRequest{Parties: 2, Threshold: 1})
+	w1 := httptest.NewRecorder()
+	req1, _ := http.NewRequest("POST", "/wallet", bytes.NewBuffer(jsonBody))
+	req1.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w1, req1)
+	assert.Equal(t, http.StatusOK, w1.Code)
+
+	var createResponse stringFields
+	err := decodeData(w1.Body.Bytes(), &createResponse)
+	if err != nil {
+		t.Fatalf("Failed to parse create wallet response: %v", err)
+	}
+	walletAddress := createResponse["address"]
+
+	walletsMutex.Lock()
+	previous := wallets[walletAddress]
+	walletsMutex.Unlock()
+
+	// Move the 2-of-2 wallet to a 3-of-3
+	useFixedPreParams(t, 3)
+	jsonBody, _ = json.Marshal(reshareWalletRequest{Parties: 3, Threshold: 2})
+	w2 := httptest.NewRecorder()
+	req2, _ := http.NewRequest("POST", "/wallet/"+walletAddress+"/reshare", bytes.NewBuffer(jsonBody))
+	req2.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w2, req2)
+	assert.Equal(t, http.StatusOK, w2.Code)
+
+	var reshareResponse walletResponse
+	err = decodeData(w2.Body.Bytes(), &reshareResponse)
+	if err != nil {
+		t.Fatalf("Failed to parse reshare response: %v", err)
+	}
+	assert.Equal(t, walletAddress, reshareResponse.Address)
+	assert.Equal(t, 3, reshareResponse.Parties)
+	assert.Equal(t, 2, reshareResponse.Threshold)
+
+	walletsMutex.Lock()
+	reshared := wallets[walletAddress]
+	walletsMutex.Unlock()
+	assert.Len(t, reshared.SaveData, 3)
+	assert.True(t, reshared.PubKey.Equal(previous.PubKey), "Public key should not change")
+	waitSharesWiped(t, previous)
+	for _, saveData := range previous.SaveData {
+		assert.Equal(t, 0, saveData.Xi.Sign(), "Previous shares should be wiped")
+	}
+
+	// The new quorum of three parties signs for the same address
+	digest := crypto.Keccak256([]byte("reshare"))
+	requestBody := signDataRequest{
+		Data:   "0x" + hex.EncodeToString(digest),
+		Wallet: walletAddress,
+		Hash:   hashNone,
+	}
+	jsonBody, _ = json.Marshal(requestBody)
+
+	w3 := httptest.NewRecorder()
+	req3, _ := http.NewRequest("POST", "/sign", bytes.NewBuffer(jsonBody))
+	req3.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w3, req3)
+	assert.Equal(t, http.StatusOK, w3.Code)
+
+	var signResponse stringFields
+	err = decodeData(w3.Body.Bytes(), &signResponse)
+	if err != nil {
+		t.Fatalf("Failed to parse sign data response: %v", err)
+	}
+	rsv, err := hex.DecodeString(signResponse["rsv"])
+	assert.NoError(t, err)
+	rsv[64] -= 27
+	pubKey, err := crypto.SigToPub(digest, rsv)
+	assert.NoError(t, err)
+	assert.Equal(t, walletAddress, crypto.PubkeyToAddress(*pubKey).Hex())
+}
+
+func TestReshareWalletInvalidInput(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	router := gin.Default()
+	router.POST("/wallet/:address/reshare", reshareWallet)
+
+	address := "0x00000000000000000000000000000000000000D6"
+	addFakeWallet(address)
+	t.Cleanup(func() {
+		walletsMutex.Lock()
+		delete(wallets, address)
+		walletsMutex.Unlock()
+	})
+
+	tests := []struct {
+		name    string
+		address string
+		body    string
+		status  int
+	}{
+		{"invalid body", address, `{`, http.StatusBadRequest},
+		{"too few parties", address, `{"parties": 1, "threshold": 1}`, http.StatusBadRequest},
+		{"threshold too high", address, `{"parties": 3, "threshold": 3}`, http.StatusBadRequest},
+		{"unknown wallet", "0x00000000000000000000000000000000000000d7", `{"parties": 3, "threshold": 1}`, http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("POST", "/wallet/"+tt.address+"/reshare", bytes.NewBufferString(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			router.ServeHTTP(w, req)
+			assert.Equal(t, tt.status, w.Code)
+		})
+	}
+}
+
+func TestRefreshWallet(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	useDeterministicKeygen(t, "refresh wallet")
+
+	router := gin.Default()
+	router.POST("/wallet", createWallet)
+	router.POST("/wallet/:address/refresh", refreshWallet)
+	router.POST("/sign", signData)
+
+	jsonBody, _ := json.Marshal(createWalletRequest{Parties: 2, Threshold: 1})
+	w1 := httptest.NewRecorder()
+	req1, _ := http.NewRequest("POST", "/wallet", bytes.NewBuffer(jsonBody))
+	req1.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w1, req1)
+	assert.Equal(t, http.StatusOK, w1.Code)
+
+	var createResponse stringFields
+	err := decodeData(w1.Body.Bytes(), &createResponse)
+	if err != nil {
+		t.Fatalf("Failed to parse create wallet response: %v", err)
+	}
+	walletAddress := createResponse["address"]
+
+	walletsMutex.Lock()
+	previous := wallets[walletAddress]
+	walletsMutex.Unlock()
+	previousXi := make(map[string]bool)
+	for _, saveData := range previous.SaveData {
+		previousXi[saveData.Xi.String()] = true
+	}
+
+	useFixedPreParams(t, 2)
+	w2 := httptest.NewRecorder()
+	req2, _ := http.NewRequest("POST", "/wallet/"+walletAddress+"/refresh", nil)
+	router.ServeHTTP(w2, req2)
+	assert.Equal(t, http.StatusOK, w2.Code)
+
+	var refreshResponse walletResponse
+	err = decodeData(w2.Body.Bytes(), &refreshResponse)
+	if err != nil {
+		t.Fatalf("Failed to parse refresh response: %v", err)
+	}
+	assert.Equal(t, walletAddress, refreshResponse.Address)
+	assert.Equal(t, 2, refreshResponse.Parties)
+	assert.Equal(t, 1, refreshResponse.Threshold)
+
+	walletsMutex.Lock()
+	refreshed := wallets[walletAddress]
+	walletsMutex.Unlock()
+	assert.Len(t, refreshed.SaveData, 2)
+	for _, saveData := range refreshed.SaveData {
+		assert.False(t, previousXi[saveData.Xi.String()], "Shares should be new")
+	}
+	waitSharesWiped(t, previous)
+	for _, saveData := range previous.SaveData {
+		assert.Equal(t, 0, saveData.Xi.Sign(), "Previous shares should be wiped")
+	}
+
+	// The refreshed shares still sign for the same address
+	digest := crypto.Keccak256([]byte("refresh"))
+	requestBody := signDataRequest{
+		Data:   "0x" + hex.EncodeToString(digest),
+		Wallet: walletAddress,
+		Hash:   hashNone,
+	}
+	jsonBody, _ = json.Marshal(requestBody)
+
+	w3 := httptest.NewRecorder()
+	req3, _ := http.NewRequest("POST", "/sign", bytes.NewBuffer(jsonBody))
+	req3.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w3, req3)
+	assert.Equal(t, http.StatusOK, w3.Code)
+
+	var signResponse stringFields
+	err = decodeData(w3.Body.Bytes(), &signResponse)
+	if err != nil {
+		t.Fatalf("Failed to parse sign data response: %v", err)
+	}
+	rsv, err := hex.DecodeString(signResponse["rsv"])
+	assert.NoError(t, err)
+	rsv[64] -= 27
+	pubKey, err := crypto.SigToPub(digest, rsv)
+	assert.NoError(t, err)
+	assert.Equal(t, walletAddress, crypto.PubkeyToAddress(*pubKey).Hex())
+}
+
+func TestRefreshWalletNonExistent(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	router := gin.Default()
+	router.POST("/wallet/:address/refresh", refreshWallet)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/wallet/0x00000000000000000000000000000000000000d8/refresh", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestRecoverWallet(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	useDeterministicKeygen(t, "recover wallet")
+
+	wallet, err := walletService.CreateWallet(context.Background(), createWalletRequest{Parties: 3, Threshold: 1}, nil)
+	if err != nil {
+		t.Fatalf("Failed to create wallet: %v", err)
+	}
+	t.Cleanup(func() {
+		walletsMutex.Lock()
+		delete(wallets, wallet.Address)
+		walletsMutex.Unlock()
+	})
+
+	router := gin.Default()
+	router.POST("/wallet/:address/recover", recoverWallet)
+	router.POST("/sign", signData)
+
+	useFixedPreParams(t, 3)
+
+	// Lose the share of the first party, which signs by default
+	lost := wallet.PartyIDs[0].Id
+	delete(wallet.SaveData, lost)
+
+	sign := func() *httptest.ResponseRecorder {
+		digest := crypto.Keccak256([]byte("recover"))
+		jsonBody, _ := json.Marshal(signDataRequest{Data: "0x" + hex.EncodeToString(digest), Wallet: wallet.Address, Hash: hashNone})
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/sign", bytes.NewBuffer(jsonBody))
+		req.Header.Set("Content-Type", "application/json")
+		router.ServeHTTP(w, req)
+		return w
+	}
+	assert.NotEqual(t, http.StatusOK, sign().Code, "The default quorum can't sign without its share")
+
+	jsonBody, _ := json.Marshal(recoverWalletRequest{LostParties: []string{lost}})
+	w1 := httptest.NewRecorder()
+	req1, _ := http.NewRequest("POST", "/wallet/"+wallet.Address+"/recover", bytes.NewBuffer(jsonBody))
+	req1.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w1, req1)
+	if !assert.Equal(t, http.StatusOK, w1.Code, w1.Body.String()) {
+		return
+	}
+
+	var recoverResponse walletResponse
+	if err := decodeData(w1.Body.Bytes(), &recoverResponse); err != nil {
+		t.Fatalf("Failed to parse recover response: %v", err)
+	}
+	assert.Equal(t, wallet.Address, recoverResponse.Address)
+	assert.Equal(t, 3, recoverResponse.Parties)
+	assert.Equal(t, 1, recoverResponse.Threshold)
+
+	walletsMutex.Lock()
+	recovered := wallets[wallet.Address]
+	walletsMutex.Unlock()
+	assert.Len(t, recovered.SaveData, 3, "Every party should hold a share again")
+	assert.True(t, recovered.PubKey.Equal(wallet.PubKey), "Public key should not change")
+	waitSharesWiped(t, wallet)
+	for _, saveData := range wallet.SaveData {
+		assert.Equal(t, 0, saveData.Xi.Sign(), "Surviving shares should be wiped")
+	}
+
+	// The recovered wallet signs for the same address
+	w2 := sign()
+	if !assert.Equal(t, http.StatusOK, w2.Code, w2.Body.String()) {
+		return
+	}
+	var signResponse stringFields
+	if err := decodeData(w2.Body.Bytes(), &signResponse); err != nil {
+		t.Fatalf("Failed to parse sign data response: %v", err)
+	}
+	rsv, err := hex.DecodeString(signResponse["rsv"])
+	assert.NoError(t, err)
+	rsv[64] -= 27
+	pubKey, err := crypto.SigToPub(crypto.Keccak256([]byte("recover")), rsv)
+	assert.NoError(t, err)
+	assert.Equal(t, wallet.Address, crypto.PubkeyToAddress(*pubKey).Hex())
+}
+
+func TestRecoverWalletInvalidInput(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	useDeterministicKeygen(t, "recover invalid")
+
+	wallet, err := walletService.CreateWallet(context.Background(), createWalletRequest{Parties: 3, Threshold: 1}, nil)
+	if err != nil {
+		t.Fatalf("Failed to create wallet: %v", err)
+	}
+	t.Cleanup(func() {
+		walletsMutex.Lock()
+		delete(wallets, wallet.Address)
+		walletsMutex.Unlock()
+	})
+	first, second := wallet.PartyIDs[0].Id, wallet.PartyIDs[1].Id
+
+	router := gin.Default()
+	router.POST("/wallet/:address/recover", recoverWallet)
+
+	tests := []struct {
+		name    string
+		address string
+		body    string
+		status  int
+		message string
+	}{
+		{"invalid body", wallet.Address, `{"lostParties": "0"}`, http.StatusBadRequest, "invalid request body"},
+		{"nothing lost", wallet.Address, `{}`, http.StatusBadRequest, "lostParties is required"},
+		{"unknown party", wallet.Address, `{"lostParties": ["nope"]}`, http.StatusBadRequest, "party nope is not part of the wallet"},
+		{"duplicate party", wallet.Address, `{"lostParties": ["` + first + `", "` + first + `"]}`, http.StatusBadRequest, "listed more than once"},
+		{"too few survivors", wallet.Address, `{"lostParties": ["` + first + `", "` + second + `"]}`, http.StatusBadRequest, "1 parties still hold their share, recovery needs at least 2"},
+		{"unknown wallet", "0x00000000000000000000000000000000000000d9", `{"lostParties": ["0"]}`, http.StatusNotFound, "wallet not found"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("POST", "/wallet/"+tt.address+"/recover", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			router.ServeHTTP(w, req)
+			assert.Equal(t, tt.status, w.Code)
+			apiErr, err := decodeError(w.Body.Bytes())
+			assert.NoError(t, err)
+			assert.Contains(t, apiErr.Message, tt.message)
+		})
+	}
+
+	// A share missing from the wallet counts as lost
+	delete(wallet.SaveData, second)
+	_, err = survivingQuorum(wallet, []string{first})
+	assert.ErrorContains(t, err, "recovery needs at least 2")
+	survivors, err := survivingQuorum(wallet, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{first, wallet.PartyIDs[2].Id}, survivors)
+}
+
+// waitSharesWiped waits for the ceremonies still using the shares of a
+// replaced or deleted wallet to be over, the shares are wiped by then
+func waitSharesWiped(t *testing.T, wallet *Wallet) {
+	t.Helper()
+	assert.Eventually(t, func() bool {
+		wallet.sharesMutex.Lock()
+		defer wallet.sharesMutex.Unlock()
+		return wallet.retired && wallet.shareHolds == 0
+	}, 5*time.Second, 10*time.Millisecond, "The previous shares should be released")
+}
+
+func TestReplaceWalletWaitsForCeremonies(t *testing.T) {
+	previous := addFakeWallet("0x5555555555555555555555555555555555555555")
+	t.Cleanup(func() {
+		walletsMutex.Lock()
+		delete(wallets, previous.Address)
+		walletsMutex.Unlock()
+	})
+	replacement := &Wallet{Address: previous.Address}
+
+	cer, err := newCeremony(context.Background(), 1, signTimeout)
+	if err != nil {
+		t.Fatalf("Failed to start ceremony: %v", err)
+	}
+	assert.NoError(t, cer.useShares(previous))
+	gate := make(chan struct{})
+	cer.run(func() { <-gate })
+
+	// The shares stay usable while a ceremony runs on them
+	assert.NoError(t, replaceWallet(previous, replacement))
+	cer.close()
+	for _, saveData := range previous.SaveData {
+		assert.NotZero(t, saveData.Xi.Sign(), "Shares in use should not be wiped")
+	}
+	assert.ErrorIs(t, previous.holdShares(), errWalletRetired, "No ceremony should start on a replaced wallet")
+
+	// They are wiped once its parties returned
+	close(gate)
+	waitSharesWiped(t, previous)
+	for _, saveData := range previous.SaveData {
+		assert.Equal(t, 0, saveData.Xi.Sign(), "Previous shares should be wiped")
+	}
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// apiResponse is the envelope of every API response. Data holds the result
+// of a successful request and is null on failure, Error is null on success.
+type apiResponse struct {
+	Data  any       `json:"data"`
+	Error *apiError `json:"error"`
+}
+
+// apiError describes why a request failed. Code is meant for programs and
+// only depends on the kind of failure, Message is meant for people.
+type apiError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// Error codes, one per HTTP status the API answers with
+const (
+	codeInvalidRequest  = "invalid_request"
+	codeUnauthorized    = "unauthorized"
+	codeForbidden       = "forbidden"
+	codeNotFound        = "not_found"
+	codeConflict        = "conflict"
+	codeTooLarge        = "payload_too_large"
+	codeMediaType       = "unsupported_media_type"
+	codeRateLimited     = "rate_limited"
+	codeInternal        = "internal_error"
+	codeUnavailable     = "unavailable"
+	codeCeremonyTimeout = "ceremony_timeout"
+	codeWalletLimit     = "wallet_limit_reached"
+)
+
+// errorCode returns the error code for an HTTP status
+func errorCode(status int) string {
+	switch status {
+	case http.StatusBadRequest:
+		return codeInvalidRequest
+	case http.StatusUnauthorized:
+		return codeUnauthorized
+	case http.StatusForbidden:
+		return codeForbidden
+	case http.StatusNotFound:
+		return codeNotFound
+	case http.StatusConflict:
+		return codeConflict
+	case http.StatusRequestEntityTooLarge:
+		return codeTooLarge
+	case http.StatusUnsupportedMediaType:
+		return codeMediaType
+	case http.StatusTooManyRequests:
+		return codeRateLimited
+	case http.StatusServiceUnavailable:
+		return codeUnavailable
+	case http.StatusGatewayTimeout:
+		return codeCeremonyTimeout
+	case http.StatusInsufficientStorage:
+		return codeWalletLimit
+	}
+	return codeInternal
+}
+
+// dataResponse wraps the result of a successful request
+func dataResponse(data any) apiResponse {
+	return apiResponse{Data: data}
+}
+
+// errorResponse wraps the error of a failed request
+func errorResponse(status int, message string) apiResponse {
+	return apiResponse{Error: &apiError{Code: errorCode(status), Message: message}}
+}
+
+// respond writes the result of a successful request
+func respond(c *gin.Context, status int, data any) {
+	c.JSON(status, dataResponse(data))
+}
+
+// respondError writes the error of a failed request
+func respondError(c *gin.Context, status int, message string) {
+	c.JSON(status, errorResponse(status, message))
+}
+
+// abortWithError writes the error of a request rejected by a middleware and
+// stops the handler chain
+func abortWithError(c *gin.Context, status int, message string) {
+	c.AbortWithStatusJSON(status, errorResponse(status, message))
+}
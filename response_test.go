@@ -0,0 +1,138 @@
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+// assertEnvelope checks that a response is an envelope holding either data or
+// an error matching its status
+func assertEnvelope(t *testing.T, w *httptest.ResponseRecorder, status int) {
+	t.Helper()
+	assert.Equal(t, status, w.Code)
+
+	var response map[string]json.RawMessage
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Response should be JSON: %v", err)
+	}
+	assert.Len(t, response, 2, "Response should only hold data and error")
+	if status == http.StatusOK {
+		assert.NotEqual(t, "null", string(response["data"]))
+		assert.Equal(t, "null", string(response["error"]))
+		return
+	}
+	assert.Equal(t, "null", string(response["data"]))
+	responseError, err := decodeError(w.Body.Bytes())
+	if assert.NoError(t, err) {
+		assert.Equal(t, errorCode(status), responseError.Code)
+		assert.NotEmpty(t, responseError.Message)
+	}
+}
+
+func TestResponseEnvelope(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	key, _ := crypto.GenerateKey()
+	useInstantSigning(t, key)
+
+	address := "0x00000000000000000000000000000000000000E8"
+	missing := "0x00000000000000000000000000000000000000E9"
+	wallet := addFakeWallet(address)
+	wallet.PubKey = &key.PublicKey
+	t.Cleanup(func() {
+		walletsMutex.Lock()
+		delete(wallets, address)
+		walletsMutex.Unlock()
+	})
+
+	data := "0x74657374"
+	signature, _ := crypto.Sign(crypto.Keccak256([]byte("test")), key)
+	typedData := `{"wallet": "` + address + `", "typedData": ` + mailTypedData + `}`
+	tx := func(wallet string) string {
+		return `{"wallet": "` + wallet + `", "chainId": "0x1", "tx": {"nonce": "0x0", "to": "` + missing + `", "value": "0x1", "gas": "0x5208", "gasPrice": "0x1"}}`
+	}
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		body   string
+		header http.Header
+		status int
+	}{
+		{"list wallets", "GET", "/wallets", "", nil, http.StatusOK},
+		{"list wallets invalid limit", "GET", "/wallets?limit=0", "", nil, http.StatusBadRequest},
+		{"get wallet", "GET", "/wallet/" + address, "", nil, http.StatusOK},
+		{"get unknown wallet", "GET", "/wallet/" + missing, "", nil, http.StatusNotFound},
+		{"get malformed address", "GET", "/wallet/0x1234", "", nil, http.StatusBadRequest},
+		{"create wallet invalid config", "POST", "/wallet", `{"parties": 1}`, nil, http.StatusBadRequest},
+		{"preview wallet invalid config", "POST", "/wallet/preview", `{"parties": 1}`, nil, http.StatusBadRequest},
+		{"export wallet", "GET", "/wallet/" + address + "/export?confirm=true", "", http.Header{exportPassphraseHeader: {"correct horse battery staple"}}, http.StatusOK},
+		{"export unconfirmed", "GET", "/wallet/" + address + "/export", "", nil, http.StatusBadRequest},
+		{"import invalid body", "POST", "/wallet/import", `{`, nil, http.StatusBadRequest},
+		{"reshare unknown wallet", "POST", "/wallet/" + missing + "/reshare", `{"parties": 3, "threshold": 1}`, nil, http.StatusNotFound},
+		{"refresh unknown wallet", "POST", "/wallet/" + missing + "/refresh", "", nil, http.StatusNotFound},
+		{"delete unknown wallet", "DELETE", "/wallet/" + missing, "", nil, http.StatusNotFound},
+		{"sign", "POST", "/sign", `{"wallet": "` + address + `", "data": "` + data + `"}`, nil, http.StatusOK},
+		{"sign unknown wallet", "POST", "/sign", `{"wallet": "` + missing + `", "data": "` + data + `"}`, nil, http.StatusNotFound},
+		{"sign batch", "POST", "/sign/batch", `{"wallet": "` + address + `", "items": [{"data": "` + data + `"}]}`, nil, http.StatusOK},
+		{"sign batch no items", "POST", "/sign/batch", `{"wallet": "` + address + `", "items": []}`, nil, http.StatusBadRequest},
+		{"sign typed data", "POST", "/sign/typed-data", typedData, nil, http.StatusOK},
+		{"sign typed data invalid body", "POST", "/sign/typed-data", `{`, nil, http.StatusBadRequest},
+		{"sign tx", "POST", "/sign/tx", tx(address), nil, http.StatusOK},
+		{"sign tx unknown wallet", "POST", "/sign/tx", tx(missing), nil, http.StatusNotFound},
+		{"sign raw", "POST", "/sign/raw", "data", http.Header{"Content-Type": {rawSignContentType}, walletHeader: {address}}, http.StatusOK},
+		{"sign raw unknown wallet", "POST", "/sign/raw", "data", http.Header{"Content-Type": {rawSignContentType}, walletHeader: {missing}}, http.StatusNotFound},
+		{"verify", "POST", "/verify", `{"wallet": "` + address + `", "data": "` + data + `", "signature": "0x` + hex.EncodeToString(signature[:64]) + `"}`, nil, http.StatusOK},
+		{"verify missing signature", "POST", "/verify", `{"wallet": "` + address + `", "data": "` + data + `"}`, nil, http.StatusBadRequest},
+		{"unknown route", "GET", "/unknown", "", nil, http.StatusNotFound},
+	}
+
+	previousLimit := keygenRateLimit
+	keygenRateLimit = 0
+	t.Cleanup(func() { keygenRateLimit = previousLimit })
+
+	router := newRouter(nil)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(tt.method, tt.path, bytes.NewBufferString(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			for name, values := range tt.header {
+				req.Header[name] = values
+			}
+			router.ServeHTTP(w, req)
+			assertEnvelope(t, w, tt.status)
+		})
+	}
+}
+
+func TestResponseEnvelopeMiddlewares(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	previousLimit := apiRateLimit
+	apiRateLimit = 1
+	t.Cleanup(func() { apiRateLimit = previousLimit })
+
+	router := newRouter(newAPIKeySet([]string{"key"}))
+	serve := func(header http.Header) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/wallets", nil)
+		req.Header = header
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	assertEnvelope(t, serve(http.Header{}), http.StatusUnauthorized)
+	authorized := http.Header{"Authorization": {"Bearer key"}}
+	assertEnvelope(t, serve(authorized), http.StatusOK)
+	assertEnvelope(t, serve(authorized), http.StatusTooManyRequests)
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/bnb-chain/tss-lib/tss"
+)
+
+// PartyRouter delivers the messages of a ceremony to the parties they are
+// addressed to. Parties are matched by key, which stays unique when party IDs
+// are reused across the two committees of a resharing.
+type PartyRouter struct {
+	parties []tss.Party
+	byKey   map[string]tss.Party
+	deliver func(party tss.Party, wireBytes []byte, from *tss.PartyID, isBroadcast bool)
+}
+
+// newPartyRouter creates a router between the parties, deliver is called for
+// every party a message must reach
+func newPartyRouter(parties []tss.Party, deliver func(party tss.Party, wireBytes []byte, from *tss.PartyID, isBroadcast bool)) *PartyRouter {
+	byKey := make(map[string]tss.Party, len(parties))
+	for _, party := range parties {
+		byKey[string(party.PartyID().Key)] = party
+	}
+	return &PartyRouter{parties: parties, byKey: byKey, deliver: deliver}
+}
+
+// Route delivers a message to its recipients, or to every other party when it
+// has none
+func (r *PartyRouter) Route(msg tss.Message) error {
+	if msg.GetTo() == nil {
+		return r.Broadcast(msg)
+	}
+	return r.SendTo(msg)
+}
+
+// Broadcast delivers a message to every party but its sender
+func (r *PartyRouter) Broadcast(msg tss.Message) error {
+	wireBytes, err := wireBytes(msg)
+	if err != nil {
+		return err
+	}
+	from := msg.GetFrom()
+	for _, party := range r.parties {
+		if string(party.PartyID().Key) == string(from.Key) {
+			continue
+		}
+		r.deliver(party, wireBytes, from, msg.IsBroadcast())
+	}
+	return nil
+}
+
+// SendTo delivers a message to each of its recipients, a recipient that is
+// not part of the ceremony is an error
+func (r *PartyRouter) SendTo(msg tss.Message) error {
+	wireBytes, err := wireBytes(msg)
+	if err != nil {
+		return err
+	}
+	for _, to := range msg.GetTo() {
+		party, exists := r.byKey[string(to.Key)]
+		if !exists {
+			return fmt.Errorf("message addressed to unknown party %s", to.Id)
+		}
+		r.deliver(party, wireBytes, msg.GetFrom(), msg.IsBroadcast())
+	}
+	return nil
+}
+
+// wireBytes serializes a message for delivery
+func wireBytes(msg tss.Message) ([]byte, error) {
+	wireBytes, _, err := msg.WireBytes()
+	if err != nil {
+		return nil, tss.NewError(err, "failed to serialize wire bytes", 0, msg.GetFrom(), nil)
+	}
+	return wireBytes, nil
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/bnb-chain/tss-lib/tss"
+	"github.com/stretchr/testify/assert"
+)
+
+// routedParty is a party that only has an ID, for routing tests
+type routedParty struct {
+	tss.Party
+	id *tss.PartyID
+}
+
+func (p *routedParty) PartyID() *tss.PartyID {
+	return p.id
+}
+
+// routedMessage is a message with only routing information and a fixed
+// payload
+type routedMessage struct {
+	tss.Message
+	from      *tss.PartyID
+	to        []*tss.PartyID
+	broadcast bool
+}
+
+func (m *routedMessage) GetFrom() *tss.PartyID { return m.from }
+func (m *routedMessage) GetTo() []*tss.PartyID { return m.to }
+func (m *routedMessage) IsBroadcast() bool     { return m.broadcast }
+func (m *routedMessage) Type() string          { return "routed" }
+func (m *routedMessage) WireBytes() ([]byte, *tss.MessageRouting, error) {
+	return []byte(m.from.Id), nil, nil
+}
+
+// delivery records a message handed to a party
+type delivery struct {
+	to          string
+	wireBytes   string
+	isBroadcast bool
+}
+
+func newTestRouter(ids []*tss.PartyID) (*PartyRouter, *[]delivery) {
+	parties := make([]tss.Party, len(ids))
+	for i, id := range ids {
+		parties[i] = &routedParty{id: id}
+	}
+	var delivered []delivery
+	router := newPartyRouter(parties, func(party tss.Party, wireBytes []byte, from *tss.PartyID, isBroadcast bool) {
+		delivered = append(delivered, delivery{to: party.PartyID().Id, wireBytes: string(wireBytes), isBroadcast: isBroadcast})
+	})
+	return router, &delivered
+}
+
+func TestPartyRouterBroadcast(t *testing.T) {
+	ids := []*tss.PartyID{
+		tss.NewPartyID("a", "A", big.NewInt(1)),
+		tss.NewPartyID("b", "B", big.NewInt(2)),
+		tss.NewPartyID("c", "C", big.NewInt(3)),
+	}
+	router, delivered := newTestRouter(ids)
+
+	err := router.Route(&routedMessage{from: ids[1], broadcast: true})
+	assert.NoError(t, err)
+	assert.Equal(t, []delivery{
+		{to: "a", wireBytes: "b", isBroadcast: true},
+		{to: "c", wireBytes: "b", isBroadcast: true},
+	}, *delivered, "Broadcasts should reach every party but the sender")
+}
+
+func TestPartyRouterSendTo(t *testing.T) {
+	ids := []*tss.PartyID{
+		tss.NewPartyID("a", "A", big.NewInt(1)),
+		tss.NewPartyID("b", "B", big.NewInt(2)),
+		tss.NewPartyID("c", "C", big.NewInt(3)),
+	}
+	router, delivered := newTestRouter(ids)
+
+	err := router.Route(&routedMessage{from: ids[0], to: []*tss.PartyID{ids[2]}})
+	assert.NoError(t, err)
+	assert.Equal(t, []delivery{{to: "c", wireBytes: "a"}}, *delivered, "P2P messages should only reach their recipient")
+
+	*delivered = nil
+	err = router.SendTo(&routedMessage{from: ids[0], to: []*tss.PartyID{ids[1], ids[2]}, broadcast: true})
+	assert.NoError(t, err)
+	assert.Equal(t, []delivery{
+		{to: "b", wireBytes: "a", isBroadcast: true},
+		{to: "c", wireBytes: "a", isBroadcast: true},
+	}, *delivered)
+}
+
+func TestPartyRouterMatchesByKey(t *testing.T) {
+	// The two committees of a resharing reuse the same party IDs
+	oldParty := tss.NewPartyID("0", "P[0]", big.NewInt(10))
+	newParty := tss.NewPartyID("0", "P[0]", big.NewInt(20))
+	parties := []tss.Party{&routedParty{id: oldParty}, &routedParty{id: newParty}}
+	var delivered []tss.Party
+	router := newPartyRouter(parties, func(party tss.Party, wireBytes []byte, from *tss.PartyID, isBroadcast bool) {
+		delivered = append(delivered, party)
+	})
+
+	err := router.SendTo(&routedMessage{from: oldParty, to: []*tss.PartyID{tss.NewPartyID("0", "P[0]", big.NewInt(20))}})
+	assert.NoError(t, err)
+	assert.Equal(t, []tss.Party{parties[1]}, delivered, "Recipients should be matched by key, not by ID")
+
+	delivered = nil
+	err = router.Broadcast(&routedMessage{from: oldParty, broadcast: true})
+	assert.NoError(t, err)
+	assert.Equal(t, []tss.Party{parties[1]}, delivered, "Only the sender itself should be skipped")
+
+	err = router.SendTo(&routedMessage{from: oldParty, to: []*tss.PartyID{tss.NewPartyID("9", "P[9]", big.NewInt(99))}})
+	assert.Error(t, err, "Messages to parties outside the ceremony should fail")
+}
@@ -0,0 +1,466 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+
+	tsscrypto "github.com/bnb-chain/tss-lib/crypto"
+	"github.com/bnb-chain/tss-lib/crypto/paillier"
+	"github.com/bnb-chain/tss-lib/ecdsa/keygen"
+	"github.com/bnb-chain/tss-lib/tss"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// saveDataSchemaVersion is the version of the schema written by the
+// SaveDataCodecs, payloads of a later version are rejected
+const saveDataSchemaVersion = 1
+
+// SaveDataCodec serializes the key share of one party of an ECDSA wallet in a
+// stable, versioned format that other services can read, unlike the tss-lib
+// structs which may change with the library. The schema is in savedata.proto.
+type SaveDataCodec interface {
+	Encode(save *keygen.LocalPartySaveData) ([]byte, error)
+	Decode(payload []byte) (*keygen.LocalPartySaveData, error)
+}
+
+// Codecs of key shares, by name
+var saveDataCodecs = map[string]SaveDataCodec{
+	"json":     jsonSaveDataCodec{},
+	"protobuf": protoSaveDataCodec{},
+}
+
+// saveDataRecord is a key share in the schema of savedata.proto. Integers are
+// big-endian, nil stands for an unset one. JSON tags follow the proto3 JSON
+// mapping, so both codecs write the same message.
+type saveDataRecord struct {
+	Version     uint32             `json:"version"`
+	Curve       string             `json:"curve"`
+	PaillierSK  *paillierKeyRecord `json:"paillierSk,omitempty"`
+	NTildei     []byte             `json:"ntildeI,omitempty"`
+	H1i         []byte             `json:"h1I,omitempty"`
+	H2i         []byte             `json:"h2I,omitempty"`
+	Alpha       []byte             `json:"alpha,omitempty"`
+	Beta        []byte             `json:"beta,omitempty"`
+	P           []byte             `json:"p,omitempty"`
+	Q           []byte             `json:"q,omitempty"`
+	Xi          []byte             `json:"xi,omitempty"`
+	ShareID     []byte             `json:"shareId,omitempty"`
+	Ks          [][]byte           `json:"ks,omitempty"`
+	NTildej     [][]byte           `json:"ntildeJ,omitempty"`
+	H1j         [][]byte           `json:"h1J,omitempty"`
+	H2j         [][]byte           `json:"h2J,omitempty"`
+	BigXj       []*pointRecord     `json:"bigXj,omitempty"`
+	PaillierPKs [][]byte           `json:"paillierPks,omitempty"`
+	ECDSAPub    *pointRecord       `json:"ecdsaPub,omitempty"`
+}
+
+// paillierKeyRecord is a Paillier private key in a saveDataRecord
+type paillierKeyRecord struct {
+	N       []byte `json:"n,omitempty"`
+	LambdaN []byte `json:"lambdaN,omitempty"`
+	PhiN    []byte `json:"phiN,omitempty"`
+	P       []byte `json:"p,omitempty"`
+	Q       []byte `json:"q,omitempty"`
+}
+
+// pointRecord is a curve point in a saveDataRecord
+type pointRecord struct {
+	X []byte `json:"x,omitempty"`
+	Y []byte `json:"y,omitempty"`
+}
+
+// Field numbers of savedata.proto
+const (
+	fieldVersion     protowire.Number = 1
+	fieldCurve       protowire.Number = 2
+	fieldPaillierSK  protowire.Number = 3
+	fieldNTildei     protowire.Number = 4
+	fieldH1i         protowire.Number = 5
+	fieldH2i         protowire.Number = 6
+	fieldAlpha       protowire.Number = 7
+	fieldBeta        protowire.Number = 8
+	fieldP           protowire.Number = 9
+	fieldQ           protowire.Number = 10
+	fieldXi          protowire.Number = 11
+	fieldShareID     protowire.Number = 12
+	fieldKs          protowire.Number = 13
+	fieldNTildej     protowire.Number = 14
+	fieldH1j         protowire.Number = 15
+	fieldH2j         protowire.Number = 16
+	fieldBigXj       protowire.Number = 17
+	fieldPaillierPKs protowire.Number = 18
+	fieldECDSAPub    protowire.Number = 19
+)
+
+// intBytes returns the big-endian bytes of n, nil when n is
+func intBytes(n *big.Int) []byte {
+	if n == nil {
+		return nil
+	}
+	return n.Bytes()
+}
+
+// bytesInt is the inverse of intBytes
+func bytesInt(b []byte) *big.Int {
+	if len(b) == 0 {
+		return nil
+	}
+	return new(big.Int).SetBytes(b)
+}
+
+// newSaveDataRecord converts a key share to the schema
+func newSaveDataRecord(save *keygen.LocalPartySaveData) (*saveDataRecord, error) {
+	if save.ECDSAPub == nil {
+		return nil, errors.New("key share has no public key")
+	}
+	curve, ok := tss.GetCurveName(save.ECDSAPub.Curve())
+	if !ok {
+		return nil, errors.New("key share is on an unknown curve")
+	}
+	point := func(p *tsscrypto.ECPoint) *pointRecord {
+		if p == nil {
+			return nil
+		}
+		return &pointRecord{X: intBytes(p.X()), Y: intBytes(p.Y())}
+	}
+	ints := func(ns []*big.Int) [][]byte {
+		out := make([][]byte, len(ns))
+		for i, n := range ns {
+			out[i] = intBytes(n)
+		}
+		return out
+	}
+
+	record := &saveDataRecord{
+		Version:  saveDataSchemaVersion,
+		Curve:    string(curve),
+		NTildei:  intBytes(save.NTildei),
+		H1i:      intBytes(save.H1i),
+		H2i:      intBytes(save.H2i),
+		Alpha:    intBytes(save.Alpha),
+		Beta:     intBytes(save.Beta),
+		P:        intBytes(save.P),
+		Q:        intBytes(save.Q),
+		Xi:       intBytes(save.Xi),
+		ShareID:  intBytes(save.ShareID),
+		Ks:       ints(save.Ks),
+		NTildej:  ints(save.NTildej),
+		H1j:      ints(save.H1j),
+		H2j:      ints(save.H2j),
+		ECDSAPub: point(save.ECDSAPub),
+	}
+	if sk := save.PaillierSK; sk != nil {
+		record.PaillierSK = &paillierKeyRecord{N: intBytes(sk.N), LambdaN: intBytes(sk.LambdaN), PhiN: intBytes(sk.PhiN), P: intBytes(sk.P), Q: intBytes(sk.Q)}
+	}
+	for _, p := range save.BigXj {
+		record.BigXj = append(record.BigXj, point(p))
+	}
+	for _, pk := range save.PaillierPKs {
+		var n *big.Int
+		if pk != nil {
+			n = pk.N
+		}
+		record.PaillierPKs = append(record.PaillierPKs, intBytes(n))
+	}
+	return record, nil
+}
+
+// saveData converts the record back to a key share, checking its version and
+// that its points are on its curve
+func (r *saveDataRecord) saveData() (*keygen.LocalPartySaveData, error) {
+	if r.Version == 0 || r.Version > saveDataSchemaVersion {
+		return nil, fmt.Errorf("unsupported key share schema version %d", r.Version)
+	}
+	curve, ok := tss.GetCurveByName(tss.CurveName(r.Curve))
+	if !ok {
+		return nil, fmt.Errorf("unsupported curve %q", r.Curve)
+	}
+	point := func(p *pointRecord) (*tsscrypto.ECPoint, error) {
+		if p == nil {
+			return nil, nil
+		}
+		return tsscrypto.NewECPoint(curve, bytesInt(p.X), bytesInt(p.Y))
+	}
+	ints := func(bs [][]byte) []*big.Int {
+		out := make([]*big.Int, len(bs))
+		for i, b := range bs {
+			out[i] = bytesInt(b)
+		}
+		return out
+	}
+
+	save := keygen.NewLocalPartySaveData(len(r.Ks))
+	save.NTildei, save.H1i, save.H2i = bytesInt(r.NTildei), bytesInt(r.H1i), bytesInt(r.H2i)
+	save.Alpha, save.Beta, save.P, save.Q = bytesInt(r.Alpha), bytesInt(r.Beta), bytesInt(r.P), bytesInt(r.Q)
+	save.Xi, save.ShareID = bytesInt(r.Xi), bytesInt(r.ShareID)
+	if sk := r.PaillierSK; sk != nil {
+		save.PaillierSK = &paillier.PrivateKey{
+			PublicKey: paillier.PublicKey{N: bytesInt(sk.N)},
+			LambdaN:   bytesInt(sk.LambdaN),
+			PhiN:      bytesInt(sk.PhiN),
+			P:         bytesInt(sk.P),
+			Q:         bytesInt(sk.Q),
+		}
+	}
+	save.Ks, save.NTildej, save.H1j, save.H2j = ints(r.Ks), ints(r.NTildej), ints(r.H1j), ints(r.H2j)
+	save.BigXj = make([]*tsscrypto.ECPoint, len(r.BigXj))
+	for j, p := range r.BigXj {
+		var err error
+		if save.BigXj[j], err = point(p); err != nil {
+			return nil, fmt.Errorf("invalid public share %d: %w", j, err)
+		}
+	}
+	save.PaillierPKs = make([]*paillier.PublicKey, len(r.PaillierPKs))
+	for j, n := range r.PaillierPKs {
+		if len(n) > 0 {
+			save.PaillierPKs[j] = &paillier.PublicKey{N: bytesInt(n)}
+		}
+	}
+	var err error
+	if save.ECDSAPub, err = point(r.ECDSAPub); err != nil {
+		return nil, fmt.Errorf("invalid public key: %w", err)
+	}
+	if save.ECDSAPub == nil {
+		return nil, errors.New("key share has no public key")
+	}
+	return &save, nil
+}
+
+// jsonSaveDataCodec writes key shares as JSON, integers as base64
+type jsonSaveDataCodec struct{}
+
+func (jsonSaveDataCodec) Encode(save *keygen.LocalPartySaveData) ([]byte, error) {
+	record, err := newSaveDataRecord(save)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(record)
+}
+
+func (jsonSaveDataCodec) Decode(payload []byte) (*keygen.LocalPartySaveData, error) {
+	var record saveDataRecord
+	if err := json.Unmarshal(payload, &record); err != nil {
+		return nil, fmt.Errorf("invalid key share: %w", err)
+	}
+	return record.saveData()
+}
+
+// protoSaveDataCodec writes key shares in the protobuf wire format of
+// savedata.proto
+type protoSaveDataCodec struct{}
+
+func (protoSaveDataCodec) Encode(save *keygen.LocalPartySaveData) ([]byte, error) {
+	record, err := newSaveDataRecord(save)
+	if err != nil {
+		return nil, err
+	}
+	var b []byte
+	b = protowire.AppendTag(b, fieldVersion, protowire.VarintType)
+	b = protowire.AppendVarint(b, uint64(record.Version))
+	b = appendStringField(b, fieldCurve, record.Curve)
+	if sk := record.PaillierSK; sk != nil {
+		var m []byte
+		for i, field := range [][]byte{sk.N, sk.LambdaN, sk.PhiN, sk.P, sk.Q} {
+			m = appendBytesField(m, protowire.Number(i+1), field)
+		}
+		b = appendMessageField(b, fieldPaillierSK, m)
+	}
+	// Fields are written in the order of their numbers, so a key share
+	// always encodes to the same bytes
+	for num := fieldNTildei; num <= fieldShareID; num++ {
+		b = appendBytesField(b, num, *record.bytesField(num))
+	}
+	for num := fieldKs; num <= fieldH2j; num++ {
+		b = appendRepeatedBytesField(b, num, *record.repeatedField(num))
+	}
+	for _, p := range record.BigXj {
+		b = appendMessageField(b, fieldBigXj, appendPoint(nil, p))
+	}
+	b = appendRepeatedBytesField(b, fieldPaillierPKs, record.PaillierPKs)
+	if record.ECDSAPub != nil {
+		b = appendMessageField(b, fieldECDSAPub, appendPoint(nil, record.ECDSAPub))
+	}
+	return b, nil
+}
+
+func (protoSaveDataCodec) Decode(payload []byte) (*keygen.LocalPartySaveData, error) {
+	var record saveDataRecord
+	err := consumeFields(payload, func(num protowire.Number, typ protowire.Type, value []byte, varint uint64) error {
+		switch {
+		case num == fieldVersion && typ == protowire.VarintType:
+			record.Version = uint32(varint)
+		case num == fieldCurve && typ == protowire.BytesType:
+			record.Curve = string(value)
+		case num == fieldPaillierSK && typ == protowire.BytesType:
+			sk := &paillierKeyRecord{}
+			fields := []*[]byte{&sk.N, &sk.LambdaN, &sk.PhiN, &sk.P, &sk.Q}
+			record.PaillierSK = sk
+			return consumeFields(value, func(num protowire.Number, typ protowire.Type, value []byte, _ uint64) error {
+				if typ == protowire.BytesType && num >= 1 && int(num) <= len(fields) {
+					*fields[num-1] = value
+				}
+				return nil
+			})
+		case num == fieldBigXj && typ == protowire.BytesType:
+			p, err := consumePoint(value)
+			record.BigXj = append(record.BigXj, p)
+			return err
+		case num == fieldECDSAPub && typ == protowire.BytesType:
+			var err error
+			record.ECDSAPub, err = consumePoint(value)
+			return err
+		case typ == protowire.BytesType:
+			if field := record.bytesField(num); field != nil {
+				*field = value
+			} else if fields := record.repeatedField(num); fields != nil {
+				*fields = append(*fields, value)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("invalid key share: %w", err)
+	}
+	return record.saveData()
+}
+
+// bytesField returns the singular bytes field of the record with the number
+func (r *saveDataRecord) bytesField(num protowire.Number) *[]byte {
+	switch num {
+	case fieldNTildei:
+		return &r.NTildei
+	case fieldH1i:
+		return &r.H1i
+	case fieldH2i:
+		return &r.H2i
+	case fieldAlpha:
+		return &r.Alpha
+	case fieldBeta:
+		return &r.Beta
+	case fieldP:
+		return &r.P
+	case fieldQ:
+		return &r.Q
+	case fieldXi:
+		return &r.Xi
+	case fieldShareID:
+		return &r.ShareID
+	}
+	return nil
+}
+
+// repeatedField returns the repeated bytes field of the record with the number
+func (r *saveDataRecord) repeatedField(num protowire.Number) *[][]byte {
+	switch num {
+	case fieldKs:
+		return &r.Ks
+	case fieldNTildej:
+		return &r.NTildej
+	case fieldH1j:
+		return &r.H1j
+	case fieldH2j:
+		return &r.H2j
+	case fieldPaillierPKs:
+		return &r.PaillierPKs
+	}
+	return nil
+}
+
+// appendBytesField appends a singular bytes field, omitted when empty as
+// proto3 does
+func appendBytesField(b []byte, num protowire.Number, value []byte) []byte {
+	if len(value) == 0 {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendBytes(b, value)
+}
+
+// appendRepeatedBytesField appends every value of a repeated bytes field,
+// empty ones included
+func appendRepeatedBytesField(b []byte, num protowire.Number, values [][]byte) []byte {
+	for _, value := range values {
+		b = protowire.AppendTag(b, num, protowire.BytesType)
+		b = protowire.AppendBytes(b, value)
+	}
+	return b
+}
+
+// appendStringField appends a singular string field, omitted when empty
+func appendStringField(b []byte, num protowire.Number, value string) []byte {
+	if value == "" {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendString(b, value)
+}
+
+// appendMessageField appends an embedded message
+func appendMessageField(b []byte, num protowire.Number, message []byte) []byte {
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendBytes(b, message)
+}
+
+// appendPoint appends the fields of an ECPoint message, an unset point is an
+// empty message
+func appendPoint(b []byte, p *pointRecord) []byte {
+	if p == nil {
+		return b
+	}
+	b = appendBytesField(b, 1, p.X)
+	return appendBytesField(b, 2, p.Y)
+}
+
+// consumePoint parses an ECPoint message, an empty one is an unset point
+func consumePoint(message []byte) (*pointRecord, error) {
+	if len(message) == 0 {
+		return nil, nil
+	}
+	p := &pointRecord{}
+	err := consumeFields(message, func(num protowire.Number, typ protowire.Type, value []byte, _ uint64) error {
+		switch {
+		case num == 1 && typ == protowire.BytesType:
+			p.X = value
+		case num == 2 && typ == protowire.BytesType:
+			p.Y = value
+		}
+		return nil
+	})
+	return p, err
+}
+
+// consumeFields calls fn with every field of a message, the value of bytes
+// fields or the varint of varint fields. Fields of other types are skipped.
+func consumeFields(message []byte, fn func(num protowire.Number, typ protowire.Type, value []byte, varint uint64) error) error {
+	for len(message) > 0 {
+		num, typ, n := protowire.ConsumeTag(message)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		message = message[n:]
+
+		var value []byte
+		var varint uint64
+		switch typ {
+		case protowire.BytesType:
+			value, n = protowire.ConsumeBytes(message)
+		case protowire.VarintType:
+			varint, n = protowire.ConsumeVarint(message)
+		default:
+			n = protowire.ConsumeFieldValue(num, typ, message)
+		}
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		message = message[n:]
+		if typ != protowire.BytesType && typ != protowire.VarintType {
+			continue
+		}
+		if err := fn(num, typ, value, varint); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/bnb-chain/tss-lib/ecdsa/keygen"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+func TestSaveDataCodecs(t *testing.T) {
+	useDeterministicKeygen(t, "save data codecs")
+	wallet, err := walletService.CreateWallet(context.Background(), createWalletRequest{Parties: 3, Threshold: 1}, nil)
+	if err != nil {
+		t.Fatalf("Failed to create wallet: %v", err)
+	}
+	t.Cleanup(func() {
+		walletsMutex.Lock()
+		delete(wallets, wallet.Address)
+		walletsMutex.Unlock()
+	})
+
+	for name, codec := range saveDataCodecs {
+		t.Run(name, func(t *testing.T) {
+			decoded := make(map[string]*keygen.LocalPartySaveData, len(wallet.SaveData))
+			for id, save := range wallet.SaveData {
+				payload, err := codec.Encode(save)
+				if err != nil {
+					t.Fatalf("Failed to encode share of party %s: %v", id, err)
+				}
+				again, _ := codec.Encode(save)
+				assert.Equal(t, payload, again, "Encoding should be deterministic")
+
+				decoded[id], err = codec.Decode(payload)
+				if err != nil {
+					t.Fatalf("Failed to decode share of party %s: %v", id, err)
+				}
+				// Every big.Int survives, down to the Paillier key and the
+				// range proof parameters
+				expected, _ := json.Marshal(save)
+				actual, _ := json.Marshal(decoded[id])
+				assert.Equal(t, string(expected), string(actual), "Share of party %s should round-trip", id)
+				assert.Equal(t, 0, save.Xi.Cmp(decoded[id].Xi))
+				assert.Equal(t, 0, save.PaillierSK.LambdaN.Cmp(decoded[id].PaillierSK.LambdaN))
+				assert.Equal(t, 0, save.NTildei.Cmp(decoded[id].NTildei))
+				assert.Equal(t, 0, save.H1j[2].Cmp(decoded[id].H1j[2]))
+				assert.Equal(t, 0, save.H2j[1].Cmp(decoded[id].H2j[1]))
+			}
+
+			// The decoded shares still sign for the wallet
+			original := wallet.SaveData
+			wallet.SaveData = decoded
+			defer func() { wallet.SaveData = original }()
+			digest := crypto.Keccak256([]byte(name))
+			signature, err := signDigest(wallet, []string{"2", "1"}, digest, nil)
+			if err != nil {
+				t.Fatalf("Failed to sign with decoded shares: %v", err)
+			}
+			sig := append(append(append([]byte{}, signature.R...), signature.S...), signature.SignatureRecovery[0])
+			pubKey, err := crypto.SigToPub(digest, sig)
+			assert.NoError(t, err)
+			assert.Equal(t, wallet.Address, crypto.PubkeyToAddress(*pubKey).Hex())
+		})
+	}
+}
+
+func TestSaveDataCodecsRejectInvalidPayloads(t *testing.T) {
+	useDeterministicKeygen(t, "invalid save data")
+	wallet, err := walletService.CreateWallet(context.Background(), createWalletRequest{Parties: 2, Threshold: 1}, nil)
+	if err != nil {
+		t.Fatalf("Failed to create wallet: %v", err)
+	}
+	t.Cleanup(func() {
+		walletsMutex.Lock()
+		delete(wallets, wallet.Address)
+		walletsMutex.Unlock()
+	})
+	save := wallet.SaveData[wallet.PartyIDs[0].Id]
+
+	jsonCodec, protoCodec := saveDataCodecs["json"], saveDataCodecs["protobuf"]
+	payload, _ := jsonCodec.Encode(save)
+	var record map[string]any
+	_ = json.Unmarshal(payload, &record)
+	record["version"] = saveDataSchemaVersion + 1
+	future, _ := json.Marshal(record)
+	_, err = jsonCodec.Decode(future)
+	assert.ErrorContains(t, err, "unsupported key share schema version")
+	record["version"] = saveDataSchemaVersion
+	record["curve"] = "unknown"
+	unknownCurve, _ := json.Marshal(record)
+	_, err = jsonCodec.Decode(unknownCurve)
+	assert.ErrorContains(t, err, "unsupported curve")
+
+	payload, _ = protoCodec.Encode(save)
+	_, err = protoCodec.Decode(payload[:len(payload)-1])
+	assert.Error(t, err, "A truncated payload should be rejected")
+	_, err = protoCodec.Decode(protowire.AppendVarint(protowire.AppendTag(nil, fieldVersion, protowire.VarintType), saveDataSchemaVersion+1))
+	assert.ErrorContains(t, err, "unsupported key share schema version")
+
+	// Fields unknown to this version are skipped
+	extended := protowire.AppendString(protowire.AppendTag(payload, 100, protowire.BytesType), "later field")
+	decoded, err := protoCodec.Decode(extended)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, save.Xi.Cmp(decoded.Xi))
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// listenAddrEnv names the environment variable holding the listen address,
+// the --addr flag takes precedence over it
+const listenAddrEnv = "LISTEN_ADDR"
+
+// defaultListenAddr is used when no listen address is configured
+const defaultListenAddr = ":8080"
+
+// ginModeEnv names the environment variable holding the gin mode, the
+// --gin-mode flag takes precedence over it
+const ginModeEnv = gin.EnvGinMode
+
+// probePaths are polled constantly by load balancers and Prometheus, they are
+// left out of the access log in release mode
+var probePaths = []string{"/health", "/ready", "/metrics"}
+
+// setGinMode switches gin to the given mode. gin panics on unknown modes, so
+// they are rejected here first.
+func setGinMode(mode string) error {
+	switch mode {
+	case gin.DebugMode, gin.ReleaseMode, gin.TestMode:
+		gin.SetMode(mode)
+		return nil
+	}
+	return fmt.Errorf("unknown mode %q, expected %s, %s or %s", mode, gin.DebugMode, gin.ReleaseMode, gin.TestMode)
+}
+
+// requestLogger logs every request in debug mode, and every request but the
+// probes otherwise
+func requestLogger() gin.HandlerFunc {
+	if gin.IsDebugging() {
+		return gin.Logger()
+	}
+	return gin.LoggerWithConfig(gin.LoggerConfig{SkipPaths: probePaths})
+}
+
+// shutdownTimeout is how long in-flight requests are given to complete once
+// the service is asked to stop. It covers a full keygen ceremony by default.
+var shutdownTimeout = 5 * time.Minute
+
+// Timeouts of the HTTP server. The read header timeout cuts off clients
+// sending their headers slowly to hold connections open. The read timeout
+// bounds reading a whole request, a large file to sign included. The write
+// timeout bounds a whole response, ceremonies included, it covers a keygen
+// with its retries by default. Idle keep-alive connections are closed after
+// the idle timeout. 0 disables a timeout, except for the read header and idle
+// timeouts which then use the read timeout.
+var (
+	readHeaderTimeout = 10 * time.Second
+	readTimeout       = 5 * time.Minute
+	writeTimeout      = 20 * time.Minute
+	idleTimeout       = 2 * time.Minute
+)
+
+// newServer creates the HTTP server of handler with the configured timeouts
+func newServer(handler http.Handler, tlsConfig *tls.Config) *http.Server {
+	return &http.Server{
+		Handler:           handler,
+		TLSConfig:         tlsConfig,
+		ReadHeaderTimeout: readHeaderTimeout,
+		ReadTimeout:       readTimeout,
+		WriteTimeout:      writeTimeout,
+		IdleTimeout:       idleTimeout,
+	}
+}
+
+// validateServerTimeouts checks the server timeouts are not negative and that
+// the write timeout leaves a keygen ceremony time to respond
+func validateServerTimeouts() error {
+	if readHeaderTimeout < 0 || readTimeout < 0 || writeTimeout < 0 || idleTimeout < 0 {
+		return errors.New("server timeouts must not be negative")
+	}
+	if writeTimeout > 0 && writeTimeout < keygenTimeout {
+		return fmt.Errorf("--write-timeout %s must be at least --keygen-timeout %s", writeTimeout, keygenTimeout)
+	}
+	return nil
+}
+
+// validateListenAddr checks that addr is a host:port pair, the host may be
+// empty to listen on every interface and port 0 picks a free port
+func validateListenAddr(addr string) error {
+	_, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return fmt.Errorf("invalid listen address %q: %w", addr, err)
+	}
+	if n, err := strconv.Atoi(port); err != nil || n < 0 || n > 65535 {
+		return fmt.Errorf("invalid listen address %q: port must be between 0 and 65535", addr)
+	}
+	return nil
+}
+
+// listen validates addr and opens a TCP listener on it
+func listen(addr string) (net.Listener, error) {
+	if err := validateListenAddr(addr); err != nil {
+		return nil, err
+	}
+	return net.Listen("tcp", addr)
+}
+
+// serve runs the server on the listener until ctx is cancelled, then stops
+// accepting connections and waits for in-flight requests, such as running
+// ceremonies and the persistence of their wallets, to complete. Connections
+// are served over TLS when the server has a TLS config.
+func serve(ctx context.Context, srv *http.Server, ln net.Listener) error {
+	errCh := make(chan error, 1)
+	go func() {
+		if srv.TLSConfig != nil {
+			errCh <- srv.ServeTLS(ln, "", "")
+			return
+		}
+		errCh <- srv.Serve(ln)
+	}()
+
+	select {
+	case err := <-errCh:
+		return err
+	case <-ctx.Done():
+	}
+
+	log.Printf("shutting down, waiting up to %s for in-flight requests", shutdownTimeout)
+	ready.Store(false)
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		return err
+	}
+	if err := <-errCh; !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
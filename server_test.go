@@ -0,0 +1,228 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestServeGracefulShutdown(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	router := gin.Default()
+	router.GET("/slow", func(c *gin.Context) {
+		close(started)
+		<-release
+		c.JSON(http.StatusOK, gin.H{"status": "done"})
+	})
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- serve(ctx, &http.Server{Handler: router}, ln)
+	}()
+	t.Cleanup(func() { ready.Store(true) })
+
+	type result struct {
+		code int
+		body string
+		err  error
+	}
+	resultCh := make(chan result, 1)
+	go func() {
+		resp, err := http.Get("http://" + ln.Addr().String() + "/slow")
+		if err != nil {
+			resultCh <- result{err: err}
+			return
+		}
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		resultCh <- result{code: resp.StatusCode, body: string(body), err: err}
+	}()
+
+	<-started
+	cancel()
+
+	// The server stops accepting new connections but waits for the request
+	assert.Eventually(t, func() bool {
+		conn, err := net.Dial("tcp", ln.Addr().String())
+		if err == nil {
+			conn.Close()
+		}
+		return err != nil
+	}, 5*time.Second, 10*time.Millisecond, "Listener should be closed")
+	select {
+	case err := <-serveErr:
+		t.Fatalf("serve returned before the in-flight request completed: %v", err)
+	default:
+	}
+
+	close(release)
+	res := <-resultCh
+	assert.NoError(t, res.err)
+	assert.Equal(t, http.StatusOK, res.code)
+	assert.JSONEq(t, `{"status": "done"}`, res.body)
+
+	select {
+	case err := <-serveErr:
+		assert.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("serve did not return after the in-flight request completed")
+	}
+}
+
+func TestListenEphemeralAddress(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	ln, err := listen("127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	addr := ln.Addr().(*net.TCPAddr)
+	assert.True(t, addr.IP.IsLoopback(), "Server should bind to the given host")
+	assert.NotZero(t, addr.Port, "Port 0 should pick a free port")
+
+	router := gin.Default()
+	router.GET("/health", healthCheck)
+	ctx, cancel := context.WithCancel(context.Background())
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- serve(ctx, &http.Server{Handler: router}, ln)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-serveErr
+		ready.Store(true)
+	})
+
+	resp, err := http.Get("http://" + addr.String() + "/health")
+	if err != nil {
+		t.Fatalf("Failed to reach server: %v", err)
+	}
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+}
+
+func TestValidateListenAddr(t *testing.T) {
+	for _, addr := range []string{":8080", "127.0.0.1:9000", "localhost:0", "[::1]:8080"} {
+		assert.NoError(t, validateListenAddr(addr), addr)
+	}
+	for _, addr := range []string{"", "8080", "localhost", ":http", ":-1", ":65536", "127.0.0.1:80:80"} {
+		assert.Error(t, validateListenAddr(addr), addr)
+	}
+}
+
+func TestGinMode(t *testing.T) {
+	t.Cleanup(func() {
+		gin.SetMode(gin.TestMode)
+		gin.DefaultWriter = os.Stdout
+	})
+	assert.Error(t, setGinMode("production"), "Unknown modes should be rejected")
+
+	// newRouter logs to the writer gin has when it is built
+	newLoggedRouter := func(mode string) (*gin.Engine, *bytes.Buffer) {
+		assert.NoError(t, setGinMode(mode))
+		assert.Equal(t, mode, gin.Mode())
+		var logs bytes.Buffer
+		gin.DefaultWriter = &logs
+		return newRouter(nil), &logs
+	}
+	request := func(router *gin.Engine, path string) {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", path, nil)
+		router.ServeHTTP(w, req)
+	}
+
+	router, logs := newLoggedRouter(gin.DebugMode)
+	assert.Contains(t, logs.String(), "[GIN-debug]", "Debug mode should log the routes")
+	logs.Reset()
+	request(router, "/health")
+	assert.Contains(t, logs.String(), "/health", "Debug mode should log the probes")
+
+	router, logs = newLoggedRouter(gin.ReleaseMode)
+	assert.NotContains(t, logs.String(), "[GIN-debug]", "Release mode should not log the routes")
+	request(router, "/health")
+	assert.Empty(t, logs.String(), "Release mode should not log the probes")
+	request(router, "/wallets")
+	assert.Contains(t, logs.String(), "/wallets", "Release mode should log API requests")
+}
+
+func TestServerTimeouts(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	previous := readHeaderTimeout
+	readHeaderTimeout = 200 * time.Millisecond
+	t.Cleanup(func() { readHeaderTimeout = previous })
+
+	router := gin.Default()
+	router.GET("/health", healthCheck)
+	srv := newServer(router, nil)
+	assert.Equal(t, writeTimeout, srv.WriteTimeout)
+	assert.Equal(t, idleTimeout, srv.IdleTimeout)
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- serve(ctx, srv, ln)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-serveErr
+		ready.Store(true)
+	})
+
+	// A client sending its headers slowly is cut off by the header timeout
+	conn, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	defer conn.Close()
+	start := time.Now()
+	_, err = conn.Write([]byte("GET /health HTTP/1.1\r\nHost: localhost\r\n"))
+	assert.NoError(t, err)
+	_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	_, err = io.ReadAll(conn)
+	assert.NoError(t, err, "The server should close the connection before the client gives up")
+	assert.Less(t, time.Since(start), 2*time.Second)
+
+	// A client sending its headers in time is served
+	resp, err := http.Get("http://" + ln.Addr().String() + "/health")
+	if err != nil {
+		t.Fatalf("Failed to reach server: %v", err)
+	}
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+}
+
+func TestValidateServerTimeouts(t *testing.T) {
+	previousWrite, previousIdle := writeTimeout, idleTimeout
+	t.Cleanup(func() { writeTimeout, idleTimeout = previousWrite, previousIdle })
+
+	assert.NoError(t, validateServerTimeouts(), "The defaults should be valid")
+	writeTimeout = keygenTimeout / 2
+	assert.ErrorContains(t, validateServerTimeouts(), "must be at least --keygen-timeout")
+	writeTimeout = 0
+	assert.NoError(t, validateServerTimeouts(), "0 disables the write timeout")
+	idleTimeout = -time.Second
+	assert.Error(t, validateServerTimeouts())
+}
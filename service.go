@@ -0,0 +1,350 @@
+package main
+
+import (
+	"context"
+	"crypto/elliptic"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/bnb-chain/tss-lib/common"
+	"github.com/bnb-chain/tss-lib/tss"
+	"github.com/gin-gonic/gin"
+)
+
+// WalletService holds the core wallet operations with plain Go signatures,
+// independent of any HTTP framework. The Gin handlers are thin adapters over
+// it. Wallets are kept in the process wide registry and saved to its store.
+type WalletService struct{}
+
+// walletService is the service behind the Gin handlers
+var walletService = &WalletService{}
+
+// Kinds of errors caused by the request given to a WalletService operation,
+// the returned error wraps one of them. Other errors are failures of the
+// ceremony or of the store.
+var (
+	ErrInvalidRequest = errors.New("invalid request")
+	ErrWalletNotFound = errors.New("wallet not found")
+)
+
+// errPersistWallet is returned when a new wallet could not be saved
+var errPersistWallet = errors.New("failed to persist wallet")
+
+// requestError is an error of the given kind, its message is the one of err
+type requestError struct {
+	kind error
+	err  error
+}
+
+func (e *requestError) Error() string   { return e.err.Error() }
+func (e *requestError) Unwrap() []error { return []error{e.kind, e.err} }
+
+// invalidRequest marks err as caused by an invalid request
+func invalidRequest(err error) error {
+	return &requestError{kind: ErrInvalidRequest, err: err}
+}
+
+// lookupWallet returns the wallet with the given address, in any case. A
+// malformed address is an invalid request, a well formed one matching no
+// wallet is ErrWalletNotFound.
+func lookupWallet(address string) (*Wallet, error) {
+	if err := validateWalletAddress(address); err != nil {
+		return nil, invalidRequest(err)
+	}
+	walletsMutex.Lock()
+	wallet, exists := wallets[checksumAddress(address)]
+	walletsMutex.Unlock()
+	if !exists {
+		return nil, ErrWalletNotFound
+	}
+	return wallet, nil
+}
+
+// serviceErrorStatus returns the HTTP status for an error of a WalletService
+// operation
+func serviceErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, ErrInvalidRequest):
+		return http.StatusBadRequest
+	case errors.Is(err, ErrPolicyViolation):
+		return http.StatusForbidden
+	case errors.Is(err, ErrWalletNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, errNonceUsed), errors.Is(err, errRequestExpired), errors.Is(err, errIdempotencyKeyReused), errors.Is(err, errWalletRetired):
+		return http.StatusConflict
+	case errors.Is(err, errWalletLimitReached):
+		return http.StatusInsufficientStorage
+	}
+	return ceremonyErrorStatus(err)
+}
+
+// serviceErrorResponse returns the HTTP status and body for an error of a
+// WalletService operation
+func serviceErrorResponse(err error) (int, apiResponse) {
+	status := serviceErrorStatus(err)
+	return status, errorResponse(status, err.Error())
+}
+
+// respondServiceError writes the error of a WalletService operation
+func respondServiceError(c *gin.Context, err error) {
+	status, body := serviceErrorResponse(err)
+	c.JSON(status, body)
+}
+
+// defaultCreateWalletRequest returns a wallet creation with the default
+// number of parties and threshold
+func defaultCreateWalletRequest() createWalletRequest {
+	return createWalletRequest{
+		Parties:   defaultParties,
+		Threshold: defaultThreshold,
+	}
+}
+
+// walletSpec is a validated wallet creation
+type walletSpec struct {
+	request   createWalletRequest
+	algorithm string
+	curveName tss.CurveName
+	curve     elliptic.Curve
+}
+
+// newWalletSpec validates a wallet creation and resolves its algorithm and
+// curve. An empty address type defaults to an Ethereum address, or to a
+// Solana one for EdDSA wallets.
+func newWalletSpec(request createWalletRequest) (walletSpec, error) {
+	if err := validateWalletConfig(request.Parties, request.Threshold); err != nil {
+		return walletSpec{}, invalidRequest(err)
+	}
+	algorithm, curveName, curve, err := resolveAlgorithm(request.Algorithm, request.Curve)
+	if err != nil {
+		return walletSpec{}, invalidRequest(err)
+	}
+	if request.AddressType == "" {
+		request.AddressType = addressEthereum
+		if algorithm == algorithmEdDSA {
+			request.AddressType = addressSolana
+		}
+	}
+	if err := validateAddressType(request.AddressType, curveName); err != nil {
+		return walletSpec{}, invalidRequest(err)
+	}
+	if err := validateLabels(request.Label, request.Metadata); err != nil {
+		return walletSpec{}, invalidRequest(err)
+	}
+	if err := validatePolicy(request.Policy); err != nil {
+		return walletSpec{}, invalidRequest(err)
+	}
+	if err := validatePartySpecs(request.PartySpecs, request.Parties); err != nil {
+		return walletSpec{}, invalidRequest(err)
+	}
+	return walletSpec{request: request, algorithm: algorithm, curveName: curveName, curve: curve}, nil
+}
+
+// CreateWallet runs a keygen ceremony and stores the new wallet. onRound,
+// when not nil, is called as each round of the ceremony completes.
+func (s *WalletService) CreateWallet(ctx context.Context, request createWalletRequest, onRound func(round int)) (*Wallet, error) {
+	spec, err := newWalletSpec(request)
+	if err != nil {
+		return nil, err
+	}
+	return s.create(ctx, spec, onRound)
+}
+
+// create runs the keygen ceremony of a validated wallet creation and stores
+// the new wallet
+func (s *WalletService) create(ctx context.Context, spec walletSpec, onRound func(round int)) (*Wallet, error) {
+	if err := checkWalletLimit(); err != nil {
+		return nil, err
+	}
+	// The checkpoint only outlives the creation when the process dies during
+	// the keygen, it is then resumed on the next start
+	checkpoint := keygenCheckpoints.start(spec.request)
+	defer checkpoint.remove()
+	wallet, err := generateWallet(ctx, spec, checkpoint, onRound)
+	if err != nil {
+		return nil, err
+	}
+	wallet.CreatedAt = time.Now().UTC()
+	if err := addWallet(wallet); err != nil {
+		// Another creation may have taken the last slot during the ceremony
+		if errors.Is(err, errWalletLimitReached) {
+			return nil, err
+		}
+		log.Printf("failed to persist wallet %s: %v", wallet.Address, err)
+		return nil, errPersistWallet
+	}
+	walletsCreatedTotal.Inc()
+	return wallet, nil
+}
+
+// walletQuery selects a page of wallets, ordered by address
+type walletQuery struct {
+	Limit  int
+	Offset int
+	// Label only keeps the wallets with this label when FilterByLabel is set
+	Label         string
+	FilterByLabel bool
+	// Threshold and Parties only keep the wallets with this threshold and
+	// number of parties when not 0
+	Threshold int
+	Parties   int
+}
+
+// ListWallets returns the page of wallets matching the query along with the
+// total number of matching wallets
+func (s *WalletService) ListWallets(query walletQuery) ([]*Wallet, int, error) {
+	if query.Limit < 1 || query.Limit > maxListLimit {
+		return nil, 0, invalidRequest(fmt.Errorf("limit must be between 1 and %d", maxListLimit))
+	}
+	if query.Offset < 0 {
+		return nil, 0, invalidRequest(errors.New("offset must be a non-negative integer"))
+	}
+	if query.Threshold < 0 || query.Parties < 0 {
+		return nil, 0, invalidRequest(errors.New("threshold and parties must be positive integers"))
+	}
+
+	// Only the wallet pointers are copied under the lock
+	walletsMutex.Lock()
+	matching := make([]*Wallet, 0, len(wallets))
+	for _, wallet := range wallets {
+		if query.FilterByLabel && wallet.Label != query.Label {
+			continue
+		}
+		if query.Threshold != 0 && wallet.Threshold != query.Threshold {
+			continue
+		}
+		if query.Parties != 0 && wallet.Parties != query.Parties {
+			continue
+		}
+		matching = append(matching, wallet)
+	}
+	walletsMutex.Unlock()
+
+	// Checksummed addresses mix cases, they are compared as numbers
+	sort.Slice(matching, func(i, j int) bool {
+		return strings.ToLower(matching[i].Address) < strings.ToLower(matching[j].Address)
+	})
+	start := min(query.Offset, len(matching))
+	return matching[start:min(start+query.Limit, len(matching))], len(matching), nil
+}
+
+// SignResult is a signature produced by a wallet
+type SignResult struct {
+	Wallet    *Wallet
+	Digest    []byte
+	Signature *common.SignatureData
+	// Signers are the IDs of the parties that took part in the signing
+	Signers []string
+}
+
+// response builds the response body of the signature, with the encoding and
+// recovery id format asked for in the request. The quorum and threshold are
+// echoed for audit logs.
+func (r *SignResult) response(request signDataRequest) (gin.H, error) {
+	response, err := signatureResponse(r.Signature, r.Digest, r.Wallet.PubKey.Curve, request.Encoding, request.RawRecoveryID)
+	if err != nil {
+		return nil, err
+	}
+	response["signers"] = r.Signers
+	response["threshold"] = r.Wallet.Threshold
+	return response, nil
+}
+
+// signJob is a validated sign request ready to run
+type signJob struct {
+	wallet  *Wallet
+	request signDataRequest
+	digest  []byte
+	// signers is the quorum the request resolves to
+	signers tss.SortedPartyIDs
+}
+
+// Sign runs a signing ceremony for the request. onRound, when not nil, is
+// called as each round of the ceremony completes.
+func (s *WalletService) Sign(request signDataRequest, onRound func(round int)) (*SignResult, error) {
+	job, err := s.prepareSign(request)
+	if err != nil {
+		return nil, err
+	}
+	return job.run(onRound)
+}
+
+// prepareSign validates a sign request, looks up the wallet, computes the
+// digest it signs and uses up the request's nonce
+func (s *WalletService) prepareSign(request signDataRequest) (*signJob, error) {
+	if (request.Data == "" && request.Message == "") || request.Wallet == "" {
+		return nil, invalidRequest(errors.New("data and wallet are required"))
+	}
+	if request.Data != "" && request.Message != "" {
+		return nil, invalidRequest(errors.New("only one of data and message can be set"))
+	}
+
+	if err := validateDataEncoding(request.DataEncoding); err != nil {
+		return nil, invalidRequest(err)
+	}
+	data := []byte(request.Message)
+	if request.Data != "" {
+		var err error
+		data, err = decodeSignData(request.Data, request.DataEncoding)
+		if err != nil {
+			return nil, invalidRequest(errors.New("invalid data"))
+		}
+	}
+	if err := validateSigningMode(request.Mode, request.Hash); err != nil {
+		return nil, invalidRequest(err)
+	}
+	if err := validateEncoding(request.Encoding); err != nil {
+		return nil, invalidRequest(err)
+	}
+	now := time.Now()
+	if err := validateReplayFields(request.Nonce, request.Expiry, now); err != nil {
+		return nil, invalidRequest(err)
+	}
+
+	wallet, err := lookupWallet(request.Wallet)
+	if err != nil {
+		return nil, err
+	}
+	digest, err := walletMessage(wallet, data, request.Mode, request.Hash)
+	if err != nil {
+		return nil, invalidRequest(err)
+	}
+	if err := wallet.checkPolicy(data, request.Mode, request.Hash); err != nil {
+		return nil, err
+	}
+	signers, err := signingQuorum(wallet, request.Signers)
+	if err != nil {
+		return nil, invalidRequest(err)
+	}
+	if err := validateWalletEncoding(wallet, request.Encoding); err != nil {
+		return nil, err
+	}
+	if err := checkReplay(signNonces, wallet.Address, request.Nonce, request.Expiry, now); err != nil {
+		return nil, err
+	}
+	return &signJob{wallet: wallet, request: request, digest: digest, signers: signers}, nil
+}
+
+// run signs the digest of the job with its quorum
+func (j *signJob) run(onRound func(round int)) (*SignResult, error) {
+	signerIDs := partyIDStrings(j.signers)
+	sigData, err := signDigest(j.wallet, signerIDs, j.digest, onRound)
+	if err != nil {
+		return nil, err
+	}
+	return &SignResult{Wallet: j.wallet, Digest: j.digest, Signature: sigData, Signers: signerIDs}, nil
+}
+
+// sign runs the job and returns the response body of its signature
+func (j *signJob) sign(onRound func(round int)) (gin.H, error) {
+	result, err := j.run(onRound)
+	if err != nil {
+		return nil, err
+	}
+	return result.response(j.request)
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWalletServiceCreateAndSign(t *testing.T) {
+	useDeterministicKeygen(t, "service create and sign")
+	service := &WalletService{}
+
+	wallet, err := service.CreateWallet(context.Background(), createWalletRequest{Parties: 2, Threshold: 1, Label: "service"}, nil)
+	if err != nil {
+		t.Fatalf("Failed to create wallet: %v", err)
+	}
+	t.Cleanup(func() {
+		walletsMutex.Lock()
+		delete(wallets, wallet.Address)
+		walletsMutex.Unlock()
+	})
+	assert.Equal(t, addressEthereum, wallet.AddressType, "Address type should default to Ethereum")
+	assert.False(t, wallet.CreatedAt.IsZero())
+
+	page, total, err := service.ListWallets(walletQuery{Limit: maxListLimit, Label: "service", FilterByLabel: true})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, total)
+	if assert.Len(t, page, 1) {
+		assert.Same(t, wallet, page[0])
+	}
+
+	// Dealt keygens have no rounds, the signing ceremony reports its own
+	var rounds []int
+	result, err := service.Sign(signDataRequest{Data: "0x74657374", Wallet: wallet.Address}, func(round int) {
+		rounds = append(rounds, round)
+	})
+	if err != nil {
+		t.Fatalf("Failed to sign: %v", err)
+	}
+	assert.NotEmpty(t, rounds, "Rounds should be reported")
+	assert.Equal(t, crypto.Keccak256([]byte("test")), result.Digest)
+	r := new(big.Int).SetBytes(result.Signature.R)
+	s := new(big.Int).SetBytes(result.Signature.S)
+	assert.True(t, ecdsa.Verify(wallet.PubKey, result.Digest, r, s), "Signature should verify")
+}
+
+func TestWalletServiceErrors(t *testing.T) {
+	service := &WalletService{}
+	address := "0x00000000000000000000000000000000000000F0"
+	addFakeWallet(address)
+	t.Cleanup(func() {
+		walletsMutex.Lock()
+		delete(wallets, address)
+		walletsMutex.Unlock()
+	})
+
+	tests := []struct {
+		name string
+		run  func() error
+		kind error
+	}{
+		{"create with one party", func() error {
+			_, err := service.CreateWallet(context.Background(), createWalletRequest{Parties: 1, Threshold: 1}, nil)
+			return err
+		}, ErrInvalidRequest},
+		{"create with unknown curve", func() error {
+			_, err := service.CreateWallet(context.Background(), createWalletRequest{Parties: 3, Threshold: 1, Curve: "ed448"}, nil)
+			return err
+		}, ErrInvalidRequest},
+		{"list with zero limit", func() error {
+			_, _, err := service.ListWallets(walletQuery{})
+			return err
+		}, ErrInvalidRequest},
+		{"list with negative offset", func() error {
+			_, _, err := service.ListWallets(walletQuery{Limit: 1, Offset: -1})
+			return err
+		}, ErrInvalidRequest},
+		{"list with negative threshold", func() error {
+			_, _, err := service.ListWallets(walletQuery{Limit: 1, Threshold: -1})
+			return err
+		}, ErrInvalidRequest},
+		{"sign without data", func() error {
+			_, err := service.Sign(signDataRequest{Wallet: address}, nil)
+			return err
+		}, ErrInvalidRequest},
+		{"sign with unknown wallet", func() error {
+			_, err := service.Sign(signDataRequest{Data: "0x01", Wallet: "0x00000000000000000000000000000000000000f1"}, nil)
+			return err
+		}, ErrWalletNotFound},
+		{"sign with unknown signer", func() error {
+			_, err := service.Sign(signDataRequest{Data: "0x01", Wallet: address, Signers: []string{"0", "9"}}, nil)
+			return err
+		}, ErrInvalidRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.run()
+			assert.True(t, errors.Is(err, tt.kind), "Error %v should be a %v", err, tt.kind)
+		})
+	}
+}
+
+func TestWalletServiceListPages(t *testing.T) {
+	service := &WalletService{}
+	addresses := []string{
+		"0x00000000000000000000000000000000000000F4",
+		"0x00000000000000000000000000000000000000F2",
+		"0x00000000000000000000000000000000000000f3",
+	}
+	for _, address := range addresses {
+		addFakeWallet(address).Label = "service-pages"
+	}
+	t.Cleanup(func() {
+		walletsMutex.Lock()
+		for _, address := range addresses {
+			delete(wallets, address)
+		}
+		walletsMutex.Unlock()
+	})
+
+	page, total, err := service.ListWallets(walletQuery{Limit: 2, Offset: 1, Label: "service-pages", FilterByLabel: true})
+	assert.NoError(t, err)
+	assert.Equal(t, 3, total)
+	if assert.Len(t, page, 2) {
+		assert.Equal(t, addresses[2], page[0].Address, "Wallets should be ordered by address")
+		assert.Equal(t, addresses[0], page[1].Address)
+	}
+
+	page, total, err = service.ListWallets(walletQuery{Limit: 2, Offset: 5, Label: "service-pages", FilterByLabel: true})
+	assert.NoError(t, err)
+	assert.Equal(t, 3, total)
+	assert.Empty(t, page)
+}
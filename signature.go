@@ -0,0 +1,71 @@
+package main
+
+import (
+	"crypto/elliptic"
+	"encoding/asn1"
+	"fmt"
+	"math/big"
+)
+
+// Signature encodings accepted by the sign endpoints, raw is the default
+const (
+	// encodingRaw is r || s, 32 bytes each
+	encodingRaw = "raw"
+	// encodingDER is the ASN.1 DER SEQUENCE { r INTEGER, s INTEGER }
+	encodingDER = "der"
+	// encodingEth65 is r || s || v as used by Ethereum
+	encodingEth65 = "eth65"
+	// encodingEIP2098 is the 64 byte compact r || yParity·2^255 + s
+	encodingEIP2098 = "eip2098"
+)
+
+// validateEncoding checks that the signature encoding is supported
+func validateEncoding(encoding string) error {
+	switch encoding {
+	case "", encodingRaw, encodingDER, encodingEth65, encodingEIP2098:
+		return nil
+	default:
+		return fmt.Errorf("unsupported encoding %q", encoding)
+	}
+}
+
+// derSignature is the ASN.1 structure of an ECDSA signature
+type derSignature struct {
+	R, S *big.Int
+}
+
+// normalizeLowS returns s, or N - s when s is in the upper half of the curve
+// order, along with the matching recovery id. Both are valid but many
+// verifiers, Ethereum among them (EIP-2), only accept the lower one.
+func normalizeLowS(s *big.Int, recoveryID byte, curve elliptic.Curve) (*big.Int, byte) {
+	n := curve.Params().N
+	halfN := new(big.Int).Rsh(n, 1)
+	if s.Cmp(halfN) > 0 {
+		return new(big.Int).Sub(n, s), recoveryID ^ 1
+	}
+	return s, recoveryID
+}
+
+// encodeDER encodes the signature in ASN.1 DER with a low s
+func encodeDER(r, s *big.Int, curve elliptic.Curve) ([]byte, error) {
+	lowS, _ := normalizeLowS(s, 0, curve)
+	der, err := asn1.Marshal(derSignature{R: r, S: lowS})
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode signature: %w", err)
+	}
+	return der, nil
+}
+
+// encodeEIP2098 packs a low s signature in the EIP-2098 compact form: r
+// followed by s with the y parity of the recovery id in its top bit, which
+// is always clear in a low s
+func encodeEIP2098(r, s *big.Int, recoveryID byte) ([]byte, error) {
+	if recoveryID > 1 {
+		return nil, fmt.Errorf("recovery id %d has no compact encoding", recoveryID)
+	}
+	compact := make([]byte, 64)
+	r.FillBytes(compact[:32])
+	s.FillBytes(compact[32:])
+	compact[32] |= recoveryID << 7
+	return compact, nil
+}
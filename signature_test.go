@@ -0,0 +1,301 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"encoding/asn1"
+	"encoding/hex"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/bnb-chain/tss-lib/common"
+	"github.com/bnb-chain/tss-lib/tss"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSignDataDEREncoding(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	useDeterministicKeygen(t, "der encoding")
+
+	router := gin.Default()
+	router.POST("/wallet", createWallet)
+	router.POST("/sign", signData)
+
+	jsonBody, _ := json.Marshal(createWalletRequest{Parties: 2, Threshold: 1})
+	w1 := httptest.NewRecorder()
+	req1, _ := http.NewRequest("POST", "/wallet", bytes.NewBuffer(jsonBody))
+	req1.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w1, req1)
+	assert.Equal(t, http.StatusOK, w1.Code)
+
+	var createResponse stringFields
+	err := decodeData(w1.Body.Bytes(), &createResponse)
+	if err != nil {
+		t.Fatalf("Failed to parse create wallet response: %v", err)
+	}
+	walletAddress := createResponse["address"]
+
+	jsonBody, _ = json.Marshal(signDataRequest{Data: "0x74657374", Wallet: walletAddress, Encoding: encodingDER})
+	w2 := httptest.NewRecorder()
+	req2, _ := http.NewRequest("POST", "/sign", bytes.NewBuffer(jsonBody))
+	req2.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w2, req2)
+	assert.Equal(t, http.StatusOK, w2.Code)
+
+	var signResponse stringFields
+	err = decodeData(w2.Body.Bytes(), &signResponse)
+	if err != nil {
+		t.Fatalf("Failed to parse sign response: %v", err)
+	}
+
+	der, err := hex.DecodeString(signResponse["signature"])
+	assert.NoError(t, err)
+	var decoded derSignature
+	rest, err := asn1.Unmarshal(der, &decoded)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Empty(t, rest)
+
+	rsv, _ := hex.DecodeString(signResponse["rsv"])
+	digest, _ := hex.DecodeString(signResponse["digest"])
+	r := new(big.Int).SetBytes(rsv[:32])
+	s := new(big.Int).SetBytes(rsv[32:64])
+	assert.Equal(t, r, decoded.R)
+	assert.Equal(t, s, decoded.S)
+
+	walletsMutex.Lock()
+	pubKey := wallets[walletAddress].PubKey
+	walletsMutex.Unlock()
+	assert.True(t, ecdsa.Verify(pubKey, digest, decoded.R, decoded.S))
+}
+
+func TestEncodeDERLowS(t *testing.T) {
+	curve := tss.S256()
+	r := big.NewInt(12345)
+	highS := new(big.Int).Sub(curve.Params().N, big.NewInt(2))
+
+	der, err := encodeDER(r, highS, curve)
+	assert.NoError(t, err)
+
+	var decoded derSignature
+	_, err = asn1.Unmarshal(der, &decoded)
+	assert.NoError(t, err)
+	assert.Equal(t, r, decoded.R)
+	assert.Equal(t, big.NewInt(2), decoded.S, "s above N/2 should be replaced by N - s")
+
+	// r with the high bit set needs a leading zero byte to stay positive
+	highR := new(big.Int).Lsh(big.NewInt(1), 255)
+	der, err = encodeDER(highR, big.NewInt(1), curve)
+	assert.NoError(t, err)
+	_, err = asn1.Unmarshal(der, &decoded)
+	assert.NoError(t, err)
+	assert.Equal(t, highR, decoded.R)
+	assert.Equal(t, 1, decoded.R.Sign())
+}
+
+func TestSignDataInvalidEncoding(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	router := gin.Default()
+	router.POST("/sign", signData)
+
+	address := "0x00000000000000000000000000000000000000DE"
+	addFakeWallet(address)
+	t.Cleanup(func() {
+		walletsMutex.Lock()
+		delete(wallets, address)
+		walletsMutex.Unlock()
+	})
+
+	jsonBody, _ := json.Marshal(signDataRequest{Data: "0x74657374", Wallet: address, Encoding: "pem"})
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/sign", bytes.NewBuffer(jsonBody))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestSignatureResponseLowS(t *testing.T) {
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	digest := crypto.Keccak256([]byte("test"))
+	sig, err := crypto.Sign(digest, key)
+	if err != nil {
+		t.Fatalf("Failed to sign: %v", err)
+	}
+
+	// go-ethereum only produces low s, flip it to the equally valid high s
+	curve := tss.S256()
+	lowS := new(big.Int).SetBytes(sig[32:64])
+	highS := new(big.Int).Sub(curve.Params().N, lowS)
+	sigData := &common.SignatureData{
+		R:                 sig[:32],
+		S:                 highS.Bytes(),
+		SignatureRecovery: []byte{sig[64] ^ 1},
+	}
+
+	response, err := signatureResponse(sigData, digest, curve, encodingEth65, false)
+	if !assert.NoError(t, err) {
+		return
+	}
+	rsv, _ := hex.DecodeString(response["rsv"].(string))
+	assert.Equal(t, sig[:64], rsv[:64], "s should be normalized to the lower half of the curve order")
+	assert.Equal(t, sig[64]+27, rsv[64], "recovery id should be flipped along with s")
+	assert.Equal(t, response["rsv"], response["signature"])
+
+	// The normalized signature must still recover the wallet public key
+	rsv[64] -= 27
+	recovered, err := crypto.SigToPub(digest, rsv)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, crypto.PubkeyToAddress(key.PublicKey), crypto.PubkeyToAddress(*recovered))
+}
+
+func TestSignatureResponseEIP2098(t *testing.T) {
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	curve := tss.S256()
+
+	// Sign until both y parities are covered, every other signature has a
+	// high s for the normalization to flip
+	parities := map[byte]bool{}
+	for i := 0; len(parities) < 2 || i < 4; i++ {
+		digest := crypto.Keccak256([]byte{byte(i)})
+		sig, err := crypto.Sign(digest, key)
+		if err != nil {
+			t.Fatalf("Failed to sign: %v", err)
+		}
+		sigData := &common.SignatureData{R: sig[:32], S: sig[32:64], SignatureRecovery: []byte{sig[64]}}
+		if i%2 == 1 {
+			highS := new(big.Int).Sub(curve.Params().N, new(big.Int).SetBytes(sig[32:64]))
+			sigData.S, sigData.SignatureRecovery = highS.Bytes(), []byte{sig[64] ^ 1}
+		}
+
+		response, err := signatureResponse(sigData, digest, curve, encodingEIP2098, false)
+		if !assert.NoError(t, err) {
+			return
+		}
+		compact, _ := hex.DecodeString(response["signature"].(string))
+		if !assert.Len(t, compact, 64) {
+			return
+		}
+
+		// Decode the compact form back to r, s and v
+		r := compact[:32]
+		yParity := compact[32] >> 7
+		s := append([]byte{}, compact[32:]...)
+		s[0] &= 0x7f
+		parities[yParity] = true
+		assert.Equal(t, sig[:32], r)
+		assert.Equal(t, sig[32:64], s, "s should be the low s")
+		assert.Equal(t, sig[64], yParity)
+		assert.Equal(t, hexutil.EncodeUint64(uint64(27+yParity)), response["v"])
+
+		recovered, err := crypto.SigToPub(digest, append(append(r, s...), yParity))
+		if assert.NoError(t, err) {
+			assert.Equal(t, crypto.PubkeyToAddress(key.PublicKey), crypto.PubkeyToAddress(*recovered))
+		}
+	}
+
+	_, err = encodeEIP2098(big.NewInt(1), big.NewInt(1), 2)
+	assert.Error(t, err, "Recovery ids above 1 have no y parity bit")
+}
+
+// signAndVerify signs data with the wallet through /sign and returns the
+// signature after checking /verify accepts it
+func signAndVerify(t *testing.T, router *gin.Engine, wallet *Wallet, data string) []byte {
+	t.Helper()
+	jsonBody, _ := json.Marshal(signDataRequest{Data: data, Wallet: wallet.Address})
+	w1 := httptest.NewRecorder()
+	req1, _ := http.NewRequest("POST", "/sign", bytes.NewBuffer(jsonBody))
+	req1.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w1, req1)
+	if !assert.Equal(t, http.StatusOK, w1.Code, w1.Body.String()) {
+		t.FailNow()
+	}
+	var signResponse stringFields
+	if err := decodeData(w1.Body.Bytes(), &signResponse); err != nil {
+		t.Fatalf("Failed to parse sign response: %v", err)
+	}
+	signature, err := hex.DecodeString(signResponse["signature"])
+	if err != nil {
+		t.Fatalf("Invalid signature %q: %v", signResponse["signature"], err)
+	}
+
+	jsonBody, _ = json.Marshal(verifySignatureRequest{Wallet: wallet.Address, Data: data, Signature: "0x" + hex.EncodeToString(signature), Strict: true})
+	w2 := httptest.NewRecorder()
+	req2, _ := http.NewRequest("POST", "/verify", bytes.NewBuffer(jsonBody))
+	req2.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w2, req2)
+	assert.Equal(t, http.StatusOK, w2.Code)
+	var verifyResponse map[string]interface{}
+	if err := decodeData(w2.Body.Bytes(), &verifyResponse); err != nil {
+		t.Fatalf("Failed to parse verify response: %v", err)
+	}
+	assert.Equal(t, true, verifyResponse["valid"], "The signature should verify: %v", verifyResponse["reason"])
+	return signature
+}
+
+func TestSignaturesAreNotDeterministic(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	useDeterministicKeygen(t, "non deterministic signatures")
+
+	router := gin.Default()
+	router.POST("/sign", signData)
+	router.POST("/verify", verifyData)
+
+	tests := []struct {
+		name      string
+		algorithm string
+	}{
+		{"ecdsa", algorithmECDSA},
+		{"eddsa", algorithmEdDSA},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			wallet, err := walletService.CreateWallet(context.Background(), createWalletRequest{Parties: 2, Threshold: 1, Algorithm: tt.algorithm}, nil)
+			if err != nil {
+				t.Fatalf("Failed to create wallet: %v", err)
+			}
+			t.Cleanup(func() {
+				walletsMutex.Lock()
+				delete(wallets, wallet.Address)
+				walletsMutex.Unlock()
+			})
+
+			// Both signatures of the same data are valid, each ceremony
+			// draws its own nonce so their r (R for Ed25519) differ
+			first := signAndVerify(t, router, wallet, "0x74657374")
+			second := signAndVerify(t, router, wallet, "0x74657374")
+			assert.NotEqual(t, first[:32], second[:32], "Each ceremony should use a new nonce")
+			if tt.algorithm != algorithmECDSA {
+				return
+			}
+
+			// Neither is the RFC 6979 signature of the whole key
+			signers, err := signingQuorum(wallet, nil)
+			assert.NoError(t, err)
+			key, err := reconstructPrivateKey(wallet, signers)
+			if !assert.NoError(t, err) {
+				return
+			}
+			deterministic, err := crypto.Sign(crypto.Keccak256([]byte("test")), key)
+			assert.NoError(t, err)
+			assert.NotEqual(t, deterministic[:32], first[:32])
+			assert.NotEqual(t, deterministic[:32], second[:32])
+		})
+	}
+}
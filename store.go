@@ -0,0 +1,375 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	tsscrypto "github.com/bnb-chain/tss-lib/crypto"
+	"github.com/bnb-chain/tss-lib/ecdsa/keygen"
+	eddsakeygen "github.com/bnb-chain/tss-lib/eddsa/keygen"
+	"github.com/bnb-chain/tss-lib/tss"
+)
+
+// WalletStore persists wallets, including their key shares, so they survive a restart
+type WalletStore interface {
+	// Save writes the wallet, replacing any previous copy with the same address
+	Save(wallet *Wallet) error
+	// Delete removes the wallet with the given address
+	Delete(address string) error
+	// LoadAll returns every persisted wallet
+	LoadAll() ([]*Wallet, error)
+}
+
+// store is the persistence layer in use, nil when persistence is disabled
+var store WalletStore
+
+// storedPartyID is the serializable form of a tss.PartyID
+type storedPartyID struct {
+	ID      string `json:"id"`
+	Moniker string `json:"moniker"`
+	Key     string `json:"key"`
+}
+
+// storedWallet is the serializable form of a Wallet. The public key is not
+// stored since it is recomputed from the key shares when loading. The key
+// shares of EdDSA wallets are kept in EdDSASaveData, and when an encryption
+// key is configured the key shares are only kept in EncryptedSaveData.
+type storedWallet struct {
+	Address           string                                     `json:"address"`
+	PartyIDs          []storedPartyID                            `json:"partyIds"`
+	Parties           int                                        `json:"parties"`
+	Threshold         int                                        `json:"threshold"`
+	Algorithm         string                                     `json:"algorithm,omitempty"`
+	Curve             tss.CurveName                              `json:"curve,omitempty"`
+	AddressType       string                                     `json:"addressType,omitempty"`
+	Addresses         map[string]string                          `json:"addresses,omitempty"`
+	Label             string                                     `json:"label,omitempty"`
+	Metadata          map[string]string                          `json:"metadata,omitempty"`
+	Policy            *SigningPolicy                             `json:"policy,omitempty"`
+	CreatedAt         *time.Time                                 `json:"createdAt,omitempty"`
+	LastSignedAt      *time.Time                                 `json:"lastSignedAt,omitempty"`
+	SaveData          map[string]*keygen.LocalPartySaveData      `json:"saveData,omitempty"`
+	EdDSASaveData     map[string]*eddsakeygen.LocalPartySaveData `json:"eddsaSaveData,omitempty"`
+	EncryptedSaveData []byte                                     `json:"encryptedSaveData,omitempty"`
+}
+
+// newStoredWallet converts a wallet into its serializable form, encrypting the
+// key shares when a cipher is given
+func newStoredWallet(wallet *Wallet, sc *shareCipher) (*storedWallet, error) {
+	partyIDs := make([]storedPartyID, len(wallet.PartyIDs))
+	for i, partyID := range wallet.PartyIDs {
+		partyIDs[i] = storedPartyID{
+			ID:      partyID.Id,
+			Moniker: partyID.Moniker,
+			Key:     partyID.KeyInt().Text(16),
+		}
+	}
+	sw := &storedWallet{
+		Address:      wallet.Address,
+		PartyIDs:     partyIDs,
+		Parties:      wallet.Parties,
+		Threshold:    wallet.Threshold,
+		Algorithm:    wallet.Algorithm,
+		Curve:        wallet.Curve,
+		AddressType:  wallet.AddressType,
+		Addresses:    wallet.Addresses,
+		Label:        wallet.Label,
+		Metadata:     wallet.Metadata,
+		Policy:       wallet.Policy,
+		CreatedAt:    optionalTime(wallet.CreatedAt),
+		LastSignedAt: optionalTime(wallet.LastSignedAt()),
+	}
+	if sc == nil {
+		sw.SaveData = wallet.SaveData
+		sw.EdDSASaveData = wallet.EdDSASaveData
+		return sw, nil
+	}
+
+	var shares any = wallet.SaveData
+	if wallet.Algorithm == algorithmEdDSA {
+		shares = wallet.EdDSASaveData
+	}
+	plaintext, err := json.Marshal(shares)
+	if err != nil {
+		return nil, fmt.Errorf("failed to serialize SaveData: %w", err)
+	}
+	sw.EncryptedSaveData, err = sc.Encrypt(plaintext)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encrypt SaveData: %w", err)
+	}
+	return sw, nil
+}
+
+// toWallet rebuilds the wallet, decrypting the key shares if needed, sorting
+// the party IDs and recomputing the public key
+func (sw *storedWallet) toWallet(sc *shareCipher) (*Wallet, error) {
+	return sw.rebuild(sc, false)
+}
+
+// rebuild rebuilds the wallet like toWallet. partial lets parties go without
+// a key share, as in the checkpoint of a keygen, as long as one party has one.
+func (sw *storedWallet) rebuild(sc *shareCipher, partial bool) (*Wallet, error) {
+	if err := sw.decryptShares(sc); err != nil {
+		return nil, err
+	}
+
+	// Wallets stored before algorithms and curves could be chosen are all
+	// ECDSA wallets on secp256k1
+	algorithm, curveName, curve, err := resolveAlgorithm(sw.Algorithm, string(sw.Curve))
+	if err != nil {
+		return nil, err
+	}
+
+	partyIDs, err := sw.partyIDs()
+	if err != nil {
+		return nil, err
+	}
+
+	// Every share must belong to its party and to the same public key
+	var ecdsaPub *tsscrypto.ECPoint
+	for _, partyID := range partyIDs {
+		shareID, sharePub := sw.sharePublic(algorithm, partyID.Id)
+		if sharePub == nil && partial {
+			continue
+		}
+		if sharePub == nil {
+			return nil, fmt.Errorf("missing SaveData for party %s", partyID.Id)
+		}
+		if shareID == nil || shareID.Cmp(partyID.KeyInt()) != 0 {
+			return nil, fmt.Errorf("SaveData for party %s belongs to another party", partyID.Id)
+		}
+		if ecdsaPub == nil {
+			ecdsaPub = sharePub
+		} else if !ecdsaPub.Equals(sharePub) {
+			return nil, errors.New("key shares do not agree on the public key")
+		}
+	}
+	if ecdsaPub == nil {
+		return nil, errors.New("missing SaveData")
+	}
+	pubKey := ecdsaPub.ToECDSAPubKey()
+	pubKey.Curve = curve
+
+	// Addresses are recomputed like the public key, wallets stored before
+	// address types existed only have an Ethereum address
+	addressType := sw.AddressType
+	if addressType == "" {
+		addressType = addressEthereum
+	}
+	if err := validateAddressType(addressType, curveName); err != nil {
+		return nil, err
+	}
+	addresses, err := deriveAddresses(pubKey, addressType)
+	if err != nil {
+		return nil, err
+	}
+
+	wallet := &Wallet{
+		Address:       sw.Address,
+		PartyIDs:      tss.SortPartyIDs(partyIDs),
+		Parties:       sw.Parties,
+		Threshold:     sw.Threshold,
+		Curve:         curveName,
+		PubKey:        pubKey,
+		Algorithm:     algorithm,
+		SaveData:      sw.SaveData,
+		EdDSASaveData: sw.EdDSASaveData,
+		AddressType:   addressType,
+		Addresses:     addresses,
+		Label:         sw.Label,
+		Metadata:      sw.Metadata,
+		Policy:        sw.Policy,
+	}
+	if sw.CreatedAt != nil {
+		wallet.CreatedAt = sw.CreatedAt.UTC()
+	}
+	if sw.LastSignedAt != nil {
+		wallet.setLastSignedAt(*sw.LastSignedAt)
+	}
+	return wallet, nil
+}
+
+// decryptShares fills SaveData or EdDSASaveData from EncryptedSaveData, if
+// the key shares are encrypted
+func (sw *storedWallet) decryptShares(sc *shareCipher) error {
+	if sw.EncryptedSaveData == nil {
+		return nil
+	}
+	if sc == nil {
+		return fmt.Errorf("wallet is encrypted but %s is not set", encryptionKeyEnv)
+	}
+	plaintext, err := sc.Decrypt(sw.EncryptedSaveData)
+	if err != nil {
+		return err
+	}
+	var shares any = &sw.SaveData
+	if sw.Algorithm == algorithmEdDSA {
+		shares = &sw.EdDSASaveData
+	}
+	if err := json.Unmarshal(plaintext, shares); err != nil {
+		return fmt.Errorf("failed to parse SaveData: %w", err)
+	}
+	return nil
+}
+
+// partyIDs rebuilds the unsorted party IDs of the wallet
+func (sw *storedWallet) partyIDs() (tss.UnSortedPartyIDs, error) {
+	partyIDs := make(tss.UnSortedPartyIDs, len(sw.PartyIDs))
+	for i, partyID := range sw.PartyIDs {
+		key, ok := new(big.Int).SetString(partyID.Key, 16)
+		if !ok {
+			return nil, fmt.Errorf("invalid key for party %s", partyID.ID)
+		}
+		partyIDs[i] = tss.NewPartyID(partyID.ID, partyID.Moniker, key)
+	}
+	return partyIDs, nil
+}
+
+// sharePublic returns the share ID and public key held by the key share of a
+// party, nil when the wallet has no share for it
+func (sw *storedWallet) sharePublic(algorithm, partyID string) (*big.Int, *tsscrypto.ECPoint) {
+	if algorithm == algorithmEdDSA {
+		if saveData, exists := sw.EdDSASaveData[partyID]; exists {
+			return saveData.ShareID, saveData.EDDSAPub
+		}
+		return nil, nil
+	}
+	if saveData, exists := sw.SaveData[partyID]; exists {
+		return saveData.ShareID, saveData.ECDSAPub
+	}
+	return nil, nil
+}
+
+// fileStore keeps one JSON file per wallet inside a directory. Key shares are
+// encrypted when a cipher is configured.
+type fileStore struct {
+	dir    string
+	cipher *shareCipher
+}
+
+// newFileStore creates a file store, creating the directory if needed. A nil
+// cipher stores key shares in plaintext.
+func newFileStore(dir string, sc *shareCipher) (*fileStore, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create data dir: %w", err)
+	}
+	return &fileStore{dir: dir, cipher: sc}, nil
+}
+
+func (fs *fileStore) path(address string) string {
+	return filepath.Join(fs.dir, address+".json")
+}
+
+// Save writes the wallet to a temporary file first so a crash never leaves a
+// half-written wallet behind. It returns once the file and its rename are
+// synced to disk.
+func (fs *fileStore) Save(wallet *Wallet) error {
+	sw, err := newStoredWallet(wallet, fs.cipher)
+	if err != nil {
+		return err
+	}
+	payload, err := json.Marshal(sw)
+	if err != nil {
+		return fmt.Errorf("failed to serialize wallet: %w", err)
+	}
+	tmpPath := fs.path(wallet.Address) + ".tmp"
+	if err := writeFileSync(tmpPath, payload, 0o600); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to write wallet: %w", err)
+	}
+	if err := os.Rename(tmpPath, fs.path(wallet.Address)); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to write wallet: %w", err)
+	}
+	if err := syncDir(fs.dir); err != nil {
+		return fmt.Errorf("failed to write wallet: %w", err)
+	}
+	return nil
+}
+
+// writeFileSync writes the file and flushes it to disk before returning
+func writeFileSync(name string, data []byte, perm os.FileMode) error {
+	f, err := os.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// syncDir flushes the entries of a directory, so that a file renamed into it
+// is still there after a crash
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	if err := d.Sync(); err != nil {
+		d.Close()
+		return err
+	}
+	return d.Close()
+}
+
+func (fs *fileStore) Delete(address string) error {
+	err := os.Remove(fs.path(address))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to delete wallet: %w", err)
+	}
+	return nil
+}
+
+func (fs *fileStore) LoadAll() ([]*Wallet, error) {
+	entries, err := os.ReadDir(fs.dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read data dir: %w", err)
+	}
+
+	loaded := make([]*Wallet, 0, len(entries))
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".json") {
+			continue
+		}
+		payload, err := os.ReadFile(filepath.Join(fs.dir, entry.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", entry.Name(), err)
+		}
+		var sw storedWallet
+		if err := json.Unmarshal(payload, &sw); err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", entry.Name(), err)
+		}
+		wallet, err := sw.toWallet(fs.cipher)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load %s: %w", entry.Name(), err)
+		}
+		loaded = append(loaded, wallet)
+	}
+	return loaded, nil
+}
+
+// loadWallets fills the wallets map with every wallet found in the store
+func loadWallets(s WalletStore) error {
+	loaded, err := s.LoadAll()
+	if err != nil {
+		return err
+	}
+	walletsMutex.Lock()
+	defer walletsMutex.Unlock()
+	for _, wallet := range loaded {
+		wallets[wallet.Address] = wallet
+	}
+	return nil
+}
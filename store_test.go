@@ -0,0 +1,606 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFileStoreReloadAndSign(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	useDeterministicKeygen(t, "reload and sign")
+
+	dataDir := t.TempDir()
+	fileStore, err := newFileStore(dataDir, nil)
+	if err != nil {
+		t.Fatalf("Failed to create file store: %v", err)
+	}
+	store = fileStore
+	t.Cleanup(func() { store = nil })
+
+	router := gin.Default()
+	router.POST("/wallet", createWallet)
+	router.POST("/sign", signData)
+
+	// Create a wallet
+	w1 := httptest.NewRecorder()
+	req1, _ := http.NewRequest("POST", "/wallet", nil)
+	router.ServeHTTP(w1, req1)
+	assert.Equal(t, http.StatusOK, w1.Code)
+
+	var createResponse stringFields
+	err = decodeData(w1.Body.Bytes(), &createResponse)
+	if err != nil {
+		t.Fatalf("Failed to parse create wallet response: %v", err)
+	}
+	walletAddress := createResponse["address"]
+
+	info, err := os.Stat(filepath.Join(dataDir, walletAddress+".json"))
+	assert.NoError(t, err, "Wallet should be written to the data dir")
+	assert.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+
+	// Simulate a restart by dropping the in-memory wallets and reloading them
+	walletsMutex.Lock()
+	created := wallets[walletAddress]
+	previousWallets := wallets
+	wallets = make(map[string]*Wallet)
+	walletsMutex.Unlock()
+	t.Cleanup(func() {
+		walletsMutex.Lock()
+		wallets = previousWallets
+		walletsMutex.Unlock()
+	})
+
+	reloadedStore, err := newFileStore(dataDir, nil)
+	if err != nil {
+		t.Fatalf("Failed to reopen file store: %v", err)
+	}
+	err = loadWallets(reloadedStore)
+	assert.NoError(t, err)
+
+	walletsMutex.Lock()
+	reloaded, exists := wallets[walletAddress]
+	walletsMutex.Unlock()
+	if !exists {
+		t.Fatalf("Wallet %s should be reloaded from disk", walletAddress)
+	}
+	assert.Equal(t, created.Parties, reloaded.Parties)
+	assert.Equal(t, created.Threshold, reloaded.Threshold)
+	assert.Equal(t, crypto.FromECDSAPub(created.PubKey), crypto.FromECDSAPub(reloaded.PubKey))
+	for i, partyID := range created.PartyIDs {
+		assert.Equal(t, partyID.Id, reloaded.PartyIDs[i].Id)
+		assert.Equal(t, partyID.Key, reloaded.PartyIDs[i].Key)
+		assert.Equal(t, 0, created.SaveData[partyID.Id].Xi.Cmp(reloaded.SaveData[partyID.Id].Xi))
+	}
+
+	// Sign with the reloaded wallet
+	digest := crypto.Keccak256([]byte("reload"))
+	requestBody := signDataRequest{
+		Data:   "0x" + hex.EncodeToString(digest),
+		Wallet: walletAddress,
+		Hash:   hashNone,
+	}
+	jsonBody, _ := json.Marshal(requestBody)
+
+	w2 := httptest.NewRecorder()
+	req2, _ := http.NewRequest("POST", "/sign", bytes.NewBuffer(jsonBody))
+	req2.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w2, req2)
+	assert.Equal(t, http.StatusOK, w2.Code)
+
+	var signResponse stringFields
+	err = decodeData(w2.Body.Bytes(), &signResponse)
+	if err != nil {
+		t.Fatalf("Failed to parse sign data response: %v", err)
+	}
+	signature, err := hex.DecodeString(signResponse["signature"])
+	assert.NoError(t, err)
+	r := new(big.Int).SetBytes(signature[:32])
+	s := new(big.Int).SetBytes(signature[32:])
+	assert.True(t, ecdsa.Verify(reloaded.PubKey, digest, r, s), "Signature should verify after reload")
+}
+
+func TestFileStoreDelete(t *testing.T) {
+	fileStore, err := newFileStore(t.TempDir(), nil)
+	if err != nil {
+		t.Fatalf("Failed to create file store: %v", err)
+	}
+
+	path := fileStore.path("0xabc")
+	err = os.WriteFile(path, []byte("{}"), 0o600)
+	assert.NoError(t, err)
+
+	assert.NoError(t, fileStore.Delete("0xabc"))
+	_, err = os.Stat(path)
+	assert.True(t, os.IsNotExist(err), "Wallet file should be removed")
+
+	// Deleting a missing wallet is not an error
+	assert.NoError(t, fileStore.Delete("0xabc"))
+}
+
+func TestFileStoreEncryptedReloadAndSign(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	useDeterministicKeygen(t, "encrypted reload and sign")
+
+	dataDir := t.TempDir()
+	fileStore, err := newFileStore(dataDir, newShareCipher("passphrase"))
+	if err != nil {
+		t.Fatalf("Failed to create file store: %v", err)
+	}
+	store = fileStore
+	t.Cleanup(func() { store = nil })
+
+	router := gin.Default()
+	router.POST("/wallet", createWallet)
+	router.POST("/sign", signData)
+
+	// Create a wallet
+	jsonBody, _ := json.Marshal(createWalletRequest{Parties: 2, Threshold: 1})
+	w1 := httptest.NewRecorder()
+	req1, _ := http.NewRequest("POST", "/wallet", bytes.NewBuffer(jsonBody))
+	req1.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w1, req1)
+	assert.Equal(t, http.StatusOK, w1.Code)
+
+	var createResponse stringFields
+	err = decodeData(w1.Body.Bytes(), &createResponse)
+	if err != nil {
+		t.Fatalf("Failed to parse create wallet response: %v", err)
+	}
+	walletAddress := createResponse["address"]
+
+	// The share material must not be stored in plaintext
+	payload, err := os.ReadFile(filepath.Join(dataDir, walletAddress+".json"))
+	assert.NoError(t, err)
+	var sw storedWallet
+	assert.NoError(t, json.Unmarshal(payload, &sw))
+	assert.Nil(t, sw.SaveData)
+	assert.NotEmpty(t, sw.EncryptedSaveData)
+	assert.NotContains(t, string(payload), "PaillierSK")
+
+	// A wrong passphrase or no passphrase at all fails to load
+	wrongStore, err := newFileStore(dataDir, newShareCipher("wrong"))
+	assert.NoError(t, err)
+	_, err = wrongStore.LoadAll()
+	assert.ErrorIs(t, err, errDecryptionFailed)
+
+	plainStore, err := newFileStore(dataDir, nil)
+	assert.NoError(t, err)
+	_, err = plainStore.LoadAll()
+	assert.Error(t, err)
+
+	// The right passphrase yields a usable wallet
+	walletsMutex.Lock()
+	previousWallets := wallets
+	wallets = make(map[string]*Wallet)
+	walletsMutex.Unlock()
+	t.Cleanup(func() {
+		walletsMutex.Lock()
+		wallets = previousWallets
+		walletsMutex.Unlock()
+	})
+
+	rightStore, err := newFileStore(dataDir, newShareCipher("passphrase"))
+	assert.NoError(t, err)
+	err = loadWallets(rightStore)
+	assert.NoError(t, err)
+
+	digest := crypto.Keccak256([]byte("encrypted"))
+	requestBody := signDataRequest{
+		Data:   "0x" + hex.EncodeToString(digest),
+		Wallet: walletAddress,
+		Hash:   hashNone,
+	}
+	jsonBody, _ = json.Marshal(requestBody)
+
+	w2 := httptest.NewRecorder()
+	req2, _ := http.NewRequest("POST", "/sign", bytes.NewBuffer(jsonBody))
+	req2.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w2, req2)
+	assert.Equal(t, http.StatusOK, w2.Code)
+}
+
+func TestDeleteWalletRemovesPersistedCopy(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	fileStore, err := newFileStore(t.TempDir(), nil)
+	if err != nil {
+		t.Fatalf("Failed to create file store: %v", err)
+	}
+	store = fileStore
+	t.Cleanup(func() { store = nil })
+
+	router := gin.Default()
+	router.DELETE("/wallet/:address", deleteWallet)
+
+	address := "0x00000000000000000000000000000000000000D3"
+	addFakeWallet(address)
+	path := fileStore.path(address)
+	err = os.WriteFile(path, []byte("{}"), 0o600)
+	assert.NoError(t, err)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("DELETE", "/wallet/"+address, nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNoContent, w.Code)
+
+	_, err = os.Stat(path)
+	assert.True(t, os.IsNotExist(err), "Persisted wallet should be removed")
+}
+
+// useLastSignedWriter gives the test a writer of last signing times of its
+// own, so it only flushes the wallets it signed with
+func useLastSignedWriter(t *testing.T) {
+	previous := lastSignedTimes
+	lastSignedTimes = newLastSignedWriter()
+	t.Cleanup(func() { lastSignedTimes = previous })
+}
+
+func TestSignDataUpdatesLastSignedAt(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	useDeterministicKeygen(t, "last signed at")
+
+	fileStore, err := newFileStore(t.TempDir(), nil)
+	if err != nil {
+		t.Fatalf("Failed to create file store: %v", err)
+	}
+	store = fileStore
+	t.Cleanup(func() { store = nil })
+	useLastSignedWriter(t)
+
+	router := gin.Default()
+	router.POST("/wallet", createWallet)
+	router.GET("/wallet/:address", getWallet)
+	router.POST("/sign", signData)
+
+	before := time.Now()
+	w1 := httptest.NewRecorder()
+	req1, _ := http.NewRequest("POST", "/wallet", nil)
+	router.ServeHTTP(w1, req1)
+	assert.Equal(t, http.StatusOK, w1.Code)
+
+	var createResponse stringFields
+	err = decodeData(w1.Body.Bytes(), &createResponse)
+	if err != nil {
+		t.Fatalf("Failed to parse create wallet response: %v", err)
+	}
+	walletAddress := createResponse["address"]
+
+	getWalletResponse := func() walletResponse {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/wallet/"+walletAddress, nil)
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+		var response walletResponse
+		err := decodeData(w.Body.Bytes(), &response)
+		if err != nil {
+			t.Fatalf("Failed to parse get wallet response: %v", err)
+		}
+		return response
+	}
+
+	created := getWalletResponse()
+	if assert.NotNil(t, created.CreatedAt) {
+		assert.False(t, created.CreatedAt.Before(before.Truncate(time.Second)))
+	}
+	assert.Nil(t, created.LastSignedAt, "A new wallet has never signed")
+
+	jsonBody, _ := json.Marshal(signDataRequest{Data: "0x74657374", Wallet: walletAddress})
+	w2 := httptest.NewRecorder()
+	req2, _ := http.NewRequest("POST", "/sign", bytes.NewBuffer(jsonBody))
+	req2.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w2, req2)
+	assert.Equal(t, http.StatusOK, w2.Code)
+
+	signed := getWalletResponse()
+	if !assert.NotNil(t, signed.LastSignedAt) {
+		return
+	}
+	assert.True(t, signed.LastSignedAt.After(*created.CreatedAt))
+	assert.Equal(t, created.CreatedAt, signed.CreatedAt)
+
+	// Both timestamps survive a reload once the signing was persisted
+	lastSignedTimes.flush()
+	loaded, err := fileStore.LoadAll()
+	if !assert.NoError(t, err) || !assert.Len(t, loaded, 1) {
+		return
+	}
+	assert.True(t, loaded[0].CreatedAt.Equal(*created.CreatedAt))
+	assert.True(t, loaded[0].LastSignedAt().Equal(*signed.LastSignedAt))
+}
+
+// slowStore delays the writes of a store and records whether a new wallet
+// was already registered while it was first saved
+type slowStore struct {
+	WalletStore
+	delay   time.Duration
+	fail    bool
+	saved   map[string]bool
+	visible []bool
+}
+
+// Save of a new wallet runs with walletsMutex held by addWallet, so wallets
+// can be read
+func (s *slowStore) Save(wallet *Wallet) error {
+	if !s.saved[wallet.Address] {
+		_, exists := wallets[wallet.Address]
+		s.visible = append(s.visible, exists)
+		s.saved[wallet.Address] = true
+	}
+	time.Sleep(s.delay)
+	if s.fail {
+		return errors.New("disk full")
+	}
+	return s.WalletStore.Save(wallet)
+}
+
+func TestSignImmediatelyAfterCreate(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	useDeterministicKeygen(t, "sign after create")
+
+	dataDir := t.TempDir()
+	fileStore, err := newFileStore(dataDir, nil)
+	if err != nil {
+		t.Fatalf("Failed to create file store: %v", err)
+	}
+	slow := &slowStore{WalletStore: fileStore, delay: 50 * time.Millisecond, saved: make(map[string]bool)}
+	store = slow
+	t.Cleanup(func() { store = nil })
+
+	router := gin.Default()
+	router.POST("/wallet", createWallet)
+	router.POST("/sign", signData)
+
+	for i := 0; i < 3; i++ {
+		w1 := httptest.NewRecorder()
+		req1, _ := http.NewRequest("POST", "/wallet", strings.NewReader(`{"parties": 2, "threshold": 1}`))
+		req1.Header.Set("Content-Type", "application/json")
+		router.ServeHTTP(w1, req1)
+		if !assert.Equal(t, http.StatusOK, w1.Code, w1.Body.String()) {
+			return
+		}
+		var createResponse stringFields
+		if err := decodeData(w1.Body.Bytes(), &createResponse); err != nil {
+			t.Fatalf("Failed to parse create wallet response: %v", err)
+		}
+		walletAddress := createResponse["address"]
+		t.Cleanup(func() {
+			walletsMutex.Lock()
+			delete(wallets, walletAddress)
+			walletsMutex.Unlock()
+		})
+
+		// The wallet is on disk as soon as the creation returns
+		_, err := os.Stat(filepath.Join(dataDir, walletAddress+".json"))
+		assert.NoError(t, err, "Wallet should be persisted before the creation returns")
+
+		jsonBody, _ := json.Marshal(signDataRequest{Data: "0x74657374", Wallet: walletAddress})
+		w2 := httptest.NewRecorder()
+		req2, _ := http.NewRequest("POST", "/sign", bytes.NewBuffer(jsonBody))
+		req2.Header.Set("Content-Type", "application/json")
+		router.ServeHTTP(w2, req2)
+		assert.Equal(t, http.StatusOK, w2.Code, w2.Body.String())
+	}
+	assert.Equal(t, []bool{false, false, false}, slow.visible, "A wallet should not be signable before it is saved")
+
+	// A failed write leaves no wallet to sign with
+	walletsMutex.Lock()
+	count := len(wallets)
+	walletsMutex.Unlock()
+	slow.fail = true
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/wallet", strings.NewReader(`{"parties": 2, "threshold": 1}`))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	walletsMutex.Lock()
+	assert.Equal(t, count, len(wallets), "A wallet that failed to persist should not be registered")
+	walletsMutex.Unlock()
+}
+
+// blockingStore holds the writes of a store until release is closed, the
+// address of each held write is sent on saving
+type blockingStore struct {
+	WalletStore
+	saving  chan string
+	release chan struct{}
+}
+
+func (s *blockingStore) Save(wallet *Wallet) error {
+	s.saving <- wallet.Address
+	<-s.release
+	return s.WalletStore.Save(wallet)
+}
+
+func TestWalletsAvailableWhileSigningPersists(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	key, _ := crypto.GenerateKey()
+	useInstantSigning(t, key)
+	address := deriveAddress(&key.PublicKey)
+	wallet := addFakeWallet(address)
+	wallet.PubKey = &key.PublicKey
+	t.Cleanup(func() {
+		walletsMutex.Lock()
+		delete(wallets, address)
+		walletsMutex.Unlock()
+	})
+
+	dataDir := t.TempDir()
+	fileStore, err := newFileStore(dataDir, nil)
+	if err != nil {
+		t.Fatalf("Failed to create file store: %v", err)
+	}
+	blocking := &blockingStore{WalletStore: fileStore, saving: make(chan string, 1), release: make(chan struct{})}
+	store = blocking
+	t.Cleanup(func() { store = nil })
+	useLastSignedWriter(t)
+
+	// Signing does not wait for the last signing time to be written
+	_, err = walletService.Sign(signDataRequest{Data: "0x74657374", Wallet: address}, nil)
+	assert.NoError(t, err)
+	select {
+	case <-blocking.saving:
+		t.Fatal("Signing should not persist the wallet")
+	default:
+	}
+
+	flushed := make(chan struct{})
+	go func() {
+		defer close(flushed)
+		lastSignedTimes.flush()
+	}()
+	select {
+	case saved := <-blocking.saving:
+		assert.Equal(t, address, saved)
+	case <-time.After(5 * time.Second):
+		t.Fatal("The signing was not persisted")
+	}
+
+	// While the last signing time is being written, wallets can still be
+	// listed and looked up
+	listed := make(chan struct{})
+	go func() {
+		defer close(listed)
+		page, _, err := walletService.ListWallets(walletQuery{Limit: maxListLimit})
+		assert.NoError(t, err)
+		assert.NotEmpty(t, page)
+		_, err = lookupWallet(address)
+		assert.NoError(t, err)
+	}()
+	select {
+	case <-listed:
+	case <-time.After(2 * time.Second):
+		close(blocking.release)
+		t.Fatal("Listing wallets should not wait for a signing to be persisted")
+	}
+
+	// A deletion waits for the write instead, so the wallet is not written
+	// back once deleted
+	router := gin.Default()
+	router.DELETE("/wallet/:address", deleteWallet)
+	deleted := make(chan int, 1)
+	go func() {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("DELETE", "/wallet/"+address, nil)
+		router.ServeHTTP(w, req)
+		deleted <- w.Code
+	}()
+	select {
+	case <-deleted:
+		t.Fatal("The deletion should wait for the write of the wallet")
+	case <-time.After(100 * time.Millisecond):
+	}
+	close(blocking.release)
+	<-flushed
+	assert.Equal(t, http.StatusNoContent, <-deleted)
+	_, err = os.Stat(filepath.Join(dataDir, address+".json"))
+	assert.ErrorIs(t, err, os.ErrNotExist, "The deleted wallet should not be persisted")
+}
+
+func TestWalletsAvailableWhileAdding(t *testing.T) {
+	existing := addFakeWallet("0x3333333333333333333333333333333333333333")
+	added := addFakeWallet("0x4444444444444444444444444444444444444444")
+	walletsMutex.Lock()
+	delete(wallets, added.Address)
+	walletsMutex.Unlock()
+	t.Cleanup(func() {
+		walletsMutex.Lock()
+		delete(wallets, existing.Address)
+		delete(wallets, added.Address)
+		walletsMutex.Unlock()
+	})
+
+	fileStore, err := newFileStore(t.TempDir(), nil)
+	if err != nil {
+		t.Fatalf("Failed to create file store: %v", err)
+	}
+	blocking := &blockingStore{WalletStore: fileStore, saving: make(chan string, 1), release: make(chan struct{})}
+	store = blocking
+	t.Cleanup(func() { store = nil })
+
+	addErr := make(chan error, 1)
+	go func() { addErr <- addWallet(added) }()
+	select {
+	case saved := <-blocking.saving:
+		assert.Equal(t, added.Address, saved)
+	case <-time.After(5 * time.Second):
+		t.Fatal("The wallet was not persisted")
+	}
+
+	// While the new wallet is being written, the other wallets can still be
+	// listed and looked up, and the new one is not registered yet
+	listed := make(chan struct{})
+	go func() {
+		defer close(listed)
+		_, err := lookupWallet(existing.Address)
+		assert.NoError(t, err)
+		_, err = lookupWallet(added.Address)
+		assert.Error(t, err, "A wallet should not be registered before it is persisted")
+		_, _, err = walletService.ListWallets(walletQuery{Limit: maxListLimit})
+		assert.NoError(t, err)
+	}()
+	select {
+	case <-listed:
+	case <-time.After(2 * time.Second):
+		close(blocking.release)
+		t.Fatal("Looking up wallets should not wait for a wallet to be persisted")
+	}
+
+	close(blocking.release)
+	assert.NoError(t, <-addErr)
+	_, err = lookupWallet(added.Address)
+	assert.NoError(t, err)
+}
+
+func TestLastSignedWriterRun(t *testing.T) {
+	wallet := addFakeWallet("0x6666666666666666666666666666666666666666")
+	t.Cleanup(func() {
+		walletsMutex.Lock()
+		delete(wallets, wallet.Address)
+		walletsMutex.Unlock()
+	})
+	dataDir := t.TempDir()
+	fileStore, err := newFileStore(dataDir, nil)
+	if err != nil {
+		t.Fatalf("Failed to create file store: %v", err)
+	}
+	store = fileStore
+	t.Cleanup(func() { store = nil })
+	useLastSignedWriter(t)
+	previousInterval := lastSignedFlushInterval
+	lastSignedFlushInterval = 10 * time.Millisecond
+	t.Cleanup(func() { lastSignedFlushInterval = previousInterval })
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go lastSignedTimes.run(ctx)
+
+	// Signatures made in a row are persisted together on the next tick
+	for i := 0; i < 3; i++ {
+		recordSigning(wallet)
+	}
+	assert.Eventually(t, func() bool {
+		payload, err := os.ReadFile(filepath.Join(dataDir, wallet.Address+".json"))
+		if err != nil {
+			return false
+		}
+		var persisted storedWallet
+		return json.Unmarshal(payload, &persisted) == nil && persisted.LastSignedAt != nil && persisted.LastSignedAt.Equal(wallet.LastSignedAt())
+	}, 5*time.Second, 10*time.Millisecond, "The last signing time should be persisted in the background")
+}
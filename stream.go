@@ -0,0 +1,158 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bnb-chain/tss-lib/tss"
+	"github.com/gin-gonic/gin"
+)
+
+// streamSSE is the stream query parameter value asking for Server-Sent Events
+const streamSSE = "sse"
+
+// roundPattern extracts the round number from a tss-lib message type, e.g.
+// binance.tsslib.ecdsa.keygen.KGRound2Message1
+var roundPattern = regexp.MustCompile(`Round(\d+)Message`)
+
+// roundProgress reports the rounds of a ceremony as they complete. A round is
+// complete once every party has sent its messages for it. The time each
+// round took is recorded in the round duration metric and logged once the
+// ceremony is over.
+type roundProgress struct {
+	algorithm string
+	operation string
+	parties   int
+	senders   map[int]map[string]bool
+	reported  map[int]bool
+	onRound   func(round int)
+	// completed is when the last round completed, or the ceremony started
+	completed time.Time
+	timings   []roundTiming
+}
+
+// roundTiming is how long a round of a ceremony took
+type roundTiming struct {
+	round    int
+	duration time.Duration
+}
+
+// newRoundProgress tracks the rounds of a ceremony between the given number
+// of parties, onRound may be nil
+func newRoundProgress(algorithm, operation string, parties int, onRound func(round int)) *roundProgress {
+	return &roundProgress{
+		algorithm: algorithm,
+		operation: operation,
+		parties:   parties,
+		senders:   make(map[int]map[string]bool),
+		reported:  make(map[int]bool),
+		onRound:   onRound,
+		completed: time.Now(),
+	}
+}
+
+// observe records a message sent during the ceremony
+func (p *roundProgress) observe(msg tss.Message) {
+	match := roundPattern.FindStringSubmatch(msg.Type())
+	if match == nil {
+		return
+	}
+	round, err := strconv.Atoi(match[1])
+	if err != nil || p.reported[round] {
+		return
+	}
+	if p.senders[round] == nil {
+		p.senders[round] = make(map[string]bool, p.parties)
+	}
+	p.senders[round][string(msg.GetFrom().Key)] = true
+	if len(p.senders[round]) == p.parties {
+		p.reported[round] = true
+		now := time.Now()
+		duration := now.Sub(p.completed)
+		p.completed = now
+		p.timings = append(p.timings, roundTiming{round: round, duration: duration})
+		roundDurationSeconds.WithLabelValues(p.algorithm, p.operation, strconv.Itoa(round)).Observe(duration.Seconds())
+		if p.onRound != nil {
+			p.onRound(round)
+		}
+	}
+}
+
+// logTimings logs how long each round of the ceremony took
+func (p *roundProgress) logTimings() {
+	if len(p.timings) == 0 {
+		return
+	}
+	rounds := make([]string, len(p.timings))
+	for i, timing := range p.timings {
+		rounds[i] = fmt.Sprintf("round %d %s", timing.round, timing.duration.Round(time.Millisecond))
+	}
+	log.Printf("%s %s ceremony rounds: %s", p.algorithm, p.operation, strings.Join(rounds, ", "))
+}
+
+// ceremonyResult is the response of a ceremony run by streamCeremony
+type ceremonyResult struct {
+	status int
+	body   any
+}
+
+// streamCeremony runs a ceremony and writes its response. With ?stream=sse
+// the response is a stream of Server-Sent Events instead, a round event as
+// each protocol round completes and a final result or error event holding
+// the response body. A streamed ceremony may outlive the request once the
+// client is gone and c is reused by gin, so run must not use c: handlers read
+// what it needs from c beforehand.
+func streamCeremony(c *gin.Context, run func(onRound func(round int)) (int, any)) {
+	stream := c.Query("stream")
+	if stream == "" {
+		status, body := run(nil)
+		c.JSON(status, body)
+		return
+	}
+	if stream != streamSSE {
+		respondError(c, http.StatusBadRequest, "unsupported stream mode, use sse")
+		return
+	}
+
+	// Rounds are few, the buffer never fills up unless the client is gone
+	rounds := make(chan int, 32)
+	done := make(chan ceremonyResult, 1)
+	go func() {
+		status, body := run(func(round int) {
+			select {
+			case rounds <- round:
+			default:
+			}
+		})
+		done <- ceremonyResult{status: status, body: body}
+	}()
+
+	c.Header("Cache-Control", "no-cache")
+	c.Status(http.StatusOK)
+	for {
+		select {
+		case round := <-rounds:
+			c.SSEvent("round", gin.H{"round": round})
+			c.Writer.Flush()
+		case result := <-done:
+			// Every round was reported before the ceremony returned
+			for len(rounds) > 0 {
+				c.SSEvent("round", gin.H{"round": <-rounds})
+			}
+			event := "result"
+			if result.status != http.StatusOK {
+				event = "error"
+			}
+			c.SSEvent(event, result.body)
+			c.Writer.Flush()
+			return
+		case <-c.Request.Context().Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/ecdsa"
+	"encoding/hex"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+// sseEvent is an event read from a Server-Sent Events stream
+type sseEvent struct {
+	name string
+	data string
+}
+
+// readSSEvents parses the events of a Server-Sent Events stream
+func readSSEvents(t *testing.T, body string) []sseEvent {
+	t.Helper()
+	var events []sseEvent
+	var event sseEvent
+	scanner := bufio.NewScanner(strings.NewReader(body))
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case line == "":
+			if event.name != "" {
+				events = append(events, event)
+			}
+			event = sseEvent{}
+		case strings.HasPrefix(line, "event:"):
+			event.name = strings.TrimPrefix(line, "event:")
+		case strings.HasPrefix(line, "data:"):
+			event.data = strings.TrimPrefix(line, "data:")
+		}
+	}
+	return events
+}
+
+// assertRounds checks that the stream reported rounds in order from 1 and at
+// least up to the given round before its final event
+func assertRounds(t *testing.T, events []sseEvent, atLeast int) {
+	t.Helper()
+	var rounds []int
+	for _, event := range events[:len(events)-1] {
+		assert.Equal(t, "round", event.name)
+		var data map[string]int
+		assert.NoError(t, json.Unmarshal([]byte(event.data), &data))
+		rounds = append(rounds, data["round"])
+	}
+	assert.GreaterOrEqual(t, len(rounds), atLeast)
+	for i, round := range rounds {
+		assert.Equal(t, i+1, round, "Rounds should be reported in order")
+	}
+}
+
+func TestStreamKeygenAndSigning(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	router := gin.Default()
+	router.POST("/wallet", createWallet)
+	router.POST("/sign", signData)
+
+	jsonBody, _ := json.Marshal(createWalletRequest{Parties: 2, Threshold: 1})
+	w1 := httptest.NewRecorder()
+	req1, _ := http.NewRequest("POST", "/wallet?stream=sse", bytes.NewBuffer(jsonBody))
+	req1.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w1, req1)
+	assert.Equal(t, http.StatusOK, w1.Code)
+	assert.Equal(t, "text/event-stream", w1.Header().Get("Content-Type"))
+
+	events := readSSEvents(t, w1.Body.String())
+	if !assert.NotEmpty(t, events) {
+		return
+	}
+	result := events[len(events)-1]
+	assert.Equal(t, "result", result.name, "Stream should end with the result")
+	// Keygen parties send messages in rounds 1 to 3
+	assertRounds(t, events, 3)
+
+	var createResponse stringFields
+	err := decodeData([]byte(result.data), &createResponse)
+	if err != nil {
+		t.Fatalf("Failed to parse create wallet event: %v", err)
+	}
+	walletAddress := createResponse["address"]
+	walletsMutex.Lock()
+	wallet, exists := wallets[walletAddress]
+	walletsMutex.Unlock()
+	if !assert.True(t, exists, "Streamed wallet should be stored") {
+		return
+	}
+
+	digest := crypto.Keccak256([]byte("stream"))
+	jsonBody, _ = json.Marshal(signDataRequest{
+		Data:   "0x" + hex.EncodeToString(digest),
+		Wallet: walletAddress,
+		Hash:   hashNone,
+	})
+	w2 := httptest.NewRecorder()
+	req2, _ := http.NewRequest("POST", "/sign?stream=sse", bytes.NewBuffer(jsonBody))
+	req2.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w2, req2)
+	assert.Equal(t, http.StatusOK, w2.Code)
+
+	events = readSSEvents(t, w2.Body.String())
+	if !assert.NotEmpty(t, events) {
+		return
+	}
+	result = events[len(events)-1]
+	assert.Equal(t, "result", result.name, "Stream should end with the signature")
+	// Signing parties send messages in rounds 1 to 9, the ceremony may end
+	// on the first signature before the last messages of round 9 are seen
+	assertRounds(t, events, 8)
+
+	var signResponse stringFields
+	err = decodeData([]byte(result.data), &signResponse)
+	if err != nil {
+		t.Fatalf("Failed to parse sign data event: %v", err)
+	}
+	signature, err := hex.DecodeString(signResponse["signature"])
+	assert.NoError(t, err)
+	if assert.Len(t, signature, 64) {
+		r := new(big.Int).SetBytes(signature[:32])
+		s := new(big.Int).SetBytes(signature[32:])
+		assert.True(t, ecdsa.Verify(wallet.PubKey, digest, r, s), "Streamed signature should verify")
+	}
+}
+
+func TestStreamInvalidMode(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	router := gin.Default()
+	router.POST("/sign", signData)
+
+	address := "0x00000000000000000000000000000000000000E4"
+	addFakeWallet(address)
+	t.Cleanup(func() {
+		walletsMutex.Lock()
+		delete(wallets, address)
+		walletsMutex.Unlock()
+	})
+
+	jsonBody, _ := json.Marshal(signDataRequest{Message: "stream", Wallet: address})
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/sign?stream=websocket", bytes.NewBuffer(jsonBody))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+// writeSelfSignedCert writes a self-signed certificate and its key as PEM
+// files in dir and returns their paths along with the parsed certificate
+func writeSelfSignedCert(t *testing.T, dir, name string, usage x509.ExtKeyUsage) (certFile, keyFile string, cert tls.Certificate) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Minute),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{usage},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("Failed to create certificate: %v", err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("Failed to marshal key: %v", err)
+	}
+
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+	certFile = filepath.Join(dir, name+".crt")
+	keyFile = filepath.Join(dir, name+".key")
+	assert.NoError(t, os.WriteFile(certFile, certPEM, 0o600))
+	assert.NoError(t, os.WriteFile(keyFile, keyPEM, 0o600))
+	cert, err = tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		t.Fatalf("Failed to load certificate: %v", err)
+	}
+	return certFile, keyFile, cert
+}
+
+// serveTLS runs the router over TLS on a free port until the test ends and
+// returns its base URL
+func serveTLS(t *testing.T, router http.Handler, config *tls.Config) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		assert.NoError(t, serve(ctx, &http.Server{Handler: router, TLSConfig: config}, ln))
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+		ready.Store(true)
+	})
+	return "https://" + ln.Addr().String()
+}
+
+// tlsClient trusts the server certificate and presents the client
+// certificates, if any
+func tlsClient(t *testing.T, serverCertFile string, clientCerts ...tls.Certificate) *http.Client {
+	t.Helper()
+	serverPEM, err := os.ReadFile(serverCertFile)
+	if err != nil {
+		t.Fatalf("Failed to read server certificate: %v", err)
+	}
+	roots := x509.NewCertPool()
+	roots.AppendCertsFromPEM(serverPEM)
+	return &http.Client{Transport: &http.Transport{
+		TLSClientConfig: &tls.Config{RootCAs: roots, Certificates: clientCerts},
+	}}
+}
+
+func TestServeTLS(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	dir := t.TempDir()
+	certFile, keyFile, _ := writeSelfSignedCert(t, dir, "server", x509.ExtKeyUsageServerAuth)
+
+	config, err := newTLSConfig(certFile, keyFile, "")
+	if err != nil {
+		t.Fatalf("Failed to create TLS config: %v", err)
+	}
+	url := serveTLS(t, newRouter(nil), config)
+
+	resp, err := tlsClient(t, certFile).Get(url + "/wallets")
+	if err != nil {
+		t.Fatalf("Failed to reach server over TLS: %v", err)
+	}
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	if assert.NotNil(t, resp.TLS) {
+		assert.GreaterOrEqual(t, resp.TLS.Version, uint16(tls.VersionTLS12))
+	}
+
+	// Plain HTTP is not served
+	resp, err = http.Get("http" + url[len("https"):] + "/wallets")
+	if err == nil {
+		resp.Body.Close()
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	}
+}
+
+func TestServeMutualTLS(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	dir := t.TempDir()
+	certFile, keyFile, _ := writeSelfSignedCert(t, dir, "server", x509.ExtKeyUsageServerAuth)
+	clientCAFile, _, clientCert := writeSelfSignedCert(t, dir, "client", x509.ExtKeyUsageClientAuth)
+	_, _, unknownCert := writeSelfSignedCert(t, dir, "unknown", x509.ExtKeyUsageClientAuth)
+
+	config, err := newTLSConfig(certFile, keyFile, clientCAFile)
+	if err != nil {
+		t.Fatalf("Failed to create TLS config: %v", err)
+	}
+	previous := requireClientCerts
+	requireClientCerts = true
+	t.Cleanup(func() { requireClientCerts = previous })
+	url := serveTLS(t, newRouter(nil), config)
+
+	get := func(client *http.Client, path string) int {
+		resp, err := client.Get(url + path)
+		if err != nil {
+			return 0
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	withCert := tlsClient(t, certFile, clientCert)
+	withoutCert := tlsClient(t, certFile)
+	assert.Equal(t, http.StatusOK, get(withCert, "/wallets"))
+	assert.Equal(t, http.StatusUnauthorized, get(withoutCert, "/wallets"))
+	// A certificate from another CA is not accepted
+	assert.Equal(t, http.StatusUnauthorized, get(tlsClient(t, certFile, unknownCert), "/wallets"))
+
+	// Probes do not need a client certificate
+	assert.Equal(t, http.StatusOK, get(withoutCert, "/health"))
+}
+
+func TestNewTLSConfigInvalid(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile, _ := writeSelfSignedCert(t, dir, "server", x509.ExtKeyUsageServerAuth)
+	notPEM := filepath.Join(dir, "ca.txt")
+	assert.NoError(t, os.WriteFile(notPEM, []byte("not a certificate"), 0o600))
+
+	_, err := newTLSConfig(certFile, "", "")
+	assert.Error(t, err, "A key should be required")
+	_, err = newTLSConfig(certFile, certFile, "")
+	assert.Error(t, err, "A certificate is not a key")
+	_, err = newTLSConfig(certFile, keyFile, notPEM)
+	assert.Error(t, err, "The client CA file should hold a certificate")
+	_, err = newTLSConfig(certFile, keyFile, filepath.Join(dir, "missing.pem"))
+	assert.Error(t, err)
+}
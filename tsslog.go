@@ -0,0 +1,131 @@
+package main
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/bnb-chain/tss-lib/common"
+	golog "github.com/ipfs/go-log"
+)
+
+// tssLogLevelEnv names the environment variable holding the level of the
+// tss-lib messages kept in the log, the --tss-log-level flag takes precedence
+// over it
+const tssLogLevelEnv = "TSS_LOG_LEVEL"
+
+// defaultTSSLogLevel keeps the warnings and errors of tss-lib, its info and
+// debug messages describe every round of every party
+const defaultTSSLogLevel = "warning"
+
+// logLevel orders the severities of tss-lib messages
+type logLevel int
+
+const (
+	levelDebug logLevel = iota
+	levelInfo
+	levelWarning
+	levelError
+)
+
+// logLevelNames are the level names accepted in the configuration
+var logLevelNames = map[string]logLevel{
+	"debug":   levelDebug,
+	"info":    levelInfo,
+	"warning": levelWarning,
+	"error":   levelError,
+}
+
+// parseLogLevel returns the level named name
+func parseLogLevel(name string) (logLevel, error) {
+	level, ok := logLevelNames[name]
+	if !ok {
+		return 0, fmt.Errorf("unknown log level %q, expected debug, info, warning or error", name)
+	}
+	return level, nil
+}
+
+// String returns the name of the level
+func (l logLevel) String() string {
+	for name, level := range logLevelNames {
+		if level == l {
+			return name
+		}
+	}
+	return fmt.Sprintf("level(%d)", int(l))
+}
+
+// tssLogger writes the messages tss-lib logs to the service log, tagged with
+// their level, and drops the ones below level. The tracing methods of go-log
+// are left to the logger it replaces.
+type tssLogger struct {
+	golog.EventLogger
+	level  logLevel
+	output *log.Logger
+}
+
+// useTSSLogger sends the messages of tss-lib at level and above to output
+func useTSSLogger(level logLevel, output *log.Logger) {
+	previous := common.Logger
+	if logger, ok := previous.(*tssLogger); ok {
+		previous = logger.EventLogger
+	}
+	common.Logger = &tssLogger{EventLogger: previous, level: level, output: output}
+}
+
+// print writes message when its level is kept
+func (l *tssLogger) print(level logLevel, message string) {
+	if level < l.level {
+		return
+	}
+	l.output.Printf("tss-lib %s: %s", level, message)
+}
+
+func (l *tssLogger) Debug(args ...interface{}) {
+	l.print(levelDebug, fmt.Sprint(args...))
+}
+
+func (l *tssLogger) Debugf(format string, args ...interface{}) {
+	l.print(levelDebug, fmt.Sprintf(format, args...))
+}
+
+func (l *tssLogger) Info(args ...interface{}) {
+	l.print(levelInfo, fmt.Sprint(args...))
+}
+
+func (l *tssLogger) Infof(format string, args ...interface{}) {
+	l.print(levelInfo, fmt.Sprintf(format, args...))
+}
+
+func (l *tssLogger) Warning(args ...interface{}) {
+	l.print(levelWarning, fmt.Sprint(args...))
+}
+
+func (l *tssLogger) Warningf(format string, args ...interface{}) {
+	l.print(levelWarning, fmt.Sprintf(format, args...))
+}
+
+func (l *tssLogger) Error(args ...interface{}) {
+	l.print(levelError, fmt.Sprint(args...))
+}
+
+func (l *tssLogger) Errorf(format string, args ...interface{}) {
+	l.print(levelError, fmt.Sprintf(format, args...))
+}
+
+// Fatal exits whatever the level, tss-lib does not expect it to return
+func (l *tssLogger) Fatal(args ...interface{}) {
+	l.output.Fatalf("tss-lib fatal: %s", fmt.Sprint(args...))
+}
+
+func (l *tssLogger) Fatalf(format string, args ...interface{}) {
+	l.output.Fatalf("tss-lib fatal: %s", fmt.Sprintf(format, args...))
+}
+
+// Panic panics whatever the level, tss-lib does not expect it to return
+func (l *tssLogger) Panic(args ...interface{}) {
+	l.output.Panicf("tss-lib panic: %s", fmt.Sprint(args...))
+}
+
+func (l *tssLogger) Panicf(format string, args ...interface{}) {
+	l.output.Panicf("tss-lib panic: %s", fmt.Sprintf(format, args...))
+}
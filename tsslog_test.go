@@ -0,0 +1,62 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"strings"
+	"testing"
+
+	"github.com/bnb-chain/tss-lib/common"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTSSLogger(t *testing.T) {
+	previous := common.Logger
+	t.Cleanup(func() { common.Logger = previous })
+
+	// createLogged runs an EdDSA keygen, fast enough for a real ceremony, and
+	// returns what tss-lib logged at level
+	createLogged := func(level logLevel) string {
+		var output bytes.Buffer
+		useTSSLogger(level, log.New(&output, "", 0))
+		wallet, err := walletService.CreateWallet(context.Background(), createWalletRequest{Parties: 2, Threshold: 1, Algorithm: algorithmEdDSA}, nil)
+		if err != nil {
+			t.Fatalf("Failed to create wallet: %v", err)
+		}
+		walletsMutex.Lock()
+		delete(wallets, wallet.Address)
+		walletsMutex.Unlock()
+		return output.String()
+	}
+
+	debug := createLogged(levelDebug)
+	assert.Contains(t, debug, "tss-lib info: party", "Round progress should be logged")
+	assert.Contains(t, debug, "round 1 starting")
+	assert.Contains(t, debug, "tss-lib debug: party")
+	assert.Contains(t, debug, "round 2 update")
+
+	info := createLogged(levelInfo)
+	assert.Contains(t, info, "round 1 starting")
+	assert.NotContains(t, info, "tss-lib debug:", "Debug messages should be dropped at info level")
+
+	assert.Empty(t, createLogged(levelError), "A successful keygen logs no error")
+
+	// Replacing the logger again wraps the original one, not the previous
+	// replacement
+	useTSSLogger(levelError, log.New(&bytes.Buffer{}, "", 0))
+	assert.Equal(t, previous, common.Logger.(*tssLogger).EventLogger)
+}
+
+func TestParseLogLevel(t *testing.T) {
+	for _, name := range []string{"debug", "info", "warning", "error"} {
+		level, err := parseLogLevel(name)
+		assert.NoError(t, err)
+		assert.Equal(t, name, level.String())
+	}
+	_, err := parseLogLevel("verbose")
+	assert.Error(t, err)
+	_, err = parseLogLevel("")
+	assert.Error(t, err)
+	assert.True(t, strings.HasPrefix(logLevel(9).String(), "level("))
+}
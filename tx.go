@@ -0,0 +1,200 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+
+	ethcommon "github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/gin-gonic/gin"
+)
+
+// signTxFields are the fields of a transaction to sign, in the JSON-RPC
+// format. Setting maxFeePerGas makes it an EIP-1559 transaction, otherwise it
+// is a legacy transaction using gasPrice.
+type signTxFields struct {
+	Nonce                hexutil.Uint64     `json:"nonce"`
+	To                   *ethcommon.Address `json:"to"`
+	Value                *hexutil.Big       `json:"value"`
+	Gas                  hexutil.Uint64     `json:"gas"`
+	GasPrice             *hexutil.Big       `json:"gasPrice"`
+	MaxFeePerGas         *hexutil.Big       `json:"maxFeePerGas"`
+	MaxPriorityFeePerGas *hexutil.Big       `json:"maxPriorityFeePerGas"`
+	Data                 hexutil.Bytes      `json:"data"`
+}
+
+// signTxRequest represents the request body for signTx endpoint. The
+// transaction is given either as fields in tx or as rawTx, an unsigned
+// transaction in its binary encoding. Without chainId, only legacy
+// transactions can be signed, without EIP-155 replay protection.
+type signTxRequest struct {
+	Wallet  string         `json:"wallet"`
+	ChainID hexutil.Uint64 `json:"chainId"`
+	Tx      *signTxFields  `json:"tx"`
+	RawTx   string         `json:"rawTx"`
+}
+
+// signTx signs an Ethereum transaction with a wallet and returns it signed in
+// its binary encoding, ready to be sent with eth_sendRawTransaction
+func signTx(c *gin.Context) {
+	var requestBody signTxRequest
+
+	if err := c.BindJSON(&requestBody); err != nil {
+		respondError(c, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	if requestBody.Wallet == "" {
+		respondError(c, http.StatusBadRequest, "wallet is required")
+		return
+	}
+	var chainID *big.Int
+	if requestBody.ChainID != 0 {
+		chainID = new(big.Int).SetUint64(uint64(requestBody.ChainID))
+	}
+	tx, err := unsignedTx(requestBody, chainID)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	wallet, err := lookupWallet(requestBody.Wallet)
+	if err != nil {
+		respondServiceError(c, err)
+		return
+	}
+	if wallet.Curve != curveSecp256k1 {
+		respondError(c, http.StatusBadRequest, fmt.Sprintf("transactions can only be signed by %s wallets", curveSecp256k1))
+		return
+	}
+	// The policy sees the unsigned transaction in its binary encoding, hashed
+	// with keccak256
+	unsigned, err := tx.MarshalBinary()
+	if err != nil {
+		respondError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err := wallet.checkPolicy(unsigned, modeRaw, hashKeccak256); err != nil {
+		respondServiceError(c, err)
+		return
+	}
+
+	// The signer picks the EIP-155 or EIP-1559 signing hash for the tx type,
+	// and sets v to recovery + chainId*2 + 35 on legacy transactions. Without
+	// a chain ID it is the Homestead signer, whose v is 27 or 28.
+	signer := types.LatestSignerForChainID(chainID)
+	digest := signer.Hash(tx).Bytes()
+	sigData, err := signDigest(wallet, nil, digest, nil)
+	if err != nil {
+		respondError(c, ceremonyErrorStatus(err), err.Error())
+		return
+	}
+
+	var recoveryID byte
+	if len(sigData.SignatureRecovery) > 0 {
+		recoveryID = sigData.SignatureRecovery[0] & 1
+	}
+	s, recoveryID := normalizeLowS(new(big.Int).SetBytes(sigData.S), recoveryID, wallet.PubKey.Curve)
+	signature := make([]byte, 65)
+	new(big.Int).SetBytes(sigData.R).FillBytes(signature[:32])
+	s.FillBytes(signature[32:64])
+	signature[64] = recoveryID
+
+	signed, err := tx.WithSignature(signer, signature)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+	rawTx, err := signed.MarshalBinary()
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+	respond(c, http.StatusOK, gin.H{
+		"rawTransaction": hexutil.Encode(rawTx),
+		"hash":           signed.Hash().Hex(),
+	})
+}
+
+// unsignedTx builds the transaction to sign from the request, chainID is nil
+// when the request has none
+func unsignedTx(requestBody signTxRequest, chainID *big.Int) (*types.Transaction, error) {
+	if (requestBody.Tx == nil) == (requestBody.RawTx == "") {
+		return nil, errors.New("exactly one of tx and rawTx must be set")
+	}
+	if requestBody.RawTx != "" {
+		return decodeUnsignedTx(requestBody.RawTx, chainID)
+	}
+
+	fields := requestBody.Tx
+	value := new(big.Int)
+	if fields.Value != nil {
+		value = fields.Value.ToInt()
+	}
+	if fields.Gas == 0 {
+		return nil, errors.New("tx.gas is required")
+	}
+	if fields.MaxFeePerGas != nil {
+		if fields.GasPrice != nil {
+			return nil, errors.New("tx.gasPrice can't be set along with tx.maxFeePerGas")
+		}
+		if chainID == nil {
+			return nil, errors.New("chainId is required for EIP-1559 transactions")
+		}
+		tip := new(big.Int)
+		if fields.MaxPriorityFeePerGas != nil {
+			tip = fields.MaxPriorityFeePerGas.ToInt()
+		}
+		return types.NewTx(&types.DynamicFeeTx{
+			ChainID:   chainID,
+			Nonce:     uint64(fields.Nonce),
+			GasTipCap: tip,
+			GasFeeCap: fields.MaxFeePerGas.ToInt(),
+			Gas:       uint64(fields.Gas),
+			To:        fields.To,
+			Value:     value,
+			Data:      fields.Data,
+		}), nil
+	}
+	if fields.GasPrice == nil {
+		return nil, errors.New("one of tx.gasPrice and tx.maxFeePerGas is required")
+	}
+	if fields.MaxPriorityFeePerGas != nil {
+		return nil, errors.New("tx.maxPriorityFeePerGas requires tx.maxFeePerGas")
+	}
+	return types.NewTx(&types.LegacyTx{
+		Nonce:    uint64(fields.Nonce),
+		GasPrice: fields.GasPrice.ToInt(),
+		Gas:      uint64(fields.Gas),
+		To:       fields.To,
+		Value:    value,
+		Data:     fields.Data,
+	}), nil
+}
+
+// decodeUnsignedTx decodes a transaction in its binary encoding, as produced
+// by go-ethereum for a transaction without signature
+func decodeUnsignedTx(rawTx string, chainID *big.Int) (*types.Transaction, error) {
+	encoded, err := hexutil.Decode(rawTx)
+	if err != nil {
+		return nil, errors.New("invalid rawTx")
+	}
+	tx := new(types.Transaction)
+	if err := tx.UnmarshalBinary(encoded); err != nil {
+		return nil, fmt.Errorf("invalid rawTx: %w", err)
+	}
+	if v, r, s := tx.RawSignatureValues(); v.Sign() != 0 || r.Sign() != 0 || s.Sign() != 0 {
+		return nil, errors.New("rawTx is already signed")
+	}
+	if tx.Type() != types.LegacyTxType {
+		if chainID == nil {
+			return nil, fmt.Errorf("chainId is required for transactions of type %d", tx.Type())
+		}
+		if tx.ChainId().Cmp(chainID) != 0 {
+			return nil, fmt.Errorf("rawTx is for chain %s, not %s", tx.ChainId(), chainID)
+		}
+	}
+	return tx, nil
+}
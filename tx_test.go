@@ -0,0 +1,226 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	ethcommon "github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSignTx(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	useDeterministicKeygen(t, "sign tx")
+
+	router := gin.Default()
+	router.POST("/wallet", createWallet)
+	router.POST("/sign/tx", signTx)
+
+	jsonBody, _ := json.Marshal(createWalletRequest{Parties: 2, Threshold: 1})
+	w1 := httptest.NewRecorder()
+	req1, _ := http.NewRequest("POST", "/wallet", bytes.NewBuffer(jsonBody))
+	req1.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w1, req1)
+	assert.Equal(t, http.StatusOK, w1.Code)
+
+	var createResponse stringFields
+	err := decodeData(w1.Body.Bytes(), &createResponse)
+	if err != nil {
+		t.Fatalf("Failed to parse create wallet response: %v", err)
+	}
+	walletAddress := createResponse["address"]
+
+	to := ethcommon.HexToAddress("0x000000000000000000000000000000000000dEaD")
+	legacy, _ := types.NewTx(&types.LegacyTx{
+		Nonce:    3,
+		GasPrice: big.NewInt(20_000_000_000),
+		Gas:      21000,
+		To:       &to,
+		Value:    big.NewInt(1_000_000_000_000_000),
+	}).MarshalBinary()
+
+	tests := []struct {
+		name    string
+		request signTxRequest
+		txType  uint8
+	}{
+		{
+			name: "eip1559 fields",
+			request: signTxRequest{
+				Wallet:  walletAddress,
+				ChainID: 1,
+				Tx: &signTxFields{
+					Nonce:                7,
+					To:                   &to,
+					Value:                (*hexutil.Big)(big.NewInt(1)),
+					Gas:                  21000,
+					MaxFeePerGas:         (*hexutil.Big)(big.NewInt(30_000_000_000)),
+					MaxPriorityFeePerGas: (*hexutil.Big)(big.NewInt(1_000_000_000)),
+				},
+			},
+			txType: types.DynamicFeeTxType,
+		},
+		{
+			name: "legacy raw tx",
+			request: signTxRequest{
+				Wallet:  walletAddress,
+				ChainID: 11155111,
+				RawTx:   hexutil.Encode(legacy),
+			},
+			txType: types.LegacyTxType,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			jsonBody, _ := json.Marshal(tt.request)
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("POST", "/sign/tx", bytes.NewBuffer(jsonBody))
+			req.Header.Set("Content-Type", "application/json")
+			router.ServeHTTP(w, req)
+			if !assert.Equal(t, http.StatusOK, w.Code) {
+				return
+			}
+
+			var response map[string]string
+			err := decodeData(w.Body.Bytes(), &response)
+			if err != nil {
+				t.Fatalf("Failed to parse sign tx response: %v", err)
+			}
+			rawTx, err := hexutil.Decode(response["rawTransaction"])
+			assert.NoError(t, err)
+			tx := new(types.Transaction)
+			if !assert.NoError(t, tx.UnmarshalBinary(rawTx)) {
+				return
+			}
+
+			chainID := new(big.Int).SetUint64(uint64(tt.request.ChainID))
+			assert.Equal(t, tt.txType, tx.Type())
+			assert.Equal(t, chainID, tx.ChainId())
+			assert.Equal(t, response["hash"], tx.Hash().Hex())
+			sender, err := types.Sender(types.LatestSignerForChainID(chainID), tx)
+			assert.NoError(t, err)
+			assert.Equal(t, walletAddress, sender.Hex(), "Transaction sender should be the wallet")
+		})
+	}
+}
+
+func TestSignTxInvalidInput(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	router := gin.Default()
+	router.POST("/sign/tx", signTx)
+
+	address := "0x00000000000000000000000000000000000000E4"
+	addFakeWallet(address)
+	t.Cleanup(func() {
+		walletsMutex.Lock()
+		delete(wallets, address)
+		walletsMutex.Unlock()
+	})
+
+	to := ethcommon.HexToAddress("0x000000000000000000000000000000000000dEaD")
+	otherChain, _ := types.NewTx(&types.DynamicFeeTx{ChainID: big.NewInt(5), Gas: 21000, To: &to, GasFeeCap: big.NewInt(1), GasTipCap: big.NewInt(1), Value: big.NewInt(0)}).MarshalBinary()
+	tx := `{"gas": "0x5208", "gasPrice": "0x1", "to": "` + to.Hex() + `"}`
+	tests := []struct {
+		name   string
+		body   string
+		status int
+	}{
+		{"invalid body", `{`, http.StatusBadRequest},
+		{"missing wallet", `{"chainId": "0x1", "tx": ` + tx + `}`, http.StatusBadRequest},
+		{"eip1559 tx without chain id", `{"wallet": "` + address + `", "tx": {"gas": "0x5208", "maxFeePerGas": "0x1"}}`, http.StatusBadRequest},
+		{"typed raw tx without chain id", `{"wallet": "` + address + `", "rawTx": "` + hexutil.Encode(otherChain) + `"}`, http.StatusBadRequest},
+		{"missing tx", `{"wallet": "` + address + `", "chainId": "0x1"}`, http.StatusBadRequest},
+		{"tx and raw tx", `{"wallet": "` + address + `", "chainId": "0x1", "tx": ` + tx + `, "rawTx": "0x01"}`, http.StatusBadRequest},
+		{"missing gas price", `{"wallet": "` + address + `", "chainId": "0x1", "tx": {"gas": "0x5208"}}`, http.StatusBadRequest},
+		{"missing gas", `{"wallet": "` + address + `", "chainId": "0x1", "tx": {"gasPrice": "0x1"}}`, http.StatusBadRequest},
+		{"invalid raw tx", `{"wallet": "` + address + `", "chainId": "0x1", "rawTx": "0x0102"}`, http.StatusBadRequest},
+		{"raw tx for another chain", `{"wallet": "` + address + `", "chainId": "0x1", "rawTx": "` + hexutil.Encode(otherChain) + `"}`, http.StatusBadRequest},
+		{"unknown wallet", `{"wallet": "0x00000000000000000000000000000000000000E5", "chainId": "0x1", "tx": ` + tx + `}`, http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("POST", "/sign/tx", bytes.NewBufferString(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			router.ServeHTTP(w, req)
+			assert.Equal(t, tt.status, w.Code)
+		})
+	}
+}
+
+func TestSignTxChainID(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	useDeterministicKeygen(t, "sign tx chain id")
+	wallet, err := walletService.CreateWallet(context.Background(), createWalletRequest{Parties: 2, Threshold: 1}, nil)
+	if err != nil {
+		t.Fatalf("Failed to create wallet: %v", err)
+	}
+	t.Cleanup(func() {
+		walletsMutex.Lock()
+		delete(wallets, wallet.Address)
+		walletsMutex.Unlock()
+	})
+
+	router := gin.Default()
+	router.POST("/sign/tx", signTx)
+
+	to := ethcommon.HexToAddress("0x000000000000000000000000000000000000dEaD")
+	tests := []struct {
+		name    string
+		chainID uint64
+		// v is recovery + base, with a recovery of 0 or 1
+		base uint64
+	}{
+		{"legacy without chain id", 0, 27},
+		{"mainnet", 1, 37},
+		{"polygon", 137, 137*2 + 35},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request := signTxRequest{
+				Wallet:  wallet.Address,
+				ChainID: hexutil.Uint64(tt.chainID),
+				Tx:      &signTxFields{Nonce: 1, To: &to, Gas: 21000, GasPrice: (*hexutil.Big)(big.NewInt(1_000_000_000))},
+			}
+			jsonBody, _ := json.Marshal(request)
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("POST", "/sign/tx", bytes.NewBuffer(jsonBody))
+			req.Header.Set("Content-Type", "application/json")
+			router.ServeHTTP(w, req)
+			if !assert.Equal(t, http.StatusOK, w.Code) {
+				return
+			}
+
+			var response map[string]string
+			if err := decodeData(w.Body.Bytes(), &response); err != nil {
+				t.Fatalf("Failed to parse sign tx response: %v", err)
+			}
+			rawTx, _ := hexutil.Decode(response["rawTransaction"])
+			tx := new(types.Transaction)
+			if !assert.NoError(t, tx.UnmarshalBinary(rawTx)) {
+				return
+			}
+			v, _, _ := tx.RawSignatureValues()
+			assert.True(t, v.Uint64() == tt.base || v.Uint64() == tt.base+1, "v should be %d or %d, got %s", tt.base, tt.base+1, v)
+			assert.Equal(t, tt.chainID != 0, tx.Protected(), "Only transactions with a chain ID should be replay protected")
+
+			var chainID *big.Int
+			if tt.chainID != 0 {
+				chainID = new(big.Int).SetUint64(tt.chainID)
+			}
+			sender, err := types.Sender(types.LatestSignerForChainID(chainID), tx)
+			assert.NoError(t, err)
+			assert.Equal(t, wallet.Address, sender.Hex(), "v should recover the wallet")
+		})
+	}
+}
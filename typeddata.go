@@ -0,0 +1,101 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/ethereum/go-ethereum/signer/core/apitypes"
+	"github.com/gin-gonic/gin"
+)
+
+// eip712DomainType is the type every EIP-712 payload must define for its domain
+const eip712DomainType = "EIP712Domain"
+
+// signTypedDataRequest represents the request body for signTypedData endpoint
+type signTypedDataRequest struct {
+	Wallet    string             `json:"wallet"`
+	TypedData apitypes.TypedData `json:"typedData"`
+	// RawRecoveryID returns v as 0/1 instead of the Ethereum 27/28
+	RawRecoveryID bool `json:"rawRecoveryId"`
+	// Encoding of the signature field: raw (default), der or eth65
+	Encoding string `json:"encoding"`
+}
+
+// signTypedData signs EIP-712 typed data the way eth_signTypedData_v4 does
+func signTypedData(c *gin.Context) {
+	var requestBody signTypedDataRequest
+
+	if err := c.BindJSON(&requestBody); err != nil {
+		respondError(c, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	if requestBody.Wallet == "" {
+		respondError(c, http.StatusBadRequest, "wallet is required")
+		return
+	}
+
+	digest, err := typedDataDigest(requestBody.TypedData)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err := validateEncoding(requestBody.Encoding); err != nil {
+		respondError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	wallet, err := lookupWallet(requestBody.Wallet)
+	if err != nil {
+		respondServiceError(c, err)
+		return
+	}
+	if err := requireECDSA(wallet, "typed data signing"); err != nil {
+		respondServiceError(c, err)
+		return
+	}
+	// The policy sees the typed data in its JSON encoding, hashed with
+	// keccak256
+	encoded, err := json.Marshal(requestBody.TypedData)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, "invalid typed data")
+		return
+	}
+	if err := wallet.checkPolicy(encoded, modeRaw, hashKeccak256); err != nil {
+		respondServiceError(c, err)
+		return
+	}
+
+	sigData, err := signDigest(wallet, nil, digest, nil)
+	if err != nil {
+		respondError(c, ceremonyErrorStatus(err), err.Error())
+		return
+	}
+	response, err := signatureResponse(sigData, digest, wallet.PubKey.Curve, requestBody.Encoding, requestBody.RawRecoveryID)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+	respond(c, http.StatusOK, response)
+}
+
+// typedDataDigest validates the typed data and returns its EIP-712 digest,
+// keccak256("\x19\x01" || domainSeparator || hashStruct(message))
+func typedDataDigest(typedData apitypes.TypedData) ([]byte, error) {
+	if _, exists := typedData.Types[eip712DomainType]; !exists {
+		return nil, fmt.Errorf("types must define %s", eip712DomainType)
+	}
+	if typedData.PrimaryType == "" || typedData.PrimaryType == eip712DomainType {
+		return nil, errors.New("primaryType must name the type of the message")
+	}
+	if _, exists := typedData.Types[typedData.PrimaryType]; !exists {
+		return nil, fmt.Errorf("primaryType %q is not defined in types", typedData.PrimaryType)
+	}
+
+	digest, _, err := apitypes.TypedDataAndHash(typedData)
+	if err != nil {
+		return nil, fmt.Errorf("invalid typed data: %w", err)
+	}
+	return digest, nil
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/signer/core/apitypes"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+// mailTypedData is the Mail example from the EIP-712 specification
+const mailTypedData = `{
+	"types": {
+		"EIP712Domain": [
+			{"name": "name", "type": "string"},
+			{"name": "version", "type": "string"},
+			{"name": "chainId", "type": "uint256"},
+			{"name": "verifyingContract", "type": "address"}
+		],
+		"Person": [
+			{"name": "name", "type": "string"},
+			{"name": "wallet", "type": "address"}
+		],
+		"Mail": [
+			{"name": "from", "type": "Person"},
+			{"name": "to", "type": "Person"},
+			{"name": "contents", "type": "string"}
+		]
+	},
+	"primaryType": "Mail",
+	"domain": {
+		"name": "Ether Mail",
+		"version": "1",
+		"chainId": 1,
+		"verifyingContract": "0xCcCCccccCCCCcCCCCCCcCcCccCcCCCcCcccccccC"
+	},
+	"message": {
+		"from": {"name": "Cow", "wallet": "0xCD2a3d9F938E13CD947Ec05AbC7FE734Df8DD826"},
+		"to": {"name": "Bob", "wallet": "0xbBbBBBBbbBBBbbbBbbBbbbbBBbBbbbbBbBbbBBbB"},
+		"contents": "Hello, Bob!"
+	}
+}`
+
+// mailTypedDataDigest is the digest of mailTypedData given by the specification
+const mailTypedDataDigest = "be609aee343fb3c4b28e1df9e632fca64fcfaede20f02e86244efddf30957bd2"
+
+func parseTypedData(t *testing.T, payload string) apitypes.TypedData {
+	var typedData apitypes.TypedData
+	if err := json.Unmarshal([]byte(payload), &typedData); err != nil {
+		t.Fatalf("Failed to parse typed data: %v", err)
+	}
+	return typedData
+}
+
+func TestTypedDataDigest(t *testing.T) {
+	digest, err := typedDataDigest(parseTypedData(t, mailTypedData))
+	assert.NoError(t, err)
+	assert.Equal(t, mailTypedDataDigest, hex.EncodeToString(digest))
+}
+
+func TestTypedDataDigestInvalid(t *testing.T) {
+	// primaryType not defined in types
+	typedData := parseTypedData(t, mailTypedData)
+	typedData.PrimaryType = "Letter"
+	_, err := typedDataDigest(typedData)
+	assert.Error(t, err)
+
+	// primaryType pointing at the domain
+	typedData.PrimaryType = eip712DomainType
+	_, err = typedDataDigest(typedData)
+	assert.Error(t, err)
+
+	// Reference to an undefined type
+	typedData = parseTypedData(t, strings.Replace(mailTypedData, `"type": "Person"}`, `"type": "Human"}`, 1))
+	_, err = typedDataDigest(typedData)
+	assert.Error(t, err)
+
+	// Missing domain type
+	typedData = parseTypedData(t, mailTypedData)
+	delete(typedData.Types, eip712DomainType)
+	_, err = typedDataDigest(typedData)
+	assert.Error(t, err)
+}
+
+func TestSignTypedData(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	useDeterministicKeygen(t, "sign typed data")
+
+	router := gin.Default()
+	router.POST("/wallet", createWallet)
+	router.POST("/sign/typed-data", signTypedData)
+
+	// Create a wallet
+	w1 := httptest.NewRecorder()
+	req1, _ := http.NewRequest("POST", "/wallet", nil)
+	router.ServeHTTP(w1, req1)
+	assert.Equal(t, http.StatusOK, w1.Code)
+
+	var createResponse stringFields
+	err := decodeData(w1.Body.Bytes(), &createResponse)
+	if err != nil {
+		t.Fatalf("Failed to parse create wallet response: %v", err)
+	}
+	walletAddress := createResponse["address"]
+
+	jsonBody := `{"wallet": "` + walletAddress + `", "typedData": ` + mailTypedData + `}`
+	w2 := httptest.NewRecorder()
+	req2, _ := http.NewRequest("POST", "/sign/typed-data", strings.NewReader(jsonBody))
+	req2.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w2, req2)
+	assert.Equal(t, http.StatusOK, w2.Code)
+
+	var signResponse stringFields
+	err = decodeData(w2.Body.Bytes(), &signResponse)
+	if err != nil {
+		t.Fatalf("Failed to parse sign typed data response: %v", err)
+	}
+	assert.Equal(t, mailTypedDataDigest, signResponse["digest"])
+
+	rsv, err := hex.DecodeString(signResponse["rsv"])
+	assert.NoError(t, err)
+	assert.Len(t, rsv, 65)
+	digest, _ := hex.DecodeString(mailTypedDataDigest)
+	rsv[64] -= 27
+	pubKey, err := crypto.SigToPub(digest, rsv)
+	assert.NoError(t, err)
+	assert.Equal(t, walletAddress, crypto.PubkeyToAddress(*pubKey).Hex())
+}
+
+func TestSignTypedDataInvalidInput(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	router := gin.Default()
+	router.POST("/sign/typed-data", signTypedData)
+
+	invalidBodies := []string{
+		// Missing wallet
+		`{"typedData": ` + mailTypedData + `}`,
+		// Mismatched primaryType
+		`{"wallet": "0xadcdf1cc67362d0d61ad8954d077b78a1d80087b", "typedData": ` + strings.Replace(mailTypedData, `"primaryType": "Mail"`, `"primaryType": "Letter"`, 1) + `}`,
+		// Malformed type definition
+		`{"wallet": "0xadcdf1cc67362d0d61ad8954d077b78a1d80087b", "typedData": ` + strings.Replace(mailTypedData, `{"name": "contents", "type": "string"}`, `{"name": "contents", "type": ""}`, 1) + `}`,
+		// Not JSON
+		`{"wallet": `,
+	}
+	for _, body := range invalidBodies {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/sign/typed-data", bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	}
+}
@@ -0,0 +1,278 @@
+package main
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+
+	"github.com/bnb-chain/tss-lib/tss"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/gin-gonic/gin"
+)
+
+// verifySignatureRequest represents the request body for verifyData endpoint
+type verifySignatureRequest struct {
+	Wallet string `json:"wallet"`
+	Data   string `json:"data"`
+	Mode   string `json:"mode"`
+	Hash   string `json:"hash"`
+	// Signature is r || s, or r || s || v as returned in rsv
+	Signature string `json:"signature"`
+	// Strict rejects high-S signatures, which are malleable
+	Strict bool `json:"strict"`
+}
+
+// Reasons a well formed signature is rejected
+const (
+	reasonZeroScalar     = "r and s must not be zero"
+	reasonScalarRange    = "r and s must be lower than the curve order"
+	reasonHighS          = "s is in the upper half of the curve order"
+	reasonMismatch       = "signature does not match the public key"
+	reasonInvalidRecover = "v does not recover the public key"
+)
+
+// verifyData checks a signature over data against a wallet's public key. The
+// digest is computed the same way as by signData.
+func verifyData(c *gin.Context) {
+	var requestBody verifySignatureRequest
+
+	if err := c.BindJSON(&requestBody); err != nil {
+		respondError(c, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	if requestBody.Data == "" || requestBody.Wallet == "" || requestBody.Signature == "" {
+		respondError(c, http.StatusBadRequest, "data, wallet and signature are required")
+		return
+	}
+
+	data, err := decodeHexData(requestBody.Data)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, "invalid data")
+		return
+	}
+	signature, err := decodeHexData(requestBody.Signature)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, "invalid signature")
+		return
+	}
+
+	wallet, err := lookupWallet(requestBody.Wallet)
+	if err != nil {
+		respondServiceError(c, err)
+		return
+	}
+	digest, err := signedMessage(wallet.Algorithm, data, requestBody.Mode, requestBody.Hash)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	reason, err := verifyEncodedSignature(wallet, digest, signature, requestBody.Strict)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	if reason != "" {
+		respond(c, http.StatusOK, gin.H{"valid": false, "reason": reason})
+		return
+	}
+	respond(c, http.StatusOK, gin.H{"valid": true})
+}
+
+// verifyPubKeyRequest represents the request body for verifyPubKey endpoint
+type verifyPubKeyRequest struct {
+	// PubKey is the hex encoded public key: compressed or uncompressed SEC1,
+	// or the 64 byte X || Y, for ECDSA curves and 32 bytes for ed25519
+	PubKey string `json:"pubKey"`
+	// Curve is secp256k1 (default), p256 or ed25519
+	Curve string `json:"curve"`
+	Data  string `json:"data"`
+	Mode  string `json:"mode"`
+	Hash  string `json:"hash"`
+	// Signature is r || s, or r || s || v, and R || S for ed25519
+	Signature string `json:"signature"`
+	// Strict rejects high-S signatures, which are malleable
+	Strict bool `json:"strict"`
+}
+
+// verifyPubKey checks a signature over data against the public key given in
+// the request instead of the one of a wallet, e.g. for keys held elsewhere
+func verifyPubKey(c *gin.Context) {
+	var requestBody verifyPubKeyRequest
+
+	if err := c.BindJSON(&requestBody); err != nil {
+		respondError(c, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	if requestBody.Data == "" || requestBody.PubKey == "" || requestBody.Signature == "" {
+		respondError(c, http.StatusBadRequest, "data, pubKey and signature are required")
+		return
+	}
+
+	data, err := decodeHexData(requestBody.Data)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, "invalid data")
+		return
+	}
+	algorithm := algorithmECDSA
+	if tss.CurveName(requestBody.Curve) == curveEd25519 {
+		algorithm = algorithmEdDSA
+	}
+	digest, err := signedMessage(algorithm, data, requestBody.Mode, requestBody.Hash)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	signature, err := decodeHexData(requestBody.Signature)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, "invalid signature")
+		return
+	}
+	encodedPubKey, err := decodeHexData(requestBody.PubKey)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, "invalid pubKey")
+		return
+	}
+
+	var reason string
+	if tss.CurveName(requestBody.Curve) == curveEd25519 {
+		if len(encodedPubKey) != ed25519.PublicKeySize {
+			respondError(c, http.StatusBadRequest, fmt.Sprintf("ed25519 pubKey must be %d bytes", ed25519.PublicKeySize))
+			return
+		}
+		reason, err = verifyEd25519Signature(encodedPubKey, digest, signature)
+	} else {
+		curveName, curve, curveErr := curveByName(requestBody.Curve)
+		if curveErr != nil {
+			respondError(c, http.StatusBadRequest, curveErr.Error())
+			return
+		}
+		pubKey, keyErr := decodeECDSAPubKey(encodedPubKey, curveName, curve)
+		if keyErr != nil {
+			respondError(c, http.StatusBadRequest, keyErr.Error())
+			return
+		}
+		reason, err = verifyECDSASignature(pubKey, curveName == curveSecp256k1, digest, signature, requestBody.Strict)
+	}
+	if err != nil {
+		respondError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	if reason != "" {
+		respond(c, http.StatusOK, gin.H{"valid": false, "reason": reason})
+		return
+	}
+	respond(c, http.StatusOK, gin.H{"valid": true})
+}
+
+// decodeECDSAPubKey decodes a compressed or uncompressed SEC1 public key, or
+// the bare X || Y, and checks that it is a point of the curve
+func decodeECDSAPubKey(encoded []byte, curveName tss.CurveName, curve elliptic.Curve) (*ecdsa.PublicKey, error) {
+	size := (curve.Params().BitSize + 7) / 8
+	if len(encoded) == 2*size {
+		encoded = append([]byte{4}, encoded...)
+	}
+	var x, y *big.Int
+	switch {
+	case len(encoded) == 1+size && curveName == curveSecp256k1:
+		// elliptic only decompresses curves with a = -3, secp256k1 has a = 0
+		pubKey, err := crypto.DecompressPubkey(encoded)
+		if err != nil {
+			return nil, errors.New("pubKey is not a point of the curve")
+		}
+		x, y = pubKey.X, pubKey.Y
+	case len(encoded) == 1+size:
+		x, y = elliptic.UnmarshalCompressed(curve, encoded)
+	case len(encoded) == 1+2*size && encoded[0] == 4:
+		x, y = new(big.Int).SetBytes(encoded[1:1+size]), new(big.Int).SetBytes(encoded[1+size:])
+		if !curve.IsOnCurve(x, y) {
+			x = nil
+		}
+	default:
+		return nil, fmt.Errorf("pubKey must be a %d or %d byte SEC1 key, or the %d byte X || Y", 1+size, 1+2*size, 2*size)
+	}
+	if x == nil {
+		return nil, errors.New("pubKey is not a point of the curve")
+	}
+	return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+}
+
+// verifyEncodedSignature checks a raw (r || s) or eth65 (r || s || v)
+// signature against the public key of a wallet and returns why it is
+// rejected, or an empty reason when it is valid. EdDSA wallets take their 64
+// byte Ed25519 signatures.
+func verifyEncodedSignature(wallet *Wallet, digest, signature []byte, strict bool) (string, error) {
+	if wallet.Algorithm == algorithmEdDSA {
+		return verifyEd25519Signature(ed25519PubKeyBytes(wallet.PubKey), digest, signature)
+	}
+	return verifyECDSASignature(wallet.PubKey, wallet.Curve == curveSecp256k1, digest, signature, strict)
+}
+
+// verifyEd25519Signature checks a 64 byte Ed25519 signature of digest
+func verifyEd25519Signature(pubKey ed25519.PublicKey, digest, signature []byte) (string, error) {
+	if len(signature) != ed25519.SignatureSize {
+		return "", fmt.Errorf("signature must be %d bytes", ed25519.SignatureSize)
+	}
+	if !ed25519.Verify(pubKey, digest, signature) {
+		return reasonMismatch, nil
+	}
+	return "", nil
+}
+
+// verifyECDSASignature checks a raw (r || s) or eth65 (r || s || v) ECDSA
+// signature of digest. With recoverable, as on secp256k1, the recovery id of
+// an eth65 signature must also recover the public key.
+func verifyECDSASignature(pubKey *ecdsa.PublicKey, recoverable bool, digest, signature []byte, strict bool) (string, error) {
+	size := (pubKey.Curve.Params().BitSize + 7) / 8
+	if len(signature) != 2*size && len(signature) != 2*size+1 {
+		return "", errors.New("signature must be r || s or r || s || v")
+	}
+
+	r := new(big.Int).SetBytes(signature[:size])
+	s := new(big.Int).SetBytes(signature[size : 2*size])
+	if reason := checkSignatureScalars(r, s, pubKey.Curve, strict); reason != "" {
+		return reason, nil
+	}
+	if !ecdsa.Verify(pubKey, digest, r, s) {
+		return reasonMismatch, nil
+	}
+	if len(signature) == 2*size || !recoverable {
+		return "", nil
+	}
+
+	v := signature[2*size]
+	if v >= 27 {
+		v -= 27
+	}
+	if v > 1 {
+		return reasonInvalidRecover, nil
+	}
+	sig := append(append([]byte{}, signature[:2*size]...), v)
+	recovered, err := crypto.Ecrecover(digest, sig)
+	if err != nil || !bytes.Equal(recovered, crypto.FromECDSAPub(pubKey)) {
+		return reasonInvalidRecover, nil
+	}
+	return "", nil
+}
+
+// checkSignatureScalars rejects r and s outside of [1, N-1], and in strict
+// mode an s above N/2. For every valid (r, s) the signature (r, N-s) is valid
+// too, only accepting the low s keeps signatures from being malleable.
+func checkSignatureScalars(r, s *big.Int, curve elliptic.Curve, strict bool) string {
+	n := curve.Params().N
+	if r.Sign() == 0 || s.Sign() == 0 {
+		return reasonZeroScalar
+	}
+	if r.Cmp(n) >= 0 || s.Cmp(n) >= 0 {
+		return reasonScalarRange
+	}
+	if strict && s.Cmp(new(big.Int).Rsh(n, 1)) > 0 {
+		return reasonHighS
+	}
+	return ""
+}
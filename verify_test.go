@@ -0,0 +1,245 @@
+package main
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVerifyData(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	router := gin.Default()
+	router.POST("/verify", verifyData)
+
+	address := "0x00000000000000000000000000000000000000e1"
+	wallet := addFakeWallet(address)
+	t.Cleanup(func() {
+		walletsMutex.Lock()
+		delete(wallets, address)
+		walletsMutex.Unlock()
+	})
+	key, _ := crypto.GenerateKey()
+	wallet.PubKey = &key.PublicKey
+
+	data := []byte("test")
+	sig, err := crypto.Sign(crypto.Keccak256(data), key)
+	if err != nil {
+		t.Fatalf("Failed to sign: %v", err)
+	}
+	eth65 := append(append([]byte{}, sig[:64]...), sig[64]+27)
+	tampered := append([]byte{}, sig[:64]...)
+	tampered[10] ^= 0xff
+	wrongV := append(append([]byte{}, sig[:64]...), (sig[64]^1)+27)
+
+	// (r, N-s) is the high-S twin of the signature, valid unless strict
+	n := crypto.S256().Params().N
+	highS := append([]byte{}, sig[:64]...)
+	new(big.Int).Sub(n, new(big.Int).SetBytes(sig[32:64])).FillBytes(highS[32:])
+	zeroR := append(make([]byte, 32), sig[32:64]...)
+	zeroS := append(append([]byte{}, sig[:32]...), make([]byte, 32)...)
+	overflowS := append([]byte{}, sig[:64]...)
+	n.FillBytes(overflowS[32:])
+
+	tests := []struct {
+		name      string
+		data      []byte
+		signature []byte
+		strict    bool
+		valid     bool
+		reason    string
+	}{
+		{"raw", data, sig[:64], false, true, ""},
+		{"raw strict", data, sig[:64], true, true, ""},
+		{"eth65", data, eth65, false, true, ""},
+		{"tampered signature", data, tampered, false, false, reasonMismatch},
+		{"tampered data", []byte("tesT"), sig[:64], false, false, reasonMismatch},
+		{"wrong recovery id", data, wrongV, false, false, reasonInvalidRecover},
+		{"high s", data, highS, false, true, ""},
+		{"high s strict", data, highS, true, false, reasonHighS},
+		{"zero r", data, zeroR, false, false, reasonZeroScalar},
+		{"zero s", data, zeroS, false, false, reasonZeroScalar},
+		{"s equal to curve order", data, overflowS, false, false, reasonScalarRange},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			jsonBody, _ := json.Marshal(verifySignatureRequest{
+				Wallet:    address,
+				Data:      "0x" + hex.EncodeToString(tt.data),
+				Signature: "0x" + hex.EncodeToString(tt.signature),
+				Strict:    tt.strict,
+			})
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("POST", "/verify", bytes.NewBuffer(jsonBody))
+			req.Header.Set("Content-Type", "application/json")
+			router.ServeHTTP(w, req)
+			assert.Equal(t, http.StatusOK, w.Code)
+
+			var response struct {
+				Valid  bool   `json:"valid"`
+				Reason string `json:"reason"`
+			}
+			err := decodeData(w.Body.Bytes(), &response)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.valid, response.Valid)
+			assert.Equal(t, tt.reason, response.Reason)
+		})
+	}
+}
+
+func TestVerifyDataInvalidInput(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	router := gin.Default()
+	router.POST("/verify", verifyData)
+
+	address := "0x00000000000000000000000000000000000000E2"
+	addFakeWallet(address)
+	t.Cleanup(func() {
+		walletsMutex.Lock()
+		delete(wallets, address)
+		walletsMutex.Unlock()
+	})
+
+	signature := "0x" + hex.EncodeToString(make([]byte, 64))
+	tests := []struct {
+		name   string
+		body   string
+		status int
+	}{
+		{"invalid body", `{`, http.StatusBadRequest},
+		{"missing signature", `{"wallet": "` + address + `", "data": "0x01"}`, http.StatusBadRequest},
+		{"invalid signature", `{"wallet": "` + address + `", "data": "0x01", "signature": "0xzz"}`, http.StatusBadRequest},
+		{"short signature", `{"wallet": "` + address + `", "data": "0x01", "signature": "0x0102"}`, http.StatusBadRequest},
+		{"invalid hash", `{"wallet": "` + address + `", "data": "0x01", "hash": "md5", "signature": "` + signature + `"}`, http.StatusBadRequest},
+		{"unknown wallet", `{"wallet": "0x00000000000000000000000000000000000000E3", "data": "0x01", "signature": "` + signature + `"}`, http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("POST", "/verify", bytes.NewBufferString(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			router.ServeHTTP(w, req)
+			assert.Equal(t, tt.status, w.Code)
+		})
+	}
+}
+
+func TestVerifyPubKey(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	router := gin.Default()
+	router.POST("/verify/pubkey", verifyPubKey)
+
+	data := []byte("test")
+	digest := crypto.Keccak256(data)
+
+	key, _ := crypto.GenerateKey()
+	other, _ := crypto.GenerateKey()
+	sig, err := crypto.Sign(digest, key)
+	if err != nil {
+		t.Fatalf("Failed to sign: %v", err)
+	}
+	eth65 := append(append([]byte{}, sig[:64]...), sig[64]+27)
+	uncompressed := crypto.FromECDSAPub(&key.PublicKey)
+
+	p256Key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	p256Other, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	r, s, err := ecdsa.Sign(rand.Reader, p256Key, digest)
+	if err != nil {
+		t.Fatalf("Failed to sign: %v", err)
+	}
+	p256Sig := make([]byte, 64)
+	r.FillBytes(p256Sig[:32])
+	s.FillBytes(p256Sig[32:])
+
+	edPub, edKey, _ := ed25519.GenerateKey(rand.Reader)
+	edOther, _, _ := ed25519.GenerateKey(rand.Reader)
+	// Ed25519 signs the data itself
+	edSig := ed25519.Sign(edKey, data)
+
+	tests := []struct {
+		name      string
+		curve     string
+		pubKey    []byte
+		signature []byte
+		valid     bool
+		reason    string
+	}{
+		{"secp256k1 uncompressed", "", uncompressed, sig[:64], true, ""},
+		{"secp256k1 compressed", "secp256k1", crypto.CompressPubkey(&key.PublicKey), sig[:64], true, ""},
+		{"secp256k1 x and y", "", uncompressed[1:], sig[:64], true, ""},
+		{"secp256k1 eth65", "", uncompressed, eth65, true, ""},
+		{"secp256k1 other key", "", crypto.FromECDSAPub(&other.PublicKey), sig[:64], false, reasonMismatch},
+		{"secp256k1 other compressed key", "", crypto.CompressPubkey(&other.PublicKey), eth65, false, reasonMismatch},
+		{"p256", "p256", elliptic.MarshalCompressed(elliptic.P256(), p256Key.X, p256Key.Y), p256Sig, true, ""},
+		{"p256 other key", "p256", pubKeyBytes(&p256Other.PublicKey), p256Sig, false, reasonMismatch},
+		{"ed25519", "ed25519", edPub, edSig, true, ""},
+		{"ed25519 other key", "ed25519", edOther, edSig, false, reasonMismatch},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			jsonBody, _ := json.Marshal(verifyPubKeyRequest{
+				PubKey:    "0x" + hex.EncodeToString(tt.pubKey),
+				Curve:     tt.curve,
+				Data:      "0x" + hex.EncodeToString(data),
+				Signature: "0x" + hex.EncodeToString(tt.signature),
+			})
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("POST", "/verify/pubkey", bytes.NewBuffer(jsonBody))
+			req.Header.Set("Content-Type", "application/json")
+			router.ServeHTTP(w, req)
+			assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+			var response struct {
+				Valid  bool   `json:"valid"`
+				Reason string `json:"reason"`
+			}
+			err := decodeData(w.Body.Bytes(), &response)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.valid, response.Valid)
+			assert.Equal(t, tt.reason, response.Reason)
+		})
+	}
+
+	notOnCurve := append([]byte{}, uncompressed...)
+	notOnCurve[64] ^= 1
+	invalid := []struct {
+		name    string
+		request verifyPubKeyRequest
+		message string
+	}{
+		{"missing pubKey", verifyPubKeyRequest{Data: "0x01", Signature: "0x01"}, "data, pubKey and signature are required"},
+		{"invalid pubKey", verifyPubKeyRequest{PubKey: "0xzz", Data: "0x01", Signature: "0x01"}, "invalid pubKey"},
+		{"not on curve", verifyPubKeyRequest{PubKey: hex.EncodeToString(notOnCurve), Data: "0x01", Signature: hex.EncodeToString(sig[:64])}, "not a point of the curve"},
+		{"wrong length", verifyPubKeyRequest{PubKey: "0x0102", Data: "0x01", Signature: hex.EncodeToString(sig[:64])}, "pubKey must be"},
+		{"unknown curve", verifyPubKeyRequest{PubKey: hex.EncodeToString(uncompressed), Curve: "p384", Data: "0x01", Signature: hex.EncodeToString(sig[:64])}, "unsupported curve"},
+		{"short ed25519 key", verifyPubKeyRequest{PubKey: hex.EncodeToString(edPub[:31]), Curve: "ed25519", Data: "0x01", Signature: hex.EncodeToString(edSig)}, "ed25519 pubKey must be 32 bytes"},
+		{"short signature", verifyPubKeyRequest{PubKey: hex.EncodeToString(uncompressed), Data: "0x01", Signature: "0x01"}, "signature must be"},
+	}
+	for _, tt := range invalid {
+		t.Run(tt.name, func(t *testing.T) {
+			jsonBody, _ := json.Marshal(tt.request)
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("POST", "/verify/pubkey", bytes.NewBuffer(jsonBody))
+			req.Header.Set("Content-Type", "application/json")
+			router.ServeHTTP(w, req)
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+			apiErr, err := decodeError(w.Body.Bytes())
+			assert.NoError(t, err)
+			assert.Contains(t, apiErr.Message, tt.message)
+		})
+	}
+}
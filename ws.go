@@ -0,0 +1,107 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+)
+
+// maxSocketMessageSize bounds the size of a request read from a WebSocket
+const maxSocketMessageSize = 1 << 20
+
+var socketUpgrader = websocket.Upgrader{}
+
+// socketSignRequest is a sign request sent over a WebSocket. ID is chosen by
+// the client and returned with the result, as several ceremonies can run at
+// once on the same connection.
+type socketSignRequest struct {
+	ID string `json:"id"`
+	signDataRequest
+}
+
+// socketSignResponse is the result of a socketSignRequest, the same envelope
+// as returned by signData along with the HTTP status it would have
+type socketSignResponse struct {
+	ID     string `json:"id"`
+	Status int    `json:"status"`
+	apiResponse
+}
+
+// signSocket upgrades the connection to a WebSocket taking sign requests.
+// Every request runs its own ceremony and the results are pushed back as the
+// ceremonies complete, not necessarily in the order of the requests. Each
+// request is charged to the caller's rate limit like a request of its own.
+func signSocket(c *gin.Context) {
+	value, _ := c.Get(rateLimitContextKey)
+	charge, _ := value.(rateLimitCharge)
+
+	conn, err := socketUpgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		// The upgrader already answered with an error
+		return
+	}
+	defer conn.Close()
+	conn.SetReadLimit(maxSocketMessageSize)
+
+	// A connection supports one writer at a time
+	var writeMutex sync.Mutex
+	send := func(response socketSignResponse) {
+		writeMutex.Lock()
+		defer writeMutex.Unlock()
+		if err := conn.WriteJSON(response); err != nil {
+			log.Printf("failed to send sign result %q: %v", response.ID, err)
+		}
+	}
+
+	// Ceremonies still running when the client goes away complete before the
+	// connection is closed
+	var pending sync.WaitGroup
+	defer pending.Wait()
+	for {
+		_, message, err := conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		var request socketSignRequest
+		if err := json.Unmarshal(message, &request); err != nil {
+			send(socketSignResponse{Status: http.StatusBadRequest, apiResponse: errorResponse(http.StatusBadRequest, "invalid request")})
+			continue
+		}
+		if request.ID == "" {
+			send(socketSignResponse{Status: http.StatusBadRequest, apiResponse: errorResponse(http.StatusBadRequest, "id is required")})
+			continue
+		}
+
+		if charge != nil {
+			if allowed, retryAfter := charge(); !allowed {
+				message := fmt.Sprintf("rate limit exceeded, retry in %ds", int(math.Ceil(retryAfter.Seconds())))
+				send(socketSignResponse{ID: request.ID, Status: http.StatusTooManyRequests, apiResponse: errorResponse(http.StatusTooManyRequests, message)})
+				continue
+			}
+		}
+
+		job, err := walletService.prepareSign(request.signDataRequest)
+		if err != nil {
+			status, body := serviceErrorResponse(err)
+			send(socketSignResponse{ID: request.ID, Status: status, apiResponse: body})
+			continue
+		}
+		pending.Add(1)
+		go func() {
+			defer pending.Done()
+			response, err := job.sign(nil)
+			if err != nil {
+				status, body := serviceErrorResponse(err)
+				send(socketSignResponse{ID: request.ID, Status: status, apiResponse: body})
+				return
+			}
+			send(socketSignResponse{ID: request.ID, Status: http.StatusOK, apiResponse: dataResponse(response)})
+		}()
+	}
+}
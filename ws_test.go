@@ -0,0 +1,175 @@
+package main
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSignSocket(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	useDeterministicKeygen(t, "sign socket")
+
+	router := gin.Default()
+	router.POST("/wallet", createWallet)
+	router.GET("/ws", signSocket)
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	jsonBody, _ := json.Marshal(createWalletRequest{Parties: 2, Threshold: 1})
+	resp, err := http.Post(server.URL+"/wallet", "application/json", bytes.NewBuffer(jsonBody))
+	if err != nil {
+		t.Fatalf("Failed to create wallet: %v", err)
+	}
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		t.Fatalf("Failed to read create wallet response: %v", err)
+	}
+	var createResponse stringFields
+	err = decodeData(body, &createResponse)
+	if err != nil {
+		t.Fatalf("Failed to parse create wallet response: %v", err)
+	}
+	walletAddress := createResponse["address"]
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http")+"/ws", nil)
+	if err != nil {
+		t.Fatalf("Failed to open WebSocket: %v", err)
+	}
+	defer conn.Close()
+
+	// Both requests are sent before reading any result, the ceremonies run
+	// concurrently and the results are told apart by their id
+	digests := map[string][]byte{
+		"first":  crypto.Keccak256([]byte("first")),
+		"second": crypto.Keccak256([]byte("second")),
+	}
+	for id, digest := range digests {
+		err := conn.WriteJSON(socketSignRequest{
+			ID: id,
+			signDataRequest: signDataRequest{
+				Data:   "0x" + hex.EncodeToString(digest),
+				Wallet: walletAddress,
+				Hash:   hashNone,
+			},
+		})
+		assert.NoError(t, err)
+	}
+
+	walletsMutex.Lock()
+	pubKey := wallets[walletAddress].PubKey
+	walletsMutex.Unlock()
+	for range len(digests) {
+		var response struct {
+			ID     string       `json:"id"`
+			Status int          `json:"status"`
+			Data   stringFields `json:"data"`
+			Error  *apiError    `json:"error"`
+		}
+		if err := conn.ReadJSON(&response); err != nil {
+			t.Fatalf("Failed to read sign result: %v", err)
+		}
+		assert.Equal(t, http.StatusOK, response.Status)
+		assert.Nil(t, response.Error)
+		digest, exists := digests[response.ID]
+		if !assert.True(t, exists, "Result should carry the id of its request") {
+			continue
+		}
+		delete(digests, response.ID)
+
+		signature, err := hex.DecodeString(response.Data["signature"])
+		assert.NoError(t, err)
+		if assert.Len(t, signature, 64) {
+			r := new(big.Int).SetBytes(signature[:32])
+			s := new(big.Int).SetBytes(signature[32:])
+			assert.True(t, ecdsa.Verify(pubKey, digest, r, s), "Signature %s should verify", response.ID)
+		}
+	}
+	assert.Empty(t, digests, "Every request should get a result")
+}
+
+func TestSignSocketInvalidRequests(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	router := gin.Default()
+	router.GET("/ws", signSocket)
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http")+"/ws", nil)
+	if err != nil {
+		t.Fatalf("Failed to open WebSocket: %v", err)
+	}
+	defer conn.Close()
+
+	tests := []struct {
+		name    string
+		message string
+		id      string
+		status  int
+	}{
+		{"invalid JSON", `{`, "", http.StatusBadRequest},
+		{"missing id", `{"wallet": "0x00000000000000000000000000000000000000E5", "data": "0x01"}`, "", http.StatusBadRequest},
+		{"missing data", `{"id": "a", "wallet": "0x00000000000000000000000000000000000000E5"}`, "a", http.StatusBadRequest},
+		{"unknown wallet", `{"id": "b", "wallet": "0x00000000000000000000000000000000000000E5", "data": "0x01"}`, "b", http.StatusNotFound},
+	}
+	// The connection stays open after an invalid request
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.NoError(t, conn.WriteMessage(websocket.TextMessage, []byte(tt.message)))
+			var response socketSignResponse
+			if err := conn.ReadJSON(&response); err != nil {
+				t.Fatalf("Failed to read response: %v", err)
+			}
+			assert.Equal(t, tt.id, response.ID)
+			assert.Equal(t, tt.status, response.Status)
+			if assert.NotNil(t, response.Error) {
+				assert.Equal(t, errorCode(tt.status), response.Error.Code)
+			}
+		})
+	}
+}
+
+func TestSignSocketRateLimit(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	// 12 per minute allows a burst of 3 requests, the upgrade takes one
+	router := gin.Default()
+	router.GET("/ws", rateLimit(newRateLimiter(12)), signSocket)
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http")+"/ws", nil)
+	if err != nil {
+		t.Fatalf("Failed to open WebSocket: %v", err)
+	}
+	defer conn.Close()
+
+	// Every sign request is charged, so the connection cannot be used to
+	// exceed the limit
+	message := `{"id": "a", "wallet": "0x00000000000000000000000000000000000000E5", "data": "0x01"}`
+	statuses := make([]int, 3)
+	for i := range statuses {
+		assert.NoError(t, conn.WriteMessage(websocket.TextMessage, []byte(message)))
+		var response socketSignResponse
+		if err := conn.ReadJSON(&response); err != nil {
+			t.Fatalf("Failed to read response: %v", err)
+		}
+		assert.Equal(t, "a", response.ID)
+		statuses[i] = response.Status
+	}
+	assert.Equal(t, []int{http.StatusNotFound, http.StatusNotFound, http.StatusTooManyRequests}, statuses)
+}